@@ -2,39 +2,414 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 
+	"gochatbot/internal/blob"
+	"gochatbot/internal/config"
 	"gochatbot/internal/httpapi"
+	"gochatbot/internal/idempotency"
+	"gochatbot/internal/jobs"
+	"gochatbot/internal/llm"
+	"gochatbot/internal/metrics"
+	"gochatbot/internal/migrate"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/ratelimit"
+	"gochatbot/internal/reconcile"
 	"gochatbot/internal/repo"
 	"gochatbot/internal/service"
+	"gochatbot/internal/shadow"
+	"gochatbot/internal/tools"
+	"gochatbot/internal/tracing"
+	"gochatbot/internal/webhook"
+	"gochatbot/migrations"
 )
 
 func main() {
-	addr := ":8080"
-	if v := os.Getenv("ADDR"); v != "" {
-		addr = v
+	if err := run(); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return runMigrate(os.Args[2:])
 	}
 
-	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	cfg, opts, err := config.Load(os.Args[1:], os.Getenv)
+	if opts.PrintConfig && !errors.Is(err, flag.ErrHelp) {
+		if werr := cfg.WriteRedacted(os.Stdout); werr != nil {
+			return werr
+		}
+		return err
+	}
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("config:\n%w", err)
 	}
 
-	tenantRepo := repo.NewTenantRepo(conn)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+
+	poolCfg, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		return err
+	}
+	poolCfg.MaxConns = int32(cfg.Database.MaxConns)
+	poolCfg.ConnConfig.Tracer = tracing.NewQueryTracer()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	if cfg.Database.AutoMigrate {
+		if err := migrateOnStart(ctx, pool); err != nil {
+			return err
+		}
+	}
+
+	m := metrics.New()
+
+	// the lease outlasts every attempt, however long job_timeout is
+	jobRepo := repo.NewJobRepo(pool).WithLease(max(repo.DefaultJobLease, 2*cfg.Worker.JobTimeout))
+	m.MustRegister(
+		metrics.NewPoolCollector(pool),
+		metrics.NewQueueDepthCollector(jobRepo.Depth),
+	)
+	// every job carries its producer's trace to the worker
+	queue := tracing.WrapQueue(jobs.Observe(jobRepo, m))
+
+	tenantRepo := repo.NewTenantRepo(pool)
 	tenantSvc := service.NewTenantService(tenantRepo)
 
-	templateRepo := repo.NewTemplateRepo(conn)
-	templateSvc := service.NewTemplateService(templateRepo)
+	webhookSvc := service.NewWebhookService(repo.NewWebhookRepo(pool), queue,
+		webhook.NewSender(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivate), nil).
+		WithInsecureURLs(cfg.Webhooks.AllowPrivate)
+
+	templateRepo := repo.NewTemplateRepo(pool)
+	templateSvc := service.NewTemplateService(templateRepo).WithRecorder(m).WithEvents(webhookSvc)
+
+	idemRepo := repo.NewIdempotencyRepo(pool)
+	go purgeEvery(ctx, time.Hour, "idempotency keys", idemRepo.PurgeExpired)
 
-	s := httpapi.New(httpapi.Deps{
+	if cfg.HTTP.CursorSecret == "" {
+		log.Printf("http.cursor_secret is empty: list cursors will not survive a restart or work across replicas")
+	}
+	// uploads may carry a key too; 1 MiB leaves room for the multipart framing
+	idem := idempotency.New(idemRepo, cfg.HTTP.IdempotencyTTL).
+		WithMaxBody(int64(cfg.Storage.MaxUploadBytes) + 1<<20)
+	deps := httpapi.Deps{
 		TenantSvc:   tenantSvc,
 		TemplateSvc: templateSvc,
+		DB:          pool,
+		Metrics:     m,
+		Idempotency: idem,
+		Cursors:     pagination.NewCodec([]byte(cfg.HTTP.CursorSecret)).WithLegacy(cfg.HTTP.LegacyCursors),
+
+		PublishWithoutIfMatch: cfg.HTTP.PublishWithoutIfMatch,
+	}
+
+	sessionRepo := repo.NewSessionRepo(pool)
+	sessionEvents := service.NewSessionEvents(sessionRepo)
+	go repo.ListenSessionEvents(ctx, pool, sessionEvents.Notify)
+	deps.SessionEvents = sessionEvents
+	leadRepo := repo.NewLeadRepo(pool)
+	deps.LeadSvc = service.NewLeadService(leadRepo)
+	deps.SessionSvc = service.NewSessionService(service.StoredSessions(sessionRepo, leadRepo), queue, nil).
+		WithRecorder(m).
+		WithEvents(webhookSvc)
+	leadSinkSvc := service.NewLeadSinkService(leadRepo, repo.NewLeadSinkRepo(pool), nil)
+	deps.WebhookSvc = webhookSvc
+	store, err := newBlobStore(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	if cfg.Storage.URLSecret == "" {
+		log.Printf("storage.url_secret is empty: download links will not survive a restart or work across replicas")
+	}
+	deps.UploadSvc = service.NewUploadService(repo.NewUploadRepo(pool), sessionRepo, store,
+		blob.NewLinker([]byte(cfg.Storage.URLSecret), strings.TrimSuffix(cfg.Storage.PublicURL, "/"), cfg.Storage.URLTTL, nil)).
+		WithLimits(int64(cfg.Storage.MaxUploadBytes), cfg.Storage.AllowedTypes)
+	provider, err := newProvider(cfg.LLM)
+	if err != nil {
+		return err
+	}
+	messageSvc := service.NewMessageService(service.StoredMessages(sessionRepo), nil).
+		WithReplies(queue)
+	toolRegistry, err := newToolRegistry(tenantRepo, m)
+	if err != nil {
+		return err
+	}
+	replySvc := service.NewReplyService(sessionRepo, templateRepo, provider, messageSvc).
+		WithTyper(sessionEvents).
+		WithTools(toolRegistry).
+		WithContextWindow(service.NewContextBuilder(provider, messageSvc).
+			WithBudget(cfg.LLM.ContextTokens).
+			WithRecentTurns(cfg.LLM.RecentTurns))
+	deps.Chat = messageSvc
+	deps.Heartbeat = cfg.HTTP.SSEHeartbeat
+
+	if len(cfg.RateLimit.Rules) > 0 {
+		deps.RateLimit = newRateLimiter(ctx, cfg.RateLimit, cfg.Auth.APIKeys, pool, m)
+	}
+	var reconcileSvc *service.ReconcileService
+	if cfg.Reconcile.Enabled() {
+		legacy, source, closeLegacy, err := legacyReader(ctx, cfg.Reconcile)
+		if err != nil {
+			return err
+		}
+		defer closeLegacy()
+		reconcileSvc = service.NewReconcileService(repo.NewReconcileRepo(pool),
+			reconcile.NewPostgresReader(pool), legacy, source, tracing.WrapOnceQueue(jobRepo))
+		deps.ReconcileSvc = reconcileSvc
+	}
+	if cfg.Legacy.URL != "" {
+		cut, cmp, err := newCutover(ctx, cfg, m)
+		if err != nil {
+			return err
+		}
+		deps.Cutover = cut
+		defer cmp.Wait()
+	}
+	s := httpapi.New(deps)
+
+	worker := jobs.NewWorker(jobRepo, nil).
+		WithObserver(m).
+		WithPollInterval(cfg.Worker.PollInterval).
+		WithJobTimeout(cfg.Worker.JobTimeout)
+	worker.Handle(service.ReplyJobKind, replySvc.HandleJob)
+	worker.Handle(service.WebhookJobKind, webhookSvc.HandleJob)
+	worker.Handle(service.LeadExportJobKind, leadSinkSvc.HandleJob)
+	if reconcileSvc != nil {
+		worker.Handle(service.ReconcileJobKind, func(ctx context.Context, _ repo.Job) error {
+			_, err := reconcileSvc.Run(ctx)
+			return err
+		})
+		if cfg.Reconcile.Interval > 0 {
+			go reconcileSvc.Schedule(ctx, cfg.Reconcile.Interval)
+		}
+	}
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		if cfg.Worker.Enabled {
+			worker.Run(workerCtx)
+		}
+	}()
+
+	srv := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", cfg.HTTP.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	select {
+	case err := <-serveErr:
+		stopWorker()
+		<-workerDone
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down")
+	s.Drain()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+
+	stopWorker()
+	select {
+	case <-workerDone:
+	case <-shutdownCtx.Done():
+		log.Printf("worker did not drain before timeout")
+	}
+	return nil
+}
+
+// newCutover builds the legacy router and, when a routes file is configured,
+// loads it and keeps it current: on change (polled) and on SIGHUP.
+func newCutover(ctx context.Context, cfg config.Config, m *metrics.Metrics) (*httpapi.Cutover, *shadow.Comparator, error) {
+	cmp, err := shadow.New(shadow.Config{
+		LegacyURL:   cfg.Legacy.URL,
+		IgnorePaths: cfg.Shadow.IgnorePaths,
+		Timeout:     cfg.Shadow.Timeout,
+		MaxInFlight: cfg.Shadow.MaxInFlight,
 	})
+	if err != nil {
+		return nil, nil, err
+	}
+	cut, err := httpapi.NewCutover(cfg.Legacy.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	cut.WithShadow(cmp.WithRecorder(m)).WithObserver(m)
+
+	path := cfg.Legacy.RoutesFile
+	if path == "" {
+		return cut, cmp, nil
+	}
+	if err := cut.Reload(path); err != nil {
+		return nil, nil, fmt.Errorf("legacy.routes_file: %w", err)
+	}
+	go cut.Watch(ctx, path, cfg.Legacy.ReloadInterval)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := cut.Reload(path); err != nil {
+					log.Printf("cutover: reload: %v", err)
+				}
+			}
+		}
+	}()
+	return cut, cmp, nil
+}
+
+// legacyReader opens the configured legacy source for reconciliation and
+// returns a label for it that is stored with each run.
+func legacyReader(ctx context.Context, cfg config.ReconcileConfig) (reconcile.Reader, string, func(), error) {
+	if cfg.LegacyExport != "" {
+		return reconcile.NewJSONReader(cfg.LegacyExport), "json:" + cfg.LegacyExport, func() {}, nil
+	}
+	poolCfg, err := pgxpool.ParseConfig(cfg.LegacyDSN)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("reconcile.legacy_dsn: %w", err)
+	}
+	poolCfg.MaxConns = 2
+	legacy, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return reconcile.NewPostgresReader(legacy), "postgres:" + poolCfg.ConnConfig.Host, legacy.Close, nil
+}
+
+// newProvider builds the model behind assistant replies.
+func newProvider(cfg config.LLMConfig) (llm.Provider, error) {
+	if cfg.Provider == "openai" {
+		return llm.NewOpenAI(llm.OpenAIConfig{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: cfg.Model, Timeout: cfg.Timeout})
+	}
+	log.Printf("llm.provider is fake: assistant replies echo the user")
+	return llm.NewScripted().WithFallback(llm.Echo), nil
+}
+
+// newBlobStore opens the upload store; the settings were checked by
+// config.Validate.
+func newBlobStore(cfg config.StorageConfig) (blob.Store, error) {
+	if cfg.Backend == "s3" {
+		return blob.NewS3(blob.S3Config{Endpoint: cfg.S3Endpoint, Bucket: cfg.S3Bucket, Region: cfg.S3Region,
+			AccessKey: cfg.S3AccessKey, SecretKey: cfg.S3SecretKey}, &http.Client{Timeout: 5 * time.Minute})
+	}
+	return blob.NewLocal(cfg.LocalDir)
+}
+
+// newToolRegistry registers the built-in tools; each tenant's allowlist
+// decides which of them its assistant may call.
+func newToolRegistry(allow tools.Allowlist, m *metrics.Metrics) (*tools.Registry, error) {
+	r := tools.NewRegistry(allow).WithObserver(m)
+	for _, t := range tools.Builtins(nil) {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// newRateLimiter builds the limiter; the rules were checked by config.Validate.
+// Only apiKeys count towards key limits.
+func newRateLimiter(ctx context.Context, cfg config.RateLimitConfig, apiKeys []string, pool *pgxpool.Pool, m *metrics.Metrics) *ratelimit.Limiter {
+	rules, _ := ratelimit.ParseRules(cfg.Rules)
+	var store ratelimit.Store = ratelimit.NewMemory()
+	if cfg.Backend == "postgres" {
+		rl := repo.NewRateLimitRepo(pool)
+		go purgeEvery(ctx, 10*time.Minute, "rate limit buckets", rl.PurgeExpired)
+		store = rl
+	}
+	return ratelimit.New(store, rules).
+		WithObserver(m).
+		WithTrustForwarded(cfg.TrustForwarded).
+		WithAPIKeys(apiKeys)
+}
+
+// purgeEvery runs purge on a fixed interval until ctx is done.
+func purgeEvery(ctx context.Context, every time.Duration, what string, purge func(context.Context) (int64, error)) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := purge(ctx)
+		if err != nil {
+			log.Printf("purge %s: %v", what, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("purged %d expired %s", n, what)
+		}
+	}
+}
 
-	log.Printf("listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, s))
+func migrateOnStart(ctx context.Context, pool *pgxpool.Pool) error {
+	runner, err := migrate.New(migrations.FS)
+	if err != nil {
+		return err
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ran, err := runner.Up(ctx, conn)
+	for _, m := range ran {
+		log.Printf("migrate: applied %04d_%s", m.Version, m.Name)
+	}
+	return err
 }
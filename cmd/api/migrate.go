@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"gochatbot/internal/config"
+	"gochatbot/internal/migrate"
+	"gochatbot/migrations"
+)
+
+const migrateUsage = "usage: api migrate up|status|down [steps] [config flags]"
+
+// runMigrate handles `api migrate ...`. It uses a plain connection rather than
+// the pool: the runner needs one session to hold the advisory lock.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	cmd, args := args[0], args[1:]
+
+	steps := 1
+	if cmd == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n < 1 {
+				return errors.New("down: steps must be at least 1")
+			}
+			steps, args = n, args[1:]
+		}
+	}
+
+	cfg, _, err := config.Load(args, os.Getenv)
+	if err != nil {
+		return fmt.Errorf("config:\n%w", err)
+	}
+
+	return migrate.Connect(context.Background(), cfg.Database.URL, migrations.FS, func(runner *migrate.Runner, conn migrate.Conn) error {
+		return migrateCommand(cmd, steps, runner, conn)
+	})
+}
+
+func migrateCommand(cmd string, steps int, runner *migrate.Runner, conn migrate.Conn) error {
+	ctx := context.Background()
+	switch cmd {
+	case "up":
+		ran, err := runner.Up(ctx, conn)
+		for _, m := range ran {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Println("up to date")
+		}
+		return err
+
+	case "down":
+		reverted, err := runner.Down(ctx, conn, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		sts, err := runner.Status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return printStatus(sts)
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printStatus(sts []migrate.Status) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT\tDOWN")
+	for _, st := range sts {
+		at := "-"
+		if st.Applied {
+			at = st.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		down := "no"
+		if st.Down != "" {
+			down = "yes"
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\t%s\n", st.Version, st.Name, st.State(), at, down)
+	}
+	return tw.Flush()
+}
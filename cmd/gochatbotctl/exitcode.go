@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/migrate"
+)
+
+// Exit codes are part of the CLI contract: scripts branch on them, so only
+// ever add new ones.
+const (
+	exitOK       = 0
+	exitError    = 1 // anything unexpected: database down, bugs
+	exitUsage    = 2 // bad command line or config (matches the flag package)
+	exitNotFound = 3
+	exitConflict = 4
+	exitInvalid  = 5 // input rejected by validation
+)
+
+var (
+	errUsage   = errors.New("usage")
+	errInvalid = errors.New("invalid input")
+)
+
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{errUsage, exitUsage},
+	{flag.ErrHelp, exitUsage},
+
+	{domain.ErrTenantNotFound, exitNotFound},
+	{domain.ErrTemplateNotFound, exitNotFound},
+	{domain.ErrVersionNotFound, exitNotFound},
+	{domain.ErrTemplateVersionNotFound, exitNotFound},
+	{domain.ErrSessionNotFound, exitNotFound},
+	{domain.ErrJobNotFound, exitNotFound},
+	{domain.ErrLeadNotFound, exitNotFound},
+	{domain.ErrWebhookNotFound, exitNotFound},
+	{domain.ErrDeliveryNotFound, exitNotFound},
+	{domain.ErrUploadNotFound, exitNotFound},
+	{domain.ErrReconcileRunNotFound, exitNotFound},
+
+	{domain.ErrTenantSlugTaken, exitConflict},
+	{domain.ErrTemplateSlugTaken, exitConflict},
+	{domain.ErrTemplateImmutable, exitConflict},
+	{domain.ErrVersionAlreadyPublished, exitConflict},
+	{domain.ErrPublishedVersionImmutable, exitConflict},
+	{domain.ErrSessionClosed, exitConflict},
+	{domain.ErrJobNotRetryable, exitConflict},
+	{domain.ErrRevisionMismatch, exitConflict},
+	{domain.ErrDeliveryPending, exitConflict},
+	{migrate.ErrChecksumMismatch, exitConflict},
+	{migrate.ErrNoDownScript, exitConflict},
+
+	{errInvalid, exitInvalid},
+	{domain.ErrInvalidSlug, exitInvalid},
+	{domain.ErrInvalidEmail, exitInvalid},
+	{domain.ErrInvalidPhone, exitInvalid},
+	{domain.ErrInvalidColor, exitInvalid},
+	{domain.ErrInvalidCursor, exitInvalid},
+	{domain.ErrInvalidRole, exitInvalid},
+	{domain.ErrEmptyMessage, exitInvalid},
+	{domain.ErrUnknownEmailProfile, exitInvalid},
+	{domain.ErrInvalidName, exitInvalid},
+	{domain.ErrInvalidVersion, exitInvalid},
+	{domain.ErrInvalidLeadStatus, exitInvalid},
+	{domain.ErrInvalidWebhookURL, exitInvalid},
+	{domain.ErrInvalidWebhookEvent, exitInvalid},
+	{domain.ErrUploadTooLarge, exitInvalid},
+	{domain.ErrUploadTypeNotAllowed, exitInvalid},
+	{domain.ErrEmptyUpload, exitInvalid},
+	{domain.ErrInvalidDownloadLink, exitInvalid},
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return exitError
+}
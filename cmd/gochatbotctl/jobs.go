@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gochatbot/internal/repo"
+)
+
+// jobView is the JSON shape of a job; repo.Job has no tags.
+type jobView struct {
+	ID          string         `json:"id"`
+	Kind        string         `json:"kind"`
+	Status      string         `json:"status"`
+	Attempts    int            `json:"attempts"`
+	MaxAttempts int            `json:"max_attempts"`
+	RunAt       time.Time      `json:"run_at"`
+	LastError   string         `json:"last_error,omitempty"`
+	Payload     map[string]any `json:"payload"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func viewJob(j repo.Job) jobView {
+	return jobView{
+		ID:          j.ID,
+		Kind:        j.Kind,
+		Status:      j.Status,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		RunAt:       j.RunAt,
+		LastError:   j.LastError,
+		Payload:     j.Payload,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+	}
+}
+
+func jobTable(js ...jobView) table {
+	t := table{header: []string{"ID", "KIND", "STATUS", "ATTEMPTS", "RUN AT", "UPDATED AT", "LAST ERROR"}}
+	for _, j := range js {
+		t.rows = append(t.rows, []string{
+			j.ID, j.Kind, j.Status,
+			fmt.Sprintf("%d/%d", j.Attempts, j.MaxAttempts),
+			formatTime(j.RunAt), formatTime(j.UpdatedAt), orDash(j.LastError),
+		})
+	}
+	return t
+}
+
+func jobDetailTable(j jobView) table {
+	return table{
+		header: []string{"FIELD", "VALUE"},
+		rows: [][]string{
+			{"id", j.ID},
+			{"kind", j.Kind},
+			{"status", j.Status},
+			{"attempts", fmt.Sprintf("%d/%d", j.Attempts, j.MaxAttempts)},
+			{"run_at", formatTime(j.RunAt)},
+			{"created_at", formatTime(j.CreatedAt)},
+			{"updated_at", formatTime(j.UpdatedAt)},
+			{"last_error", orDash(j.LastError)},
+			{"payload", compact(j.Payload)},
+		},
+	}
+}
+
+var jobStatuses = map[string]bool{"queued": true, "running": true, "done": true, "failed": true}
+
+func (a *app) jobsList(ctx context.Context, args []string) error {
+	fs := a.flags("jobs list")
+	status := fs.String("status", "", "queued, running, done or failed (default all)")
+	kind := fs.String("kind", "", "job kind (default all)")
+	limit := fs.Int("limit", 50, "max jobs, most recently updated first (1-500)")
+	if err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *status != "" && !jobStatuses[*status] {
+		return fmt.Errorf("%w: -status must be queued, running, done or failed, got %q", errUsage, *status)
+	}
+
+	list, err := a.jobs.List(ctx, repo.JobFilter{Status: *status, Kind: *kind, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	views := make([]jobView, 0, len(list))
+	for _, j := range list {
+		views = append(views, viewJob(j))
+	}
+	return a.out.print(views, jobTable(views...))
+}
+
+func (a *app) jobsShow(ctx context.Context, args []string) error {
+	fs := a.flags("jobs show <id>")
+	if err := a.parse(fs, args, 1); err != nil {
+		return err
+	}
+	j, err := a.jobs.Get(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	v := viewJob(j)
+	return a.out.print(v, jobDetailTable(v))
+}
+
+// jobsRetry requeues a failed job with a fresh set of attempts.
+func (a *app) jobsRetry(ctx context.Context, args []string) error {
+	fs := a.flags("jobs retry <id>")
+	if err := a.parse(fs, args, 1); err != nil {
+		return err
+	}
+	j, err := a.jobs.Requeue(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	v := viewJob(j)
+	return a.out.print(v, jobTable(v))
+}
+
+func (a *app) jobsPurge(ctx context.Context, args []string) error {
+	fs := a.flags("jobs purge")
+	status := fs.String("status", "done", "done or failed")
+	olderThan := fs.Duration("older-than", 7*24*time.Hour, "only jobs last updated longer ago than this")
+	if err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *status != "done" && *status != "failed" {
+		return fmt.Errorf("%w: -status must be done or failed, got %q", errUsage, *status)
+	}
+	if *olderThan < 0 {
+		return fmt.Errorf("%w: -older-than must not be negative", errUsage)
+	}
+
+	n, err := a.jobs.Purge(ctx, *status, time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+	res := struct {
+		Status  string `json:"status"`
+		Deleted int64  `json:"deleted"`
+	}{*status, n}
+	return a.out.print(res, table{
+		header: []string{"STATUS", "DELETED"},
+		rows:   [][]string{{res.Status, strconv.FormatInt(n, 10)}},
+	})
+}
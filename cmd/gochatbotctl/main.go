@@ -0,0 +1,289 @@
+// Command gochatbotctl is the admin CLI: the ops tasks that used to be
+// hand-written SQL, run through the same services the API uses.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gochatbot/internal/config"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/jobs"
+	"gochatbot/internal/metrics"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+	"gochatbot/internal/tracing"
+	"gochatbot/internal/webhook"
+)
+
+const usage = `usage: gochatbotctl [config flags] <group> <command> [-o table|json] [flags] [args]
+
+  tenants   create <name> <slug>
+            list [-limit n] [-cursor c] [-q prefix] [-sort created_at|name] [-order asc|desc]
+            rename <slug> <new-name>
+            tools [-clear] <slug> [tool...]
+            sink [-clear] <slug> [config.json|-]
+  templates list [-limit n] [-cursor c] [-q prefix] [-sort s] [-order o] <tenant>
+            create <tenant> <name> <slug>
+            push <tenant> <template> <file|->
+            show <tenant> <template> <version>
+            publish <tenant> <template> <version>
+            diff <tenant> <template> <from-version> <to-version>
+  jobs      list [-status s] [-kind k] [-limit n]
+            show <id>
+            retry <id>
+            purge [-status done|failed] [-older-than 168h]
+  migrate   up | status | down [steps]
+
+Config flags, env and -config file are the same as the api binary's.
+Exit codes: 0 ok, 1 error, 2 usage, 3 not found, 4 conflict, 5 invalid input.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Getenv, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+type app struct {
+	cfg        config.Config
+	tenants    *service.TenantService
+	allowlists *repo.TenantRepo
+	templates  *service.TemplateService
+	sinks      *service.LeadSinkService
+	jobs       *repo.JobRepo
+	cursors    *pagination.Codec
+
+	stdin  io.Reader
+	stderr io.Writer
+	out    printer
+}
+
+type command func(a *app, ctx context.Context, args []string) error
+
+var commands = map[string]map[string]command{
+	"tenants": {
+		"create": (*app).tenantsCreate,
+		"list":   (*app).tenantsList,
+		"rename": (*app).tenantsRename,
+		"tools":  (*app).tenantsTools,
+		"sink":   (*app).tenantsSink,
+	},
+	"templates": {
+		"list":    (*app).templatesList,
+		"create":  (*app).templatesCreate,
+		"push":    (*app).templatesPush,
+		"show":    (*app).templatesShow,
+		"publish": (*app).templatesPublish,
+		"diff":    (*app).templatesDiff,
+	},
+	"jobs": {
+		"list":  (*app).jobsList,
+		"show":  (*app).jobsShow,
+		"retry": (*app).jobsRetry,
+		"purge": (*app).jobsPurge,
+	},
+	"migrate": {
+		"up":     (*app).migrateUp,
+		"status": (*app).migrateStatus,
+		"down":   (*app).migrateDown,
+	},
+}
+
+func run(ctx context.Context, args []string, getenv func(string) string, stdout, stderr io.Writer) int {
+	cfg, opts, err := config.Load(args, getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "config:\n%v\n", err)
+		return exitUsage
+	}
+
+	cmd, err := lookupCommand(opts.Args)
+	if err != nil {
+		fmt.Fprintf(stderr, "gochatbotctl: %v\n\n%s", err, usage)
+		return exitUsage
+	}
+
+	// pgxpool connects lazily, so usage errors below never touch the database
+	poolCfg, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		fmt.Fprintf(stderr, "config:\ndatabase.url: %v\n", err)
+		return exitUsage
+	}
+	poolCfg.MaxConns = int32(cfg.Database.MaxConns)
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "gochatbotctl: %v\n", err)
+		return exitError
+	}
+	defer pool.Close()
+
+	// wired like cmd/api, so publishing here queues the same webhooks; the
+	// API's workers deliver them
+	m := metrics.New()
+	jobRepo := repo.NewJobRepo(pool)
+	webhooks := service.NewWebhookService(repo.NewWebhookRepo(pool), tracing.WrapQueue(jobs.Observe(jobRepo, m)),
+		webhook.NewSender(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivate), nil).
+		WithInsecureURLs(cfg.Webhooks.AllowPrivate)
+
+	tenantRepo := repo.NewTenantRepo(pool)
+	a := &app{
+		cfg:        cfg,
+		tenants:    service.NewTenantService(tenantRepo),
+		allowlists: tenantRepo,
+		templates:  service.NewTemplateService(repo.NewTemplateRepo(pool)).WithRecorder(m).WithEvents(webhooks),
+		sinks:      service.NewLeadSinkService(repo.NewLeadRepo(pool), repo.NewLeadSinkRepo(pool), nil),
+		jobs:       jobRepo,
+		cursors:    pagination.NewCodec([]byte(cfg.HTTP.CursorSecret)).WithLegacy(cfg.HTTP.LegacyCursors),
+		stdin:      os.Stdin,
+		stderr:     stderr,
+		out:        printer{w: stdout, format: "table"},
+	}
+
+	err = cmd(a, ctx, opts.Args[2:])
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(stderr, "gochatbotctl: %v\n", err)
+	}
+	return exitCode(err)
+}
+
+func lookupCommand(args []string) (command, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: missing command", errUsage)
+	}
+	group, ok := commands[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown group %q", errUsage, args[0])
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("%w: %s needs one of: %s", errUsage, args[0], names(group))
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown command %q %q", errUsage, args[0], args[1])
+	}
+	return cmd, nil
+}
+
+func names(group map[string]command) string {
+	out := make([]string, 0, len(group))
+	for n := range group {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
+
+// flags starts a command's flag set with the shared -o switch. synopsis is
+// the "group command <args>" line shown on -h and usage errors.
+func (a *app) flags(synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(synopsis, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.out.format, "o", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: gochatbotctl %s\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses flags and checks the number of positional arguments.
+func (a *app) parse(fs *flag.FlagSet, args []string, nargs int) error {
+	return a.parseRange(fs, args, nargs, nargs)
+}
+
+func (a *app) parseRange(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if a.out.format != "table" && a.out.format != "json" {
+		return fmt.Errorf("%w: -o must be table or json, got %q", errUsage, a.out.format)
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		return fmt.Errorf("%w: gochatbotctl %s", errUsage, fs.Name())
+	}
+	return nil
+}
+
+// pageFlags are the paging and filter flags shared by the list commands.
+type pageFlags struct {
+	limit                *int
+	cursor, q, by, order *string
+}
+
+func addPageFlags(fs *flag.FlagSet) pageFlags {
+	return pageFlags{
+		limit:  fs.Int("limit", 50, "page size (1-200)"),
+		cursor: fs.String("cursor", "", "next_cursor or prev_cursor from a previous page"),
+		q:      fs.String("q", "", "name or slug prefix"),
+		by:     fs.String("sort", "created_at", "sort by created_at or name"),
+		order:  fs.String("order", "", "asc or desc (default desc for created_at, asc for name)"),
+	}
+}
+
+// filter validates the flags; the cursor must come from the same -q, -sort
+// and -order.
+func (p pageFlags) filter(c *pagination.Codec) (pagination.Filter, *pagination.Cursor, error) {
+	srt, err := pagination.ParseSort(*p.by, *p.order)
+	if err != nil {
+		return pagination.Filter{}, nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	f := pagination.Filter{Prefix: strings.TrimSpace(*p.q), Sort: srt}
+	if *p.cursor == "" {
+		return f, nil, nil
+	}
+	cur, err := c.Decode(*p.cursor, f)
+	if err != nil {
+		return pagination.Filter{}, nil, err
+	}
+	return f, &cur, nil
+}
+
+// encodePage signs a page's cursors the way the API does.
+func (a *app) encodePage(pg pagination.Page, f pagination.Filter) (next, prev string) {
+	if pg.Next != nil {
+		next = a.cursors.Encode(*pg.Next, f)
+	}
+	if pg.Prev != nil {
+		prev = a.cursors.Encode(*pg.Prev, f)
+	}
+	return next, prev
+}
+
+// notePage hints how to reach the neighbouring pages.
+func (a *app) notePage(next, prev string) {
+	if next != "" {
+		a.note("more: -cursor %s", next)
+	}
+	if prev != "" {
+		a.note("back: -cursor %s", prev)
+	}
+}
+
+// rctx adapts ctx for services that take the HTTP request context.
+func rctx(ctx context.Context) httpapi.RequestContext {
+	return httpapi.RequestContext{Ctx: ctx}
+}
+
+// note writes a hint to stderr so stdout stays machine-readable.
+func (a *app) note(format string, args ...any) {
+	fmt.Fprintf(a.stderr, format+"\n", args...)
+}
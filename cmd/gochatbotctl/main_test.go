@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/sink"
+)
+
+// unreachable DSN: pgxpool connects lazily, so these tests only pass if the
+// command fails before touching the database.
+func testEnv(k string) string {
+	if k == "DATABASE_URL" {
+		return "postgres://nobody@127.0.0.1:1/none?connect_timeout=1"
+	}
+	return ""
+}
+
+func runCtl(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, testEnv, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestExitCode_MapsDomainErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{domain.ErrTenantNotFound, exitNotFound},
+		{fmt.Errorf("wrapped: %w", domain.ErrVersionNotFound), exitNotFound},
+		{domain.ErrJobNotFound, exitNotFound},
+		{domain.ErrTenantSlugTaken, exitConflict},
+		{domain.ErrVersionAlreadyPublished, exitConflict},
+		{domain.ErrJobNotRetryable, exitConflict},
+		{domain.ErrRevisionMismatch, exitConflict},
+		{domain.ErrLeadNotFound, exitNotFound},
+		{domain.ErrDeliveryNotFound, exitNotFound},
+		{domain.ErrUploadNotFound, exitNotFound},
+		{domain.ErrInvalidSlug, exitInvalid},
+		{domain.ErrInvalidCursor, exitInvalid},
+		{domain.ErrInvalidName, exitInvalid},
+		{domain.ErrInvalidWebhookURL, exitInvalid},
+		{errUsage, exitUsage},
+		{fmt.Errorf("connection refused"), exitError},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.code, exitCode(c.err), "%v", c.err)
+	}
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"nope"},
+		{"tenants"},
+		{"tenants", "delete"},
+		{"tenants", "rename", "acme"},
+		{"tenants", "list", "-o", "yaml"},
+		{"tenants", "tools"},
+		{"tenants", "tools", "-clear", "acme", "capture_lead_field"},
+		{"tenants", "sink"},
+		{"tenants", "sink", "-clear", "acme", "crm.json"},
+		{"templates", "diff", "acme", "faq", "one", "2"},
+		{"jobs", "purge", "-status", "queued"},
+		{"migrate", "down", "0"},
+	} {
+		code, stdout, stderr := runCtl(t, args...)
+		require.Equal(t, exitUsage, code, "%v", args)
+		require.Empty(t, stdout)
+		require.NotEmpty(t, stderr)
+	}
+}
+
+func TestRun_ConfigErrorIsUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	code := run(context.Background(), []string{"tenants", "list"}, func(string) string { return "" }, &bytes.Buffer{}, &stderr)
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, stderr.String(), "database.url")
+}
+
+func TestRun_PushRejectsInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "draft.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"greeting":`), 0o600))
+
+	code, _, stderr := runCtl(t, "templates", "push", "acme", "faq", path)
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, stderr, "draft.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`["not","an","object"]`), 0o600))
+	code, _, _ = runCtl(t, "templates", "push", "acme", "faq", path)
+	require.Equal(t, exitInvalid, code)
+}
+
+func TestRun_ToolsRejectsUnknownNames(t *testing.T) {
+	code, stdout, stderr := runCtl(t, "tenants", "tools", "acme", "capture_lead_field", "send_money")
+	require.Equal(t, exitInvalid, code)
+	require.Empty(t, stdout)
+	require.Contains(t, stderr, `unknown tool "send_money"`)
+}
+
+func TestRun_SinkRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crm.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"kind":"rest","rest":{"url":"https://crm.example.com","mapping":["mail -> email"]}}`), 0o600))
+
+	code, stdout, stderr := runCtl(t, "tenants", "sink", "acme", path)
+	require.Equal(t, exitInvalid, code)
+	require.Empty(t, stdout)
+	require.Contains(t, stderr, `unknown lead field "mail"`)
+}
+
+func TestSinkResult_RedactsHeaders(t *testing.T) {
+	c := sink.Config{Kind: sink.KindREST, REST: &sink.RESTConfig{
+		URL: "https://crm.example.com", Headers: map[string]string{"Authorization": "Bearer s3cret"},
+		Mapping: []string{"email -> email"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, printer{w: &buf, format: "json"}.print(sinkResult("acme", c, true)))
+	require.NotContains(t, buf.String(), "s3cret")
+	require.Contains(t, buf.String(), `"Authorization": "<redacted>"`)
+	require.Equal(t, "Bearer s3cret", c.REST.Headers["Authorization"], "the caller's config is untouched")
+
+	buf.Reset()
+	require.NoError(t, printer{w: &buf, format: "table"}.print(sinkResult("acme", c, true)))
+	require.NotContains(t, buf.String(), "s3cret")
+	require.Contains(t, buf.String(), "method   POST")
+}
+
+func TestPrinter_TableAndJSON(t *testing.T) {
+	tn := httpapi.Tenant{ID: "t1", Name: "Acme Inc", Slug: "acme"}
+
+	var buf bytes.Buffer
+	require.NoError(t, printer{w: &buf, format: "table"}.print(tn, tenantTable(tn)))
+	require.Equal(t, "ID  SLUG  NAME\nt1  acme  Acme Inc\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, printer{w: &buf, format: "json"}.print(tn, tenantTable(tn)))
+	require.JSONEq(t, `{"id":"t1","name":"Acme Inc","slug":"acme"}`, buf.String())
+}
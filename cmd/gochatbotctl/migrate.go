@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gochatbot/internal/migrate"
+	"gochatbot/migrations"
+)
+
+type migrationView struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	HasDown   bool       `json:"has_down"`
+}
+
+func migrationTable(ms []migrationView) table {
+	t := table{header: []string{"VERSION", "NAME", "STATE", "APPLIED AT", "DOWN"}}
+	for _, m := range ms {
+		at := "-"
+		if m.AppliedAt != nil {
+			at = formatTime(*m.AppliedAt)
+		}
+		down := "no"
+		if m.HasDown {
+			down = "yes"
+		}
+		t.rows = append(t.rows, []string{fmt.Sprintf("%04d", m.Version), m.Name, m.State, at, down})
+	}
+	return t
+}
+
+func viewMigrations(ms []migrate.Migration, state string) []migrationView {
+	out := make([]migrationView, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, migrationView{Version: m.Version, Name: m.Name, State: state, HasDown: m.Down != ""})
+	}
+	return out
+}
+
+// withRunner hands fn a single connection: the runner's advisory lock is
+// per-session, so it can't run over the pool.
+func (a *app) withRunner(ctx context.Context, fn func(*migrate.Runner, migrate.Conn) error) error {
+	return migrate.Connect(ctx, a.cfg.Database.URL, migrations.FS, fn)
+}
+
+func (a *app) migrateUp(ctx context.Context, args []string) error {
+	fs := a.flags("migrate up")
+	if err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	return a.withRunner(ctx, func(r *migrate.Runner, conn migrate.Conn) error {
+		ran, err := r.Up(ctx, conn)
+		// report what did apply even when a later migration failed
+		views := viewMigrations(ran, "applied")
+		if perr := a.out.print(views, migrationTable(views)); perr != nil && err == nil {
+			err = perr
+		}
+		return err
+	})
+}
+
+func (a *app) migrateDown(ctx context.Context, args []string) error {
+	fs := a.flags("migrate down [steps]")
+	if err := a.parseRange(fs, args, 0, 1); err != nil {
+		return err
+	}
+	steps := 1
+	if fs.NArg() == 1 {
+		n, err := strconv.Atoi(fs.Arg(0))
+		if err != nil || n < 1 {
+			return fmt.Errorf("%w: steps must be a positive integer, got %q", errUsage, fs.Arg(0))
+		}
+		steps = n
+	}
+
+	return a.withRunner(ctx, func(r *migrate.Runner, conn migrate.Conn) error {
+		reverted, err := r.Down(ctx, conn, steps)
+		views := viewMigrations(reverted, "reverted")
+		if perr := a.out.print(views, migrationTable(views)); perr != nil && err == nil {
+			err = perr
+		}
+		return err
+	})
+}
+
+func (a *app) migrateStatus(ctx context.Context, args []string) error {
+	fs := a.flags("migrate status")
+	if err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+	return a.withRunner(ctx, func(r *migrate.Runner, conn migrate.Conn) error {
+		sts, err := r.Status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		views := make([]migrationView, 0, len(sts))
+		for _, st := range sts {
+			v := migrationView{Version: st.Version, Name: st.Name, State: st.State(), HasDown: st.Down != ""}
+			if st.Applied {
+				at := st.AppliedAt
+				v.AppliedAt = &at
+			}
+			views = append(views, v)
+		}
+		return a.out.print(views, migrationTable(views))
+	})
+}
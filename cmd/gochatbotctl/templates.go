@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/jsondiff"
+)
+
+func templateTable(ts ...httpapi.Template) table {
+	t := table{header: []string{"ID", "SLUG", "NAME", "CREATED AT"}}
+	for _, tp := range ts {
+		t.rows = append(t.rows, []string{tp.ID, tp.Slug, tp.Name, formatTime(tp.CreatedAt)})
+	}
+	return t
+}
+
+func versionTable(vs ...httpapi.TemplateVersion) table {
+	t := table{header: []string{"TEMPLATE", "VERSION", "STATUS", "CREATED AT"}}
+	for _, v := range vs {
+		t.rows = append(t.rows, []string{v.TemplateID, strconv.Itoa(v.Version), v.Status, formatTime(v.CreatedAt)})
+	}
+	return t
+}
+
+// template resolves tenant and template slugs to the template.
+func (a *app) template(ctx context.Context, tenantSlug, templateSlug string) (httpapi.Template, error) {
+	tn, err := a.tenants.GetTenantBySlug(rctx(ctx), tenantSlug)
+	if err != nil {
+		return httpapi.Template{}, err
+	}
+	return a.templates.GetTemplate(ctx, tn.ID, templateSlug)
+}
+
+func parseVersion(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: version must be a positive integer, got %q", errUsage, s)
+	}
+	return n, nil
+}
+
+func (a *app) templatesList(ctx context.Context, args []string) error {
+	fs := a.flags("templates list <tenant>")
+	page := addPageFlags(fs)
+	if err := a.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	f, cur, err := page.filter(a.cursors)
+	if err != nil {
+		return err
+	}
+
+	tn, err := a.tenants.GetTenantBySlug(rctx(ctx), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	res, err := a.templates.ListTemplates(ctx, tn.ID, *page.limit, f, cur)
+	if err != nil {
+		return err
+	}
+	res.NextCursor, res.PrevCursor = a.encodePage(res.Page, f)
+	if err := a.out.print(res, templateTable(res.Items...)); err != nil {
+		return err
+	}
+	if a.out.format == "table" {
+		a.notePage(res.NextCursor, res.PrevCursor)
+	}
+	return nil
+}
+
+func (a *app) templatesCreate(ctx context.Context, args []string) error {
+	fs := a.flags("templates create <tenant> <name> <slug>")
+	if err := a.parse(fs, args, 3); err != nil {
+		return err
+	}
+	tn, err := a.tenants.GetTenantBySlug(rctx(ctx), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	tp, err := a.templates.CreateTemplate(ctx, tn.ID, fs.Arg(1), fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	return a.out.print(tp, templateTable(tp))
+}
+
+// templatesPush creates the next draft version from a JSON file ("-" for stdin).
+func (a *app) templatesPush(ctx context.Context, args []string) error {
+	fs := a.flags("templates push <tenant> <template> <file|->")
+	if err := a.parse(fs, args, 3); err != nil {
+		return err
+	}
+
+	content, err := a.readContent(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	tp, err := a.template(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	v, err := a.templates.CreateDraft(ctx, tp.ID, content)
+	if err != nil {
+		return err
+	}
+	return a.out.print(v, versionTable(v))
+}
+
+func (a *app) readContent(path string) (json.RawMessage, error) {
+	body, err := a.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errInvalid, path, err)
+	}
+	if buf.Len() == 0 || buf.Bytes()[0] != '{' {
+		return nil, fmt.Errorf("%w: %s: template content must be a JSON object", errInvalid, path)
+	}
+	return buf.Bytes(), nil
+}
+
+// readInput reads the named file, or stdin for "-".
+func (a *app) readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(a.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// templatesShow prints a version's content; -o json prints the whole version.
+func (a *app) templatesShow(ctx context.Context, args []string) error {
+	fs := a.flags("templates show <tenant> <template> <version>")
+	if err := a.parse(fs, args, 3); err != nil {
+		return err
+	}
+	n, err := parseVersion(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	tp, err := a.template(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	v, err := a.templates.GetVersion(ctx, tp.ID, n)
+	if err != nil {
+		return err
+	}
+	if a.out.format == "json" {
+		return a.out.print(v, table{})
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, v.Content, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(a.out.w)
+	return err
+}
+
+func (a *app) templatesPublish(ctx context.Context, args []string) error {
+	fs := a.flags("templates publish <tenant> <template> <version>")
+	if err := a.parse(fs, args, 3); err != nil {
+		return err
+	}
+	n, err := parseVersion(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	tp, err := a.template(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	v, err := a.templates.Publish(ctx, tp.ID, n, 0)
+	if err != nil {
+		return err
+	}
+	return a.out.print(v, versionTable(v))
+}
+
+func (a *app) templatesDiff(ctx context.Context, args []string) error {
+	fs := a.flags("templates diff <tenant> <template> <from-version> <to-version>")
+	if err := a.parse(fs, args, 4); err != nil {
+		return err
+	}
+	from, err := parseVersion(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	to, err := parseVersion(fs.Arg(3))
+	if err != nil {
+		return err
+	}
+
+	tp, err := a.template(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	changes, err := a.templates.DiffVersions(ctx, tp.ID, from, to)
+	if err != nil {
+		return err
+	}
+	if changes == nil {
+		changes = []jsondiff.Change{}
+	}
+
+	t := table{header: []string{"OP", "PATH", "FROM", "TO"}}
+	for _, c := range changes {
+		from, to := compact(c.From), compact(c.To)
+		if c.Op == jsondiff.OpAdded {
+			from = ""
+		}
+		if c.Op == jsondiff.OpRemoved {
+			to = ""
+		}
+		t.rows = append(t.rows, []string{c.Op, c.Path, from, to})
+	}
+	return a.out.print(changes, t)
+}
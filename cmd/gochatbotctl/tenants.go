@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/sink"
+	"gochatbot/internal/tools"
+)
+
+func tenantTable(ts ...httpapi.Tenant) table {
+	t := table{header: []string{"ID", "SLUG", "NAME"}}
+	for _, tn := range ts {
+		t.rows = append(t.rows, []string{tn.ID, tn.Slug, tn.Name})
+	}
+	return t
+}
+
+func (a *app) tenantsCreate(ctx context.Context, args []string) error {
+	fs := a.flags("tenants create <name> <slug>")
+	if err := a.parse(fs, args, 2); err != nil {
+		return err
+	}
+	t, err := a.tenants.CreateTenant(rctx(ctx), fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	return a.out.print(t, tenantTable(t))
+}
+
+func (a *app) tenantsList(ctx context.Context, args []string) error {
+	fs := a.flags("tenants list")
+	page := addPageFlags(fs)
+	if err := a.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	f, cur, err := page.filter(a.cursors)
+	if err != nil {
+		return err
+	}
+
+	res, err := a.tenants.ListTenants(rctx(ctx), *page.limit, f, cur)
+	if err != nil {
+		return err
+	}
+	res.NextCursor, res.PrevCursor = a.encodePage(res.Page, f)
+	if err := a.out.print(res, tenantTable(res.Items...)); err != nil {
+		return err
+	}
+	if a.out.format == "table" {
+		a.notePage(res.NextCursor, res.PrevCursor)
+	}
+	return nil
+}
+
+func (a *app) tenantsRename(ctx context.Context, args []string) error {
+	fs := a.flags("tenants rename <slug> <new-name>")
+	if err := a.parse(fs, args, 2); err != nil {
+		return err
+	}
+	t, err := a.tenants.RenameTenant(rctx(ctx), fs.Arg(0), fs.Arg(1), 0)
+	if err != nil {
+		return err
+	}
+	return a.out.print(t, tenantTable(t))
+}
+
+// tenantsTools shows the tenant's tool allowlist, or replaces it when tools
+// are named or -clear is given. Names are checked against the built-in
+// tools before the database is touched.
+func (a *app) tenantsTools(ctx context.Context, args []string) error {
+	fs := a.flags("tenants tools [-clear] <slug> [tool...]")
+	clear := fs.Bool("clear", false, "allow no tools")
+	builtins := tools.Builtins(nil)
+	if err := a.parseRange(fs, args, 1, 1+len(builtins)); err != nil {
+		return err
+	}
+	names := fs.Args()[1:]
+	if *clear && len(names) > 0 {
+		return fmt.Errorf("%w: -clear takes no tool names", errUsage)
+	}
+	known := make([]string, len(builtins))
+	for i, t := range builtins {
+		known[i] = t.Name
+	}
+	for _, n := range names {
+		if !slices.Contains(known, n) {
+			return fmt.Errorf("%w: unknown tool %q (known: %s)", errInvalid, n, strings.Join(known, ", "))
+		}
+	}
+
+	t, err := a.tenants.GetTenantBySlug(rctx(ctx), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *clear || len(names) > 0 {
+		slices.Sort(names)
+		if err := a.allowlists.SetAllowedTools(ctx, t.Slug, slices.Compact(names)); err != nil {
+			return err
+		}
+	}
+	allowed, err := a.allowlists.AllowedTools(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+
+	res := struct {
+		Tenant string   `json:"tenant"`
+		Tools  []string `json:"tools"`
+	}{t.Slug, allowed}
+	tbl := table{header: []string{"TOOL", "ALLOWED"}}
+	for _, n := range known {
+		allowedStr := "no"
+		if slices.Contains(allowed, n) {
+			allowedStr = "yes"
+		}
+		tbl.rows = append(tbl.rows, []string{n, allowedStr})
+	}
+	return a.out.print(res, tbl)
+}
+
+// tenantsSink shows the tenant's lead sink, or replaces it from a JSON file
+// (or stdin) holding a sink.Config; -clear removes it. The config, mapping
+// included, is checked before the database is touched. Header values are
+// never printed since they usually hold API tokens.
+func (a *app) tenantsSink(ctx context.Context, args []string) error {
+	fs := a.flags("tenants sink [-clear] <slug> [config.json|-]")
+	clear := fs.Bool("clear", false, "remove the sink; leads stay new")
+	if err := a.parseRange(fs, args, 1, 2); err != nil {
+		return err
+	}
+	path := fs.Arg(1)
+	if *clear && path != "" {
+		return fmt.Errorf("%w: -clear takes no config", errUsage)
+	}
+	var raw []byte
+	if path != "" {
+		var err error
+		if raw, err = a.readInput(path); err != nil {
+			return err
+		}
+		if _, err := sink.ParseConfig(raw); err != nil {
+			return fmt.Errorf("%w: %s: %v", errInvalid, path, err)
+		}
+	}
+
+	t, err := a.tenants.GetTenantBySlug(rctx(ctx), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var (
+		c  sink.Config
+		ok bool
+	)
+	switch {
+	case *clear:
+		err = a.sinks.DeleteLeadSink(ctx, t.ID)
+	case raw != nil:
+		c, err = a.sinks.SetLeadSink(ctx, t.ID, raw)
+		ok = err == nil
+	default:
+		c, ok, err = a.sinks.GetLeadSink(ctx, t.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return a.out.print(sinkResult(t.Slug, c, ok))
+}
+
+type sinkView struct {
+	Tenant string       `json:"tenant"`
+	Sink   *sink.Config `json:"sink"`
+}
+
+// sinkResult redacts c's header values and lays it out for printing.
+func sinkResult(slug string, c sink.Config, ok bool) (sinkView, table) {
+	res := sinkView{Tenant: slug}
+	tbl := table{header: []string{"SETTING", "VALUE"}}
+	if !ok {
+		tbl.rows = append(tbl.rows, []string{"kind", "none"})
+		return res, tbl
+	}
+	tbl.rows = append(tbl.rows, []string{"kind", c.Kind})
+	if r := c.REST; r != nil {
+		rest := *r
+		rest.Headers = make(map[string]string, len(r.Headers))
+		for k := range r.Headers {
+			rest.Headers[k] = "<redacted>"
+		}
+		c.REST = &rest
+		method := rest.Method
+		if method == "" {
+			method = "POST"
+		}
+		tbl.rows = append(tbl.rows, []string{"url", rest.URL}, []string{"method", strings.ToUpper(method)})
+		keys := make([]string, 0, len(rest.Headers))
+		for k := range rest.Headers {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			tbl.rows = append(tbl.rows, []string{"header", k + ": <redacted>"})
+		}
+		for _, m := range rest.Mapping {
+			tbl.rows = append(tbl.rows, []string{"mapping", m})
+		}
+	}
+	res.Sink = &c
+	return res, tbl
+}
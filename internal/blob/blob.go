@@ -0,0 +1,87 @@
+// Package blob keeps uploaded files. A Store maps keys to bytes, on local
+// disk or in an S3-compatible bucket; what a file is, who may read it and
+// for how long is decided by the service, which hands out Linker-signed
+// download links.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Store holds objects by key. Keys are slash-separated segments of
+// letters, digits, '.', '_' and '-'; "." and ".." are not segments.
+type Store interface {
+	// Put stores exactly size bytes from r under key, replacing any object
+	// already there. A reader that ends early or runs long is an error and
+	// leaves nothing behind.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open reads the object; ErrNotFound when there is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object; deleting a missing one is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	ErrNotFound   = errors.New("blob: not found")
+	ErrInvalidKey = errors.New("blob: invalid key")
+)
+
+const maxKeyLen = 512
+
+func checkKey(key string) error {
+	if key == "" || len(key) > maxKeyLen {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+		for _, c := range seg {
+			ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'
+			if !ok {
+				return ErrInvalidKey
+			}
+		}
+	}
+	return nil
+}
+
+// sizedReader fails a Put whose reader does not hold exactly n bytes.
+type sizedReader struct {
+	r        io.Reader
+	n        int64 // bytes still expected
+	mismatch bool
+}
+
+var errSizeMismatch = errors.New("blob: content length does not match size")
+
+func (s *sizedReader) Read(p []byte) (int, error) {
+	if s.mismatch {
+		return 0, errSizeMismatch
+	}
+	if s.n <= 0 {
+		// one byte more than promised is enough to tell
+		var b [1]byte
+		if n, _ := s.r.Read(b[:]); n > 0 {
+			s.mismatch = true
+			return 0, errSizeMismatch
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.n {
+		p = p[:s.n]
+	}
+	n, err := s.r.Read(p)
+	s.n -= int64(n)
+	if err == io.EOF {
+		if s.n > 0 {
+			s.mismatch = true
+			return n, errSizeMismatch
+		}
+		err = nil
+	}
+	return n, err
+}
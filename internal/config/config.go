@@ -0,0 +1,355 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gochatbot/internal/ratelimit"
+	"gochatbot/internal/validate"
+)
+
+// Config is the whole runtime configuration. Every leaf field carries:
+//   - config: its key within the parent section (file keys and flag names)
+//   - env:    the environment variable that overrides it
+//   - secret: redacted when the config is printed
+//
+// Precedence, lowest to highest: defaults, config file, environment, flags.
+type Config struct {
+	HTTP      HTTPConfig      `config:"http"`
+	Database  DatabaseConfig  `config:"database"`
+	Tracing   TracingConfig   `config:"tracing"`
+	Worker    WorkerConfig    `config:"worker"`
+	SMTP      SMTPConfig      `config:"smtp"`
+	Auth      AuthConfig      `config:"auth"`
+	Storage   StorageConfig   `config:"storage"`
+	Legacy    LegacyConfig    `config:"legacy"`
+	Shadow    ShadowConfig    `config:"shadow"`
+	Reconcile ReconcileConfig `config:"reconcile"`
+	RateLimit RateLimitConfig `config:"ratelimit"`
+	LLM       LLMConfig       `config:"llm"`
+	Webhooks  WebhooksConfig  `config:"webhooks"`
+}
+
+type HTTPConfig struct {
+	Addr                  string        `config:"addr" env:"ADDR" usage:"listen address"`
+	ReadHeaderTimeout     time.Duration `config:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"max time to read request headers"`
+	ShutdownTimeout       time.Duration `config:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" usage:"max time to drain requests and workers on SIGTERM"`
+	IdempotencyTTL        time.Duration `config:"idempotency_ttl" env:"HTTP_IDEMPOTENCY_TTL" usage:"how long an Idempotency-Key and its response are kept"`
+	CursorSecret          string        `config:"cursor_secret" env:"HTTP_CURSOR_SECRET" secret:"true" usage:"HMAC key for list cursors; shared by all replicas, empty picks a random one per process"`
+	LegacyCursors         bool          `config:"legacy_cursors" env:"HTTP_LEGACY_CURSORS" usage:"still accept unsigned cursors issued before signing"`
+	PublishWithoutIfMatch bool          `config:"publish_without_if_match" env:"HTTP_PUBLISH_WITHOUT_IF_MATCH" usage:"still accept template publishes without If-Match, for clients written before ETags"`
+	SSEHeartbeat          time.Duration `config:"sse_heartbeat" env:"HTTP_SSE_HEARTBEAT" usage:"interval of keep-alive comments on idle event streams and pings on chat sockets"`
+}
+
+type DatabaseConfig struct {
+	URL         string `config:"url" env:"DATABASE_URL" secret:"true" usage:"Postgres connection string"`
+	MaxConns    int    `config:"max_conns" env:"DATABASE_MAX_CONNS" usage:"pgxpool max connections"`
+	AutoMigrate bool   `config:"auto_migrate" env:"DATABASE_AUTO_MIGRATE" usage:"apply pending migrations on startup"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `config:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"none, stdout or otlp"`
+	Endpoint    string  `config:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP collector host:port or URL"`
+	Insecure    bool    `config:"insecure" env:"OTEL_EXPORTER_OTLP_INSECURE" usage:"disable TLS to the collector"`
+	ServiceName string  `config:"service_name" env:"OTEL_SERVICE_NAME" usage:"service.name resource attribute"`
+	SampleRatio float64 `config:"sample_ratio" env:"OTEL_TRACES_SAMPLE_RATIO" usage:"fraction of traces sampled (0 = all)"`
+}
+
+type WorkerConfig struct {
+	Enabled      bool          `config:"enabled" env:"WORKER_ENABLED" usage:"run the job worker in this process"`
+	PollInterval time.Duration `config:"poll_interval" env:"WORKER_POLL_INTERVAL" usage:"idle poll interval"`
+	JobTimeout   time.Duration `config:"job_timeout" env:"WORKER_JOB_TIMEOUT" usage:"max runtime per job attempt"`
+}
+
+type SMTPConfig struct {
+	Host     string `config:"host" env:"SMTP_HOST" usage:"SMTP server; empty disables email"`
+	Port     int    `config:"port" env:"SMTP_PORT" usage:"SMTP port"`
+	Username string `config:"username" env:"SMTP_USERNAME" usage:"SMTP auth user"`
+	Password string `config:"password" env:"SMTP_PASSWORD" secret:"true" usage:"SMTP auth password"`
+	From     string `config:"from" env:"SMTP_FROM" usage:"envelope sender address"`
+}
+
+type AuthConfig struct {
+	APIKeys []string `config:"api_keys" env:"AUTH_API_KEYS" secret:"true" usage:"comma-separated API keys"`
+}
+
+type StorageConfig struct {
+	Backend     string `config:"backend" env:"STORAGE_BACKEND" usage:"local or s3"`
+	LocalDir    string `config:"local_dir" env:"STORAGE_LOCAL_DIR" usage:"root directory for the local backend"`
+	S3Endpoint  string `config:"s3_endpoint" env:"STORAGE_S3_ENDPOINT" usage:"S3-compatible endpoint URL"`
+	S3Bucket    string `config:"s3_bucket" env:"STORAGE_S3_BUCKET" usage:"bucket name"`
+	S3Region    string `config:"s3_region" env:"STORAGE_S3_REGION" usage:"bucket region"`
+	S3AccessKey string `config:"s3_access_key" env:"STORAGE_S3_ACCESS_KEY" usage:"access key id"`
+	S3SecretKey string `config:"s3_secret_key" env:"STORAGE_S3_SECRET_KEY" secret:"true" usage:"secret access key"`
+
+	MaxUploadBytes int           `config:"max_upload_bytes" env:"STORAGE_MAX_UPLOAD_BYTES" usage:"largest file a session may upload"`
+	AllowedTypes   []string      `config:"allowed_types" env:"STORAGE_ALLOWED_TYPES" usage:"comma-separated MIME types accepted for uploads, checked against the content"`
+	URLSecret      string        `config:"url_secret" env:"STORAGE_URL_SECRET" secret:"true" usage:"HMAC key for download links; shared by all replicas, empty picks a random one per process"`
+	URLTTL         time.Duration `config:"url_ttl" env:"STORAGE_URL_TTL" usage:"how long a download link stays valid"`
+	PublicURL      string        `config:"public_url" env:"STORAGE_PUBLIC_URL" usage:"base URL download links start with, e.g. https://api.example.com; empty gives relative links"`
+}
+
+// LegacyConfig drives the per-route cutover to the Node backend (Migration.md).
+type LegacyConfig struct {
+	URL            string        `config:"url" env:"LEGACY_URL" usage:"legacy Node base URL; empty serves every route from Go"`
+	RoutesFile     string        `config:"routes_file" env:"LEGACY_ROUTES_FILE" usage:"YAML table of per-route go/legacy/shadow modes, reloaded on change and SIGHUP"`
+	ReloadInterval time.Duration `config:"reload_interval" env:"LEGACY_RELOAD_INTERVAL" usage:"how often routes_file is checked for changes"`
+}
+
+// ShadowConfig tunes routes in shadow mode; it has no effect without legacy.url.
+type ShadowConfig struct {
+	IgnorePaths []string      `config:"ignore_paths" env:"SHADOW_IGNORE_PATHS" usage:"comma-separated JSON paths excluded from comparison"`
+	Timeout     time.Duration `config:"timeout" env:"SHADOW_TIMEOUT" usage:"max time for a legacy request"`
+	MaxInFlight int           `config:"max_in_flight" env:"SHADOW_MAX_IN_FLIGHT" usage:"concurrent comparisons before new ones are dropped"`
+}
+
+// ReconcileConfig points the dual-write detector at a legacy data source;
+// set at most one of LegacyDSN and LegacyExport.
+type ReconcileConfig struct {
+	LegacyDSN    string        `config:"legacy_dsn" env:"RECONCILE_LEGACY_DSN" secret:"true" usage:"legacy Postgres with the tenants/templates schema"`
+	LegacyExport string        `config:"legacy_export" env:"RECONCILE_LEGACY_EXPORT" usage:"legacy JSON export file, instead of legacy_dsn"`
+	Interval     time.Duration `config:"interval" env:"RECONCILE_INTERVAL" usage:"how often a run is queued; 0 runs only on demand"`
+}
+
+// RateLimitConfig throttles /v1 by route group (tenants, templates, sessions,
+// reconciliation) and dimension (key, tenant, ip); see ratelimit.ParseRule.
+type RateLimitConfig struct {
+	Backend        string   `config:"backend" env:"RATELIMIT_BACKEND" usage:"memory (per replica) or postgres (shared)"`
+	Rules          []string `config:"rules" env:"RATELIMIT_RULES" usage:"comma-separated group.dimension=count/period[/burst] limits, e.g. *.ip=600/1m/100; empty disables limiting"`
+	TrustForwarded bool     `config:"trust_forwarded" env:"RATELIMIT_TRUST_FORWARDED" usage:"take the client IP from the last X-Forwarded-For hop (only behind a proxy)"`
+}
+
+// LLMConfig picks the model that writes assistant replies.
+type LLMConfig struct {
+	Provider string        `config:"provider" env:"LLM_PROVIDER" usage:"fake (echoes the user, no network) or openai (any OpenAI-compatible API)"`
+	BaseURL  string        `config:"base_url" env:"LLM_BASE_URL" usage:"API root for the openai provider"`
+	APIKey   string        `config:"api_key" env:"LLM_API_KEY" secret:"true" usage:"bearer token for the openai provider; empty sends none"`
+	Model    string        `config:"model" env:"LLM_MODEL" usage:"model name for the openai provider"`
+	Timeout  time.Duration `config:"timeout" env:"LLM_TIMEOUT" usage:"max time for one completion"`
+	// ContextTokens should leave the model room for tool schemas and the reply.
+	ContextTokens int `config:"context_tokens" env:"LLM_CONTEXT_TOKENS" usage:"token budget for a reply prompt's messages; older turns beyond it are summarized"`
+	RecentTurns   int `config:"recent_turns" env:"LLM_RECENT_TURNS" usage:"latest turns always sent whole, never summarized"`
+}
+
+// WebhooksConfig tunes delivery of tenants' outbound webhooks.
+type WebhooksConfig struct {
+	Timeout time.Duration `config:"timeout" env:"WEBHOOKS_TIMEOUT" usage:"max time for one delivery attempt, connect to response"`
+	// AllowPrivate is for development only: it lets tenants reach our own network.
+	AllowPrivate bool `config:"allow_private" env:"WEBHOOKS_ALLOW_PRIVATE" usage:"accept http URLs and private or loopback addresses (development only)"`
+}
+
+// Enabled reports whether a legacy source is configured.
+func (c ReconcileConfig) Enabled() bool {
+	return c.LegacyDSN != "" || c.LegacyExport != ""
+}
+
+func Defaults() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+			IdempotencyTTL:    24 * time.Hour,
+			SSEHeartbeat:      15 * time.Second,
+		},
+		Database: DatabaseConfig{MaxConns: 10},
+		Tracing:  TracingConfig{Exporter: "none", ServiceName: "gochatbot"},
+		Worker: WorkerConfig{
+			Enabled:      true,
+			PollInterval: time.Second,
+			JobTimeout:   2 * time.Minute,
+		},
+		SMTP: SMTPConfig{Port: 587},
+		Storage: StorageConfig{
+			Backend:        "local",
+			LocalDir:       "./data/uploads",
+			MaxUploadBytes: 10 << 20,
+			AllowedTypes:   []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"},
+			URLTTL:         7 * 24 * time.Hour,
+		},
+		Legacy: LegacyConfig{ReloadInterval: 5 * time.Second},
+		Shadow: ShadowConfig{
+			Timeout:     5 * time.Second,
+			MaxInFlight: 32,
+		},
+		Reconcile: ReconcileConfig{Interval: time.Hour},
+		RateLimit: RateLimitConfig{Backend: "memory"},
+		LLM: LLMConfig{
+			Provider: "fake",
+			BaseURL:  "https://api.openai.com/v1",
+			Timeout:  time.Minute,
+
+			ContextTokens: 12000,
+			RecentTurns:   4,
+		},
+		Webhooks: WebhooksConfig{Timeout: 10 * time.Second},
+	}
+}
+
+// Validate reports every problem at once so a bad deploy is fixed in one pass.
+func (c Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if strings.TrimSpace(c.HTTP.Addr) == "" {
+		bad("http.addr", "required")
+	}
+	if c.HTTP.ReadHeaderTimeout <= 0 {
+		bad("http.read_header_timeout", "must be positive")
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		bad("http.shutdown_timeout", "must be positive")
+	}
+	if c.HTTP.IdempotencyTTL <= 0 {
+		bad("http.idempotency_ttl", "must be positive")
+	}
+	if c.HTTP.SSEHeartbeat <= 0 {
+		bad("http.sse_heartbeat", "must be positive")
+	}
+	if c.HTTP.CursorSecret != "" && len(c.HTTP.CursorSecret) < 32 {
+		bad("http.cursor_secret", "must be at least 32 characters")
+	}
+
+	if strings.TrimSpace(c.Database.URL) == "" {
+		bad("database.url", "required")
+	}
+	if c.Database.MaxConns < 1 {
+		bad("database.max_conns", "must be at least 1")
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "otlp":
+	default:
+		bad("tracing.exporter", "must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		bad("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	if c.Worker.PollInterval <= 0 {
+		bad("worker.poll_interval", "must be positive")
+	}
+	if c.Worker.JobTimeout <= 0 {
+		bad("worker.job_timeout", "must be positive")
+	}
+
+	if c.SMTP.Host != "" {
+		if c.SMTP.Port < 1 || c.SMTP.Port > 65535 {
+			bad("smtp.port", "must be 1-65535")
+		}
+		if _, err := validate.NormalizeEmail(c.SMTP.From); err != nil {
+			bad("smtp.from", "must be a valid email when smtp.host is set")
+		}
+	}
+
+	for i, k := range c.Auth.APIKeys {
+		if len(strings.TrimSpace(k)) < 16 {
+			bad("auth.api_keys", "key #%d is shorter than 16 characters", i+1)
+		}
+	}
+
+	switch c.Storage.Backend {
+	case "local":
+		if strings.TrimSpace(c.Storage.LocalDir) == "" {
+			bad("storage.local_dir", "required for the local backend")
+		}
+	case "s3":
+		if c.Storage.S3Endpoint == "" {
+			bad("storage.s3_endpoint", "required for the s3 backend")
+		}
+		if c.Storage.S3Bucket == "" {
+			bad("storage.s3_bucket", "required for the s3 backend")
+		}
+	default:
+		bad("storage.backend", "must be local or s3, got %q", c.Storage.Backend)
+	}
+	if c.Storage.MaxUploadBytes < 1 {
+		bad("storage.max_upload_bytes", "must be at least 1, got %d", c.Storage.MaxUploadBytes)
+	}
+	if len(c.Storage.AllowedTypes) == 0 {
+		bad("storage.allowed_types", "required")
+	}
+	if c.Storage.URLTTL <= 0 {
+		bad("storage.url_ttl", "must be positive")
+	}
+	if c.Storage.PublicURL != "" {
+		u, err := url.Parse(c.Storage.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			bad("storage.public_url", "must be an absolute http(s) URL")
+		}
+	}
+
+	if c.Legacy.URL != "" {
+		u, err := url.Parse(c.Legacy.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			bad("legacy.url", "must be an absolute http(s) URL")
+		}
+		if c.Legacy.ReloadInterval <= 0 {
+			bad("legacy.reload_interval", "must be positive")
+		}
+		if c.Shadow.Timeout <= 0 {
+			bad("shadow.timeout", "must be positive")
+		}
+		if c.Shadow.MaxInFlight < 1 {
+			bad("shadow.max_in_flight", "must be at least 1")
+		}
+	} else if c.Legacy.RoutesFile != "" {
+		bad("legacy.routes_file", "requires legacy.url")
+	}
+
+	if c.Reconcile.LegacyDSN != "" && c.Reconcile.LegacyExport != "" {
+		bad("reconcile.legacy_export", "set either reconcile.legacy_dsn or reconcile.legacy_export, not both")
+	}
+	if c.Reconcile.Interval < 0 {
+		bad("reconcile.interval", "must not be negative")
+	}
+
+	switch c.RateLimit.Backend {
+	case "memory", "postgres":
+	default:
+		bad("ratelimit.backend", "must be memory or postgres, got %q", c.RateLimit.Backend)
+	}
+	if rules, err := ratelimit.ParseRules(c.RateLimit.Rules); err != nil {
+		bad("ratelimit.rules", "%v", err)
+	} else if len(c.Auth.APIKeys) == 0 {
+		for _, r := range rules {
+			if r.Dimension == ratelimit.DimKey {
+				bad("ratelimit.rules", "%s.key needs auth.api_keys: only configured keys are counted", r.Group)
+			}
+		}
+	}
+
+	switch c.LLM.Provider {
+	case "fake":
+	case "openai":
+		u, err := url.Parse(c.LLM.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			bad("llm.base_url", "must be an absolute http(s) URL")
+		}
+		if strings.TrimSpace(c.LLM.Model) == "" {
+			bad("llm.model", "required for the openai provider")
+		}
+	default:
+		bad("llm.provider", "must be fake or openai, got %q", c.LLM.Provider)
+	}
+	if c.LLM.Timeout <= 0 {
+		bad("llm.timeout", "must be positive")
+	}
+	if c.LLM.ContextTokens < 1000 {
+		bad("llm.context_tokens", "must be at least 1000, got %d", c.LLM.ContextTokens)
+	}
+	if c.LLM.RecentTurns < 1 {
+		bad("llm.recent_turns", "must be at least 1, got %d", c.LLM.RecentTurns)
+	}
+
+	if c.Webhooks.Timeout <= 0 {
+		bad("webhooks.timeout", "must be positive")
+	}
+
+	return errors.Join(errs...)
+}
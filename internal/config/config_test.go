@@ -0,0 +1,213 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/config"
+)
+
+func env(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func writeFile(t *testing.T, name, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, []byte(body), 0o600))
+	return p
+}
+
+func TestLoad_DefaultsPlusRequiredEnv(t *testing.T) {
+	cfg, _, err := config.Load(nil, env(map[string]string{"DATABASE_URL": "postgres://x"}))
+	require.NoError(t, err)
+	require.Equal(t, ":8080", cfg.HTTP.Addr)
+	require.Equal(t, 30*time.Second, cfg.HTTP.ShutdownTimeout)
+	require.Equal(t, "postgres://x", cfg.Database.URL)
+	require.False(t, cfg.HTTP.LegacyCursors, "unsigned cursors are opt-in")
+}
+
+func TestLoad_Precedence_FileThenEnvThenFlags(t *testing.T) {
+	path := writeFile(t, "app.yaml", `
+http:
+  addr: ":7000"
+  shutdown_timeout: 5s
+database:
+  url: postgres://file
+  max_conns: 4
+`)
+	cfg, opts, err := config.Load(
+		[]string{"-config", path, "-database.max_conns", "8"},
+		env(map[string]string{"ADDR": ":9000"}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, path, opts.File)
+	require.Equal(t, ":9000", cfg.HTTP.Addr)                  // env beats file
+	require.Equal(t, 5*time.Second, cfg.HTTP.ShutdownTimeout) // file beats default
+	require.Equal(t, "postgres://file", cfg.Database.URL)
+	require.Equal(t, 8, cfg.Database.MaxConns) // flag beats file
+}
+
+func TestLoad_LeavesPositionalArgs(t *testing.T) {
+	_, opts, err := config.Load(
+		[]string{"-database.url", "postgres://x", "tenants", "list", "-o", "json"},
+		env(nil),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenants", "list", "-o", "json"}, opts.Args)
+}
+
+func TestLoad_TOMLFile(t *testing.T) {
+	path := writeFile(t, "app.toml", `
+[database]
+url = "postgres://toml"
+
+[auth]
+api_keys = ["0123456789abcdef", "fedcba9876543210"]
+`)
+	cfg, _, err := config.Load([]string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, "postgres://toml", cfg.Database.URL)
+	require.Len(t, cfg.Auth.APIKeys, 2)
+}
+
+func TestLoad_FileNullsAreUnsetAndListsKeepCommas(t *testing.T) {
+	path := writeFile(t, "app.yaml", `
+database:
+  url: postgres://file
+smtp:
+  host:
+legacy:
+  url:
+storage:
+  allowed_types:
+    - "text/plain; charset=utf-8, or not"
+    - image/png
+`)
+	cfg, _, err := config.Load([]string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	require.Empty(t, cfg.SMTP.Host)
+	require.Empty(t, cfg.Legacy.URL)
+	require.Equal(t, []string{"text/plain; charset=utf-8, or not", "image/png"}, cfg.Storage.AllowedTypes)
+}
+
+func TestLoad_ReportsAllErrorsAtOnce(t *testing.T) {
+	path := writeFile(t, "app.yaml", `
+http:
+  bogus: 1
+tracing:
+  exporter: carrier-pigeon
+`)
+	_, _, err := config.Load(
+		[]string{"-config", path, "-worker.poll_interval", "soon"},
+		env(map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "nope"}),
+	)
+	require.Error(t, err)
+	msg := err.Error()
+	require.Contains(t, msg, "http.bogus (file): unknown key")
+	require.Contains(t, msg, "worker.poll_interval (flag)")
+	require.Contains(t, msg, "database.url: required")
+	require.Contains(t, msg, "tracing.exporter")
+	require.Contains(t, msg, "smtp.from")
+}
+
+func TestWriteRedacted_MasksSecrets(t *testing.T) {
+	cfg, _, err := config.Load(nil, env(map[string]string{
+		"DATABASE_URL":  "postgres://user:hunter2@db/app",
+		"SMTP_PASSWORD": "hunter3",
+	}))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.WriteRedacted(&buf))
+	out := buf.String()
+	require.NotContains(t, out, "hunter2")
+	require.NotContains(t, out, "hunter3")
+	require.Contains(t, out, "url: REDACTED")
+	require.Contains(t, out, "addr: :8080")
+}
+
+func TestValidate_RoutesFileNeedsLegacyURL(t *testing.T) {
+	_, _, err := config.Load(nil, env(map[string]string{
+		"DATABASE_URL":       "postgres://x",
+		"LEGACY_ROUTES_FILE": "routes.yaml",
+	}))
+	require.ErrorContains(t, err, "legacy.routes_file: requires legacy.url")
+
+	cfg, _, err := config.Load(nil, env(map[string]string{
+		"DATABASE_URL":       "postgres://x",
+		"LEGACY_URL":         "http://node:3000",
+		"LEGACY_ROUTES_FILE": "routes.yaml",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, cfg.Legacy.ReloadInterval)
+}
+
+func TestValidate_RateLimitRules(t *testing.T) {
+	cfg, _, err := config.Load(nil, env(map[string]string{
+		"DATABASE_URL":      "postgres://x",
+		"RATELIMIT_BACKEND": "postgres",
+		"RATELIMIT_RULES":   "*.ip=600/1m/100, tenants.key=60/1s",
+		"AUTH_API_KEYS":     "0123456789abcdef",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"*.ip=600/1m/100", "tenants.key=60/1s"}, cfg.RateLimit.Rules)
+
+	_, _, err = config.Load(nil, env(map[string]string{
+		"DATABASE_URL":      "postgres://x",
+		"RATELIMIT_BACKEND": "redis",
+		"RATELIMIT_RULES":   "tenants.user=1/1s",
+	}))
+	require.ErrorContains(t, err, "ratelimit.backend: must be memory or postgres")
+	require.ErrorContains(t, err, "dimension must be key, tenant or ip")
+
+	_, _, err = config.Load(nil, env(map[string]string{
+		"DATABASE_URL":    "postgres://x",
+		"RATELIMIT_RULES": "tenants.key=60/1s",
+	}))
+	require.ErrorContains(t, err, "ratelimit.rules: tenants.key needs auth.api_keys")
+}
+
+func TestValidate_LLM(t *testing.T) {
+	cfg, _, err := config.Load(nil, env(map[string]string{"DATABASE_URL": "postgres://x"}))
+	require.NoError(t, err)
+	require.Equal(t, "fake", cfg.LLM.Provider)
+
+	_, _, err = config.Load(nil, env(map[string]string{
+		"DATABASE_URL": "postgres://x",
+		"LLM_PROVIDER": "openai",
+		"LLM_BASE_URL": "localhost:11434",
+	}))
+	require.ErrorContains(t, err, "llm.base_url: must be an absolute http(s) URL")
+	require.ErrorContains(t, err, "llm.model: required for the openai provider")
+
+	_, _, err = config.Load(nil, env(map[string]string{
+		"DATABASE_URL":       "postgres://x",
+		"LLM_CONTEXT_TOKENS": "500",
+		"LLM_RECENT_TURNS":   "0",
+	}))
+	require.ErrorContains(t, err, "llm.context_tokens: must be at least 1000, got 500")
+	require.ErrorContains(t, err, "llm.recent_turns: must be at least 1, got 0")
+}
+
+func TestValidate_Storage(t *testing.T) {
+	cfg, _, err := config.Load(nil, env(map[string]string{"DATABASE_URL": "postgres://x"}))
+	require.NoError(t, err)
+	require.Equal(t, 10<<20, cfg.Storage.MaxUploadBytes)
+	require.Contains(t, cfg.Storage.AllowedTypes, "application/pdf")
+
+	_, _, err = config.Load(nil, env(map[string]string{
+		"DATABASE_URL":             "postgres://x",
+		"STORAGE_MAX_UPLOAD_BYTES": "0",
+		"STORAGE_URL_TTL":          "0s",
+		"STORAGE_PUBLIC_URL":       "api.example.com",
+	}))
+	require.ErrorContains(t, err, "storage.max_upload_bytes: must be at least 1, got 0")
+	require.ErrorContains(t, err, "storage.url_ttl: must be positive")
+	require.ErrorContains(t, err, "storage.public_url: must be an absolute http(s) URL")
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the command-line switches that steer loading itself rather
+// than setting a config value.
+type Options struct {
+	File        string
+	PrintConfig bool
+	// Args are the positional arguments left after the flags, for binaries
+	// that take subcommands after the config flags.
+	Args []string
+}
+
+// field is one leaf of Config, addressed by its dotted key ("http.addr").
+type field struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	v      reflect.Value
+}
+
+func fieldsOf(cfg *Config) []field {
+	var out []field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := sf.Tag.Get("config")
+			if key == "" {
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			fv := v.Field(i)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(key, fv)
+				continue
+			}
+			out = append(out, field{
+				key:    key,
+				env:    sf.Tag.Get("env"),
+				usage:  sf.Tag.Get("usage"),
+				secret: sf.Tag.Get("secret") == "true",
+				v:      fv,
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, p := range strings.Split(raw, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				items = append(items, p)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = v.Index(i).String()
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Load builds the effective config from defaults, an optional file, the
+// environment and args (flags). Every problem found along the way, including
+// validation, is returned together.
+func Load(args []string, getenv func(string) string) (Config, Options, error) {
+	cfg := Defaults()
+	fields := fieldsOf(&cfg)
+
+	fs := flag.NewFlagSet("gochatbot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var opts Options
+	fs.StringVar(&opts.File, "config", getenv("CONFIG_FILE"), "path to a .yaml/.yml/.toml config file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective config (secrets redacted) and exit")
+	flagVals := map[string]*string{}
+	for _, f := range fields {
+		flagVals[f.key] = fs.String(f.key, "", f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return Config{}, opts, err
+	}
+	opts.Args = fs.Args()
+
+	var errs []error
+
+	if opts.File != "" {
+		if err := applyFile(fields, opts.File); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := lookup(getenv, f.env); ok {
+			if err := setValue(f.v, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s (env %s): %w", f.key, f.env, err))
+			}
+		}
+	}
+
+	byKey := map[string]field{}
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		f, ok := byKey[fl.Name]
+		if !ok {
+			return
+		}
+		if err := setValue(f.v, *flagVals[fl.Name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s (flag): %w", f.key, err))
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, opts, errors.Join(errs...)
+}
+
+// lookup treats an empty variable as unset, matching how the old ad-hoc
+// os.Getenv checks in main behaved.
+func lookup(getenv func(string) string, name string) (string, bool) {
+	v := getenv(name)
+	return v, v != ""
+}
+
+func applyFile(fields []field, path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &raw)
+	case ".toml":
+		err = toml.Unmarshal(body, &raw)
+	default:
+		return fmt.Errorf("config file: unsupported extension %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	flat := map[string]any{}
+	flatten("", raw, flat)
+
+	byKey := map[string]field{}
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+
+	var errs []error
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f, ok := byKey[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s (file): unknown key", k))
+			continue
+		}
+		if flat[k] == nil {
+			continue // "key:" with no value leaves the key unset
+		}
+		if err := setFileValue(f.v, flat[k]); err != nil {
+			errs = append(errs, fmt.Errorf("%s (file): %w", k, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func flatten(prefix string, in map[string]any, out map[string]any) {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if m, ok := v.(map[string]any); ok {
+			flatten(key, m, out)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// setFileValue sets a decoded YAML/TOML value. Scalars go back to the string
+// form the env and flag layers use, so all three layers share one parser;
+// lists are set item by item, so an item may contain a comma.
+func setFileValue(v reflect.Value, val any) error {
+	list, ok := val.([]any)
+	if !ok {
+		return setValue(v, fmt.Sprint(val))
+	}
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.String {
+		return fmt.Errorf("want a single value, got a list")
+	}
+	var items []string
+	for _, item := range list {
+		if item == nil {
+			continue
+		}
+		if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+			items = append(items, s)
+		}
+	}
+	v.Set(reflect.ValueOf(items))
+	return nil
+}
+
+const redacted = "REDACTED"
+
+// WriteRedacted prints the effective config as YAML with secrets masked.
+func (c Config) WriteRedacted(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{}
+
+	for _, f := range fieldsOf(&c) {
+		section, leaf, _ := strings.Cut(f.key, ".")
+		node, ok := sections[section]
+		if !ok {
+			node = &yaml.Node{Kind: yaml.MappingNode}
+			sections[section] = node
+			root.Content = append(root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: section}, node)
+		}
+
+		val := formatValue(f.v)
+		if f.secret && val != "" {
+			val = redacted
+		}
+		valNode := &yaml.Node{Kind: yaml.ScalarNode, Value: val}
+		if val == "" {
+			valNode.Style = yaml.DoubleQuotedStyle
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: leaf}, valNode)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
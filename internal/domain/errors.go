@@ -7,6 +7,7 @@ var (
 	ErrInvalidPhone            = errors.New("invalid phone")
 	ErrInvalidColor            = errors.New("invalid color")
 	ErrInvalidSlug             = errors.New("invalid slug")
+	ErrInvalidName             = errors.New("invalid name")
 	ErrUnknownEmailProfile     = errors.New("unknown email profile")
 	ErrTemplateVersionNotFound = errors.New("template version not found")
 	ErrSessionNotFound         = errors.New("session not found")
@@ -17,6 +18,9 @@ var (
 	ErrTenantNotFound          = errors.New("tenant not found")
 	ErrTenantSlugTaken         = errors.New("tenant slug taken")
 
+	// Optimistic concurrency: the row changed since the client read it
+	ErrRevisionMismatch = errors.New("revision mismatch")
+
 	// Templates
 	ErrTemplateNotFound  = errors.New("template not found")
 	ErrTemplateSlugTaken = errors.New("template slug taken")
@@ -24,6 +28,32 @@ var (
 
 	// Template Versions
 	ErrVersionNotFound           = errors.New("version not found")
+	ErrInvalidVersion            = errors.New("invalid version")
 	ErrVersionAlreadyPublished   = errors.New("version already published")
 	ErrPublishedVersionImmutable = errors.New("published version immutable")
+
+	// Jobs
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobNotRetryable = errors.New("job not retryable")
+
+	// Leads
+	ErrLeadNotFound      = errors.New("lead not found")
+	ErrInvalidLeadStatus = errors.New("invalid lead status")
+
+	// Webhooks
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrInvalidWebhookURL   = errors.New("invalid webhook url")
+	ErrInvalidWebhookEvent = errors.New("invalid webhook event")
+	ErrDeliveryNotFound    = errors.New("webhook delivery not found")
+	ErrDeliveryPending     = errors.New("webhook delivery pending")
+
+	// Uploads
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadTooLarge       = errors.New("upload too large")
+	ErrUploadTypeNotAllowed = errors.New("upload type not allowed")
+	ErrEmptyUpload          = errors.New("empty upload")
+	ErrInvalidDownloadLink  = errors.New("invalid or expired download link")
+
+	// Reconciliation
+	ErrReconcileRunNotFound = errors.New("reconciliation run not found")
 )
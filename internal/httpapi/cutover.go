@@ -0,0 +1,302 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+
+	"gochatbot/internal/shadow"
+)
+
+// RouteMode decides who answers a route during the Node → Go cutover.
+type RouteMode string
+
+const (
+	ModeGo     RouteMode = "go"     // Go handler only
+	ModeLegacy RouteMode = "legacy" // reverse-proxied to Node
+	ModeShadow RouteMode = "shadow" // Go answers; GETs are replayed to Node and compared
+)
+
+func (m RouteMode) valid() bool {
+	return m == ModeGo || m == ModeLegacy || m == ModeShadow
+}
+
+// RouteModes is the cutover table. Keys are chi route patterns, optionally
+// prefixed with a method ("GET /v1/tenants/{tenantSlug}"). A method-specific
+// key wins over a bare pattern; Default covers every other route.
+type RouteModes struct {
+	Default RouteMode            `yaml:"default"`
+	Routes  map[string]RouteMode `yaml:"routes"`
+}
+
+func (m RouteModes) Mode(method, pattern string) RouteMode {
+	if mode, ok := m.Routes[method+" "+pattern]; ok {
+		return mode
+	}
+	if mode, ok := m.Routes[pattern]; ok {
+		return mode
+	}
+	if m.Default == "" {
+		return ModeGo
+	}
+	return m.Default
+}
+
+func (m RouteModes) Validate() error {
+	if m.Default != "" && !m.Default.valid() {
+		return fmt.Errorf("default: unknown mode %q", m.Default)
+	}
+	for key, mode := range m.Routes {
+		if !mode.valid() {
+			return fmt.Errorf("%s: unknown mode %q", key, mode)
+		}
+		pattern := key
+		if method, rest, ok := strings.Cut(key, " "); ok {
+			if method != strings.ToUpper(method) {
+				return fmt.Errorf("%s: method must be upper case", key)
+			}
+			pattern = rest
+		}
+		if !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("%s: pattern must start with /", key)
+		}
+	}
+	return nil
+}
+
+// flat lists every entry, "default" included, for logging and metrics.
+func (m RouteModes) flat() map[string]RouteMode {
+	def := m.Default
+	if def == "" {
+		def = ModeGo
+	}
+	out := map[string]RouteMode{"default": def}
+	for k, v := range m.Routes {
+		out[k] = v
+	}
+	return out
+}
+
+// ParseRouteModes reads a YAML (or JSON) cutover table:
+//
+//	default: go
+//	routes:
+//	  GET /v1/tenants/{tenantSlug}: shadow
+//	  /v1/tenants/{tenantSlug}/templates: legacy
+func ParseRouteModes(data []byte) (RouteModes, error) {
+	var m RouteModes
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return RouteModes{}, err
+	}
+	return m, m.Validate()
+}
+
+func LoadRouteModes(path string) (RouteModes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RouteModes{}, err
+	}
+	m, err := ParseRouteModes(data)
+	if err != nil {
+		return RouteModes{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// CutoverObserver sees the current mode of every table entry ("" once an
+// entry is removed) and each routed request; *metrics.Metrics satisfies it.
+type CutoverObserver interface {
+	RouteMode(route, mode string)
+	CutoverServed(route, mode string)
+}
+
+type nopCutoverObserver struct{}
+
+func (nopCutoverObserver) RouteMode(string, string)     {}
+func (nopCutoverObserver) CutoverServed(string, string) {}
+
+// Cutover routes each request to Go, Node or both according to a RouteModes
+// table that can be swapped at runtime, so rolling a route back to Node is a
+// config change rather than a redeploy.
+type Cutover struct {
+	modes  atomic.Pointer[RouteModes]
+	proxy  *httputil.ReverseProxy
+	shadow *shadow.Comparator
+	obs    CutoverObserver
+
+	mu sync.Mutex // serializes Set
+}
+
+// NewCutover starts with every route on Go.
+func NewCutover(legacyURL string) (*Cutover, error) {
+	base, err := url.Parse(legacyURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("cutover: legacy url must be an absolute http(s) URL, got %q", legacyURL)
+	}
+
+	c := &Cutover{obs: nopCutoverObserver{}}
+	c.modes.Store(&RouteModes{Default: ModeGo})
+	c.proxy = &httputil.ReverseProxy{
+		// Rewrite keeps the client's headers (X-Request-ID included) and
+		// strips only hop-by-hop ones.
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(base)
+			pr.SetXForwarded()
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// ours is already on the response; don't send two
+			resp.Header.Del(HeaderRequestID)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("cutover: legacy %s %s: %v", r.Method, r.URL.Path, err)
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": "legacy unavailable"})
+		},
+	}
+	return c, nil
+}
+
+// WithShadow enables shadow mode; without it, Set rejects shadow entries.
+func (c *Cutover) WithShadow(cmp *shadow.Comparator) *Cutover {
+	if cmp != nil {
+		c.shadow = cmp
+	}
+	return c
+}
+
+func (c *Cutover) WithObserver(obs CutoverObserver) *Cutover {
+	if obs != nil {
+		c.obs = obs
+		for route, mode := range c.Modes().flat() {
+			obs.RouteMode(route, string(mode))
+		}
+	}
+	return c
+}
+
+func (c *Cutover) Modes() RouteModes {
+	return *c.modes.Load()
+}
+
+// Set swaps in a new table, logging every entry whose mode changed.
+func (c *Cutover) Set(next RouteModes) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	if c.shadow == nil {
+		for key, mode := range next.flat() {
+			if mode == ModeShadow {
+				return fmt.Errorf("%s: shadow mode needs a shadow comparator", key)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.Modes().flat()
+	cur := next.flat()
+	c.modes.Store(&next)
+
+	keys := make([]string, 0, len(prev)+len(cur))
+	for k := range prev {
+		keys = append(keys, k)
+	}
+	for k := range cur {
+		if _, ok := prev[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		was, now := prev[k], cur[k]
+		if was == now {
+			continue
+		}
+		log.Printf("cutover: %s: %s -> %s", k, orUnset(was), orUnset(now))
+		c.obs.RouteMode(k, string(now))
+	}
+	return nil
+}
+
+func orUnset(m RouteMode) string {
+	if m == "" {
+		return "(unset)"
+	}
+	return string(m)
+}
+
+// Reload reads path and applies it. A bad file leaves the current table alone.
+func (c *Cutover) Reload(path string) error {
+	m, err := LoadRouteModes(path)
+	if err != nil {
+		return err
+	}
+	return c.Set(m)
+}
+
+// Watch reloads path whenever its size or modification time changes, until
+// ctx is done. Errors are logged; the last good table stays in effect.
+func (c *Cutover) Watch(ctx context.Context, path string, interval time.Duration) {
+	var last os.FileInfo
+	if fi, err := os.Stat(path); err == nil {
+		last = fi
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			log.Printf("cutover: %v", err)
+			continue
+		}
+		if last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+			continue
+		}
+		last = fi
+		if err := c.Reload(path); err != nil {
+			log.Printf("cutover: reload: %v", err)
+		}
+	}
+}
+
+// wrap dispatches one Go handler by its current mode. It runs at the leaf of
+// the chi tree, where the full route pattern is known.
+func (c *Cutover) wrap(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		mode := c.Modes().Mode(r.Method, route)
+		c.obs.CutoverServed(route, string(mode))
+
+		switch mode {
+		case ModeLegacy:
+			c.proxy.ServeHTTP(w, r)
+		case ModeShadow:
+			c.shadow.Serve(w, r, route, h)
+		default:
+			h(w, r)
+		}
+	}
+}
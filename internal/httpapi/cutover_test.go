@@ -0,0 +1,199 @@
+package httpapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/metrics"
+	"gochatbot/internal/shadow"
+)
+
+// nodeStub stands in for the legacy backend and remembers the last request.
+type nodeStub struct {
+	mu   sync.Mutex
+	last *http.Request
+	body string
+}
+
+func (n *nodeStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	n.last = r
+	n.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Powered-By", "Express")
+	w.Header().Set(httpapi.HeaderRequestID, "node-made-this-up")
+	_, _ = w.Write([]byte(n.body))
+}
+
+func (n *nodeStub) lastRequest() *http.Request {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.last
+}
+
+func newCutover(t *testing.T, node *nodeStub) *httpapi.Cutover {
+	t.Helper()
+	srv := httptest.NewServer(node)
+	t.Cleanup(srv.Close)
+	cut, err := httpapi.NewCutover(srv.URL)
+	require.NoError(t, err)
+	return cut
+}
+
+func TestCutover_LegacyProxiesWithHeadersAndRequestID(t *testing.T) {
+	node := &nodeStub{body: `{"id":"n1","name":"Acme (node)","slug":"acme"}`}
+	cut := newCutover(t, node)
+	require.NoError(t, cut.Set(httpapi.RouteModes{Routes: map[string]httpapi.RouteMode{
+		"GET /v1/tenants/{tenantSlug}": httpapi.ModeLegacy,
+	}}))
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, Cutover: cut})
+
+	req := httptest.NewRequest("GET", "/v1/tenants/acme?expand=templates", nil)
+	req.Header.Set("Authorization", "Bearer k")
+	req.Header.Set(httpapi.HeaderRequestID, "req-42")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, node.body, rr.Body.String())
+	require.Equal(t, "Express", rr.Header().Get("X-Powered-By"))
+	require.Equal(t, []string{"req-42"}, rr.Header().Values(httpapi.HeaderRequestID))
+
+	got := node.lastRequest()
+	require.NotNil(t, got)
+	require.Equal(t, "/v1/tenants/acme", got.URL.Path)
+	require.Equal(t, "expand=templates", got.URL.RawQuery)
+	require.Equal(t, "Bearer k", got.Header.Get("Authorization"))
+	require.Equal(t, "req-42", got.Header.Get(httpapi.HeaderRequestID))
+	require.NotEmpty(t, got.Header.Get("X-Forwarded-For"))
+
+	// the rest of the tree still runs on Go
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"t1"`)
+}
+
+func TestCutover_SetSwitchesRoutesLive(t *testing.T) {
+	node := &nodeStub{body: `{"items":[],"next_cursor":""}`}
+	cut := newCutover(t, node)
+	m := metrics.New()
+	cut.WithObserver(m)
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, Metrics: m, Cutover: cut})
+
+	get := func() string {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+	require.Contains(t, get(), `"t1"`)
+
+	require.NoError(t, cut.Set(httpapi.RouteModes{Default: httpapi.ModeLegacy}))
+	require.JSONEq(t, node.body, get())
+
+	// rolling back is just another Set
+	require.NoError(t, cut.Set(httpapi.RouteModes{}))
+	require.Contains(t, get(), `"t1"`)
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+	require.Contains(t, body, `gochatbot_cutover_route_mode{mode="go",route="default"} 1`)
+	require.NotContains(t, body, `gochatbot_cutover_route_mode{mode="legacy"`)
+	require.Contains(t, body, `gochatbot_cutover_requests_total{mode="go",route="/v1/tenants"} 2`)
+	require.Contains(t, body, `gochatbot_cutover_requests_total{mode="legacy",route="/v1/tenants"} 1`)
+}
+
+func TestCutover_SetRejectsBadTables(t *testing.T) {
+	cut := newCutover(t, &nodeStub{})
+	require.NoError(t, cut.Set(httpapi.RouteModes{Default: httpapi.ModeLegacy}))
+
+	for _, m := range []httpapi.RouteModes{
+		{Default: "node"},
+		{Routes: map[string]httpapi.RouteMode{"/v1/tenants": "sideways"}},
+		{Routes: map[string]httpapi.RouteMode{"get /v1/tenants": httpapi.ModeGo}},
+		{Routes: map[string]httpapi.RouteMode{"v1/tenants": httpapi.ModeGo}},
+		// no comparator configured
+		{Routes: map[string]httpapi.RouteMode{"/v1/tenants": httpapi.ModeShadow}},
+	} {
+		require.Error(t, cut.Set(m), "%+v", m)
+	}
+	require.Equal(t, httpapi.ModeLegacy, cut.Modes().Default, "a rejected table must not replace the current one")
+}
+
+func TestRouteModes_MethodKeyWins(t *testing.T) {
+	m, err := httpapi.ParseRouteModes([]byte(`
+default: legacy
+routes:
+  /v1/tenants: shadow
+  POST /v1/tenants: go
+`))
+	require.NoError(t, err)
+	require.Equal(t, httpapi.ModeShadow, m.Mode("GET", "/v1/tenants"))
+	require.Equal(t, httpapi.ModeGo, m.Mode("POST", "/v1/tenants"))
+	require.Equal(t, httpapi.ModeLegacy, m.Mode("GET", "/v1/tenants/{tenantSlug}"))
+	require.Equal(t, httpapi.ModeGo, httpapi.RouteModes{}.Mode("GET", "/v1/tenants"))
+
+	_, err = httpapi.ParseRouteModes([]byte("defaults: go\n"))
+	require.Error(t, err)
+}
+
+func TestCutover_ReloadKeepsLastGoodTable(t *testing.T) {
+	cut := newCutover(t, &nodeStub{})
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+
+	require.NoError(t, os.WriteFile(path, []byte("routes:\n  /v1/tenants: legacy\n"), 0o600))
+	require.NoError(t, cut.Reload(path))
+	require.Equal(t, httpapi.ModeLegacy, cut.Modes().Mode("GET", "/v1/tenants"))
+
+	require.NoError(t, os.WriteFile(path, []byte("routes:\n  /v1/tenants: [legacy\n"), 0o600))
+	require.Error(t, cut.Reload(path))
+	require.Equal(t, httpapi.ModeLegacy, cut.Modes().Mode("GET", "/v1/tenants"))
+}
+
+func TestCutover_ShadowMirrorsTenantReads(t *testing.T) {
+	node := &nodeStub{body: `{"id":"t1","name":"Acme","slug":"acme-law"}`}
+	srv := httptest.NewServer(node)
+	defer srv.Close()
+
+	m := metrics.New()
+	cmp, err := shadow.New(shadow.Config{LegacyURL: srv.URL})
+	require.NoError(t, err)
+	cut, err := httpapi.NewCutover(srv.URL)
+	require.NoError(t, err)
+	cut.WithShadow(cmp.WithRecorder(m)).WithObserver(m)
+	require.NoError(t, cut.Set(httpapi.RouteModes{Routes: map[string]httpapi.RouteMode{
+		"/v1/tenants/{tenantSlug}": httpapi.ModeShadow,
+	}}))
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, Metrics: m, Cutover: cut})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tenants/acme-law", nil))
+	cmp.Wait()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	require.Contains(t, rr.Body.String(), `gochatbot_shadow_comparisons_total{outcome="match",route="/v1/tenants/{tenantSlug}"} 1`)
+	require.Contains(t, rr.Body.String(), `gochatbot_cutover_route_mode{mode="shadow",route="/v1/tenants/{tenantSlug}"} 1`)
+}
+
+func TestRequestID_KeptOrMinted(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}})
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set(httpapi.HeaderRequestID, "abc-123")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	require.Equal(t, "abc-123", rr.Header().Get(httpapi.HeaderRequestID))
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	require.Len(t, rr.Header().Get(httpapi.HeaderRequestID), 32)
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Every row carries a revision drawn from one database sequence, so a
+// revision alone identifies a representation and the ETag is just the
+// quoted number.
+
+func etag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// writeTagged writes v with its ETag, or 304 when If-None-Match already
+// names it.
+func writeTagged(w http.ResponseWriter, r *http.Request, revision int64, v any) {
+	tag := etag(revision)
+	w.Header().Set("ETag", tag)
+	if noneMatch(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// noneMatch uses the weak comparison RFC 9110 prescribes for If-None-Match.
+func noneMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch reads the revision a mutation is conditional on. "*" yields 0,
+// which the repos treat as unconditional. With required set a missing header
+// is answered with 428; a tag we could not have issued gets 412 right away.
+func ifMatch(w http.ResponseWriter, r *http.Request, required bool) (revision int64, ok bool) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch {
+	case h == "" && required:
+		writeJSON(w, http.StatusPreconditionRequired, map[string]any{"error": "if-match required"})
+		return 0, false
+	case h == "" || h == "*":
+		return 0, true
+	}
+	rev, err := strconv.ParseInt(strings.Trim(h, `"`), 10, 64)
+	if err != nil || rev <= 0 || h != etag(rev) {
+		writeJSON(w, http.StatusPreconditionFailed, map[string]any{"error": "precondition failed"})
+		return 0, false
+	}
+	return rev, true
+}
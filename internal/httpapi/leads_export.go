@@ -0,0 +1,208 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportFlushEvery is how many rows an export writes between flushes.
+const exportFlushEvery = 100
+
+// leadColumns are the CSV columns before the custom fields.
+var leadColumns = []string{"id", "session_id", "status", "name", "email", "phone"}
+
+// handleExportLeads streams the tenant's leads, oldest first, as CSV
+// (format=csv, the default) or NDJSON (format=ndjson). It takes the list
+// filters; cursor, limit and sort do not apply.
+//
+// Rows go out as the database returns them. The status line is only sent
+// with the first row, so a failure before it is still a JSON error; a
+// failure after it aborts the connection, so the client sees a broken
+// download rather than a short file that looks complete.
+func (s *Server) handleExportLeads(w http.ResponseWriter, r *http.Request) {
+	format := trim(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid format"})
+		return
+	}
+	f, err := parseLeadFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var enc leadEncoder
+	if format == "csv" {
+		fields, err := s.deps.LeadSvc.LeadFields(r.Context(), tenant.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+			return
+		}
+		enc = newCSVLeads(w, fields)
+	} else {
+		enc = ndjsonLeads{json.NewEncoder(w)}
+	}
+
+	rc := http.NewResponseController(w)
+	started, rows := false, 0
+	start := func() error {
+		started = true
+		h := w.Header()
+		h.Set("Content-Type", enc.contentType())
+		h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-leads.%s"`, tenant.Slug, format))
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		return enc.header()
+	}
+	err = s.deps.LeadSvc.ExportLeads(r.Context(), tenant.ID, f, func(l Lead) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := enc.lead(l); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushEvery == 0 {
+			return flushExport(rc, enc)
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start() // no leads: a CSV still gets its header row
+	}
+	if err == nil {
+		err = flushExport(rc, enc)
+	}
+	if err == nil {
+		return
+	}
+
+	if !started {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	if r.Context().Err() == nil {
+		log.Printf("lead export %s: aborted after %d rows: %v", tenant.Slug, rows, err)
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func flushExport(rc *http.ResponseController, enc leadEncoder) error {
+	if err := enc.flush(); err != nil {
+		return err
+	}
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+type leadEncoder interface {
+	contentType() string
+	header() error
+	lead(Lead) error
+	flush() error
+}
+
+type ndjsonLeads struct{ enc *json.Encoder }
+
+func (ndjsonLeads) contentType() string { return "application/x-ndjson" }
+func (ndjsonLeads) header() error       { return nil }
+func (e ndjsonLeads) lead(l Lead) error { return e.enc.Encode(l) }
+func (ndjsonLeads) flush() error        { return nil }
+
+// csvLeads writes one column per custom field the tenant's templates know
+// about; fields a lead has beyond those go, as a JSON object, into the
+// other_fields column so nothing is lost.
+type csvLeads struct {
+	w      *csv.Writer
+	fields []string
+	known  map[string]bool
+	row    []string
+}
+
+func newCSVLeads(w http.ResponseWriter, fields []string) *csvLeads {
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f] = true
+	}
+	return &csvLeads{w: csv.NewWriter(w), fields: fields, known: known}
+}
+
+func (*csvLeads) contentType() string { return "text/csv; charset=utf-8" }
+
+func (e *csvLeads) header() error {
+	cols := append(append([]string(nil), leadColumns...), e.fields...)
+	return e.w.Write(append(cols, "other_fields", "template_id", "template_version", "created_at", "updated_at"))
+}
+
+func (e *csvLeads) lead(l Lead) error {
+	row := append(e.row[:0], l.ID, l.SessionID, l.Status, cell(l.Name), cell(l.Email), l.Phone)
+	for _, f := range e.fields {
+		row = append(row, cell(fieldText(l.Fields[f])))
+	}
+	other := map[string]any{}
+	for k, v := range l.Fields {
+		if !e.known[k] {
+			other[k] = v
+		}
+	}
+	var rest string
+	if len(other) > 0 {
+		b, err := json.Marshal(other)
+		if err != nil {
+			return err
+		}
+		rest = string(b)
+	}
+	version := ""
+	if l.TemplateVersion > 0 {
+		version = strconv.Itoa(l.TemplateVersion)
+	}
+	row = append(row, rest, l.TemplateID, version, l.CreatedAt.UTC().Format(time.RFC3339), l.UpdatedAt.UTC().Format(time.RFC3339))
+	e.row = row
+	return e.w.Write(row)
+}
+
+func (e *csvLeads) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// fieldText is a custom field as one cell: strings as they are, anything
+// else as JSON.
+func fieldText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// cell keeps spreadsheets from evaluating visitor-supplied text as a
+// formula by prefixing it with a quote.
+func cell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
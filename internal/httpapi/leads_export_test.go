@@ -0,0 +1,106 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/pagination"
+)
+
+// exportLeadSvc streams leads, then returns err.
+type exportLeadSvc struct {
+	fakeLeadSvc
+	leads      []httpapi.Lead
+	err        error
+	lastFilter pagination.Filter
+}
+
+func (f *exportLeadSvc) LeadFields(context.Context, string) ([]string, error) {
+	return []string{"company", "budget"}, nil
+}
+
+func (f *exportLeadSvc) ExportLeads(_ context.Context, _ string, filter pagination.Filter, fn func(httpapi.Lead) error) error {
+	f.lastFilter = filter
+	for _, l := range f.leads {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return f.err
+}
+
+func exportServer(svc *exportLeadSvc) *httpapi.Server {
+	return httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, LeadSvc: svc})
+}
+
+func TestExportLeads_CSV(t *testing.T) {
+	ann := fakeLeadSvc{}.lead("t1", "l1")
+	ann.Fields = map[string]any{"company": "Initech", "budget": 10000, "source": "ad"}
+	bob := fakeLeadSvc{}.lead("t1", "l2")
+	bob.Name, bob.Phone, bob.Fields, bob.TemplateID, bob.TemplateVersion = "=HYPERLINK(\"x\")", "+14155552671", nil, "", 0
+	svc := &exportLeadSvc{leads: []httpapi.Lead{ann, bob}}
+
+	rr := httptest.NewRecorder()
+	exportServer(svc).ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants/acme/leads/export?status=new&created_before=2026-01-01T00:00:00Z", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename="acme-leads.csv"`, rr.Header().Get("Content-Disposition"))
+	require.Equal(t, httpapi.LeadNew, svc.lastFilter.Status)
+	require.False(t, svc.lastFilter.CreatedBefore.IsZero())
+
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "session_id", "status", "name", "email", "phone", "company", "budget",
+		"other_fields", "template_id", "template_version", "created_at", "updated_at"}, rows[0])
+	require.Equal(t, []string{"l1", "s1", "new", "Ann", "ann@example.com", "", "Initech", "10000",
+		`{"source":"ad"}`, "tpl1", "1", "2025-12-18T00:00:00Z", "2025-12-18T00:00:00Z"}, rows[1])
+	require.Equal(t, `'=HYPERLINK("x")`, rows[2][3], "formulas are defused")
+	require.Equal(t, "+14155552671", rows[2][5])
+	require.Equal(t, []string{"", ""}, rows[2][9:11])
+}
+
+func TestExportLeads_NDJSON(t *testing.T) {
+	svc := &exportLeadSvc{leads: []httpapi.Lead{fakeLeadSvc{}.lead("t1", "l1"), fakeLeadSvc{}.lead("t1", "l2")}}
+
+	rr := httptest.NewRecorder()
+	exportServer(svc).ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants/acme/leads/export?format=ndjson", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var l httpapi.Lead
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &l))
+	require.Equal(t, "l2", l.ID)
+}
+
+func TestExportLeads_EmptyCSVHasHeader(t *testing.T) {
+	rr := httptest.NewRecorder()
+	exportServer(&exportLeadSvc{}).ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants/acme/leads/export", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, strings.HasPrefix(rr.Body.String(), "id,session_id,"))
+	require.Equal(t, 1, strings.Count(rr.Body.String(), "\n"))
+}
+
+func TestExportLeads_Failures(t *testing.T) {
+	// before the first row the client still gets a status
+	rr := httptest.NewRecorder()
+	exportServer(&exportLeadSvc{err: errors.New("db down")}).ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants/acme/leads/export", nil))
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.JSONEq(t, `{"error":"internal"}`, rr.Body.String())
+
+	// after it the download is cut off
+	svc := &exportLeadSvc{leads: []httpapi.Lead{fakeLeadSvc{}.lead("t1", "l1")}, err: errors.New("db down")}
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		exportServer(svc).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tenants/acme/leads/export", nil))
+	})
+}
@@ -0,0 +1,200 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/validate"
+)
+
+// Lead statuses.
+const (
+	LeadNew      = "new"
+	LeadExported = "exported"
+	LeadFailed   = "failed"
+)
+
+type Lead struct {
+	ID              string         `json:"id"`
+	TenantID        string         `json:"tenant_id"`
+	SessionID       string         `json:"session_id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	Phone           string         `json:"phone"`
+	Fields          map[string]any `json:"fields"`
+	TemplateID      string         `json:"template_id,omitempty"`
+	TemplateVersion int            `json:"template_version,omitempty"`
+	Status          string         `json:"status"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	Revision        int64          `json:"-"` // see Tenant.Revision
+}
+
+type ListLeadsResult struct {
+	Items      []Lead          `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+	Page       pagination.Page `json:"-"` // see ListTenantsResult.Page
+}
+
+// LeadUpdate is a PATCH body: absent fields are left alone, an empty
+// string clears one. Fields replaces all custom fields.
+type LeadUpdate struct {
+	Name   *string        `json:"name"`
+	Email  *string        `json:"email"`
+	Phone  *string        `json:"phone"`
+	Fields map[string]any `json:"fields"`
+	Status *string        `json:"status"`
+}
+
+type LeadService interface {
+	ListLeads(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) (ListLeadsResult, error)
+	GetLead(ctx context.Context, tenantID, leadID string) (Lead, error)
+	UpdateLead(ctx context.Context, tenantID, leadID string, u LeadUpdate, ifRevision int64) (Lead, error)
+
+	// LeadFields names the custom fields a CSV export has a column for.
+	LeadFields(ctx context.Context, tenantID string) ([]string, error)
+	// ExportLeads calls fn for every lead matching f, oldest first, without
+	// holding them in memory; an error from fn ends the export.
+	ExportLeads(ctx context.Context, tenantID string, f pagination.Filter, fn func(Lead) error) error
+}
+
+// pathTenant resolves the {tenantSlug} every lead and webhook route is under
+// and answers the request itself when it cannot.
+func (s *Server) pathTenant(w http.ResponseWriter, r *http.Request) (Tenant, bool) {
+	tenantSlug, err := validate.NormalizeSlug(chi.URLParam(r, "tenantSlug"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidSlug.Error()})
+		return Tenant{}, false
+	}
+	tenant, err := s.deps.TenantSvc.GetTenantBySlug(requestContext(r), tenantSlug)
+	if err != nil {
+		if errors.Is(err, domain.ErrTenantNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "tenant not found"})
+			return Tenant{}, false
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return Tenant{}, false
+	}
+	return tenant, true
+}
+
+// handleListLeads takes the usual list parameters, with q matching name or
+// email, plus status.
+func (s *Server) handleListLeads(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimit(r, 50, 1, 200)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid limit"})
+		return
+	}
+
+	f, err := parseLeadFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	var cur *pagination.Cursor
+	if raw := strings.TrimSpace(r.URL.Query().Get("cursor")); raw != "" {
+		decoded, err := s.deps.Cursors.Decode(raw, f)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidCursor.Error()})
+			return
+		}
+		cur = &decoded
+	}
+
+	res, err := s.deps.LeadSvc.ListLeads(r.Context(), tenant.ID, limit, f, cur)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	res.NextCursor, res.PrevCursor = s.encodePage(res.Page, f)
+	writeJSON(w, http.StatusOK, res)
+}
+
+// parseLeadFilter is parseListFilter plus status.
+func parseLeadFilter(r *http.Request) (pagination.Filter, error) {
+	f, err := parseListFilter(r)
+	if err != nil {
+		return pagination.Filter{}, err
+	}
+	switch f.Status = trim(r.URL.Query().Get("status")); f.Status {
+	case "", LeadNew, LeadExported, LeadFailed:
+		return f, nil
+	default:
+		return pagination.Filter{}, errors.New("invalid status")
+	}
+}
+
+func (s *Server) handleGetLead(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	lead, err := s.deps.LeadSvc.GetLead(r.Context(), tenant.ID, chi.URLParam(r, "leadID"))
+	if err != nil {
+		if errors.Is(err, domain.ErrLeadNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+
+	writeTagged(w, r, lead.Revision, lead)
+}
+
+func (s *Server) handleUpdateLead(w http.ResponseWriter, r *http.Request) {
+	rev, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req LeadUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+	if req.Name == nil && req.Email == nil && req.Phone == nil && req.Fields == nil && req.Status == nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "nothing to update"})
+		return
+	}
+
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	lead, err := s.deps.LeadSvc.UpdateLead(r.Context(), tenant.ID, chi.URLParam(r, "leadID"), req, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrLeadNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+		case errors.Is(err, domain.ErrRevisionMismatch):
+			writeJSON(w, http.StatusPreconditionFailed, map[string]any{"error": "precondition failed"})
+		case errors.Is(err, domain.ErrInvalidEmail), errors.Is(err, domain.ErrInvalidPhone), errors.Is(err, domain.ErrInvalidLeadStatus):
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(lead.Revision))
+	writeJSON(w, http.StatusOK, lead)
+}
@@ -0,0 +1,30 @@
+package httpapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// openapiSpec describes every /v1 route. openapi_test.go checks it against
+// the router and validates real responses with it, so edit it together with
+// the handlers.
+//
+//go:embed openapi.json
+var openapiSpec []byte
+
+// OpenAPI returns the OpenAPI 3.1 document served at /openapi.json.
+func OpenAPI() []byte {
+	return append([]byte(nil), openapiSpec...)
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openapiSpec)
+}
+
+// Routes exposes the route table, e.g. to walk it with chi.Walk.
+func (s *Server) Routes() chi.Routes {
+	return s.r
+}
@@ -0,0 +1,656 @@
+package httpapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/ratelimit"
+)
+
+type fakeTemplateSvc struct{}
+
+var fakeCreated = time.Date(2025, 12, 18, 0, 0, 0, 0, time.UTC)
+
+func (fakeTemplateSvc) CreateTemplate(_ context.Context, tenantID, name, slug string) (httpapi.Template, error) {
+	if slug == "taken" {
+		return httpapi.Template{}, domain.ErrTemplateSlugTaken
+	}
+	return httpapi.Template{ID: "tpl1", TenantID: tenantID, Name: name, Slug: slug, CreatedAt: fakeCreated, Revision: 3}, nil
+}
+
+func (fakeTemplateSvc) GetTemplate(_ context.Context, tenantID, slug string) (httpapi.Template, error) {
+	if slug == "missing" {
+		return httpapi.Template{}, domain.ErrTemplateNotFound
+	}
+	return httpapi.Template{ID: "tpl1", TenantID: tenantID, Name: "Intake", Slug: slug, CreatedAt: fakeCreated, Revision: 3}, nil
+}
+
+func (fakeTemplateSvc) ListTemplates(_ context.Context, tenantID string, _ int, _ pagination.Filter, _ *pagination.Cursor) (httpapi.ListTemplatesResult, error) {
+	return httpapi.ListTemplatesResult{
+		Items: []httpapi.Template{{ID: "tpl1", TenantID: tenantID, Name: "Intake", Slug: "intake", CreatedAt: fakeCreated}},
+		Page:  pagination.Page{Next: &pagination.Cursor{CreatedAt: fakeCreated, ID: "tpl1"}},
+	}, nil
+}
+
+func (fakeTemplateSvc) RenameTemplate(_ context.Context, templateID, name string, ifRevision int64) (httpapi.Template, error) {
+	if ifRevision != 0 && ifRevision != 3 {
+		return httpapi.Template{}, domain.ErrRevisionMismatch
+	}
+	return httpapi.Template{ID: templateID, TenantID: "t1", Name: name, Slug: "intake", CreatedAt: fakeCreated, Revision: 4}, nil
+}
+
+func (fakeTemplateSvc) version(templateID string, version int, status string) httpapi.TemplateVersion {
+	return httpapi.TemplateVersion{ID: "v" + strconv.Itoa(version), TemplateID: templateID, Version: version, Status: status,
+		Content: json.RawMessage(`{"greeting":"hi"}`), CreatedAt: fakeCreated, Revision: int64(10 + version)}
+}
+
+func (f fakeTemplateSvc) CreateDraft(_ context.Context, templateID string, _ json.RawMessage) (httpapi.TemplateVersion, error) {
+	return f.version(templateID, 2, "draft"), nil
+}
+
+func (f fakeTemplateSvc) UpdateDraft(_ context.Context, templateID string, version int, _ json.RawMessage, _ int64) (httpapi.TemplateVersion, error) {
+	if version == 1 {
+		return httpapi.TemplateVersion{}, domain.ErrPublishedVersionImmutable
+	}
+	return f.version(templateID, version, "draft"), nil
+}
+
+func (f fakeTemplateSvc) Publish(_ context.Context, templateID string, version int, _ int64) (httpapi.TemplateVersion, error) {
+	if version == 1 {
+		return httpapi.TemplateVersion{}, domain.ErrVersionAlreadyPublished
+	}
+	return f.version(templateID, version, "published"), nil
+}
+
+func (f fakeTemplateSvc) GetPublished(_ context.Context, templateID string) (httpapi.TemplateVersion, error) {
+	return f.version(templateID, 1, "published"), nil
+}
+
+func (f fakeTemplateSvc) GetVersion(_ context.Context, templateID string, version int) (httpapi.TemplateVersion, error) {
+	if version > 2 {
+		return httpapi.TemplateVersion{}, domain.ErrVersionNotFound
+	}
+	return f.version(templateID, version, "draft"), nil
+}
+
+type fakeLeadSvc struct{}
+
+func (fakeLeadSvc) lead(tenantID, leadID string) httpapi.Lead {
+	return httpapi.Lead{ID: leadID, TenantID: tenantID, SessionID: "s1", Name: "Ann", Email: "ann@example.com",
+		Fields: map[string]any{"company": "Initech"}, TemplateID: "tpl1", TemplateVersion: 1, Status: httpapi.LeadNew,
+		CreatedAt: fakeCreated, UpdatedAt: fakeCreated, Revision: 21}
+}
+
+func (f fakeLeadSvc) ListLeads(_ context.Context, tenantID string, _ int, _ pagination.Filter, _ *pagination.Cursor) (httpapi.ListLeadsResult, error) {
+	return httpapi.ListLeadsResult{
+		Items: []httpapi.Lead{f.lead(tenantID, "l1")},
+		Page:  pagination.Page{Next: &pagination.Cursor{CreatedAt: fakeCreated, ID: "l1"}},
+	}, nil
+}
+
+func (f fakeLeadSvc) GetLead(_ context.Context, tenantID, leadID string) (httpapi.Lead, error) {
+	if leadID == "missing" {
+		return httpapi.Lead{}, domain.ErrLeadNotFound
+	}
+	return f.lead(tenantID, leadID), nil
+}
+
+func (f fakeLeadSvc) UpdateLead(_ context.Context, tenantID, leadID string, u httpapi.LeadUpdate, ifRevision int64) (httpapi.Lead, error) {
+	if ifRevision != 21 {
+		return httpapi.Lead{}, domain.ErrRevisionMismatch
+	}
+	if u.Email != nil && *u.Email == "nope" {
+		return httpapi.Lead{}, domain.ErrInvalidEmail
+	}
+	l := f.lead(tenantID, leadID)
+	if u.Status != nil {
+		l.Status = *u.Status
+	}
+	l.Revision++
+	return l, nil
+}
+
+func (fakeLeadSvc) LeadFields(context.Context, string) ([]string, error) {
+	return []string{"company", "note"}, nil
+}
+
+func (f fakeLeadSvc) ExportLeads(_ context.Context, tenantID string, _ pagination.Filter, fn func(httpapi.Lead) error) error {
+	return fn(f.lead(tenantID, "l1"))
+}
+
+type fakeWebhookSvc struct{}
+
+func (fakeWebhookSvc) hook(tenantID, id string) httpapi.Webhook {
+	return httpapi.Webhook{ID: id, TenantID: tenantID, URL: "https://example.com/hook",
+		Events: []string{"lead.created"}, CreatedAt: fakeCreated}
+}
+
+func (f fakeWebhookSvc) CreateWebhook(_ context.Context, tenantID string, req httpapi.CreateWebhookRequest) (httpapi.Webhook, error) {
+	if req.URL == "http://internal" {
+		return httpapi.Webhook{}, domain.ErrInvalidWebhookURL
+	}
+	h := f.hook(tenantID, "wh1")
+	h.Secret = "whsec_x"
+	return h, nil
+}
+
+func (f fakeWebhookSvc) ListWebhooks(_ context.Context, tenantID string) (httpapi.ListWebhooksResult, error) {
+	return httpapi.ListWebhooksResult{Items: []httpapi.Webhook{f.hook(tenantID, "wh1")}}, nil
+}
+
+func (f fakeWebhookSvc) GetWebhook(_ context.Context, tenantID, webhookID string) (httpapi.Webhook, error) {
+	if webhookID == "missing" {
+		return httpapi.Webhook{}, domain.ErrWebhookNotFound
+	}
+	return f.hook(tenantID, webhookID), nil
+}
+
+func (fakeWebhookSvc) DeleteWebhook(_ context.Context, _, webhookID string) error {
+	if webhookID == "missing" {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (fakeWebhookSvc) delivery(id string) httpapi.WebhookDelivery {
+	return httpapi.WebhookDelivery{ID: id, EventID: "evt_1", EventType: "lead.created", Status: "failed",
+		Attempts: []httpapi.WebhookAttempt{
+			{Attempt: 1, Error: "dial tcp: i/o timeout", DurationMS: 10000, CreatedAt: fakeCreated},
+			{Attempt: 2, StatusCode: 500, ResponseBody: "oops", DurationMS: 12, CreatedAt: fakeCreated},
+		},
+		CreatedAt: fakeCreated, UpdatedAt: fakeCreated}
+}
+
+func (f fakeWebhookSvc) ListDeliveries(_ context.Context, _, webhookID string, _ int) (httpapi.ListWebhookDeliveriesResult, error) {
+	if webhookID == "missing" {
+		return httpapi.ListWebhookDeliveriesResult{}, domain.ErrWebhookNotFound
+	}
+	return httpapi.ListWebhookDeliveriesResult{Items: []httpapi.WebhookDelivery{f.delivery("d1")}}, nil
+}
+
+func (f fakeWebhookSvc) Redeliver(_ context.Context, _, _, deliveryID string) (httpapi.WebhookDelivery, error) {
+	switch deliveryID {
+	case "missing":
+		return httpapi.WebhookDelivery{}, domain.ErrDeliveryNotFound
+	case "pending":
+		return httpapi.WebhookDelivery{}, domain.ErrDeliveryPending
+	}
+	d := f.delivery(deliveryID)
+	d.Status = "pending"
+	return d, nil
+}
+
+type fakeSessionSvc struct{}
+
+func (fakeSessionSvc) StartSession(_ context.Context, tenantID, templateID string) (httpapi.Session, error) {
+	return httpapi.Session{ID: "s1", TenantID: tenantID, TemplateID: templateID, CreatedAt: fakeCreated}, nil
+}
+
+func (fakeSessionSvc) CloseSession(_ context.Context, _, sessionID string) error {
+	if sessionID == "missing" {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
+
+type fakeUploadSvc struct{}
+
+func (fakeUploadSvc) MaxUploadBytes() int64 { return 100 }
+
+func (fakeUploadSvc) Upload(_ context.Context, _, sessionID, fileName string, size int64, _ io.Reader) (httpapi.Upload, error) {
+	switch {
+	case sessionID == "missing":
+		return httpapi.Upload{}, domain.ErrSessionNotFound
+	case sessionID == "closed":
+		return httpapi.Upload{}, domain.ErrSessionClosed
+	case size == 0:
+		return httpapi.Upload{}, domain.ErrEmptyUpload
+	case fileName == "page.html":
+		return httpapi.Upload{}, domain.ErrUploadTypeNotAllowed
+	}
+	return httpapi.Upload{ID: "f1", SessionID: sessionID, FileName: fileName, ContentType: "application/pdf", Size: size,
+		URL: "/v1/files/f1?expires=1767225600&sig=ab", URLExpiresAt: fakeCreated, CreatedAt: fakeCreated}, nil
+}
+
+func (f fakeUploadSvc) OpenUpload(_ context.Context, uploadID string, link url.Values) (httpapi.UploadFile, error) {
+	if link.Get("sig") == "" {
+		return httpapi.UploadFile{}, domain.ErrInvalidDownloadLink
+	}
+	if uploadID == "missing" {
+		return httpapi.UploadFile{}, domain.ErrUploadNotFound
+	}
+	up, _ := f.Upload(context.Background(), "t1", "s1", "report.pdf", 8, nil)
+	return httpapi.UploadFile{Upload: up, Body: io.NopCloser(strings.NewReader("%PDF-1.7"))}, nil
+}
+
+// multipartFile is a form whose "file" part holds content.
+func multipartFile(name, content string) string {
+	return "--b\r\nContent-Disposition: form-data; name=\"file\"; filename=\"" + name + "\"\r\n" +
+		"Content-Type: application/octet-stream\r\n\r\n" + content + "\r\n--b--\r\n"
+}
+
+func loadSpec(t *testing.T, s http.Handler) map[string]any {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+	require.Equal(t, "3.1.0", spec["openapi"])
+	return spec
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, TemplateSvc: fakeTemplateSvc{}, ReconcileSvc: &fakeReconcileSvc{},
+		SessionEvents: &fakeEvents{}, Chat: fakeChat{}, LeadSvc: fakeLeadSvc{}, WebhookSvc: fakeWebhookSvc{}, UploadSvc: fakeUploadSvc{},
+		SessionSvc: fakeSessionSvc{}})
+	spec := loadSpec(t, s)
+
+	var routed []string
+	require.NoError(t, chi.Walk(s.Routes(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/v1/") {
+			routed = append(routed, method+" "+strings.TrimSuffix(route, "/"))
+		}
+		return nil
+	}))
+
+	var documented []string
+	for path, item := range spec["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(routed)
+	sort.Strings(documented)
+	require.Equal(t, routed, documented)
+}
+
+func TestOpenAPI_ResponsesMatchSpec(t *testing.T) {
+	srv := httpapi.New(httpapi.Deps{
+		TenantSvc: &fakeTenantSvc{revision: 41, page: pagination.Page{
+			Next: &pagination.Cursor{CreatedAt: fakeCreated, ID: "t9"},
+			Prev: &pagination.Cursor{CreatedAt: fakeCreated, ID: "t1", Backward: true},
+		}},
+		TemplateSvc:   fakeTemplateSvc{},
+		ReconcileSvc:  &fakeReconcileSvc{queued: true},
+		SessionEvents: &fakeEvents{events: []httpapi.SessionEvent{{Type: httpapi.EventClosed}}},
+		Chat:          fakeChat{},
+		LeadSvc:       fakeLeadSvc{},
+		WebhookSvc:    fakeWebhookSvc{},
+		UploadSvc:     fakeUploadSvc{},
+		SessionSvc:    fakeSessionSvc{},
+	})
+	missing := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{getErr: domain.ErrTenantNotFound}, TemplateSvc: fakeTemplateSvc{}})
+	lenient := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, TemplateSvc: fakeTemplateSvc{}, PublishWithoutIfMatch: true})
+	taken := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{createErr: domain.ErrTenantSlugTaken}})
+	rules, err := ratelimit.ParseRules([]string{"*.ip=1/1h"})
+	require.NoError(t, err)
+	limited := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, RateLimit: ratelimit.New(ratelimit.NewMemory(), rules)})
+	spec := loadSpec(t, srv)
+
+	ifMatch := func(tag string) http.Header { return http.Header{"If-Match": {tag}} }
+	form := http.Header{"Content-Type": {"multipart/form-data; boundary=b"}}
+	cases := []struct {
+		srv    http.Handler
+		method string
+		path   string
+		header http.Header
+		body   string
+		status int
+	}{
+		{srv, "GET", "/v1/tenants", nil, "", 200},
+		{srv, "GET", "/v1/tenants?sort=slug", nil, "", 400},
+		{srv, "POST", "/v1/tenants", nil, `{"name":"Acme","slug":"Acme Law"}`, 201},
+		{srv, "POST", "/v1/tenants", nil, `{"name":"","slug":"acme"}`, 422},
+		{srv, "POST", "/v1/tenants", nil, `{`, 400},
+		{taken, "POST", "/v1/tenants", nil, `{"name":"Acme","slug":"acme"}`, 409},
+		{srv, "GET", "/v1/tenants/acme", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme", http.Header{"If-None-Match": {`"41"`}}, "", 304},
+		{missing, "GET", "/v1/tenants/acme", nil, "", 404},
+		{srv, "PATCH", "/v1/tenants/acme", ifMatch(`"41"`), `{"name":"Acme Legal"}`, 200},
+		{srv, "PATCH", "/v1/tenants/acme", nil, `{"name":"Acme Legal"}`, 428},
+		{srv, "PATCH", "/v1/tenants/acme", ifMatch(`"7"`), `{"name":"Acme Legal"}`, 412},
+
+		{srv, "GET", "/v1/tenants/acme/templates", nil, "", 200},
+		{missing, "GET", "/v1/tenants/acme/templates", nil, "", 404},
+		{srv, "POST", "/v1/tenants/acme/templates", nil, `{"name":"Intake","slug":"intake"}`, 201},
+		{srv, "POST", "/v1/tenants/acme/templates", nil, `{"name":"Intake","slug":"taken"}`, 409},
+		{srv, "GET", "/v1/tenants/acme/templates/intake", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/templates/missing", nil, "", 404},
+		{srv, "PATCH", "/v1/tenants/acme/templates/intake", ifMatch(`"3"`), `{"name":"Intake v2"}`, 200},
+		{srv, "PATCH", "/v1/tenants/acme/templates/intake", ifMatch(`"2"`), `{"name":"Intake v2"}`, 412},
+
+		{srv, "GET", "/v1/tenants/acme/leads?status=exported", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/leads?status=won", nil, "", 400},
+		{srv, "GET", "/v1/tenants/acme/leads/export?created_after=2025-01-01T00:00:00Z", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/leads/export?format=ndjson&status=new", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/leads/export?format=xlsx", nil, "", 400},
+		{srv, "GET", "/v1/tenants/acme/leads/l1", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/leads/l1", http.Header{"If-None-Match": {`"21"`}}, "", 304},
+		{srv, "GET", "/v1/tenants/acme/leads/missing", nil, "", 404},
+		{srv, "PATCH", "/v1/tenants/acme/leads/l1", ifMatch(`"21"`), `{"status":"exported"}`, 200},
+		{srv, "PATCH", "/v1/tenants/acme/leads/l1", ifMatch(`"21"`), `{}`, 422},
+		{srv, "PATCH", "/v1/tenants/acme/leads/l1", ifMatch(`"21"`), `{"email":"nope"}`, 422},
+		{srv, "PATCH", "/v1/tenants/acme/leads/l1", ifMatch(`"20"`), `{"name":"Ann"}`, 412},
+		{srv, "PATCH", "/v1/tenants/acme/leads/l1", nil, `{"name":"Ann"}`, 428},
+
+		{srv, "GET", "/v1/tenants/acme/webhooks", nil, "", 200},
+		{srv, "POST", "/v1/tenants/acme/webhooks", nil, `{"url":"https://example.com/hook","events":["lead.created"]}`, 201},
+		{srv, "POST", "/v1/tenants/acme/webhooks", nil, `{"url":"http://internal","events":["lead.created"]}`, 422},
+		{srv, "POST", "/v1/tenants/acme/webhooks", nil, `{`, 400},
+		{srv, "GET", "/v1/tenants/acme/webhooks/wh1", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/webhooks/missing", nil, "", 404},
+		{srv, "DELETE", "/v1/tenants/acme/webhooks/wh1", nil, "", 204},
+		{srv, "DELETE", "/v1/tenants/acme/webhooks/missing", nil, "", 404},
+		{srv, "GET", "/v1/tenants/acme/webhooks/wh1/deliveries", nil, "", 200},
+		{srv, "GET", "/v1/tenants/acme/webhooks/wh1/deliveries?limit=x", nil, "", 400},
+		{srv, "GET", "/v1/tenants/acme/webhooks/missing/deliveries", nil, "", 404},
+		{srv, "POST", "/v1/tenants/acme/webhooks/wh1/deliveries/d1/redeliver", nil, "", 202},
+		{srv, "POST", "/v1/tenants/acme/webhooks/wh1/deliveries/pending/redeliver", nil, "", 409},
+		{srv, "POST", "/v1/tenants/acme/webhooks/wh1/deliveries/missing/redeliver", nil, "", 404},
+
+		{srv, "POST", "/v1/tenants/acme/sessions", nil, "", 201},
+		{srv, "POST", "/v1/tenants/acme/sessions", nil, `{"template":"intake"}`, 201},
+		{srv, "POST", "/v1/tenants/acme/sessions", nil, `{"template":"missing"}`, 422},
+		{srv, "POST", "/v1/tenants/acme/sessions", nil, `{`, 400},
+		{srv, "POST", "/v1/tenants/acme/sessions/s1/close", nil, "", 204},
+		{srv, "POST", "/v1/tenants/acme/sessions/missing/close", nil, "", 404},
+
+		{srv, "POST", "/v1/tenants/acme/sessions/s1/uploads", form, multipartFile("report.pdf", "%PDF-1.7"), 201},
+		{srv, "POST", "/v1/tenants/acme/sessions/s1/uploads", nil, `{"file":"x"}`, 400},
+		{srv, "POST", "/v1/tenants/acme/sessions/missing/uploads", form, multipartFile("report.pdf", "%PDF-1.7"), 404},
+		{srv, "POST", "/v1/tenants/acme/sessions/closed/uploads", form, multipartFile("report.pdf", "%PDF-1.7"), 409},
+		{srv, "POST", "/v1/tenants/acme/sessions/s1/uploads", form, multipartFile("big.pdf", strings.Repeat("x", 70<<10)), 413},
+		{srv, "POST", "/v1/tenants/acme/sessions/s1/uploads", form, multipartFile("page.html", "<html>"), 415},
+		{srv, "POST", "/v1/tenants/acme/sessions/s1/uploads", form, multipartFile("empty.pdf", ""), 422},
+
+		{srv, "POST", "/v1/templates/tpl1/drafts", nil, `{"content":{"greeting":"hi"}}`, 201},
+		{srv, "POST", "/v1/templates/tpl1/publish", ifMatch(`"12"`), `{"version":2}`, 200},
+		{srv, "POST", "/v1/templates/tpl1/publish", ifMatch(`"11"`), `{"version":1}`, 409},
+		{srv, "POST", "/v1/templates/tpl1/publish", ifMatch(`"12"`), `{"version":0}`, 422},
+		{srv, "POST", "/v1/templates/tpl1/publish", nil, `{"version":2}`, 428},
+		{lenient, "POST", "/v1/templates/tpl1/publish", nil, `{"version":2}`, 200},
+		{srv, "GET", "/v1/templates/tpl1/published", nil, "", 200},
+		{srv, "GET", "/v1/templates/tpl1/versions/2", nil, "", 200},
+		{srv, "GET", "/v1/templates/tpl1/versions/9", nil, "", 404},
+		{srv, "GET", "/v1/templates/tpl1/versions/x", nil, "", 400},
+		{srv, "PUT", "/v1/templates/tpl1/versions/2", ifMatch(`"12"`), `{"content":{}}`, 200},
+		{srv, "PUT", "/v1/templates/tpl1/versions/1", ifMatch(`"11"`), `{"content":{}}`, 409},
+
+		{srv, "GET", "/v1/reconciliation/runs", nil, "", 200},
+		{srv, "POST", "/v1/reconciliation/runs", nil, "", 202},
+		{srv, "GET", "/v1/reconciliation/runs/latest", nil, "", 200},
+		{srv, "GET", "/v1/reconciliation/runs/nope", nil, "", 404},
+
+		{srv, "GET", "/v1/files/f1?expires=1767225600&sig=ab", nil, "", 200},
+		{srv, "GET", "/v1/files/f1", nil, "", 403},
+		{srv, "GET", "/v1/files/missing?expires=1767225600&sig=ab", nil, "", 404},
+
+		{srv, "GET", "/v1/sessions/s1/events", nil, "", 200},
+		{srv, "GET", "/v1/sessions/s1/events", http.Header{"Last-Event-ID": {"-1"}}, "", 400},
+		{srv, "GET", "/v1/sessions/s2/events", nil, "", 404},
+		{srv, "GET", "/v1/sessions/s1/ws", nil, "", 426},
+		{srv, "GET", "/v1/sessions/s1/ws?last_event_id=x", http.Header{"Upgrade": {"websocket"}}, "", 400},
+		{srv, "GET", "/v1/sessions/s2/ws", http.Header{"Upgrade": {"websocket"}}, "", 404},
+
+		{limited, "GET", "/v1/tenants", nil, "", 200},
+		{limited, "GET", "/v1/tenants", nil, "", 429},
+	}
+
+	covered := map[string]bool{}
+	for _, tc := range cases {
+		name := tc.method + " " + tc.path
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		for k, vs := range tc.header {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		rr := httptest.NewRecorder()
+		tc.srv.ServeHTTP(rr, req)
+		require.Equal(t, tc.status, rr.Code, "%s: %s", name, rr.Body.String())
+
+		op, opID := findOperation(t, spec, tc.method, req.URL.Path)
+		covered[opID] = true
+		resp := responseFor(t, spec, op, rr.Code, name)
+
+		for h := range mapOf(resp["headers"]) {
+			require.NotEmpty(t, rr.Header().Get(h), "%s: missing header %s", name, h)
+		}
+		content := mapOf(resp["content"])
+		if len(content) == 0 {
+			require.Empty(t, rr.Body.String(), name)
+			continue
+		}
+		if _, ok := content["application/json"]; !ok {
+			// streams and downloads: only the media type is checked
+			mt, _, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+			require.NoError(t, err, name)
+			if _, ranged := content["*/*"]; !ranged {
+				require.Contains(t, content, mt, name)
+			}
+			continue
+		}
+		require.Equal(t, "application/json", rr.Header().Get("Content-Type"), name)
+		var body any
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body), name)
+		schema := mapOf(mapOf(content["application/json"])["schema"])
+		if err := validate(spec, schema, body, "$"); err != nil {
+			t.Errorf("%s: %d response does not match spec: %v\n%s", name, rr.Code, err, rr.Body.String())
+		}
+	}
+
+	for path, item := range spec["paths"].(map[string]any) {
+		for method, op := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			id := mapOf(op)["operationId"].(string)
+			require.True(t, covered[id], "no case exercises %s %s", strings.ToUpper(method), path)
+		}
+	}
+}
+
+// findOperation matches a concrete path against the spec's path templates.
+// Like the router, it prefers literal segments: /leads/export is not
+// /leads/{leadID}.
+func findOperation(t *testing.T, spec map[string]any, method, path string) (map[string]any, string) {
+	t.Helper()
+	param := regexp.MustCompile(`\\\{[^}]+\\\}`)
+	best, params := "", -1
+	for tmpl := range spec["paths"].(map[string]any) {
+		re := "^" + param.ReplaceAllString(regexp.QuoteMeta(tmpl), `[^/]+`) + "$"
+		if !regexp.MustCompile(re).MatchString(path) {
+			continue
+		}
+		if n := strings.Count(tmpl, "{"); params < 0 || n < params {
+			best, params = tmpl, n
+		}
+	}
+	if params < 0 {
+		t.Fatalf("%s %s is not in the spec", method, path)
+	}
+	op := mapOf(mapOf(spec["paths"].(map[string]any)[best])[strings.ToLower(method)])
+	require.NotNil(t, op, "%s %s is not in the spec", method, best)
+	return op, op["operationId"].(string)
+}
+
+func responseFor(t *testing.T, spec, op map[string]any, status int, name string) map[string]any {
+	t.Helper()
+	responses := mapOf(op["responses"])
+	resp, ok := responses[strconv.Itoa(status)]
+	if !ok {
+		require.GreaterOrEqual(t, status, 500, "%s: status %d is not documented", name, status)
+		resp = responses["default"]
+	}
+	return resolve(spec, mapOf(resp))
+}
+
+func mapOf(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func resolve(spec, node map[string]any) map[string]any {
+	for node != nil {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		node = spec
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			node = mapOf(node[part])
+		}
+	}
+	return node
+}
+
+// validate checks v against the JSON Schema keywords the spec uses.
+func validate(spec, schema map[string]any, v any, at string) error {
+	schema = resolve(spec, schema)
+	if schema == nil {
+		return nil
+	}
+
+	if types, ok := schema["type"]; ok {
+		var allowed []string
+		switch ts := types.(type) {
+		case string:
+			allowed = []string{ts}
+		case []any:
+			for _, x := range ts {
+				allowed = append(allowed, x.(string))
+			}
+		}
+		if !matchesType(v, allowed) {
+			return fmt.Errorf("%s: %v is not %v", at, v, allowed)
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, v, enum)
+		}
+	}
+
+	switch x := v.(type) {
+	case string:
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, x); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, x)
+			}
+		}
+		if p, ok := schema["pattern"].(string); ok && !regexp.MustCompile(p).MatchString(x) {
+			return fmt.Errorf("%s: %q does not match %s", at, x, p)
+		}
+		if n, ok := schema["minLength"].(float64); ok && float64(len(x)) < n {
+			return fmt.Errorf("%s: shorter than %v", at, n)
+		}
+	case float64:
+		if n, ok := schema["minimum"].(float64); ok && x < n {
+			return fmt.Errorf("%s: %v is below %v", at, x, n)
+		}
+	case []any:
+		items := mapOf(schema["items"])
+		for i, e := range x {
+			if err := validate(spec, items, e, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		props := mapOf(schema["properties"])
+		for _, r := range asSlice(schema["required"]) {
+			if _, ok := x[r.(string)]; !ok {
+				return fmt.Errorf("%s: missing %q", at, r)
+			}
+		}
+		for k, e := range x {
+			p, ok := props[k]
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: unexpected property %q", at, k)
+				}
+				continue
+			}
+			if err := validate(spec, mapOf(p), e, at+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+func matchesType(v any, allowed []string) bool {
+	for _, t := range allowed {
+		switch t {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := v.(float64); ok && n == float64(int64(n)) {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]any); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]any); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestOpenAPI_ServedVerbatim(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}})
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	require.True(t, bytes.Equal(httpapi.OpenAPI(), rr.Body.Bytes()))
+}
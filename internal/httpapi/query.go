@@ -1,9 +1,13 @@
 package httpapi
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"gochatbot/internal/pagination"
 )
 
 func parseLimit(r *http.Request, def, min, max int) (int, bool) {
@@ -23,3 +27,47 @@ func parseLimit(r *http.Request, def, min, max int) (int, bool) {
 	}
 	return n, true
 }
+
+// parseListFilter reads q, created_after, created_before, sort and order.
+// Times are RFC 3339; the error text is meant for the response body.
+func parseListFilter(r *http.Request) (pagination.Filter, error) {
+	qs := r.URL.Query()
+	var f pagination.Filter
+
+	f.Prefix = trim(qs.Get("q"))
+	if len(f.Prefix) > 100 {
+		return pagination.Filter{}, errors.New("invalid q")
+	}
+
+	var err error
+	if raw := trim(qs.Get("created_after")); raw != "" {
+		if f.CreatedAfter, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return pagination.Filter{}, errors.New("invalid created_after")
+		}
+	}
+	if raw := trim(qs.Get("created_before")); raw != "" {
+		if f.CreatedBefore, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return pagination.Filter{}, errors.New("invalid created_before")
+		}
+	}
+	if !f.CreatedAfter.IsZero() && !f.CreatedBefore.IsZero() && !f.CreatedAfter.Before(f.CreatedBefore) {
+		return pagination.Filter{}, errors.New("created_after must be before created_before")
+	}
+
+	if f.Sort, err = pagination.ParseSort(trim(qs.Get("sort")), trim(qs.Get("order"))); err != nil {
+		return pagination.Filter{}, err
+	}
+	return f, nil
+}
+
+// encodePage signs the cursors around a page for the filter it was listed
+// with; a cursor is rejected if any part of the query changes.
+func (s *Server) encodePage(p pagination.Page, f pagination.Filter) (next, prev string) {
+	if p.Next != nil {
+		next = s.deps.Cursors.Encode(*p.Next, f)
+	}
+	if p.Prev != nil {
+		prev = s.deps.Cursors.Encode(*p.Prev, f)
+	}
+	return next, prev
+}
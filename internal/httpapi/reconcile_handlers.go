@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/domain"
+)
+
+type ReconcileRun struct {
+	ID               string     `json:"id"`
+	Source           string     `json:"source"`
+	Status           string     `json:"status"`
+	TenantsChecked   int        `json:"tenants_checked"`
+	TemplatesChecked int        `json:"templates_checked"`
+	DiffCount        int        `json:"diff_count"`
+	Error            string     `json:"error,omitempty"`
+	StartedAt        time.Time  `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
+
+type ReconcileDiff struct {
+	Entity string `json:"entity"`
+	Key    string `json:"key"`
+	Kind   string `json:"kind"`
+	Field  string `json:"field,omitempty"`
+	Go     string `json:"go,omitempty"`
+	Legacy string `json:"legacy,omitempty"`
+}
+
+// ReconcileReport is a run with its stored diffs, which may be fewer than
+// Run.DiffCount for a run that found a great many.
+type ReconcileReport struct {
+	Run   ReconcileRun    `json:"run"`
+	Diffs []ReconcileDiff `json:"diffs"`
+}
+
+type ListReconcileRunsResult struct {
+	Items []ReconcileRun `json:"items"`
+}
+
+type ReconcileService interface {
+	ListRuns(ctx context.Context, limit int) ([]ReconcileRun, error)
+	// GetReport accepts a run id or "latest".
+	GetReport(ctx context.Context, runID string) (ReconcileReport, error)
+	// TriggerRun queues a run unless one is already queued or running.
+	TriggerRun(ctx context.Context) (queued bool, err error)
+}
+
+func (s *Server) handleListReconcileRuns(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(r, 20, 1, 200)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid limit"})
+		return
+	}
+
+	runs, err := s.deps.ReconcileSvc.ListRuns(r.Context(), limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	writeJSON(w, http.StatusOK, ListReconcileRunsResult{Items: runs})
+}
+
+func (s *Server) handleTriggerReconcileRun(w http.ResponseWriter, r *http.Request) {
+	queued, err := s.deps.ReconcileSvc.TriggerRun(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"queued": queued})
+}
+
+func (s *Server) handleGetReconcileReport(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.deps.ReconcileSvc.GetReport(r.Context(), chi.URLParam(r, "runID"))
+	if err != nil {
+		if errors.Is(err, domain.ErrReconcileRunNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
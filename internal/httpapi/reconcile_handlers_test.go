@@ -0,0 +1,69 @@
+package httpapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+)
+
+type fakeReconcileSvc struct {
+	queued bool
+}
+
+func (f *fakeReconcileSvc) ListRuns(_ context.Context, limit int) ([]httpapi.ReconcileRun, error) {
+	return []httpapi.ReconcileRun{{ID: "r1", Source: "postgres", Status: "done", DiffCount: 1}}, nil
+}
+
+func (f *fakeReconcileSvc) GetReport(_ context.Context, runID string) (httpapi.ReconcileReport, error) {
+	if runID != "r1" && runID != "latest" {
+		return httpapi.ReconcileReport{}, domain.ErrReconcileRunNotFound
+	}
+	return httpapi.ReconcileReport{
+		Run: httpapi.ReconcileRun{ID: "r1", Source: "postgres", Status: "done", DiffCount: 1},
+		Diffs: []httpapi.ReconcileDiff{
+			{Entity: "tenant", Key: "acme", Kind: "mismatch", Field: "name", Go: "Acme", Legacy: "Acme Inc"},
+		},
+	}, nil
+}
+
+func (f *fakeReconcileSvc) TriggerRun(context.Context) (bool, error) {
+	return f.queued, nil
+}
+
+func TestReconcile_Endpoints(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, ReconcileSvc: &fakeReconcileSvc{queued: true}})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/reconciliation/runs/latest", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{
+		"run": {"id":"r1","source":"postgres","status":"done","tenants_checked":0,"templates_checked":0,"diff_count":1,"started_at":"0001-01-01T00:00:00Z"},
+		"diffs": [{"entity":"tenant","key":"acme","kind":"mismatch","field":"name","go":"Acme","legacy":"Acme Inc"}]
+	}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/reconciliation/runs/nope", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("POST", "/v1/reconciliation/runs", nil))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.JSONEq(t, `{"queued":true}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/reconciliation/runs?limit=x", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestReconcile_NotMountedWithoutService(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}})
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/reconciliation/runs", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -1,52 +1,227 @@
 package httpapi
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/idempotency"
+	"gochatbot/internal/metrics"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/ratelimit"
 )
 
+// Pinger is satisfied by *pgxpool.Pool.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 type Deps struct {
 	TenantSvc   TenantService
 	TemplateSvc TemplateService
+
+	// LeadSvc is optional; when set, /v1/tenants/{slug}/leads is served.
+	LeadSvc LeadService
+
+	// WebhookSvc is optional; when set, /v1/tenants/{slug}/webhooks is served.
+	WebhookSvc WebhookService
+
+	// SessionSvc is optional; when set, tenants start sessions at
+	// /v1/tenants/{slug}/sessions and close them at .../sessions/{id}/close.
+	SessionSvc SessionService
+
+	// UploadSvc is optional; when set, sessions take file uploads at
+	// /v1/tenants/{slug}/sessions/{id}/uploads and signed links are served
+	// at /v1/files/{id}.
+	UploadSvc UploadService
+
+	// ReconcileSvc is optional; when set, /v1/reconciliation is served. Those
+	// routes exist only in Go and are never under cutover control.
+	ReconcileSvc ReconcileService
+
+	// DB backs /readyz; when nil, readiness only reflects draining.
+	DB Pinger
+
+	// Metrics is optional; when set, requests are instrumented and /metrics is served.
+	Metrics *metrics.Metrics
+
+	// Idempotency is optional; when set, mutating /v1 requests honour the
+	// Idempotency-Key header.
+	Idempotency *idempotency.Guard
+
+	// Cutover is optional; when set, each /v1 route is served by Go, proxied
+	// to the legacy backend or shadowed, per its current RouteModes table.
+	Cutover *Cutover
+
+	// PublishWithoutIfMatch still accepts publish requests without
+	// If-Match, for clients written before ETags; every other mutation
+	// requires it.
+	PublishWithoutIfMatch bool
+
+	// Cursors signs list cursors; nil uses a random per-process key.
+	Cursors *pagination.Codec
+
+	// SessionEvents is optional; when set, GET /v1/sessions/{id}/events
+	// streams a session's messages as Server-Sent Events.
+	SessionEvents SessionEventService
+
+	// Chat is optional; with SessionEvents it enables the widget socket at
+	// GET /v1/sessions/{id}/ws.
+	Chat ChatService
+
+	// Heartbeat is how often an idle event stream gets a comment line and a
+	// socket a ping; 0 means 15s.
+	Heartbeat time.Duration
+
+	// RateLimit is optional; when set, each /v1 route group is throttled by
+	// its rules. Groups: tenants, templates, reconciliation, sessions, files.
+	RateLimit *ratelimit.Limiter
 }
 
 type Server struct {
 	r    chi.Router
 	deps Deps
+
+	draining  atomic.Bool
+	drained   chan struct{} // closed by Drain; ends event streams and sockets
+	drainOnce sync.Once
 }
 
 func New(deps Deps) *Server {
+	if deps.Cursors == nil {
+		deps.Cursors = pagination.NewCodec(nil)
+	}
+	if deps.Heartbeat <= 0 {
+		deps.Heartbeat = 15 * time.Second
+	}
 	r := chi.NewRouter()
-	s := &Server{r: r, deps: deps}
+	s := &Server{r: r, deps: deps, drained: make(chan struct{})}
+
+	r.Use(requestID)
+	r.Use(traceRequests)
+	if deps.Metrics != nil {
+		r.Use(instrument(deps.Metrics))
+		r.Method(http.MethodGet, "/metrics", deps.Metrics.Handler())
+	}
 
 	r.Get("/healthz", s.handleHealth)
+	r.Get("/readyz", s.handleReady)
+	r.Get("/openapi.json", s.handleOpenAPI)
 
+	// every /v1 route is mounted behind protect, which rate limits before
+	// the Idempotency-Key guard runs
 	r.Route("/v1", func(r chi.Router) {
 		r.Route("/tenants", func(r chi.Router) {
-			r.Get("/", s.handleListTenants)
-			r.Post("/", s.handleCreateTenant)
-			r.Get("/{slug}", s.handleGetTenantBySlug)
-		})
+			r.With(s.protect("tenants")).Get("/", s.route(s.handleListTenants))
+			r.With(s.protect("tenants")).Post("/", s.route(s.handleCreateTenant))
 
-		r.Route("/tenants/{tenantSlug}", func(r chi.Router) {
-			r.Route("/templates", func(r chi.Router) {
-				r.Get("/", s.handleListTemplates)
-				r.Post("/", s.handleCreateTemplate)
-				r.Get("/{templateSlug}", s.handleGetTemplateBySlug)
+			// one subtree per tenant: a sibling "/tenants/{tenantSlug}" mount
+			// would shadow GET /tenants/{slug}
+			r.Route("/{tenantSlug}", func(r chi.Router) {
+				// limited here, not above, so {tenantSlug} is already matched
+				r.Use(s.protect("tenants"))
+				r.Get("/", s.route(s.handleGetTenantBySlug))
+				r.Patch("/", s.route(s.handleRenameTenant))
+				r.Route("/templates", func(r chi.Router) {
+					r.Get("/", s.route(s.handleListTemplates))
+					r.Post("/", s.route(s.handleCreateTemplate))
+					r.Get("/{templateSlug}", s.route(s.handleGetTemplateBySlug))
+					r.Patch("/{templateSlug}", s.route(s.handleRenameTemplate))
+				})
+				// Go only, like the routes below: Node never exposed leads
+				if deps.LeadSvc != nil {
+					r.Route("/leads", func(r chi.Router) {
+						r.Get("/", s.handleListLeads)
+						r.Get("/export", s.handleExportLeads)
+						r.Get("/{leadID}", s.handleGetLead)
+						r.Patch("/{leadID}", s.handleUpdateLead)
+					})
+				}
+				if deps.WebhookSvc != nil {
+					r.Route("/webhooks", func(r chi.Router) {
+						r.Get("/", s.handleListWebhooks)
+						r.Post("/", s.handleCreateWebhook)
+						r.Get("/{webhookID}", s.handleGetWebhook)
+						r.Delete("/{webhookID}", s.handleDeleteWebhook)
+						r.Get("/{webhookID}/deliveries", s.handleListWebhookDeliveries)
+						r.Post("/{webhookID}/deliveries/{deliveryID}/redeliver", s.handleRedeliver)
+					})
+				}
+				if deps.SessionSvc != nil {
+					r.Post("/sessions", s.handleStartSession)
+					r.Post("/sessions/{sessionID}/close", s.handleCloseSession)
+				}
+				if deps.UploadSvc != nil {
+					r.Post("/sessions/{sessionID}/uploads", s.handleCreateUpload)
+				}
 			})
 		})
 
 		r.Route("/templates/{templateID}", func(r chi.Router) {
-			r.Post("/drafts", s.handleCreateDraft)
-			r.Post("/publish", s.handlePublish)
-			r.Get("/published", s.handleGetPublished)
+			r.Use(s.protect("templates"))
+			r.Post("/drafts", s.route(s.handleCreateDraft))
+			r.Post("/publish", s.route(s.handlePublish))
+			r.Get("/published", s.route(s.handleGetPublished))
+			r.Get("/versions/{version}", s.route(s.handleGetVersion))
+			r.Put("/versions/{version}", s.route(s.handleUpdateDraft))
 		})
+
+		if deps.ReconcileSvc != nil {
+			r.Route("/reconciliation/runs", func(r chi.Router) {
+				r.Use(s.protect("reconciliation"))
+				r.Get("/", s.handleListReconcileRuns)
+				r.Post("/", s.handleTriggerReconcileRun)
+				r.Get("/{runID}", s.handleGetReconcileReport)
+			})
+		}
+
+		// the signature is the credential: links go out in emailed transcripts
+		if deps.UploadSvc != nil {
+			r.With(s.protect("files")).Get("/files/{uploadID}", s.handleDownloadUpload)
+		}
+
+		// Go only: the legacy backend has no event stream to cut over from
+		if deps.SessionEvents != nil {
+			r.Route("/sessions/{sessionID}", func(r chi.Router) {
+				r.Use(s.protect("sessions"))
+				r.Get("/events", s.handleSessionEvents)
+				if deps.Chat != nil {
+					r.Get("/ws", s.handleSessionSocket)
+				}
+			})
+		}
 	})
 
 	return s
 }
 
+// route puts a /v1 handler under cutover control when it is configured.
+func (s *Server) route(h http.HandlerFunc) http.HandlerFunc {
+	if s.deps.Cutover == nil {
+		return h
+	}
+	return s.deps.Cutover.wrap(h)
+}
+
+// protect throttles a route group when rate limiting is configured, then
+// honours Idempotency-Key when that is: a refused request never reaches the
+// idempotency store.
+func (s *Server) protect(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if s.deps.Idempotency != nil {
+			next = s.deps.Idempotency.Middleware(next)
+		}
+		if s.deps.RateLimit != nil {
+			next = s.deps.RateLimit.Middleware(group)(next)
+		}
+		return next
+	}
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.r.ServeHTTP(w, r)
 }
@@ -55,3 +230,29 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
+
+// Drain makes /readyz fail so load balancers stop routing here while
+// in-flight requests finish, and ends open event streams and sockets so
+// they do not hold up shutdown. /healthz is unaffected: the process is
+// still alive.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+	s.drainOnce.Do(func() { close(s.drained) })
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "draining"})
+		return
+	}
+	if s.deps.DB != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		if err := s.deps.DB.Ping(ctx); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "database unavailable"})
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ready"))
+}
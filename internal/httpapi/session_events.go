@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/domain"
+)
+
+type Message struct {
+	ID        string         `json:"id"`
+	SessionID string         `json:"session_id"`
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	ToolName  string         `json:"tool_name,omitempty"`
+	ToolData  map[string]any `json:"tool_data,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Session event types, used as the SSE event name.
+const (
+	EventMessage = "message"
+	EventTyping  = "typing"
+	EventClosed  = "closed"
+)
+
+// SessionEvent is one item of a session's stream: a message, a typing
+// indicator while a reply is written, or the closed event that ends it.
+type SessionEvent struct {
+	Type    string
+	Seq     int64 // message position, sent as the SSE id; 0 otherwise
+	Message *Message
+}
+
+type SessionEventService interface {
+	// Subscribe streams the session's user and assistant messages with a
+	// seq above afterSeq, then new ones as they are stored. The channel is closed after the
+	// closed event, when ctx ends, or when reading fails; clients resume
+	// from the last id they saw.
+	Subscribe(ctx context.Context, sessionID string, afterSeq int64) (<-chan SessionEvent, error)
+}
+
+// sseRetry is how long EventSource clients wait before reconnecting.
+const sseRetry = 3 * time.Second
+
+// handleSessionEvents serves a session as Server-Sent Events. EventSource
+// sends Last-Event-ID on reconnect; a first connection may pass
+// ?last_event_id instead.
+func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events, ok := s.subscribe(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		log.Printf("session events: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(s.deps.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+			if ev.Type == EventClosed {
+				_ = rc.Flush()
+				return
+			}
+		case <-heartbeat.C:
+			// a comment line: ignored by EventSource, keeps proxies from
+			// timing out an idle stream
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-s.drained:
+			// the client reconnects to another replica with Last-Event-ID
+			return
+		case <-ctx.Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// subscribe opens the session's event stream from the last event id the
+// client saw, writing the error response when it cannot.
+func (s *Server) subscribe(ctx context.Context, w http.ResponseWriter, r *http.Request) (<-chan SessionEvent, bool) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	var after int64
+	if raw = strings.TrimSpace(raw); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid last event id"})
+			return nil, false
+		}
+		after = n
+	}
+
+	events, err := s.deps.SessionEvents.Subscribe(ctx, chi.URLParam(r, "sessionID"), after)
+	if err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+			return nil, false
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return nil, false
+	}
+	return events, true
+}
+
+func writeEvent(w io.Writer, ev SessionEvent) error {
+	data := []byte("{}")
+	if ev.Message != nil {
+		var err error
+		if data, err = json.Marshal(ev.Message); err != nil {
+			return err
+		}
+	}
+	if ev.Seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", ev.Seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
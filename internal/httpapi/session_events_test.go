@@ -0,0 +1,102 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+)
+
+// fakeEvents replays events, then holds the stream open unless it ends
+// with a closed event.
+type fakeEvents struct {
+	events []httpapi.SessionEvent
+	after  int64
+}
+
+func (f *fakeEvents) Subscribe(ctx context.Context, sessionID string, after int64) (<-chan httpapi.SessionEvent, error) {
+	if sessionID != "s1" {
+		return nil, domain.ErrSessionNotFound
+	}
+	f.after = after
+	ch := make(chan httpapi.SessionEvent)
+	go func() {
+		defer close(ch)
+		for _, ev := range f.events {
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return ch, nil
+}
+
+var created = time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
+
+func TestSessionEvents_StreamsUntilClosed(t *testing.T) {
+	fake := &fakeEvents{events: []httpapi.SessionEvent{
+		{Type: httpapi.EventMessage, Seq: 8, Message: &httpapi.Message{ID: "m8", SessionID: "s1", Role: "assistant", Content: "Hi!", CreatedAt: created}},
+		{Type: httpapi.EventClosed},
+	}}
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: fake})
+
+	req := httptest.NewRequest("GET", "/v1/sessions/s1/events", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	require.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+	require.Equal(t, int64(7), fake.after)
+	require.Equal(t, "retry: 3000\n\n"+
+		"id: 8\nevent: message\n"+
+		`data: {"id":"m8","session_id":"s1","role":"assistant","content":"Hi!","created_at":"2025-12-18T12:00:00Z"}`+"\n\n"+
+		"event: closed\ndata: {}\n\n", rr.Body.String())
+}
+
+func TestSessionEvents_BadRequests(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: &fakeEvents{}})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/sessions/nope/events", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/sessions/s1/events?last_event_id=x", nil))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSessionEvents_HeartbeatAndDrain(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: &fakeEvents{}, Heartbeat: 5 * time.Millisecond})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/sessions/s1/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), ": heartbeat") {
+			break
+		}
+	}
+	require.NoError(t, lines.Err())
+
+	s.Drain()
+	for lines.Scan() {
+	}
+	require.NoError(t, lines.Err(), "drain ends the stream cleanly")
+}
@@ -0,0 +1,214 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+
+	"gochatbot/internal/domain"
+)
+
+// Socket frame types besides the session event types, which the server
+// also sends as frames.
+const (
+	FrameAck   = "ack"
+	FrameError = "error"
+	FramePing  = "ping"
+	FramePong  = "pong"
+)
+
+// ClientFrame is a JSON text frame from the widget: "message" with Content
+// and an optional ID echoed by its ack or error, "ping", or "pong".
+type ClientFrame struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ServerFrame is a JSON text frame to the widget: a session event
+// (message, typing, closed), the ack or error for a client message, or a
+// ping/pong.
+type ServerFrame struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id,omitempty"`
+	Seq     int64    `json:"seq,omitempty"`
+	Message *Message `json:"message,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type ChatService interface {
+	// PostUserMessage stores content as a user message of the session; it
+	// fails with domain.ErrSessionClosed or domain.ErrEmptyMessage.
+	PostUserMessage(ctx context.Context, sessionID, content string) (Message, error)
+}
+
+const (
+	// socketWriteWait bounds each frame write: a client that stops reading
+	// is dropped instead of having frames queue up for it.
+	socketWriteWait = 10 * time.Second
+	// socketMaxFrame caps an incoming frame; a larger one ends the socket.
+	socketMaxFrame = 16 << 10
+	// socketQueue is how many replies the reader may get ahead of the
+	// writer before it stops reading.
+	socketQueue = 16
+)
+
+// handleSessionSocket serves a session over a WebSocket. The server pushes
+// the same events as the SSE stream, resuming after ?last_event_id, and
+// stores the client's messages as user messages. Each side pings; a client
+// that sends nothing for two heartbeats is disconnected.
+func (s *Server) handleSessionSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		writeJSON(w, http.StatusUpgradeRequired, map[string]any{"error": "websocket upgrade required"})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events, ok := s.subscribe(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	sessionID := chi.URLParam(r, "sessionID")
+	websocket.Server{
+		// the widget runs on customer sites and sends no credentials, so
+		// any origin may connect
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = socketMaxFrame
+			s.serveSocket(ctx, ws, sessionID, events)
+		},
+	}.ServeHTTP(hijacker{w}, r)
+}
+
+// hijacker reaches Hijack through wrapping ResponseWriters;
+// x/net/websocket type-asserts it on the writer it is given.
+type hijacker struct{ http.ResponseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(h.ResponseWriter).Hijack()
+}
+
+// serveSocket is the writer side: every frame goes out from here, so
+// events are pulled from the subscription only as fast as the client
+// reads them.
+func (s *Server) serveSocket(ctx context.Context, ws *websocket.Conn, sessionID string, events <-chan SessionEvent) {
+	ctx, cancel := context.WithCancel(ctx)
+	replies := make(chan ServerFrame, socketQueue)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		s.readSocket(ctx, ws, sessionID, replies)
+	}()
+	defer func() {
+		cancel()
+		_ = ws.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		_ = ws.Close()
+		<-done
+	}()
+
+	ping := time.NewTicker(s.deps.Heartbeat)
+	defer ping.Stop()
+	for {
+		var f ServerFrame
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				// the client reconnects with the last seq it saw
+				return
+			}
+			f = ServerFrame{Type: ev.Type, Seq: ev.Seq, Message: ev.Message}
+		case f = <-replies:
+		case <-ping.C:
+			f = ServerFrame{Type: FramePing}
+		case <-s.drained:
+			return
+		case <-ctx.Done():
+			return
+		}
+		_ = ws.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		if err := websocket.JSON.Send(ws, f); err != nil {
+			return
+		}
+		if f.Type == EventClosed {
+			return
+		}
+	}
+}
+
+// readSocket handles client frames one at a time. When replies is full it
+// blocks, which stops reading and pushes back on the client through TCP.
+func (s *Server) readSocket(ctx context.Context, ws *websocket.Conn, sessionID string, replies chan<- ServerFrame) {
+	reply := func(f ServerFrame) bool {
+		select {
+		case replies <- f:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		// any frame counts as alive; a client answers our pings within
+		// a heartbeat
+		_ = ws.SetReadDeadline(time.Now().Add(2 * s.deps.Heartbeat))
+		var in ClientFrame
+		if err := websocket.JSON.Receive(ws, &in); err != nil {
+			var syntax *json.SyntaxError
+			var typ *json.UnmarshalTypeError
+			if errors.As(err, &syntax) || errors.As(err, &typ) {
+				// the frame was read whole, so the socket is still usable
+				if !reply(ServerFrame{Type: FrameError, Error: "invalid frame"}) {
+					return
+				}
+				continue
+			}
+			return
+		}
+
+		var out ServerFrame
+		switch in.Type {
+		case FramePing:
+			out = ServerFrame{Type: FramePong}
+		case FramePong:
+			continue
+		case EventMessage:
+			m, err := s.deps.Chat.PostUserMessage(ctx, sessionID, in.Content)
+			if err != nil {
+				out = ServerFrame{Type: FrameError, ID: in.ID, Error: chatError(sessionID, err)}
+			} else {
+				out = ServerFrame{Type: FrameAck, ID: in.ID, Message: &m}
+			}
+		default:
+			out = ServerFrame{Type: FrameError, ID: in.ID, Error: "invalid frame"}
+		}
+		if !reply(out) {
+			return
+		}
+	}
+}
+
+func chatError(sessionID string, err error) string {
+	switch {
+	case errors.Is(err, domain.ErrSessionClosed):
+		return "session closed"
+	case errors.Is(err, domain.ErrEmptyMessage):
+		return "empty message"
+	case errors.Is(err, domain.ErrSessionNotFound):
+		return "not found"
+	default:
+		log.Printf("session socket %s: %v", sessionID, err)
+		return "internal"
+	}
+}
@@ -0,0 +1,136 @@
+package httpapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+)
+
+type fakeChat struct{}
+
+func (fakeChat) PostUserMessage(_ context.Context, sessionID, content string) (httpapi.Message, error) {
+	switch content = strings.TrimSpace(content); content {
+	case "":
+		return httpapi.Message{}, domain.ErrEmptyMessage
+	case "too late":
+		return httpapi.Message{}, domain.ErrSessionClosed
+	}
+	return httpapi.Message{ID: "m9", SessionID: sessionID, Role: "user", Content: content, CreatedAt: created}, nil
+}
+
+func dialSocket(t *testing.T, s *httpapi.Server, query string) *websocket.Conn {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/sessions/s1/ws"+query, "", "https://widget.example")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func recvFrame(t *testing.T, ws *websocket.Conn) httpapi.ServerFrame {
+	t.Helper()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var f httpapi.ServerFrame
+	require.NoError(t, websocket.JSON.Receive(ws, &f))
+	return f
+}
+
+func TestSessionSocket_EventsAndClientMessages(t *testing.T) {
+	fake := &fakeEvents{events: []httpapi.SessionEvent{
+		{Type: httpapi.EventMessage, Seq: 8, Message: &httpapi.Message{ID: "m8", SessionID: "s1", Role: "assistant", Content: "Hi!", CreatedAt: created}},
+		{Type: httpapi.EventTyping},
+	}}
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: fake, Chat: fakeChat{}})
+	ws := dialSocket(t, s, "?last_event_id=7")
+	require.Equal(t, int64(7), fake.after)
+
+	f := recvFrame(t, ws)
+	require.Equal(t, httpapi.EventMessage, f.Type)
+	require.Equal(t, int64(8), f.Seq)
+	require.Equal(t, "Hi!", f.Message.Content)
+	require.Equal(t, httpapi.EventTyping, recvFrame(t, ws).Type)
+
+	require.NoError(t, websocket.JSON.Send(ws, httpapi.ClientFrame{Type: "message", ID: "c1", Content: " hello "}))
+	f = recvFrame(t, ws)
+	require.Equal(t, httpapi.FrameAck, f.Type)
+	require.Equal(t, "c1", f.ID)
+	require.Equal(t, "m9", f.Message.ID)
+	require.Equal(t, "hello", f.Message.Content)
+
+	for content, want := range map[string]string{"  ": "empty message", "too late": "session closed"} {
+		require.NoError(t, websocket.JSON.Send(ws, httpapi.ClientFrame{Type: "message", ID: "c2", Content: content}))
+		f = recvFrame(t, ws)
+		require.Equal(t, httpapi.ServerFrame{Type: httpapi.FrameError, ID: "c2", Error: want}, f)
+	}
+
+	require.NoError(t, websocket.Message.Send(ws, "not json"))
+	require.Equal(t, httpapi.ServerFrame{Type: httpapi.FrameError, Error: "invalid frame"}, recvFrame(t, ws))
+	require.NoError(t, websocket.JSON.Send(ws, httpapi.ClientFrame{Type: "shout"}))
+	require.Equal(t, httpapi.ServerFrame{Type: httpapi.FrameError, Error: "invalid frame"}, recvFrame(t, ws))
+
+	require.NoError(t, websocket.JSON.Send(ws, httpapi.ClientFrame{Type: "ping"}))
+	require.Equal(t, httpapi.FramePong, recvFrame(t, ws).Type)
+}
+
+func TestSessionSocket_ClosedEndsSocket(t *testing.T) {
+	fake := &fakeEvents{events: []httpapi.SessionEvent{{Type: httpapi.EventClosed}}}
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: fake, Chat: fakeChat{}})
+	ws := dialSocket(t, s, "")
+
+	require.Equal(t, httpapi.EventClosed, recvFrame(t, ws).Type)
+	var f httpapi.ServerFrame
+	require.Error(t, websocket.JSON.Receive(ws, &f))
+}
+
+func TestSessionSocket_PingsAndDropsSilentClient(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: &fakeEvents{}, Chat: fakeChat{}, Heartbeat: 20 * time.Millisecond})
+	ws := dialSocket(t, s, "")
+
+	require.Equal(t, httpapi.FramePing, recvFrame(t, ws).Type)
+	// never answering: the server gives up after two heartbeats
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		var f httpapi.ServerFrame
+		if err := websocket.JSON.Receive(ws, &f); err != nil {
+			require.NotContains(t, err.Error(), "timeout", "server closed the socket")
+			return
+		}
+		require.Equal(t, httpapi.FramePing, f.Type)
+	}
+}
+
+func TestSessionSocket_DrainClosesSocket(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: &fakeEvents{}, Chat: fakeChat{}})
+	ws := dialSocket(t, s, "")
+
+	s.Drain()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var f httpapi.ServerFrame
+	err := websocket.JSON.Receive(ws, &f)
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "timeout")
+}
+
+func TestSessionSocket_BadRequests(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, SessionEvents: &fakeEvents{}, Chat: fakeChat{}})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/sessions/s1/ws", nil))
+	require.Equal(t, http.StatusUpgradeRequired, rr.Code)
+
+	req := httptest.NewRequest("GET", "/v1/sessions/nope/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/validate"
+)
+
+// Session is a widget conversation. TemplateID is empty when the session
+// has no template.
+type Session struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	TemplateID string     `json:"template_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"`
+}
+
+type SessionService interface {
+	// StartSession opens a session for the tenant; templateID may be empty.
+	StartSession(ctx context.Context, tenantID, templateID string) (Session, error)
+	// CloseSession closes the tenant's session, creating its lead and
+	// queueing the lead's export; closing a closed session is a no-op.
+	CloseSession(ctx context.Context, tenantID, sessionID string) error
+}
+
+type startSessionReq struct {
+	// Template is the slug of one of the tenant's templates; optional.
+	Template string `json:"template"`
+}
+
+// handleStartSession takes an optional body naming the template the
+// assistant should follow.
+func (s *Server) handleStartSession(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req startSessionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+
+	var templateID string
+	if req.Template != "" {
+		slug, err := validate.NormalizeSlug(req.Template)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": domain.ErrInvalidSlug.Error()})
+			return
+		}
+		tpl, err := s.deps.TemplateSvc.GetTemplate(r.Context(), tenant.ID, slug)
+		if err != nil {
+			if errors.Is(err, domain.ErrTemplateNotFound) {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "template not found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+			return
+		}
+		templateID = tpl.ID
+	}
+
+	sess, err := s.deps.SessionSvc.StartSession(r.Context(), tenant.ID, templateID)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+func (s *Server) handleCloseSession(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.deps.SessionSvc.CloseSession(r.Context(), tenant.ID, chi.URLParam(r, "sessionID")); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrSessionNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "session not found"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+	}
+}
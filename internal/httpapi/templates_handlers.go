@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,11 +22,14 @@ type Template struct {
 	Name      string    `json:"name"`
 	Slug      string    `json:"slug"`
 	CreatedAt time.Time `json:"created_at"`
+	Revision  int64     `json:"-"` // see Tenant.Revision
 }
 
 type ListTemplatesResult struct {
-	Items      []Template `json:"items"`
-	NextCursor string     `json:"next_cursor,omitempty"`
+	Items      []Template      `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+	Page       pagination.Page `json:"-"` // see ListTenantsResult.Page
 }
 
 type TemplateVersion struct {
@@ -35,15 +39,19 @@ type TemplateVersion struct {
 	Status     string          `json:"status"`
 	Content    json.RawMessage `json:"content"`
 	CreatedAt  time.Time       `json:"created_at"`
+	Revision   int64           `json:"-"`
 }
 
 type TemplateService interface {
 	CreateTemplate(ctx context.Context, tenantID, name, slug string) (Template, error)
 	GetTemplate(ctx context.Context, tenantID, slug string) (Template, error)
-	ListTemplates(ctx context.Context, tenantID string, limit int, cursor *pagination.Cursor) (ListTemplatesResult, error)
+	ListTemplates(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) (ListTemplatesResult, error)
+	RenameTemplate(ctx context.Context, templateID, name string, ifRevision int64) (Template, error)
 	CreateDraft(ctx context.Context, templateID string, content json.RawMessage) (TemplateVersion, error)
-	Publish(ctx context.Context, templateID string, version int) (TemplateVersion, error)
+	UpdateDraft(ctx context.Context, templateID string, version int, content json.RawMessage, ifRevision int64) (TemplateVersion, error)
+	Publish(ctx context.Context, templateID string, version int, ifRevision int64) (TemplateVersion, error)
 	GetPublished(ctx context.Context, templateID string) (TemplateVersion, error)
+	GetVersion(ctx context.Context, templateID string, version int) (TemplateVersion, error)
 }
 
 type createTemplateReq struct {
@@ -59,7 +67,7 @@ func (s *Server) handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tenant, err := s.deps.TenantSvc.GetTenantBySlug(RequestContext{}, tenantSlug)
+	tenant, err := s.deps.TenantSvc.GetTenantBySlug(requestContext(r), tenantSlug)
 	if err != nil {
 		if errors.Is(err, domain.ErrTenantNotFound) {
 			writeJSON(w, http.StatusNotFound, map[string]any{"error": "tenant not found"})
@@ -116,7 +124,7 @@ func (s *Server) handleGetTemplateBySlug(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tenant, err := s.deps.TenantSvc.GetTenantBySlug(RequestContext{}, tenantSlug)
+	tenant, err := s.deps.TenantSvc.GetTenantBySlug(requestContext(r), tenantSlug)
 	if err != nil {
 		if errors.Is(err, domain.ErrTenantNotFound) {
 			writeJSON(w, http.StatusNotFound, map[string]any{"error": "tenant not found"})
@@ -136,6 +144,73 @@ func (s *Server) handleGetTemplateBySlug(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	writeTagged(w, r, tpl.Revision, tpl)
+}
+
+// handleRenameTemplate addresses the template by slug like the GET above,
+// so a client can PATCH with the ETag it just read.
+func (s *Server) handleRenameTemplate(w http.ResponseWriter, r *http.Request) {
+	tenantSlug := chi.URLParam(r, "tenantSlug")
+	tenantSlug, err := validate.NormalizeSlug(tenantSlug)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidSlug.Error()})
+		return
+	}
+	templateSlug := chi.URLParam(r, "templateSlug")
+	templateSlug, err = validate.NormalizeSlug(templateSlug)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidSlug.Error()})
+		return
+	}
+	rev, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req renameReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+	req.Name = trim(req.Name)
+	if req.Name == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "invalid name"})
+		return
+	}
+
+	tenant, err := s.deps.TenantSvc.GetTenantBySlug(requestContext(r), tenantSlug)
+	if err != nil {
+		if errors.Is(err, domain.ErrTenantNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "tenant not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	tpl, err := s.deps.TemplateSvc.GetTemplate(r.Context(), tenant.ID, templateSlug)
+	if err != nil {
+		if errors.Is(err, domain.ErrTemplateNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+
+	tpl, err = s.deps.TemplateSvc.RenameTemplate(r.Context(), tpl.ID, req.Name, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTemplateNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+		case errors.Is(err, domain.ErrRevisionMismatch):
+			writeJSON(w, http.StatusPreconditionFailed, map[string]any{"error": "precondition failed"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(tpl.Revision))
 	writeJSON(w, http.StatusOK, tpl)
 }
 
@@ -147,7 +222,7 @@ func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tenant, err := s.deps.TenantSvc.GetTenantBySlug(RequestContext{}, tenantSlug)
+	tenant, err := s.deps.TenantSvc.GetTenantBySlug(requestContext(r), tenantSlug)
 	if err != nil {
 		if errors.Is(err, domain.ErrTenantNotFound) {
 			writeJSON(w, http.StatusNotFound, map[string]any{"error": "tenant not found"})
@@ -163,9 +238,15 @@ func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	f, err := parseListFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
 	var cur *pagination.Cursor
 	if raw := strings.TrimSpace(r.URL.Query().Get("cursor")); raw != "" {
-		decoded, err := pagination.Decode(raw)
+		decoded, err := s.deps.Cursors.Decode(raw, f)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidCursor.Error()})
 			return
@@ -173,11 +254,12 @@ func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 		cur = &decoded
 	}
 
-	res, err := s.deps.TemplateSvc.ListTemplates(r.Context(), tenant.ID, limit, cur)
+	res, err := s.deps.TemplateSvc.ListTemplates(r.Context(), tenant.ID, limit, f, cur)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
 		return
 	}
+	res.NextCursor, res.PrevCursor = s.encodePage(res.Page, f)
 	writeJSON(w, http.StatusOK, res)
 }
 
@@ -218,6 +300,13 @@ func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// publish predates ETags; PublishWithoutIfMatch lets clients that
+	// still omit the header through while they move over
+	rev, ok := ifMatch(w, r, !s.deps.PublishWithoutIfMatch)
+	if !ok {
+		return
+	}
+
 	var req publishReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
@@ -228,7 +317,7 @@ func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	v, err := s.deps.TemplateSvc.Publish(r.Context(), templateID, req.Version)
+	v, err := s.deps.TemplateSvc.Publish(r.Context(), templateID, req.Version, rev)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrVersionNotFound):
@@ -237,12 +326,16 @@ func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, domain.ErrVersionAlreadyPublished):
 			writeJSON(w, http.StatusConflict, map[string]any{"error": "already published"})
 			return
+		case errors.Is(err, domain.ErrRevisionMismatch):
+			writeJSON(w, http.StatusPreconditionFailed, map[string]any{"error": "precondition failed"})
+			return
 		default:
 			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
 			return
 		}
 	}
 
+	w.Header().Set("ETag", etag(v.Revision))
 	writeJSON(w, http.StatusOK, v)
 }
 
@@ -262,5 +355,72 @@ func (s *Server) handleGetPublished(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
 		return
 	}
+	writeTagged(w, r, v.Revision, v)
+}
+
+func parseVersion(w http.ResponseWriter, r *http.Request) (templateID string, version int, ok bool) {
+	templateID = chi.URLParam(r, "templateID")
+	if strings.TrimSpace(templateID) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid template id"})
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid version"})
+		return "", 0, false
+	}
+	return templateID, version, true
+}
+
+func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	templateID, version, ok := parseVersion(w, r)
+	if !ok {
+		return
+	}
+
+	v, err := s.deps.TemplateSvc.GetVersion(r.Context(), templateID, version)
+	if err != nil {
+		if errors.Is(err, domain.ErrVersionNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	writeTagged(w, r, v.Revision, v)
+}
+
+func (s *Server) handleUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	templateID, version, ok := parseVersion(w, r)
+	if !ok {
+		return
+	}
+	rev, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req createDraftReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+
+	v, err := s.deps.TemplateSvc.UpdateDraft(r.Context(), templateID, version, req.Content, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrVersionNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+		case errors.Is(err, domain.ErrPublishedVersionImmutable):
+			writeJSON(w, http.StatusConflict, map[string]any{"error": "published version is immutable"})
+		case errors.Is(err, domain.ErrRevisionMismatch):
+			writeJSON(w, http.StatusPreconditionFailed, map[string]any{"error": "precondition failed"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(v.Revision))
 	writeJSON(w, http.StatusOK, v)
 }
@@ -1,6 +1,7 @@
 package httpapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -17,21 +18,42 @@ type Tenant struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 	Slug string `json:"slug"`
+	// Revision backs the ETag header; it stays out of the body so responses
+	// match the legacy API.
+	Revision int64 `json:"-"`
 }
 
 type ListTenantsResult struct {
 	Items      []Tenant `json:"items"`
 	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
+	// Page is filled by the service; the handler signs it into the cursors.
+	Page pagination.Page `json:"-"`
 }
 
 type TenantService interface {
 	CreateTenant(rctx RequestContext, name string, slug string) (Tenant, error)
 	GetTenantBySlug(rctx RequestContext, slug string) (Tenant, error)
-	ListTenants(rctx RequestContext, limit int, cursor *pagination.Cursor) (ListTenantsResult, error)
+	RenameTenant(rctx RequestContext, slug string, name string, ifRevision int64) (Tenant, error)
+	ListTenants(rctx RequestContext, limit int, f pagination.Filter, cursor *pagination.Cursor) (ListTenantsResult, error)
 }
 
 type RequestContext struct {
-	// later: Auth info, tenant scope, request id
+	// Ctx carries the request's deadline and trace span; nil means background.
+	Ctx       context.Context
+	RequestID string
+	// later: Auth info, tenant scope
+}
+
+func (rc RequestContext) Context() context.Context {
+	if rc.Ctx == nil {
+		return context.Background()
+	}
+	return rc.Ctx
+}
+
+func requestContext(r *http.Request) RequestContext {
+	return RequestContext{Ctx: r.Context(), RequestID: RequestIDFrom(r.Context())}
 }
 
 type createTenantReq struct {
@@ -57,7 +79,7 @@ func (s *Server) handleCreateTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	t, err := s.deps.TenantSvc.CreateTenant(RequestContext{}, req.Name, slug)
+	t, err := s.deps.TenantSvc.CreateTenant(requestContext(r), req.Name, slug)
 	if err != nil {
 		if err == domain.ErrTenantSlugTaken {
 			writeJSON(w, http.StatusConflict, map[string]any{"error": "slug taken"})
@@ -71,14 +93,14 @@ func (s *Server) handleCreateTenant(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetTenantBySlug(w http.ResponseWriter, r *http.Request) {
-	slug := chi.URLParam(r, "slug")
+	slug := chi.URLParam(r, "tenantSlug")
 	slug, err := validate.NormalizeSlug(slug)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidSlug.Error()})
 		return
 	}
 
-	t, err := s.deps.TenantSvc.GetTenantBySlug(RequestContext{}, slug)
+	t, err := s.deps.TenantSvc.GetTenantBySlug(requestContext(r), slug)
 	if err != nil {
 		if errors.Is(err, domain.ErrTenantNotFound) {
 			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
@@ -88,6 +110,50 @@ func (s *Server) handleGetTenantBySlug(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeTagged(w, r, t.Revision, t)
+}
+
+type renameReq struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) handleRenameTenant(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "tenantSlug")
+	slug, err := validate.NormalizeSlug(slug)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidSlug.Error()})
+		return
+	}
+	rev, ok := ifMatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req renameReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+	req.Name = trim(req.Name)
+	if req.Name == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "invalid name"})
+		return
+	}
+
+	t, err := s.deps.TenantSvc.RenameTenant(requestContext(r), slug, req.Name, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTenantNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+		case errors.Is(err, domain.ErrRevisionMismatch):
+			writeJSON(w, http.StatusPreconditionFailed, map[string]any{"error": "precondition failed"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(t.Revision))
 	writeJSON(w, http.StatusOK, t)
 }
 
@@ -98,9 +164,15 @@ func (s *Server) handleListTenants(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	f, err := parseListFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
 	var cur *pagination.Cursor
 	if raw := strings.TrimSpace(r.URL.Query().Get("cursor")); raw != "" {
-		decoded, err := pagination.Decode(raw)
+		decoded, err := s.deps.Cursors.Decode(raw, f)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": domain.ErrInvalidCursor.Error()})
 			return
@@ -108,11 +180,12 @@ func (s *Server) handleListTenants(w http.ResponseWriter, r *http.Request) {
 		cur = &decoded
 	}
 
-	res, err := s.deps.TenantSvc.ListTenants(RequestContext{}, limit, cur)
+	res, err := s.deps.TenantSvc.ListTenants(requestContext(r), limit, f, cur)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
 		return
 	}
+	res.NextCursor, res.PrevCursor = s.encodePage(res.Page, f)
 
 	writeJSON(w, http.StatusOK, res)
 }
@@ -2,17 +2,25 @@ package httpapi_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"gochatbot/internal/domain"
 	"gochatbot/internal/httpapi"
+	"gochatbot/internal/idempotency"
+	"gochatbot/internal/metrics"
 	"gochatbot/internal/pagination"
+	"gochatbot/internal/ratelimit"
 )
 
 type fakeTenantSvc struct {
@@ -21,24 +29,30 @@ type fakeTenantSvc struct {
 	listErr   error
 
 	lastLimit  int
+	lastFilter pagination.Filter
 	lastCursor *pagination.Cursor
+	creates    int
+	revision   int64
+	page       pagination.Page
 }
 
-func (f *fakeTenantSvc) ListTenants(_ httpapi.RequestContext, limit int, cursor *pagination.Cursor) (httpapi.ListTenantsResult, error) {
+func (f *fakeTenantSvc) ListTenants(_ httpapi.RequestContext, limit int, filter pagination.Filter, cursor *pagination.Cursor) (httpapi.ListTenantsResult, error) {
 	if f.listErr != nil {
 		return httpapi.ListTenantsResult{}, f.listErr
 	}
 	f.lastLimit = limit
+	f.lastFilter = filter
 	f.lastCursor = cursor
 	return httpapi.ListTenantsResult{
 		Items: []httpapi.Tenant{
 			{ID: "t1", Name: "Acme", Slug: "acme"},
 		},
-		NextCursor: "",
+		Page: f.page,
 	}, nil
 }
 
 func (f *fakeTenantSvc) CreateTenant(_ httpapi.RequestContext, name, slug string) (httpapi.Tenant, error) {
+	f.creates++
 	if f.createErr != nil {
 		return httpapi.Tenant{}, f.createErr
 	}
@@ -49,7 +63,18 @@ func (f *fakeTenantSvc) GetTenantBySlug(_ httpapi.RequestContext, slug string) (
 	if f.getErr != nil {
 		return httpapi.Tenant{}, f.getErr
 	}
-	return httpapi.Tenant{ID: "t1", Name: "Acme", Slug: slug}, nil
+	return httpapi.Tenant{ID: "t1", Name: "Acme", Slug: slug, Revision: f.revision}, nil
+}
+
+func (f *fakeTenantSvc) RenameTenant(_ httpapi.RequestContext, slug, name string, ifRevision int64) (httpapi.Tenant, error) {
+	if f.getErr != nil {
+		return httpapi.Tenant{}, f.getErr
+	}
+	if ifRevision != 0 && ifRevision != f.revision {
+		return httpapi.Tenant{}, domain.ErrRevisionMismatch
+	}
+	f.revision++
+	return httpapi.Tenant{ID: "t1", Name: name, Slug: slug, Revision: f.revision}, nil
 }
 
 func TestHealthz(t *testing.T) {
@@ -103,6 +128,84 @@ func TestCreateTenant_BadJSON(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+// memIdempotency is an in-memory idempotency.Store.
+type memIdempotency map[string]idempotency.Record
+
+func (m memIdempotency) Begin(_ context.Context, key, hash string, _ time.Duration) (idempotency.Record, bool, error) {
+	if rec, ok := m[key]; ok {
+		return rec, false, nil
+	}
+	m[key] = idempotency.Record{Key: key, RequestHash: hash}
+	return idempotency.Record{}, true, nil
+}
+
+func (m memIdempotency) Complete(_ context.Context, key string, status int, contentType string, body []byte) error {
+	m[key] = idempotency.Record{Key: key, RequestHash: m[key].RequestHash, Done: true, Status: status, ContentType: contentType, Body: body}
+	return nil
+}
+
+func (m memIdempotency) Release(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestCreateTenant_IdempotencyKey(t *testing.T) {
+	fake := &fakeTenantSvc{}
+	s := httpapi.New(httpapi.Deps{TenantSvc: fake, Idempotency: idempotency.New(memIdempotency{}, time.Hour)})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/tenants", bytes.NewReader([]byte(body)))
+		req.Header.Set(idempotency.HeaderKey, "retry-1")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := post(`{"name":"Acme","slug":"acme"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	retry := post(`{"name":"Acme","slug":"acme"}`)
+	require.Equal(t, http.StatusCreated, retry.Code)
+	require.JSONEq(t, first.Body.String(), retry.Body.String())
+	require.Equal(t, 1, fake.creates)
+
+	require.Equal(t, http.StatusUnprocessableEntity, post(`{"name":"Other","slug":"acme"}`).Code)
+	require.Equal(t, 1, fake.creates)
+}
+
+// countingIdempotency counts the requests that reach the store.
+type countingIdempotency struct {
+	memIdempotency
+	begins int
+}
+
+func (c *countingIdempotency) Begin(ctx context.Context, key, hash string, ttl time.Duration) (idempotency.Record, bool, error) {
+	c.begins++
+	return c.memIdempotency.Begin(ctx, key, hash, ttl)
+}
+
+func TestCreateTenant_RateLimitedBeforeIdempotency(t *testing.T) {
+	rules, err := ratelimit.ParseRules([]string{"tenants.ip=1/1h"})
+	require.NoError(t, err)
+	store := &countingIdempotency{memIdempotency: memIdempotency{}}
+	s := httpapi.New(httpapi.Deps{
+		TenantSvc:   &fakeTenantSvc{},
+		Idempotency: idempotency.New(store, time.Hour),
+		RateLimit:   ratelimit.New(ratelimit.NewMemory(), rules),
+	})
+
+	post := func(key string) int {
+		req := httptest.NewRequest("POST", "/v1/tenants", bytes.NewReader([]byte(`{"name":"Acme","slug":"acme"}`)))
+		req.Header.Set(idempotency.HeaderKey, key)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusCreated, post("k1"))
+	require.Equal(t, http.StatusTooManyRequests, post("k2"))
+	require.Equal(t, 1, store.begins, "a refused request never touches the idempotency store")
+}
+
 func TestGetTenantBySlug_404(t *testing.T) {
 	s := httpapi.New(httpapi.Deps{
 		TenantSvc: &fakeTenantSvc{getErr: domain.ErrTenantNotFound},
@@ -115,6 +218,87 @@ func TestGetTenantBySlug_404(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, rr.Code)
 }
 
+func TestGetTenantBySlug_OK(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/tenants/acme-law", nil)
+
+	s.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"id":"t1","name":"Acme","slug":"acme-law"}`, rr.Body.String())
+}
+
+func TestGetTenantBySlug_ETag(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{revision: 41}})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants/acme-law", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `"41"`, rr.Header().Get("ETag"))
+
+	for _, inm := range []string{`"41"`, `W/"41"`, `"7", "41"`, `*`} {
+		req := httptest.NewRequest("GET", "/v1/tenants/acme-law", nil)
+		req.Header.Set("If-None-Match", inm)
+		rr = httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusNotModified, rr.Code, inm)
+		require.Empty(t, rr.Body.String())
+		require.Equal(t, `"41"`, rr.Header().Get("ETag"))
+	}
+
+	req := httptest.NewRequest("GET", "/v1/tenants/acme-law", nil)
+	req.Header.Set("If-None-Match", `"40"`)
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRenameTenant_IfMatch(t *testing.T) {
+	fake := &fakeTenantSvc{revision: 41}
+	s := httpapi.New(httpapi.Deps{TenantSvc: fake})
+
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/v1/tenants/acme-law", bytes.NewReader([]byte(`{"name":"Acme Legal"}`)))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusPreconditionRequired, patch("").Code)
+	require.Equal(t, http.StatusPreconditionFailed, patch(`W/"41"`).Code)
+	require.Equal(t, http.StatusPreconditionFailed, patch(`"40"`).Code)
+
+	rr := patch(`"41"`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `"42"`, rr.Header().Get("ETag"))
+	require.JSONEq(t, `{"id":"t1","name":"Acme Legal","slug":"acme-law"}`, rr.Body.String())
+
+	// the tag just used is stale now
+	require.Equal(t, http.StatusPreconditionFailed, patch(`"41"`).Code)
+	require.Equal(t, http.StatusOK, patch(`*`).Code)
+}
+
+func TestRateLimit_PerTenant(t *testing.T) {
+	rules, err := ratelimit.ParseRules([]string{"tenants.tenant=1/1h", "templates.ip=5/1h"})
+	require.NoError(t, err)
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, RateLimit: ratelimit.New(ratelimit.NewMemory(), rules)})
+
+	get := func(path string) int {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr.Code
+	}
+	require.Equal(t, http.StatusOK, get("/v1/tenants/acme"))
+	require.Equal(t, http.StatusTooManyRequests, get("/v1/tenants/acme"))
+	require.Equal(t, http.StatusOK, get("/v1/tenants/globex"))
+	require.Equal(t, http.StatusOK, get("/v1/tenants"), "lists have no tenant to key on")
+	require.Equal(t, http.StatusOK, get("/healthz"), "probes are never limited")
+}
+
 func TestCreateTenant_ConflictSlugTaken(t *testing.T) {
 	s := httpapi.New(httpapi.Deps{
 		TenantSvc: &fakeTenantSvc{createErr: domain.ErrTenantSlugTaken},
@@ -164,13 +348,15 @@ func TestListTenants_InvalidCursor(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+var testCursors = pagination.NewCodec([]byte("test-cursor-secret-at-least-32-bytes"))
+
 func TestListTenants_ValidCursor(t *testing.T) {
 	f := &fakeTenantSvc{}
-	s := httpapi.New(httpapi.Deps{TenantSvc: f})
+	s := httpapi.New(httpapi.Deps{TenantSvc: f, Cursors: testCursors})
 
 	// use real encoder to generate a valid cursor
 	c := pagination.Cursor{CreatedAt: time.Date(2025, 12, 18, 0, 0, 0, 0, time.UTC), ID: "t9"}
-	cur := pagination.Encode(c)
+	cur := testCursors.Encode(c, pagination.Filter{})
 
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/v1/tenants?cursor="+cur, nil)
@@ -180,3 +366,138 @@ func TestListTenants_ValidCursor(t *testing.T) {
 	require.NotNil(t, f.lastCursor)
 	require.Equal(t, "t9", f.lastCursor.ID)
 }
+
+func TestListTenants_FilterAndSort(t *testing.T) {
+	f := &fakeTenantSvc{}
+	s := httpapi.New(httpapi.Deps{TenantSvc: f})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/tenants?q=Ac&created_after=2025-01-01T00:00:00Z&created_before=2026-01-01T00:00:00Z&sort=name&order=desc", nil)
+	s.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "Ac", f.lastFilter.Prefix)
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), f.lastFilter.CreatedAfter)
+	require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), f.lastFilter.CreatedBefore)
+	require.Equal(t, pagination.Sort{By: pagination.SortName}, f.lastFilter.Sort)
+
+	for _, q := range []string{"sort=slug", "order=up", "created_after=yesterday", "created_after=2026-01-01T00:00:00Z&created_before=2025-01-01T00:00:00Z"} {
+		rr = httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants?"+q, nil))
+		require.Equal(t, http.StatusBadRequest, rr.Code, q)
+	}
+}
+
+func TestListTenants_CursorFromOtherQueryIsRejected(t *testing.T) {
+	f := &fakeTenantSvc{}
+	s := httpapi.New(httpapi.Deps{TenantSvc: f, Cursors: testCursors})
+
+	byName := pagination.Filter{Prefix: "ac", Sort: pagination.Sort{By: pagination.SortName, Asc: true}}
+	cur := testCursors.Encode(pagination.Cursor{Name: "Acme", ID: "t9", Sort: byName.Sort}, byName)
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants?q=ac&sort=name&cursor="+cur, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "Acme", f.lastCursor.Name)
+
+	for _, q := range []string{"q=ac&", "q=ac&sort=name&order=desc&", "q=ac&sort=created_at&order=asc&", "q=b&sort=name&", "sort=name&"} {
+		rr = httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants?"+q+"cursor="+cur, nil))
+		require.Equal(t, http.StatusBadRequest, rr.Code, q)
+		require.JSONEq(t, `{"error":"invalid cursor"}`, rr.Body.String())
+	}
+}
+
+func TestListTenants_SignedCursors(t *testing.T) {
+	f := &fakeTenantSvc{page: pagination.Page{
+		Next: &pagination.Cursor{CreatedAt: time.Date(2025, 12, 18, 0, 0, 0, 0, time.UTC), ID: "t9"},
+		Prev: &pagination.Cursor{CreatedAt: time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC), ID: "t1", Backward: true},
+	}}
+	s := httpapi.New(httpapi.Deps{TenantSvc: f, Cursors: testCursors})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var res struct {
+		NextCursor string `json:"next_cursor"`
+		PrevCursor string `json:"prev_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tenants?cursor="+res.PrevCursor, nil))
+	require.True(t, f.lastCursor.Backward)
+	require.Equal(t, "t1", f.lastCursor.ID)
+
+	// a cursor from another key, a tampered one, or an unsigned one is refused
+	other := pagination.NewCodec([]byte("another-cursor-secret-of-32-bytes!!"))
+	forged := other.Encode(pagination.Cursor{CreatedAt: time.Now(), ID: "t5"}, pagination.Filter{})
+	tampered := res.NextCursor[:len(res.NextCursor)-2] + "AA"
+	unsigned := pagination.Encode(pagination.Cursor{CreatedAt: time.Now(), ID: "t5"})
+	for _, c := range []string{forged, tampered, unsigned} {
+		rr = httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants?cursor="+c, nil))
+		require.Equal(t, http.StatusBadRequest, rr.Code, c)
+	}
+
+	// during the transition window unsigned cursors still work
+	legacy := httpapi.New(httpapi.Deps{TenantSvc: f, Cursors: pagination.NewCodec(nil).WithLegacy(true)})
+	rr = httptest.NewRecorder()
+	legacy.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants?cursor="+unsigned, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "t5", f.lastCursor.ID)
+}
+
+func TestMetrics_LabelsByRoutePattern(t *testing.T) {
+	m := metrics.New()
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, Metrics: m})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tenants?limit=lol", nil))
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `route="/v1/tenants",status="400"`)
+}
+
+func TestTracing_SpanNamedByRoutePattern(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}})
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/tenants", nil))
+
+	var names []string
+	for _, sp := range rec.Ended() {
+		names = append(names, sp.Name())
+	}
+	require.Contains(t, names, "GET /v1/tenants")
+}
+
+type fakePinger struct{ err error }
+
+func (p fakePinger) Ping(context.Context) error { return p.err }
+
+func TestReadyz(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, DB: fakePinger{}})
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	s.Drain()
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	// liveness is unaffected by draining
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestReadyz_DBDown(t *testing.T) {
+	s := httpapi.New(httpapi.Deps{TenantSvc: &fakeTenantSvc{}, DB: fakePinger{err: errors.New("conn refused")}})
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/domain"
+)
+
+// Upload is a file sent in a chat session. URL is a signed download link
+// that works without credentials until URLExpiresAt.
+type Upload struct {
+	ID           string    `json:"id"`
+	SessionID    string    `json:"session_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UploadFile is an upload opened for download; the caller closes Body.
+type UploadFile struct {
+	Upload
+	Body io.ReadCloser
+}
+
+type UploadService interface {
+	// MaxUploadBytes is the largest file Upload accepts.
+	MaxUploadBytes() int64
+	// Upload stores size bytes from body as a file of the session.
+	Upload(ctx context.Context, tenantID, sessionID, fileName string, size int64, body io.Reader) (Upload, error)
+	// OpenUpload checks a download link's query and opens the file.
+	OpenUpload(ctx context.Context, uploadID string, link url.Values) (UploadFile, error)
+}
+
+// multipartSlack is how far a request may exceed MaxUploadBytes to leave
+// room for the multipart framing around the file.
+const multipartSlack = 64 << 10
+
+// handleCreateUpload takes a multipart/form-data body whose "file" part is
+// the upload. Parts above 1 MiB are spooled to a temporary file, not held
+// in memory.
+func (s *Server) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.deps.UploadSvc.MaxUploadBytes()+multipartSlack)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": domain.ErrUploadTooLarge.Error()})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid multipart form"})
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+	f, hdr, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "missing file"})
+		return
+	}
+	defer f.Close()
+
+	up, err := s.deps.UploadSvc.Upload(r.Context(), tenant.ID, chi.URLParam(r, "sessionID"), hdr.Filename, hdr.Size, f)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, up)
+}
+
+// handleDownloadUpload serves a file to anyone holding a valid link. It is
+// always an attachment and never sniffed, so an upload cannot run as a page
+// of this origin.
+func (s *Server) handleDownloadUpload(w http.ResponseWriter, r *http.Request) {
+	f, err := s.deps.UploadSvc.OpenUpload(r.Context(), chi.URLParam(r, "uploadID"), r.URL.Query())
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer f.Body.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", f.ContentType)
+	h.Set("Content-Length", strconv.FormatInt(f.Size, 10))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.FileName}))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, f.Body)
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrSessionNotFound), errors.Is(err, domain.ErrUploadNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+	case errors.Is(err, domain.ErrSessionClosed):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+	case errors.Is(err, domain.ErrUploadTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": err.Error()})
+	case errors.Is(err, domain.ErrUploadTypeNotAllowed):
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{"error": err.Error()})
+	case errors.Is(err, domain.ErrEmptyUpload):
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidDownloadLink):
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+	}
+}
@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gochatbot/internal/domain"
+)
+
+// Webhook is a tenant's endpoint. Secret is only filled in the response
+// that created it; it cannot be read back later.
+type Webhook struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListWebhooksResult struct {
+	Items []Webhook `json:"items"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// WebhookDelivery is one event sent, or being sent, to an endpoint.
+type WebhookDelivery struct {
+	ID        string           `json:"id"`
+	EventID   string           `json:"event_id"`
+	EventType string           `json:"event_type"`
+	Status    string           `json:"status"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// WebhookAttempt is one POST of a delivery. StatusCode is absent when no
+// response came back; Error says why.
+type WebhookAttempt struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ListWebhookDeliveriesResult struct {
+	Items []WebhookDelivery `json:"items"`
+}
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, tenantID string, req CreateWebhookRequest) (Webhook, error)
+	ListWebhooks(ctx context.Context, tenantID string) (ListWebhooksResult, error)
+	GetWebhook(ctx context.Context, tenantID, webhookID string) (Webhook, error)
+	DeleteWebhook(ctx context.Context, tenantID, webhookID string) error
+
+	// ListDeliveries returns the endpoint's latest deliveries, newest first.
+	ListDeliveries(ctx context.Context, tenantID, webhookID string, limit int) (ListWebhookDeliveriesResult, error)
+	// Redeliver queues a finished delivery, or a pending one that lost its
+	// job, to be sent again.
+	Redeliver(ctx context.Context, tenantID, webhookID, deliveryID string) (WebhookDelivery, error)
+}
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := s.deps.WebhookSvc.ListWebhooks(r.Context(), tenant.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := s.deps.WebhookSvc.CreateWebhook(r.Context(), tenant.ID, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidWebhookURL) || errors.Is(err, domain.ErrInvalidWebhookEvent) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := s.deps.WebhookSvc.GetWebhook(r.Context(), tenant.ID, chi.URLParam(r, "webhookID"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.deps.WebhookSvc.DeleteWebhook(r.Context(), tenant.ID, chi.URLParam(r, "webhookID")); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimit(r, 50, 1, 200)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid limit"})
+		return
+	}
+
+	res, err := s.deps.WebhookSvc.ListDeliveries(r.Context(), tenant.ID, chi.URLParam(r, "webhookID"), limit)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleRedeliver answers 202: the delivery is queued, not yet sent.
+func (s *Server) handleRedeliver(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.pathTenant(w, r)
+	if !ok {
+		return
+	}
+
+	d, err := s.deps.WebhookSvc.Redeliver(r.Context(), tenant.ID, chi.URLParam(r, "webhookID"), chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, d)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound), errors.Is(err, domain.ErrDeliveryNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+	case errors.Is(err, domain.ErrDeliveryPending):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal"})
+	}
+}
@@ -0,0 +1,301 @@
+// Package idempotency makes retried POSTs safe. A client sends an
+// Idempotency-Key header; the first request with that key runs, its response
+// is stored, and later requests with the same key and body get the stored
+// response instead of running again. Keys are scoped to the tenant in the
+// path and the caller's credential, so clients never collide on them.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed marks a response served from the store.
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+// maxBody caps what is held in memory: request bodies, beyond which they
+// are spooled to a temporary file, and stored responses.
+const maxBody = 1 << 20
+
+var errBodyTooLarge = errors.New("body too large")
+
+// Record is the stored state of one key. Done is false while the first
+// request is still running.
+type Record struct {
+	Key         string
+	RequestHash string
+	Done        bool
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+type Store interface {
+	// Begin claims key for a request. If the key is new or expired it is
+	// claimed and acquired is true; otherwise the live record is returned.
+	Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (rec Record, acquired bool, err error)
+	// Complete stores the response for a claimed key.
+	Complete(ctx context.Context, key string, status int, contentType string, body []byte) error
+	// Release drops a claimed key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+type Guard struct {
+	store   Store
+	ttl     time.Duration
+	maxBody int64
+}
+
+// New keeps keys for ttl (24h when ttl <= 0).
+func New(store Store, ttl time.Duration) *Guard {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Guard{store: store, ttl: ttl, maxBody: maxBody}
+}
+
+// WithMaxBody lets requests with bodies up to n bytes, e.g. file uploads,
+// carry a key; the default is 1 MiB. Bodies over 1 MiB are hashed as they
+// are spooled to a temporary file, never held in memory.
+func (g *Guard) WithMaxBody(n int64) *Guard {
+	if n > maxBody {
+		g.maxBody = n
+	}
+	return g
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func validKey(k string) bool {
+	if k == "" || len(k) > 255 {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		if k[i] < 0x21 || k[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware applies to mutating requests that carry an Idempotency-Key;
+// everything else passes straight through.
+//
+// Responses with a 5xx status or 429 are not stored, so a retry runs again. A key
+// reused with a different method, path or body gets 422; a retry that
+// arrives while the first request is still running gets 409.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderKey)
+		if key == "" || !mutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validKey(key) {
+			writeError(w, http.StatusBadRequest, "invalid idempotency key")
+			return
+		}
+
+		hash, cleanup, err := g.readBody(r)
+		if errors.Is(err, errBodyTooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "body too large")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "unreadable body")
+			return
+		}
+		defer cleanup()
+
+		key = scope(r) + key
+		rec, acquired, err := g.store.Begin(r.Context(), key, hash, g.ttl)
+		if err != nil {
+			log.Printf("idempotency: begin: %v", err)
+			writeError(w, http.StatusInternalServerError, "internal")
+			return
+		}
+		if !acquired {
+			switch {
+			case rec.RequestHash != hash:
+				writeError(w, http.StatusUnprocessableEntity, "idempotency key reused with a different request")
+			case !rec.Done:
+				writeError(w, http.StatusConflict, "a request with this idempotency key is in progress")
+			default:
+				replay(w, rec)
+			}
+			return
+		}
+
+		g.run(w, r, key, next)
+	})
+}
+
+// run serves the first request for key and stores its response. Storing
+// ignores client cancellation: the client that timed out is exactly the one
+// that will retry.
+func (g *Guard) run(w http.ResponseWriter, r *http.Request, key string, next http.Handler) {
+	ctx := context.WithoutCancel(r.Context())
+	rec := &recorder{ResponseWriter: w}
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		// panics, 5xx and 429 leave the key free for a retry
+		if err := g.store.Release(ctx, key); err != nil {
+			log.Printf("idempotency: release %q: %v", key, err)
+		}
+	}()
+
+	next.ServeHTTP(rec, r)
+
+	status := rec.statusCode()
+	if status >= 500 || status == http.StatusTooManyRequests || rec.overflow {
+		return
+	}
+	if err := g.store.Complete(ctx, key, status, rec.Header().Get("Content-Type"), rec.buf.Bytes()); err != nil {
+		log.Printf("idempotency: complete %q: %v", key, err)
+		return
+	}
+	stored = true
+}
+
+// scope prefixes a client's key with the tenant slug from a
+// /v1/tenants/{slug} path and a hash of the X-API-Key or Authorization
+// header, either of which may be empty: "<slug>/<hash>/".
+func scope(r *http.Request) string {
+	var tenant string
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/v1/tenants/"); ok {
+		tenant, _, _ = strings.Cut(rest, "/")
+	}
+	var caller string
+	cred := r.Header.Get("X-API-Key")
+	if cred == "" {
+		cred = r.Header.Get("Authorization")
+	}
+	if cred != "" {
+		sum := sha256.Sum256([]byte(cred))
+		caller = hex.EncodeToString(sum[:16])
+	}
+	return tenant + "/" + caller + "/"
+}
+
+// readBody hashes the method, path and body of r and replaces r.Body with
+// a copy: in memory up to maxBody, in a temporary file beyond it, which
+// cleanup removes.
+func (g *Guard) readBody(r *http.Request) (hash string, cleanup func(), err error) {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+"\n"+r.URL.RequestURI()+"\n")
+
+	var buf bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(h, &buf), io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if n <= maxBody {
+		r.Body = io.NopCloser(&buf)
+		return hex.EncodeToString(h.Sum(nil)), func() {}, nil
+	}
+	if g.maxBody <= maxBody {
+		return "", nil, errBodyTooLarge
+	}
+
+	f, err := os.CreateTemp("", "idempotency-body-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	rest, err := io.Copy(io.MultiWriter(h, f), io.LimitReader(r.Body, g.maxBody-n+1))
+	if err == nil && n+rest > g.maxBody {
+		err = errBodyTooLarge
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	r.Body = io.NopCloser(f)
+	return hex.EncodeToString(h.Sum(nil)), cleanup, nil
+}
+
+func replay(w http.ResponseWriter, rec Record) {
+	if rec.ContentType != "" {
+		w.Header().Set("Content-Type", rec.ContentType)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(rec.Status)
+	_, _ = w.Write(rec.Body)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": msg})
+}
+
+// recorder tees the response to the client and keeps a bounded copy.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *recorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(b) > maxBody {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *recorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,198 @@
+package idempotency_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/idempotency"
+)
+
+type memStore struct {
+	mu   sync.Mutex
+	recs map[string]idempotency.Record
+}
+
+func (m *memStore) Begin(_ context.Context, key, hash string, _ time.Duration) (idempotency.Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rec, ok := m.recs[key]; ok {
+		return rec, false, nil
+	}
+	m.recs[key] = idempotency.Record{Key: key, RequestHash: hash}
+	return idempotency.Record{}, true, nil
+}
+
+func (m *memStore) Complete(_ context.Context, key string, status int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := m.recs[key]
+	rec.Done, rec.Status, rec.ContentType, rec.Body = true, status, contentType, body
+	m.recs[key] = rec
+	return nil
+}
+
+func (m *memStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.recs, key)
+	return nil
+}
+
+// counter creates one "draft" per call, like CreateDraftVersion.
+type counter struct {
+	n      int
+	status int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.n++
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(c.status)
+	fmt.Fprintf(w, `{"version":%d,"echo":%s}`, c.n, body)
+}
+
+func post(h http.Handler, key, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.HeaderKey, key)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMiddleware_ReplaysStoredResponse(t *testing.T) {
+	c := &counter{status: http.StatusCreated}
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(c)
+
+	first := post(h, "k1", "/v1/templates/t1/drafts", `{"a":1}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	retry := post(h, "k1", "/v1/templates/t1/drafts", `{"a":1}`)
+	require.Equal(t, http.StatusCreated, retry.Code)
+	require.Equal(t, first.Body.String(), retry.Body.String())
+	require.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	require.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+	require.Equal(t, 1, c.n, "handler must run once")
+
+	// a new key runs again; no key always runs
+	post(h, "k2", "/v1/templates/t1/drafts", `{"a":1}`)
+	post(h, "", "/v1/templates/t1/drafts", `{"a":1}`)
+	require.Equal(t, 3, c.n)
+}
+
+func TestMiddleware_DifferentRequestIs422(t *testing.T) {
+	c := &counter{status: http.StatusCreated}
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(c)
+
+	post(h, "k1", "/v1/templates/t1/drafts", `{"a":1}`)
+	require.Equal(t, http.StatusUnprocessableEntity, post(h, "k1", "/v1/templates/t1/drafts", `{"a":2}`).Code)
+	require.Equal(t, http.StatusUnprocessableEntity, post(h, "k1", "/v1/templates/t2/drafts", `{"a":1}`).Code)
+	require.Equal(t, 1, c.n)
+}
+
+func TestMiddleware_KeysAreScopedToTenantAndCaller(t *testing.T) {
+	c := &counter{status: http.StatusCreated}
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(c)
+	as := func(apiKey, path string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"url":"https://example.com"}`))
+		req.Header.Set(idempotency.HeaderKey, "k1")
+		req.Header.Set("X-API-Key", apiKey)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Empty(t, rr.Header().Get(idempotency.HeaderReplayed))
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusCreated, as("key-a", "/v1/tenants/acme/webhooks"))
+	require.Equal(t, http.StatusCreated, as("key-a", "/v1/tenants/beta/webhooks"), "another tenant")
+	require.Equal(t, http.StatusCreated, as("key-b", "/v1/tenants/acme/webhooks"), "another caller")
+	require.Equal(t, 3, c.n)
+}
+
+func TestMiddleware_InProgressIs409(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(slow)
+
+	done := make(chan int)
+	go func() { done <- post(h, "k1", "/v1/tenants", `{}`).Code }()
+	<-started
+	require.Equal(t, http.StatusConflict, post(h, "k1", "/v1/tenants", `{}`).Code)
+
+	close(release)
+	require.Equal(t, http.StatusCreated, <-done)
+	require.Equal(t, http.StatusCreated, post(h, "k1", "/v1/tenants", `{}`).Code)
+}
+
+func TestMiddleware_ServerErrorsAreNotStored(t *testing.T) {
+	c := &counter{status: http.StatusInternalServerError}
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(c)
+
+	require.Equal(t, http.StatusInternalServerError, post(h, "k1", "/v1/tenants", `{}`).Code)
+	c.status = http.StatusCreated
+	require.Equal(t, http.StatusCreated, post(h, "k1", "/v1/tenants", `{}`).Code)
+	require.Equal(t, 2, c.n)
+}
+
+func TestMiddleware_PassThrough(t *testing.T) {
+	c := &counter{status: http.StatusOK}
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(c)
+
+	req := httptest.NewRequest("GET", "/v1/tenants", nil)
+	req.Header.Set(idempotency.HeaderKey, "k1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, 2, c.n, "reads are never deduplicated")
+
+	require.Equal(t, http.StatusBadRequest, post(h, "bad key", "/v1/tenants", `{}`).Code)
+}
+
+func TestMiddleware_RateLimitedIsNotStored(t *testing.T) {
+	c := &counter{status: http.StatusTooManyRequests}
+	h := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(c)
+
+	require.Equal(t, http.StatusTooManyRequests, post(h, "k1", "/v1/tenants", `{}`).Code)
+	c.status = http.StatusCreated
+	require.Equal(t, http.StatusCreated, post(h, "k1", "/v1/tenants", `{}`).Code)
+}
+
+func TestMiddleware_LargeBodiesAreSpooled(t *testing.T) {
+	var got []int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, len(body))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"size":%d}`, len(body))
+	})
+	big := strings.Repeat("x", 2<<20)
+
+	small := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).Middleware(h)
+	require.Equal(t, http.StatusRequestEntityTooLarge, post(small, "k1", "/v1/uploads", big).Code)
+	require.Equal(t, http.StatusCreated, post(small, "", "/v1/uploads", big).Code, "no key, no limit")
+
+	got = nil
+	large := idempotency.New(&memStore{recs: map[string]idempotency.Record{}}, time.Hour).WithMaxBody(3 << 20).Middleware(h)
+	first := post(large, "k1", "/v1/uploads", big)
+	require.Equal(t, http.StatusCreated, first.Code)
+	replayed := post(large, "k1", "/v1/uploads", big)
+	require.Equal(t, "true", replayed.Header().Get(idempotency.HeaderReplayed))
+	require.Equal(t, first.Body.String(), replayed.Body.String())
+	require.Equal(t, http.StatusUnprocessableEntity, post(large, "k1", "/v1/uploads", big+"y").Code, "hashed past the first MiB")
+	require.Equal(t, http.StatusRequestEntityTooLarge, post(large, "k2", "/v1/uploads", big+big).Code)
+	require.Equal(t, []int{2 << 20}, got, "the handler reads the whole body once")
+}
@@ -0,0 +1,26 @@
+package jobs
+
+import "context"
+
+// Enqueuer matches service.Queue and repo.JobRepo.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, kind string, payload map[string]any) error
+}
+
+type observedQueue struct {
+	next Enqueuer
+	obs  Observer
+}
+
+// Observe reports successful enqueues to obs.
+func Observe(next Enqueuer, obs Observer) Enqueuer {
+	return &observedQueue{next: next, obs: obs}
+}
+
+func (q *observedQueue) Enqueue(ctx context.Context, kind string, payload map[string]any) error {
+	if err := q.next.Enqueue(ctx, kind, payload); err != nil {
+		return err
+	}
+	q.obs.JobEnqueued(kind)
+	return nil
+}
@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+)
+
+// Outcomes reported to the Observer.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeRetried   = "retried"
+	OutcomeFailed    = "failed"
+)
+
+type Store interface {
+	Claim(ctx context.Context, kinds []string) (repo.Job, bool, error)
+	Complete(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, runAt time.Time, lastErr string) error
+	Fail(ctx context.Context, id string, lastErr string) error
+}
+
+type Handler func(ctx context.Context, job repo.Job) error
+
+// Observer receives job lifecycle events; *metrics.Metrics satisfies it.
+type Observer interface {
+	JobEnqueued(kind string)
+	JobFinished(kind, outcome string, d time.Duration)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) JobEnqueued(string)                        {}
+func (nopObserver) JobFinished(string, string, time.Duration) {}
+
+type Worker struct {
+	store    Store
+	handlers map[string]Handler
+	obs      Observer
+	now      func() time.Time
+
+	poll       time.Duration
+	jobTimeout time.Duration
+}
+
+func NewWorker(store Store, now func() time.Time) *Worker {
+	if now == nil {
+		now = time.Now
+	}
+	return &Worker{
+		store:      store,
+		handlers:   map[string]Handler{},
+		obs:        nopObserver{},
+		now:        now,
+		poll:       time.Second,
+		jobTimeout: 2 * time.Minute,
+	}
+}
+
+func (w *Worker) WithObserver(obs Observer) *Worker {
+	if obs != nil {
+		w.obs = obs
+	}
+	return w
+}
+
+func (w *Worker) WithPollInterval(d time.Duration) *Worker {
+	if d > 0 {
+		w.poll = d
+	}
+	return w
+}
+
+func (w *Worker) WithJobTimeout(d time.Duration) *Worker {
+	if d > 0 {
+		w.jobTimeout = d
+	}
+	return w
+}
+
+// Handle registers the handler for a job kind. Only registered kinds are
+// claimed, so workers of different versions can share one table.
+func (w *Worker) Handle(kind string, h Handler) {
+	w.handlers[kind] = h
+}
+
+func (w *Worker) kinds() []string {
+	out := make([]string, 0, len(w.handlers))
+	for k := range w.handlers {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Run polls until ctx is cancelled. A job that is already running when ctx is
+// cancelled is allowed to finish (bounded by jobTimeout), which is what lets
+// shutdown drain the worker instead of abandoning a half-done export.
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		worked, err := w.ProcessOne(ctx)
+		if err != nil {
+			log.Printf("jobs: %v", err)
+		}
+		if worked {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.poll):
+		}
+	}
+}
+
+// ProcessOne claims and runs at most one job. It reports whether a job was found.
+func (w *Worker) ProcessOne(ctx context.Context) (bool, error) {
+	if len(w.handlers) == 0 {
+		return false, nil
+	}
+	job, ok, err := w.store.Claim(ctx, w.kinds())
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// detach from ctx so shutdown doesn't cancel a job mid-flight
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.jobTimeout)
+	defer cancel()
+
+	jobCtx, span := tracing.StartJob(jobCtx, job.Kind, job.Payload)
+	start := w.now()
+	runErr := w.run(jobCtx, job)
+	tracing.End(span, runErr)
+
+	// settle on a fresh context: jobCtx may be the thing that timed out
+	settleCtx, cancelSettle := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancelSettle()
+	return true, w.settle(settleCtx, job, runErr, w.now().Sub(start))
+}
+
+func (w *Worker) run(ctx context.Context, job repo.Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return w.handlers[job.Kind](ctx, job)
+}
+
+func (w *Worker) settle(ctx context.Context, job repo.Job, runErr error, d time.Duration) error {
+	if runErr == nil {
+		w.obs.JobFinished(job.Kind, OutcomeSucceeded, d)
+		return w.store.Complete(ctx, job.ID)
+	}
+	if job.Attempts >= job.MaxAttempts {
+		w.obs.JobFinished(job.Kind, OutcomeFailed, d)
+		return w.store.Fail(ctx, job.ID, runErr.Error())
+	}
+	w.obs.JobFinished(job.Kind, OutcomeRetried, d)
+	return w.store.Retry(ctx, job.ID, w.now().Add(Backoff(job.Attempts)), runErr.Error())
+}
+
+// Backoff is the delay before retry number attempt (1-based): 10s, 20s, 40s...
+// capped at one hour.
+func Backoff(attempt int) time.Duration {
+	const (
+		base = 10 * time.Second
+		max  = time.Hour
+	)
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return d
+}
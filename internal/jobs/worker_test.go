@@ -0,0 +1,216 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"gochatbot/internal/jobs"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+)
+
+type fakeStore struct {
+	mu sync.Mutex
+
+	pending   []repo.Job
+	completed []string
+	failed    map[string]string
+	retried   map[string]time.Time
+	lastKinds []string
+}
+
+func newFakeStore(js ...repo.Job) *fakeStore {
+	return &fakeStore{pending: js, failed: map[string]string{}, retried: map[string]time.Time{}}
+}
+
+// Enqueue stores the payload the way jsonb does, so a job reads back only
+// what survives a JSON round trip.
+func (s *fakeStore) Enqueue(_ context.Context, kind string, payload map[string]any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var stored map[string]any
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, repo.Job{ID: fmt.Sprintf("j%d", len(s.pending)+1), Kind: kind, Payload: stored, MaxAttempts: 5})
+	return nil
+}
+
+func (s *fakeStore) Claim(_ context.Context, kinds []string) (repo.Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastKinds = kinds
+	if len(s.pending) == 0 {
+		return repo.Job{}, false, nil
+	}
+	j := s.pending[0]
+	s.pending = s.pending[1:]
+	j.Attempts++
+	return j, true, nil
+}
+
+func (s *fakeStore) Complete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed = append(s.completed, id)
+	return nil
+}
+
+func (s *fakeStore) Retry(_ context.Context, id string, runAt time.Time, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retried[id] = runAt
+	return nil
+}
+
+func (s *fakeStore) Fail(_ context.Context, id string, lastErr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[id] = lastErr
+	return nil
+}
+
+type countingObserver struct {
+	outcomes []string
+}
+
+func (o *countingObserver) JobEnqueued(string) {}
+func (o *countingObserver) JobFinished(_ string, outcome string, _ time.Duration) {
+	o.outcomes = append(o.outcomes, outcome)
+}
+
+var t0 = time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
+
+func TestWorker_SuccessCompletes(t *testing.T) {
+	store := newFakeStore(repo.Job{ID: "j1", Kind: "export_lead", MaxAttempts: 5})
+	obs := &countingObserver{}
+	w := jobs.NewWorker(store, func() time.Time { return t0 }).WithObserver(obs)
+	w.Handle("export_lead", func(context.Context, repo.Job) error { return nil })
+
+	worked, err := w.ProcessOne(context.Background())
+	require.NoError(t, err)
+	require.True(t, worked)
+	require.Equal(t, []string{"j1"}, store.completed)
+	require.Equal(t, []string{"export_lead"}, store.lastKinds)
+	require.Equal(t, []string{jobs.OutcomeSucceeded}, obs.outcomes)
+}
+
+func TestWorker_ErrorRetriesWithBackoff(t *testing.T) {
+	store := newFakeStore(repo.Job{ID: "j1", Kind: "export_lead", MaxAttempts: 5})
+	w := jobs.NewWorker(store, func() time.Time { return t0 })
+	w.Handle("export_lead", func(context.Context, repo.Job) error { return errors.New("crm down") })
+
+	_, err := w.ProcessOne(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, t0.Add(10*time.Second), store.retried["j1"])
+	require.Empty(t, store.completed)
+}
+
+func TestWorker_LastAttemptFails(t *testing.T) {
+	store := newFakeStore(repo.Job{ID: "j1", Kind: "export_lead", Attempts: 4, MaxAttempts: 5})
+	w := jobs.NewWorker(store, nil)
+	w.Handle("export_lead", func(context.Context, repo.Job) error { panic("boom") })
+
+	_, err := w.ProcessOne(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, store.failed["j1"], "panic: boom")
+}
+
+func TestWorker_NoHandlersClaimsNothing(t *testing.T) {
+	store := newFakeStore(repo.Job{ID: "j1", Kind: "export_lead", MaxAttempts: 5})
+	w := jobs.NewWorker(store, nil)
+
+	worked, err := w.ProcessOne(context.Background())
+	require.NoError(t, err)
+	require.False(t, worked)
+	require.Empty(t, store.lastKinds)
+}
+
+func TestWorker_RunDrainsInFlightJobOnCancel(t *testing.T) {
+	store := newFakeStore(repo.Job{ID: "j1", Kind: "slow", MaxAttempts: 5})
+	w := jobs.NewWorker(store, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	w.Handle("slow", func(jobCtx context.Context, _ repo.Job) error {
+		close(started)
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		return jobCtx.Err() // must not be cancelled by the worker's ctx
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker did not stop")
+	}
+	require.Equal(t, []string{"j1"}, store.completed)
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, 10*time.Second, jobs.Backoff(1))
+	require.Equal(t, 40*time.Second, jobs.Backoff(3))
+	require.Equal(t, time.Hour, jobs.Backoff(50))
+}
+
+func TestWorker_ContinuesTheEnqueuersTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	store := newFakeStore()
+	queue := tracing.WrapQueue(jobs.Observe(store, &countingObserver{}))
+	ctx, parent := tracing.Start(context.Background(), "SessionService.CloseSession")
+	require.NoError(t, queue.Enqueue(ctx, "export_lead", map[string]any{"lead_id": "l1"}))
+	parent.End()
+
+	var handled trace.SpanContext
+	w := jobs.NewWorker(store, nil)
+	w.Handle("export_lead", func(ctx context.Context, _ repo.Job) error {
+		handled = trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	ok, err := w.ProcessOne(context.Background())
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		spans[s.Name()] = s
+	}
+	require.Contains(t, spans, "enqueue export_lead")
+	require.Contains(t, spans, "job export_lead")
+	traceID := parent.SpanContext().TraceID()
+	require.Equal(t, traceID, spans["enqueue export_lead"].SpanContext().TraceID())
+	require.Equal(t, traceID, spans["job export_lead"].SpanContext().TraceID())
+	require.Equal(t, spans["enqueue export_lead"].SpanContext().SpanID(), spans["job export_lead"].Parent().SpanID())
+	require.Equal(t, traceID, handled.TraceID(), "the handler runs inside the job span")
+}
@@ -0,0 +1,107 @@
+// Package jsondiff compares JSON documents structurally, so key order and
+// number formatting never show up as differences.
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change ops reported by Diff.
+const (
+	OpAdded   = "added"
+	OpRemoved = "removed"
+	OpChanged = "changed"
+)
+
+// Change is one difference between two documents. Path addresses the
+// value like `$.steps[2].prompt`; From is nil for additions, To for removals.
+type Change struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// Diff compares two JSON documents: object keys are matched by name, arrays
+// by index. Changes come back in path order.
+func Diff(from, to []byte) ([]Change, error) {
+	var a, b any
+	if err := json.Unmarshal(from, &a); err != nil {
+		return nil, fmt.Errorf("diff: from: %w", err)
+	}
+	if err := json.Unmarshal(to, &b); err != nil {
+		return nil, fmt.Errorf("diff: to: %w", err)
+	}
+
+	return Values(a, b), nil
+}
+
+// Values is Diff for already-decoded values (as produced by encoding/json).
+func Values(a, b any) []Change {
+	var out []Change
+	diffValue("$", a, b, &out)
+	return out
+}
+
+func diffValue(path string, a, b any, out *[]Change) {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			diffObject(path, av, bv, out)
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			diffArray(path, av, bv, out)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*out = append(*out, Change{Op: OpChanged, Path: path, From: a, To: b})
+	}
+}
+
+func diffObject(path string, a, b map[string]any, out *[]Change) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "." + k
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inB:
+			*out = append(*out, Change{Op: OpRemoved, Path: p, From: av})
+		case !inA:
+			*out = append(*out, Change{Op: OpAdded, Path: p, To: bv})
+		default:
+			diffValue(p, av, bv, out)
+		}
+	}
+}
+
+func diffArray(path string, a, b []any, out *[]Change) {
+	n := max(len(a), len(b))
+	for i := 0; i < n; i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(b):
+			*out = append(*out, Change{Op: OpRemoved, Path: p, From: a[i]})
+		case i >= len(a):
+			*out = append(*out, Change{Op: OpAdded, Path: p, To: b[i]})
+		default:
+			diffValue(p, a[i], b[i], out)
+		}
+	}
+}
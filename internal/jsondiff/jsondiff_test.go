@@ -0,0 +1,43 @@
+package jsondiff_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/jsondiff"
+)
+
+func TestDiff_Identical(t *testing.T) {
+	changes, err := jsondiff.Diff([]byte(`{"a":1,"b":[1,2]}`), []byte(`{"b":[1,2],"a":1}`))
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestDiff_ObjectsAndArrays(t *testing.T) {
+	from := []byte(`{"greeting":"hi","steps":[{"field":"name"},{"field":"email"}],"old":true}`)
+	to := []byte(`{"greeting":"hello","steps":[{"field":"name"},{"field":"phone"},{"field":"email"}],"color":"#fff"}`)
+
+	changes, err := jsondiff.Diff(from, to)
+	require.NoError(t, err)
+	require.Equal(t, []jsondiff.Change{
+		{Op: jsondiff.OpAdded, Path: "$.color", To: "#fff"},
+		{Op: jsondiff.OpChanged, Path: "$.greeting", From: "hi", To: "hello"},
+		{Op: jsondiff.OpRemoved, Path: "$.old", From: true},
+		{Op: jsondiff.OpChanged, Path: "$.steps[1].field", From: "email", To: "phone"},
+		{Op: jsondiff.OpAdded, Path: "$.steps[2]", To: map[string]any{"field": "email"}},
+	}, changes)
+}
+
+func TestDiff_TypeChangeIsWholeValue(t *testing.T) {
+	changes, err := jsondiff.Diff([]byte(`{"a":{"x":1}}`), []byte(`{"a":[1]}`))
+	require.NoError(t, err)
+	require.Equal(t, []jsondiff.Change{
+		{Op: jsondiff.OpChanged, Path: "$.a", From: map[string]any{"x": 1.0}, To: []any{1.0}},
+	}, changes)
+}
+
+func TestDiff_InvalidJSON(t *testing.T) {
+	_, err := jsondiff.Diff([]byte(`{`), []byte(`{}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,83 @@
+// Package llm talks to chat-completion models. Provider is the seam: the
+// OpenAI-compatible client serves OpenAI and the many servers that mimic
+// its API, and Scripted stands in for both in tests and local runs.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Roles of a conversation turn.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Finish reasons; providers map theirs onto these.
+const (
+	FinishStop      = "stop"
+	FinishLength    = "length"
+	FinishToolCalls = "tool_calls"
+)
+
+type Message struct {
+	Role    string
+	Content string
+	// ToolCalls are the calls an assistant turn asks for.
+	ToolCalls []ToolCall
+	// ToolCallID ties a tool turn to the call it answers.
+	ToolCallID string
+}
+
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage // a JSON object, as produced by the model
+}
+
+// Tool describes a function the model may call; Parameters is its JSON
+// Schema.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+type Request struct {
+	// Model overrides the provider's default when set.
+	Model     string
+	Messages  []Message
+	Tools     []Tool
+	MaxTokens int // 0 leaves it to the provider
+}
+
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type Response struct {
+	Message      Message // always RoleAssistant
+	FinishReason string
+	Usage        Usage
+}
+
+// Chunk is a piece of a streamed reply.
+type Chunk struct {
+	Content string
+}
+
+type Provider interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+	// Stream calls onChunk with each piece of content as it arrives and
+	// returns the whole reply, tool calls included, once it is done. An
+	// error from onChunk stops the stream and is returned.
+	Stream(ctx context.Context, req Request, onChunk func(Chunk) error) (Response, error)
+}
+
+// ErrScriptExhausted is returned by a Scripted provider with no replies left.
+var ErrScriptExhausted = errors.New("llm: script exhausted")
@@ -0,0 +1,342 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxErrorBody caps how much of an error response is read for its message.
+const maxErrorBody = 64 << 10
+
+type OpenAIConfig struct {
+	// BaseURL is the API root, e.g. https://api.openai.com/v1; requests go
+	// to BaseURL + "/chat/completions".
+	BaseURL string
+	// APIKey is sent as a bearer token; empty sends none, for local servers.
+	APIKey string
+	// Model is used when a Request names none.
+	Model   string
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// OpenAI is a Provider for the OpenAI chat completions API.
+type OpenAI struct {
+	endpoint string
+	apiKey   string
+	model    string
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// APIError is a non-2xx answer from the API.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llm: api status %d: %s", e.Status, e.Message)
+}
+
+// Temporary reports whether retrying may succeed: rate limits and server
+// errors.
+func (e *APIError) Temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+func NewOpenAI(cfg OpenAIConfig) (*OpenAI, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.BaseURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("llm: base url must be an absolute http(s) URL, got %q", cfg.BaseURL)
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("llm: model required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &OpenAI{
+		endpoint: base.String() + "/chat/completions",
+		apiKey:   cfg.APIKey,
+		model:    cfg.Model,
+		timeout:  cfg.Timeout,
+		client:   client,
+	}, nil
+}
+
+// Wire format, limited to the fields we use.
+
+type wireRequest struct {
+	Model     string        `json:"model"`
+	Messages  []wireMessage `json:"messages"`
+	Tools     []wireTool    `json:"tools,omitempty"`
+	MaxTokens int           `json:"max_tokens,omitempty"`
+	Stream    bool          `json:"stream,omitempty"`
+	// StreamOptions asks for usage in the last streamed chunk.
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type wireMessage struct {
+	Role       string         `json:"role"`
+	Content    *string        `json:"content"` // null on tool-call-only turns
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type wireToolCall struct {
+	Index    *int   `json:"index,omitempty"` // stream deltas only
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type wireTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type wireUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type wireResponse struct {
+	Choices []struct {
+		Message      wireMessage `json:"message"`
+		Delta        wireMessage `json:"delta"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *wireUsage `json:"usage"`
+}
+
+func (c *OpenAI) Complete(ctx context.Context, req Request) (Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.post(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var body wireResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Response{}, fmt.Errorf("llm: decode response: %w", err)
+	}
+	if len(body.Choices) == 0 {
+		return Response{}, fmt.Errorf("llm: response has no choices")
+	}
+	choice := body.Choices[0]
+	out := Response{Message: fromWire(choice.Message), FinishReason: choice.FinishReason}
+	if body.Usage != nil {
+		out.Usage = Usage(*body.Usage)
+	}
+	return out, nil
+}
+
+// Stream reads the API's Server-Sent Events. Tool call names and arguments
+// arrive in fragments keyed by index and are joined before returning.
+func (c *OpenAI) Stream(ctx context.Context, req Request, onChunk func(Chunk) error) (Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.post(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		out     = Response{Message: Message{Role: RoleAssistant}}
+		content strings.Builder
+		calls   = map[int]*ToolCall{}
+		args    = map[int]*strings.Builder{}
+	)
+	lines := bufio.NewScanner(resp.Body)
+	lines.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data:")
+		if !ok {
+			continue // blank separators, comments, other fields
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var ev wireResponse
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return Response{}, fmt.Errorf("llm: decode stream event: %w", err)
+		}
+		if ev.Usage != nil {
+			out.Usage = Usage(*ev.Usage)
+		}
+		if len(ev.Choices) == 0 {
+			continue
+		}
+		choice := ev.Choices[0]
+		if choice.FinishReason != "" {
+			out.FinishReason = choice.FinishReason
+		}
+		if d := choice.Delta.Content; d != nil && *d != "" {
+			content.WriteString(*d)
+			if err := onChunk(Chunk{Content: *d}); err != nil {
+				return Response{}, err
+			}
+		}
+		for i, tc := range choice.Delta.ToolCalls {
+			idx := i
+			if tc.Index != nil {
+				idx = *tc.Index
+			}
+			call := calls[idx]
+			if call == nil {
+				call = &ToolCall{}
+				calls[idx] = call
+				args[idx] = &strings.Builder{}
+			}
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			call.Name += tc.Function.Name
+			args[idx].WriteString(tc.Function.Arguments)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return Response{}, fmt.Errorf("llm: read stream: %w", err)
+	}
+
+	out.Message.Content = content.String()
+	idxs := make([]int, 0, len(calls))
+	for i := range calls {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+	for _, i := range idxs {
+		calls[i].Arguments = rawArguments(args[i].String())
+		out.Message.ToolCalls = append(out.Message.ToolCalls, *calls[i])
+	}
+	return out, nil
+}
+
+func (c *OpenAI) post(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	wr := wireRequest{Model: req.Model, MaxTokens: req.MaxTokens, Stream: stream}
+	if wr.Model == "" {
+		wr.Model = c.model
+	}
+	if stream {
+		wr.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	for _, m := range req.Messages {
+		wr.Messages = append(wr.Messages, toWire(m))
+	}
+	for _, t := range req.Tools {
+		var wt wireTool
+		wt.Type = "function"
+		wt.Function.Name = t.Name
+		wt.Function.Description = t.Description
+		wt.Function.Parameters = t.Parameters
+		wr.Tools = append(wr.Tools, wt)
+	}
+	body, err := json.Marshal(wr)
+	if err != nil {
+		return nil, err
+	}
+
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	if stream {
+		hr.Header.Set("Accept", "text/event-stream")
+	}
+	if c.apiKey != "" {
+		hr.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.client.Do(hr)
+	if err != nil {
+		return nil, fmt.Errorf("llm: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return resp, nil
+}
+
+func apiError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	msg := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &body) == nil && body.Error.Message != "" {
+		msg = body.Error.Message
+	}
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{Status: resp.StatusCode, Message: msg}
+}
+
+func toWire(m Message) wireMessage {
+	w := wireMessage{Role: m.Role, ToolCallID: m.ToolCallID}
+	if m.Content != "" || len(m.ToolCalls) == 0 {
+		content := m.Content
+		w.Content = &content
+	}
+	for _, tc := range m.ToolCalls {
+		var wc wireToolCall
+		wc.ID = tc.ID
+		wc.Type = "function"
+		wc.Function.Name = tc.Name
+		wc.Function.Arguments = string(tc.Arguments)
+		w.ToolCalls = append(w.ToolCalls, wc)
+	}
+	return w
+}
+
+func fromWire(w wireMessage) Message {
+	m := Message{Role: RoleAssistant, ToolCallID: w.ToolCallID}
+	if w.Content != nil {
+		m.Content = *w.Content
+	}
+	for _, wc := range w.ToolCalls {
+		m.ToolCalls = append(m.ToolCalls, ToolCall{ID: wc.ID, Name: wc.Function.Name, Arguments: rawArguments(wc.Function.Arguments)})
+	}
+	return m
+}
+
+// rawArguments keeps the model's argument string as JSON; models sometimes
+// send nothing for a call without parameters.
+func rawArguments(s string) json.RawMessage {
+	if strings.TrimSpace(s) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(s)
+}
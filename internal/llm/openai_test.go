@@ -0,0 +1,161 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/llm"
+)
+
+func newClient(t *testing.T, h http.HandlerFunc) *llm.OpenAI {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	c, err := llm.NewOpenAI(llm.OpenAIConfig{BaseURL: ts.URL + "/v1/", APIKey: "sk-test", Model: "small"})
+	require.NoError(t, err)
+	return c
+}
+
+func TestOpenAI_CompleteSendsConversationAndReadsToolCalls(t *testing.T) {
+	var got map[string]any
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/chat/completions", r.URL.Path)
+		require.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":null,
+			"tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup_business_hours","arguments":"{\"day\":\"mon\"}"}}]},
+			"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+	})
+
+	resp, err := c.Complete(context.Background(), llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Be brief."},
+			{Role: llm.RoleUser, Content: "Open Monday?"},
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_0", Name: "noop", Arguments: json.RawMessage(`{}`)}}},
+			{Role: llm.RoleTool, ToolCallID: "call_0", Content: "ok"},
+		},
+		Tools: []llm.Tool{{Name: "lookup_business_hours", Parameters: json.RawMessage(`{"type":"object"}`)}},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "small", got["model"], "default model")
+	msgs := got["messages"].([]any)
+	require.Len(t, msgs, 4)
+	require.Equal(t, map[string]any{"role": "system", "content": "Be brief."}, msgs[0])
+	require.Nil(t, msgs[2].(map[string]any)["content"], "tool-call turns send null content")
+	require.Equal(t, "call_0", msgs[3].(map[string]any)["tool_call_id"])
+	tool := got["tools"].([]any)[0].(map[string]any)
+	require.Equal(t, "function", tool["type"])
+	require.Equal(t, "lookup_business_hours", tool["function"].(map[string]any)["name"])
+
+	require.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 12, CompletionTokens: 3}, resp.Usage)
+	require.Equal(t, llm.RoleAssistant, resp.Message.Role)
+	require.Equal(t, []llm.ToolCall{{ID: "call_1", Name: "lookup_business_hours", Arguments: json.RawMessage(`{"day":"mon"}`)}}, resp.Message.ToolCalls)
+}
+
+func TestOpenAI_StreamJoinsDeltas(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, true, req["stream"])
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range []string{
+			`{"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"choices":[{"delta":{"content":"lo"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"capture_","arguments":"{\"fie"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"lead_field","arguments":"ld\":\"email\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":7}}`,
+			`[DONE]`,
+		} {
+			_, _ = io.WriteString(w, "data: "+ev+"\n\n")
+		}
+	})
+
+	var chunks []string
+	resp, err := c.Stream(context.Background(), llm.Request{Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}}},
+		func(ch llm.Chunk) error {
+			chunks = append(chunks, ch.Content)
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Hel", "lo"}, chunks)
+	require.Equal(t, "Hello", resp.Message.Content)
+	require.Equal(t, llm.FinishToolCalls, resp.FinishReason)
+	require.Equal(t, llm.Usage{PromptTokens: 5, CompletionTokens: 7}, resp.Usage)
+	require.Equal(t, []llm.ToolCall{{ID: "call_1", Name: "capture_lead_field", Arguments: json.RawMessage(`{"field":"email"}`)}}, resp.Message.ToolCalls)
+}
+
+func TestOpenAI_Errors(t *testing.T) {
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"error":{"message":"slow down"}}`)
+	})
+	_, err := c.Complete(context.Background(), llm.Request{})
+	var apiErr *llm.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusTooManyRequests, apiErr.Status)
+	require.Equal(t, "slow down", apiErr.Message)
+	require.True(t, apiErr.Temporary())
+
+	stop := errors.New("stop")
+	c = newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "data: "+`{"choices":[{"delta":{"content":"a"}}]}`+"\n\n")
+	})
+	_, err = c.Stream(context.Background(), llm.Request{}, func(llm.Chunk) error { return stop })
+	require.ErrorIs(t, err, stop)
+
+	_, err = llm.NewOpenAI(llm.OpenAIConfig{BaseURL: "api.example.com", Model: "m"})
+	require.Error(t, err)
+	_, err = llm.NewOpenAI(llm.OpenAIConfig{BaseURL: "https://api.example.com"})
+	require.Error(t, err)
+}
+
+func TestScripted_RepliesInOrderThenFallsBack(t *testing.T) {
+	p := llm.NewScripted(
+		llm.Response{Message: llm.Message{ToolCalls: []llm.ToolCall{{ID: "c1", Name: "t"}}}},
+		llm.Response{Message: llm.Message{Content: "Hi there,  friend"}},
+	)
+	ctx := context.Background()
+
+	r, err := p.Complete(ctx, llm.Request{})
+	require.NoError(t, err)
+	require.Equal(t, llm.RoleAssistant, r.Message.Role)
+	require.Equal(t, llm.FinishToolCalls, r.FinishReason)
+
+	var chunks []string
+	r, err = p.Stream(ctx, llm.Request{}, func(c llm.Chunk) error {
+		chunks = append(chunks, c.Content)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Hi ", "there,  ", "friend"}, chunks)
+	require.Equal(t, "Hi there,  friend", strings.Join(chunks, ""))
+	require.Equal(t, llm.FinishStop, r.FinishReason)
+
+	_, err = p.Complete(ctx, llm.Request{})
+	require.ErrorIs(t, err, llm.ErrScriptExhausted)
+	require.Len(t, p.Requests(), 3)
+
+	p.WithFallback(llm.Echo)
+	r, err = p.Complete(ctx, llm.Request{Messages: []llm.Message{{Role: llm.RoleUser, Content: "ping"}}})
+	require.NoError(t, err)
+	require.Equal(t, "You said: ping", r.Message.Content)
+}
+
+func TestApproxCounter(t *testing.T) {
+	var c llm.ApproxCounter
+	require.Equal(t, 0, c.CountTokens(nil))
+	require.Equal(t, 4+2, c.CountTokens([]llm.Message{{Role: llm.RoleUser, Content: "hello!"}}))
+	require.Equal(t, 4+4, c.CountTokens([]llm.Message{{Role: llm.RoleAssistant,
+		ToolCalls: []llm.ToolCall{{ID: "c1", Name: "lookup", Arguments: json.RawMessage(`{"a":1}`)}}}}), "15 bytes of call")
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Scripted is a deterministic Provider: it answers with its replies in
+// order and records every request. Once the script runs out it uses the
+// fallback, if any, or fails with ErrScriptExhausted.
+type Scripted struct {
+	mu       sync.Mutex
+	replies  []Response
+	fallback func(Request) Response
+	requests []Request
+}
+
+// NewScripted returns a provider that answers with replies in order. A
+// reply without a Role is an assistant reply; without a FinishReason it
+// finishes with FinishToolCalls when it has tool calls and FinishStop
+// otherwise.
+func NewScripted(replies ...Response) *Scripted {
+	return &Scripted{replies: replies}
+}
+
+// WithFallback answers with fn once the script is used up.
+func (s *Scripted) WithFallback(fn func(Request) Response) *Scripted {
+	s.fallback = fn
+	return s
+}
+
+// Echo is a fallback that repeats the last user message, for running the
+// service without a model.
+func Echo(req Request) Response {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if m := req.Messages[i]; m.Role == RoleUser {
+			return Response{Message: Message{Content: "You said: " + m.Content}}
+		}
+	}
+	return Response{Message: Message{Content: "Hello! How can I help?"}}
+}
+
+// Requests returns every request received so far.
+func (s *Scripted) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Scripted) Complete(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	var r Response
+	switch {
+	case len(s.replies) > 0:
+		r = s.replies[0]
+		s.replies = s.replies[1:]
+	case s.fallback != nil:
+		r = s.fallback(req)
+	default:
+		return Response{}, ErrScriptExhausted
+	}
+	if r.Message.Role == "" {
+		r.Message.Role = RoleAssistant
+	}
+	if r.FinishReason == "" {
+		r.FinishReason = FinishStop
+		if len(r.Message.ToolCalls) > 0 {
+			r.FinishReason = FinishToolCalls
+		}
+	}
+	return r, nil
+}
+
+// Stream delivers the next reply one word at a time, each chunk keeping
+// the whitespace that follows the word.
+func (s *Scripted) Stream(ctx context.Context, req Request, onChunk func(Chunk) error) (Response, error) {
+	r, err := s.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+	for rest := r.Message.Content; rest != ""; {
+		end := strings.IndexAny(rest, " \n")
+		if end < 0 {
+			end = len(rest) - 1
+		}
+		for end+1 < len(rest) && (rest[end+1] == ' ' || rest[end+1] == '\n') {
+			end++
+		}
+		if err := onChunk(Chunk{Content: rest[:end+1]}); err != nil {
+			return Response{}, err
+		}
+		rest = rest[end+1:]
+	}
+	return r, nil
+}
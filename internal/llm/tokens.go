@@ -0,0 +1,29 @@
+package llm
+
+// TokenCounter estimates how many tokens messages take in a prompt. Counts
+// only have to be consistent with the budget they are checked against, so
+// a model-specific tokenizer can be plugged in where precision matters.
+type TokenCounter interface {
+	CountTokens(msgs []Message) int
+}
+
+// ApproxCounter assumes about four bytes per token, which is close for
+// English text with the common BPE vocabularies, plus a fixed overhead per
+// message for role and framing.
+type ApproxCounter struct{}
+
+// messageOverhead is what chat formats spend on a message's role and
+// delimiters.
+const messageOverhead = 4
+
+func (ApproxCounter) CountTokens(msgs []Message) int {
+	n := 0
+	for _, m := range msgs {
+		bytes := len(m.Content) + len(m.ToolCallID)
+		for _, c := range m.ToolCalls {
+			bytes += len(c.ID) + len(c.Name) + len(c.Arguments)
+		}
+		n += messageOverhead + (bytes+3)/4
+	}
+	return n
+}
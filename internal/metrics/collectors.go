@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatter is satisfied by *pgxpool.Pool.
+type PoolStatter interface {
+	Stat() *pgxpool.Stat
+}
+
+type poolCollector struct {
+	pool PoolStatter
+
+	acquired      *prometheus.Desc
+	idle          *prometheus.Desc
+	constructing  *prometheus.Desc
+	total         *prometheus.Desc
+	max           *prometheus.Desc
+	acquireCount  *prometheus.Desc
+	acquireWait   *prometheus.Desc
+	emptyAcquire  *prometheus.Desc
+	canceledCount *prometheus.Desc
+}
+
+// NewPoolCollector exposes pgxpool.Stat as gauges and counters. Stats are read
+// on scrape, so there is nothing to keep in sync.
+func NewPoolCollector(pool PoolStatter) prometheus.Collector {
+	d := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		pool:          pool,
+		acquired:      d("acquired_conns", "Connections currently checked out."),
+		idle:          d("idle_conns", "Idle connections."),
+		constructing:  d("constructing_conns", "Connections being established."),
+		total:         d("total_conns", "Total connections in the pool."),
+		max:           d("max_conns", "Configured pool size."),
+		acquireCount:  d("acquire_total", "Successful acquires."),
+		acquireWait:   d("acquire_wait_seconds_total", "Time spent waiting on acquire."),
+		emptyAcquire:  d("empty_acquire_total", "Acquires that had to wait for a connection."),
+		canceledCount: d("canceled_acquire_total", "Acquires canceled by their context."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.constructing
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquireCount
+	ch <- c.acquireWait
+	ch <- c.emptyAcquire
+	ch <- c.canceledCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructing, prometheus.GaugeValue, float64(s.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledCount, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+}
+
+// DepthFunc reports the number of pending jobs per kind.
+type DepthFunc func(ctx context.Context) (map[string]int64, error)
+
+type queueDepthCollector struct {
+	depth   DepthFunc
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+// NewQueueDepthCollector queries depth on every scrape. A failing query drops
+// the series for that scrape rather than reporting a stale zero.
+func NewQueueDepthCollector(depth DepthFunc) prometheus.Collector {
+	return &queueDepthCollector{
+		depth:   depth,
+		timeout: 2 * time.Second,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jobs", "queue_depth"),
+			"Jobs waiting to run, by kind.",
+			[]string{"kind"}, nil,
+		),
+	}
+}
+
+func (c *queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	byKind, err := c.depth(ctx)
+	if err != nil {
+		return
+	}
+	for kind, n := range byKind {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), kind)
+	}
+}
@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gochatbot"
+
+// Metrics owns a private registry so tests can build as many as they like
+// without tripping over the global default registerer.
+type Metrics struct {
+	reg *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	jobsEnqueued *prometheus.CounterVec
+	jobsFinished *prometheus.CounterVec
+	jobDuration  *prometheus.HistogramVec
+
+	sessionsStarted    prometheus.Counter
+	sessionsClosed     prometheus.Counter
+	leadsCreated       prometheus.Counter
+	templatesPublished prometheus.Counter
+
+	shadowComparisons *prometheus.CounterVec
+	cutoverMode       *prometheus.GaugeVec
+	cutoverRequests   *prometheus.CounterVec
+	rateLimited       *prometheus.CounterVec
+
+	toolCalls *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	m := &Metrics{
+		reg: reg,
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by method, chi route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by method and chi route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		jobsEnqueued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "enqueued_total",
+			Help:      "Jobs enqueued by kind.",
+		}, []string{"kind"}),
+		jobsFinished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "finished_total",
+			Help:      "Job attempts by kind and outcome.",
+		}, []string{"kind", "outcome"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "jobs",
+			Name:      "duration_seconds",
+			Help:      "Job handler latency by kind.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"kind"}),
+
+		sessionsStarted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_started_total",
+			Help:      "Chat sessions started.",
+		}),
+		sessionsClosed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_closed_total",
+			Help:      "Chat sessions closed.",
+		}),
+		leadsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "leads_created_total",
+			Help:      "Leads created from closed sessions.",
+		}),
+		templatesPublished: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "templates_published_total",
+			Help:      "Template versions published.",
+		}),
+
+		shadowComparisons: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "shadow",
+			Name:      "comparisons_total",
+			Help:      "Legacy shadow-read comparisons by chi route pattern and outcome.",
+		}, []string{"route", "outcome"}),
+		cutoverMode: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cutover",
+			Name:      "route_mode",
+			Help:      "1 for the current mode (go, legacy, shadow) of each cutover table entry.",
+		}, []string{"route", "mode"}),
+		cutoverRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cutover",
+			Name:      "requests_total",
+			Help:      "Requests by chi route pattern and the cutover mode that served them.",
+		}, []string{"route", "mode"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "rate_limited_total",
+			Help:      "Requests refused with 429 by route group and the dimension whose bucket was empty.",
+		}, []string{"group", "dimension"}),
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "tool",
+			Name:      "calls_total",
+			Help:      "Tool calls the assistant asked for, by tool and outcome (ok, denied, invalid, error, timeout).",
+		}, []string{"tool", "outcome"}),
+	}
+
+	reg.MustRegister(
+		m.httpRequests, m.httpDuration,
+		m.jobsEnqueued, m.jobsFinished, m.jobDuration,
+		m.sessionsStarted, m.sessionsClosed, m.leadsCreated, m.templatesPublished,
+		m.shadowComparisons, m.cutoverMode, m.cutoverRequests,
+		m.rateLimited,
+		m.toolCalls,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{Registry: m.reg})
+}
+
+// MustRegister adds extra collectors (pool stats, queue depth) to the registry.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.reg.MustRegister(cs...)
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, d time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+func (m *Metrics) JobEnqueued(kind string) {
+	m.jobsEnqueued.WithLabelValues(kind).Inc()
+}
+
+func (m *Metrics) JobFinished(kind, outcome string, d time.Duration) {
+	m.jobsFinished.WithLabelValues(kind, outcome).Inc()
+	m.jobDuration.WithLabelValues(kind).Observe(d.Seconds())
+}
+
+func (m *Metrics) SessionStarted()    { m.sessionsStarted.Inc() }
+func (m *Metrics) SessionClosed()     { m.sessionsClosed.Inc() }
+func (m *Metrics) LeadCreated()       { m.leadsCreated.Inc() }
+func (m *Metrics) TemplatePublished() { m.templatesPublished.Inc() }
+
+func (m *Metrics) ShadowCompared(route, outcome string) {
+	m.shadowComparisons.WithLabelValues(route, outcome).Inc()
+}
+
+// RouteMode records an entry's current cutover mode; "" drops the entry.
+func (m *Metrics) RouteMode(route, mode string) {
+	m.cutoverMode.DeletePartialMatch(prometheus.Labels{"route": route})
+	if mode != "" {
+		m.cutoverMode.WithLabelValues(route, mode).Set(1)
+	}
+}
+
+func (m *Metrics) CutoverServed(route, mode string) {
+	m.cutoverRequests.WithLabelValues(route, mode).Inc()
+}
+
+func (m *Metrics) RateLimited(group, dimension string) {
+	m.rateLimited.WithLabelValues(group, dimension).Inc()
+}
+
+func (m *Metrics) ToolCalled(tool, outcome string) {
+	m.toolCalls.WithLabelValues(tool, outcome).Inc()
+}
@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/metrics"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rr.Code)
+	b, err := io.ReadAll(rr.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestMetrics_HTTPAndDomainCounters(t *testing.T) {
+	m := metrics.New()
+	m.ObserveHTTP("GET", "/v1/tenants/{slug}", 404, 5*time.Millisecond)
+	m.TemplatePublished()
+	m.JobFinished("export_lead", "succeeded", time.Second)
+	m.ToolCalled("capture_lead_field", "invalid")
+
+	out := scrape(t, m)
+	require.Contains(t, out, `gochatbot_http_requests_total{method="GET",route="/v1/tenants/{slug}",status="404"} 1`)
+	require.Contains(t, out, `gochatbot_templates_published_total 1`)
+	require.Contains(t, out, `gochatbot_jobs_finished_total{kind="export_lead",outcome="succeeded"} 1`)
+	require.Contains(t, out, `gochatbot_tool_calls_total{outcome="invalid",tool="capture_lead_field"} 1`)
+}
+
+func TestMetrics_PoolAndQueueDepthCollectors(t *testing.T) {
+	// pgxpool connects lazily, so an unreachable DSN is fine for reading stats.
+	pool, err := pgxpool.New(context.Background(), "postgres://u:p@127.0.0.1:1/none")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	m := metrics.New()
+	m.MustRegister(
+		metrics.NewPoolCollector(pool),
+		metrics.NewQueueDepthCollector(func(context.Context) (map[string]int64, error) {
+			return map[string]int64{"export_lead": 3}, nil
+		}),
+	)
+
+	out := scrape(t, m)
+	require.Contains(t, out, `gochatbot_db_pool_total_conns 0`)
+	require.Contains(t, out, `gochatbot_jobs_queue_depth{kind="export_lead"} 3`)
+}
+
+func TestMetrics_QueueDepthErrorDoesNotFailScrape(t *testing.T) {
+	m := metrics.New()
+	m.MustRegister(metrics.NewQueueDepthCollector(func(context.Context) (map[string]int64, error) {
+		return nil, errors.New("db down")
+	}))
+
+	out := scrape(t, m)
+	require.NotContains(t, out, `gochatbot_jobs_queue_depth{`)
+}
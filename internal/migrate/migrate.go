@@ -0,0 +1,291 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration changed on disk")
+	ErrNoDownScript     = errors.New("migration has no down script")
+	ErrBadFilename      = errors.New("bad migration filename")
+)
+
+// lockKey is the pg_advisory_lock id shared by every runner ("gochatbt" in ASCII).
+const lockKey int64 = 0x676f636861746274
+
+// Conn is a single session: advisory locks are per-connection, so callers
+// holding a pool must Acquire one. *pgx.Conn and *pgxpool.Conn both satisfy it.
+type Conn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // empty when there is no NNNN_name.down.sql
+	Checksum string // sha256 of Up
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the recorded checksum differs from the file.
+	Modified bool
+}
+
+// State is how status listings name it: pending, applied or modified.
+func (s Status) State() string {
+	switch {
+	case s.Modified:
+		return "modified"
+	case s.Applied:
+		return "applied"
+	default:
+		return "pending"
+	}
+}
+
+type Runner struct {
+	migrations []Migration
+}
+
+// New reads migrations from fsys. Files are named NNNN_name.sql with an
+// optional NNNN_name.down.sql; versions must be unique.
+func New(fsys fs.FS) (*Runner, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		down := strings.HasSuffix(name, ".down.sql")
+		base := strings.TrimSuffix(strings.TrimSuffix(name, ".sql"), ".down")
+
+		num, label, ok := strings.Cut(base, "_")
+		version, convErr := strconv.Atoi(num)
+		if !ok || convErr != nil || version <= 0 || label == "" {
+			return nil, fmt.Errorf("%w: %s", ErrBadFilename, name)
+		}
+
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if m.Name != label {
+			return nil, fmt.Errorf("%w: version %d used by %q and %q", ErrBadFilename, version, m.Name, label)
+		}
+		if down {
+			m.Down = string(body)
+		} else {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has a down script but no up", ErrBadFilename, m.Version)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return &Runner{migrations: out}, nil
+}
+
+func (r *Runner) Migrations() []Migration {
+	return append([]Migration(nil), r.migrations...)
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock serializes runners across processes for the duration of fn.
+func withLock(ctx context.Context, conn Conn, fn func() error) (err error) {
+	if _, err := conn.Exec(ctx, `select pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// unlock even if ctx was cancelled mid-run
+		if _, uerr := conn.Exec(context.WithoutCancel(ctx), `select pg_advisory_unlock($1)`, lockKey); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `
+		create table if not exists schema_migrations (
+		  version int primary key,
+		  name text not null,
+		  checksum text not null,
+		  applied_at timestamptz not null default now()
+		)
+	`); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func loadApplied(ctx context.Context, conn Conn) (map[int]applied, error) {
+	rows, err := conn.Query(ctx, `select version, checksum, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]applied{}
+	for rows.Next() {
+		var v int
+		var a applied
+		if err := rows.Scan(&v, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out[v] = a
+	}
+	return out, rows.Err()
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction. It refuses to run if an applied migration's file was edited.
+func (r *Runner) Up(ctx context.Context, conn Conn) ([]Migration, error) {
+	var ran []Migration
+	err := withLock(ctx, conn, func() error {
+		done, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			if a, ok := done[m.Version]; ok && a.checksum != m.Checksum {
+				return fmt.Errorf("%w: %04d_%s", ErrChecksumMismatch, m.Version, m.Name)
+			}
+		}
+		for _, m := range r.migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, m.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `
+					insert into schema_migrations (version, name, checksum) values ($1, $2, $3)
+				`, m.Version, m.Name, m.Checksum)
+				return err
+			}); err != nil {
+				return fmt.Errorf("%04d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the newest `steps` applied migrations using their down scripts.
+func (r *Runner) Down(ctx context.Context, conn Conn, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := withLock(ctx, conn, func() error {
+		done, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(m.Down) == "" {
+				return fmt.Errorf("%w: %04d_%s", ErrNoDownScript, m.Version, m.Name)
+			}
+			if err := apply(ctx, conn, m.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `delete from schema_migrations where version = $1`, m.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("%04d_%s down: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status reports every known migration and whether it has been applied.
+func (r *Runner) Status(ctx context.Context, conn Conn) ([]Status, error) {
+	var out []Status
+	err := withLock(ctx, conn, func() error {
+		done, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			st := Status{Migration: m}
+			if a, ok := done[m.Version]; ok {
+				st.Applied = true
+				st.AppliedAt = a.appliedAt
+				st.Modified = a.checksum != m.Checksum
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Connect reads the migrations in fsys and hands fn a runner and its own
+// connection to databaseURL, closed when fn returns. The migrate commands of
+// both binaries go through it, so neither borrows a pooled session.
+func Connect(ctx context.Context, databaseURL string, fsys fs.FS, fn func(*Runner, Conn) error) error {
+	runner, err := New(fsys)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close(context.WithoutCancel(ctx)) }()
+	return fn(runner, conn)
+}
+
+func apply(ctx context.Context, conn Conn, sql string, record func(pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
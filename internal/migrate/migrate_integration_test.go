@@ -0,0 +1,64 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/migrate"
+	"gochatbot/internal/testdb"
+)
+
+func TestRunner_UpStatusDown(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"0001_a.sql":      {Data: []byte("create table a (id int);")},
+		"0001_a.down.sql": {Data: []byte("drop table a;")},
+		"0002_b.sql":      {Data: []byte("create table b (id int);")},
+		"0002_b.down.sql": {Data: []byte("drop table b;")},
+	}
+	r, err := migrate.New(fsys)
+	require.NoError(t, err)
+
+	ran, err := r.Up(ctx, db.Conn)
+	require.NoError(t, err)
+	require.Len(t, ran, 2)
+
+	// second run is a no-op
+	ran, err = r.Up(ctx, db.Conn)
+	require.NoError(t, err)
+	require.Empty(t, ran)
+
+	sts, err := r.Status(ctx, db.Conn)
+	require.NoError(t, err)
+	require.True(t, sts[0].Applied)
+	require.True(t, sts[1].Applied)
+
+	reverted, err := r.Down(ctx, db.Conn, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, 2, reverted[0].Version)
+
+	sts, err = r.Status(ctx, db.Conn)
+	require.NoError(t, err)
+	require.False(t, sts[1].Applied)
+}
+
+func TestRunner_RefusesEditedMigration(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	ctx := context.Background()
+
+	r, err := migrate.New(fstest.MapFS{"0001_a.sql": {Data: []byte("create table a (id int);")}})
+	require.NoError(t, err)
+	_, err = r.Up(ctx, db.Conn)
+	require.NoError(t, err)
+
+	edited, err := migrate.New(fstest.MapFS{"0001_a.sql": {Data: []byte("create table a (id bigint);")}})
+	require.NoError(t, err)
+	_, err = edited.Up(ctx, db.Conn)
+	require.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+}
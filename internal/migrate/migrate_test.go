@@ -0,0 +1,58 @@
+package migrate_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/migrate"
+	"gochatbot/migrations"
+)
+
+func TestNew_ParsesUpAndDownInVersionOrder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_b.sql":      {Data: []byte("create table b();")},
+		"0001_a.sql":      {Data: []byte("create table a();")},
+		"0001_a.down.sql": {Data: []byte("drop table a;")},
+		"README.md":       {Data: []byte("ignored")},
+	}
+	r, err := migrate.New(fsys)
+	require.NoError(t, err)
+
+	ms := r.Migrations()
+	require.Len(t, ms, 2)
+	require.Equal(t, 1, ms[0].Version)
+	require.Equal(t, "a", ms[0].Name)
+	require.Equal(t, "drop table a;", ms[0].Down)
+	require.Equal(t, 2, ms[1].Version)
+	require.Empty(t, ms[1].Down)
+	require.Len(t, ms[0].Checksum, 64)
+}
+
+func TestNew_RejectsBadNames(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"no version":      {"init.sql": {Data: []byte("x")}},
+		"duplicate":       {"0001_a.sql": {Data: []byte("x")}, "0001_b.sql": {Data: []byte("y")}},
+		"down without up": {"0001_a.down.sql": {Data: []byte("x")}},
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := migrate.New(fsys)
+			require.ErrorIs(t, err, migrate.ErrBadFilename)
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	r, err := migrate.New(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, r.Migrations())
+	require.Equal(t, 1, r.Migrations()[0].Version)
+}
+
+func TestStatus_State(t *testing.T) {
+	require.Equal(t, "pending", migrate.Status{}.State())
+	require.Equal(t, "applied", migrate.Status{Applied: true}.State())
+	require.Equal(t, "modified", migrate.Status{Applied: true, Modified: true}.State())
+}
@@ -0,0 +1,61 @@
+package pagination_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/pagination"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	c := pagination.NewCodec([]byte("k"))
+	f := pagination.Filter{Prefix: "ac", Sort: pagination.Sort{By: pagination.SortName}}
+	cur := pagination.Cursor{Name: "Acme | Partners", ID: "t1", Sort: f.Sort, Backward: true}
+
+	got, err := c.Decode(c.Encode(cur, f), f)
+	require.NoError(t, err)
+	require.Equal(t, cur, got)
+
+	f = pagination.Filter{CreatedAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cur = pagination.Cursor{CreatedAt: time.Date(2025, 12, 18, 1, 2, 3, 4, time.UTC), ID: "t2"}
+	got, err = c.Decode(c.Encode(cur, f), f)
+	require.NoError(t, err)
+	require.Equal(t, cur.CreatedAt, got.CreatedAt)
+	require.False(t, got.Backward)
+}
+
+func TestCodec_RejectsOtherFilterSortOrKey(t *testing.T) {
+	c := pagination.NewCodec([]byte("k"))
+	f := pagination.Filter{Prefix: "ac"}
+	enc := c.Encode(pagination.Cursor{CreatedAt: time.Now(), ID: "t1"}, f)
+
+	for _, other := range []pagination.Filter{
+		{},
+		{Prefix: "acm"},
+		{Prefix: "ac", CreatedBefore: time.Now()},
+		{Prefix: "ac", Sort: pagination.Sort{Asc: true}},
+		{Prefix: "ac", Status: "new"},
+	} {
+		_, err := c.Decode(enc, other)
+		require.ErrorIs(t, err, domain.ErrInvalidCursor)
+	}
+
+	_, err := pagination.NewCodec([]byte("other")).Decode(enc, f)
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+	_, err = c.Decode("not.base64!", f)
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
+
+func TestCodec_LegacyCursors(t *testing.T) {
+	legacy := pagination.Encode(pagination.Cursor{CreatedAt: time.Now(), ID: "t1"})
+
+	_, err := pagination.NewCodec(nil).Decode(legacy, pagination.Filter{})
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+
+	got, err := pagination.NewCodec(nil).WithLegacy(true).Decode(legacy, pagination.Filter{Prefix: "anything"})
+	require.NoError(t, err)
+	require.Equal(t, "t1", got.ID)
+}
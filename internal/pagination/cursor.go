@@ -8,14 +8,38 @@ import (
 	"gochatbot/internal/domain"
 )
 
+// Cursor marks the row a page starts after. Sort is the ordering the page
+// was listed in; Name is only set when that ordering is by name. A Backward
+// cursor pages towards the start of the list instead.
 type Cursor struct {
 	CreatedAt time.Time
+	Name      string
 	ID        string
+	Sort      Sort
+	Backward  bool
 }
 
-// Encode cursor as base64("RFC3339Nano|id")
+// Page holds the cursors around a page; nil means there is nothing more in
+// that direction.
+type Page struct {
+	Next *Cursor
+	Prev *Cursor
+}
+
+// Encode cursor as base64("RFC3339Nano|id") for the default sort, which is
+// the format clients already hold, and base64("sort|id|value") otherwise.
+// These cursors are unsigned; the API issues Codec cursors and only decodes
+// these during the transition.
 func Encode(c Cursor) string {
-	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	var raw string
+	switch {
+	case c.Sort.IsDefault():
+		raw = c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	case c.Sort.By == SortName:
+		raw = c.Sort.String() + "|" + c.ID + "|" + c.Name
+	default:
+		raw = c.Sort.String() + "|" + c.ID + "|" + c.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
 	return base64.RawURLEncoding.EncodeToString([]byte(raw))
 }
 
@@ -30,20 +54,63 @@ func Decode(s string) (Cursor, error) {
 		return Cursor{}, domain.ErrInvalidCursor
 	}
 
-	parts := strings.SplitN(string(b), "|", 2)
-	if len(parts) != 2 {
+	parts := strings.SplitN(string(b), "|", 3)
+	switch len(parts) {
+	case 2:
+		return decodeDefault(parts[0], parts[1])
+	case 3:
+		return decodeSorted(parts[0], parts[1], parts[2])
+	}
+	return Cursor{}, domain.ErrInvalidCursor
+}
+
+// DecodeFor decodes s and rejects it unless it was issued for sort, so a
+// client that changes the ordering mid-walk starts over instead of skipping
+// or repeating rows.
+func DecodeFor(s string, sort Sort) (Cursor, error) {
+	c, err := Decode(s)
+	if err != nil {
+		return Cursor{}, err
+	}
+	if c.Sort.String() != sort.String() {
 		return Cursor{}, domain.ErrInvalidCursor
 	}
+	return c, nil
+}
 
-	t, err := time.Parse(time.RFC3339Nano, parts[0])
+func decodeDefault(ts, id string) (Cursor, error) {
+	t, err := time.Parse(time.RFC3339Nano, ts)
 	if err != nil {
 		return Cursor{}, domain.ErrInvalidCursor
 	}
 
-	id := strings.TrimSpace(parts[1])
+	id = strings.TrimSpace(id)
 	if id == "" {
 		return Cursor{}, domain.ErrInvalidCursor
 	}
 
 	return Cursor{CreatedAt: t, ID: id}, nil
 }
+
+func decodeSorted(sort, id, value string) (Cursor, error) {
+	by, order, _ := strings.Cut(sort, ":")
+	srt, err := ParseSort(by, order)
+	if err != nil || sort != srt.String() {
+		return Cursor{}, domain.ErrInvalidCursor
+	}
+
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return Cursor{}, domain.ErrInvalidCursor
+	}
+
+	c := Cursor{ID: id, Sort: srt}
+	if srt.By == SortName {
+		c.Name = value
+		return c, nil
+	}
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, value); err != nil {
+		return Cursor{}, domain.ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -46,3 +46,57 @@ func TestCursor_Decode_EmptyID(t *testing.T) {
 	_, err := pagination.Decode(s)
 	require.ErrorIs(t, err, domain.ErrInvalidCursor)
 }
+
+func TestCursor_DefaultSortKeepsLegacyFormat(t *testing.T) {
+	// base64("2025-12-18T00:00:00Z|t9"), as issued before sorting existed
+	got, err := pagination.DecodeFor("MjAyNS0xMi0xOFQwMDowMDowMFp8dDk", pagination.Sort{})
+	require.NoError(t, err)
+	require.Equal(t, "t9", got.ID)
+
+	enc := pagination.Encode(pagination.Cursor{CreatedAt: got.CreatedAt, ID: "t9"})
+	require.Equal(t, "MjAyNS0xMi0xOFQwMDowMDowMFp8dDk", enc)
+}
+
+func TestCursor_SortedRoundTrip(t *testing.T) {
+	byName := pagination.Sort{By: pagination.SortName, Asc: true}
+	c0 := pagination.Cursor{Name: "Acme | Partners", ID: "t1", Sort: byName}
+
+	got, err := pagination.DecodeFor(pagination.Encode(c0), byName)
+	require.NoError(t, err)
+	require.Equal(t, c0, got)
+
+	oldest := pagination.Sort{By: pagination.SortCreatedAt, Asc: true}
+	c1 := pagination.Cursor{CreatedAt: time.Date(2025, 12, 18, 1, 2, 3, 4, time.UTC), ID: "t2", Sort: oldest}
+	got, err = pagination.DecodeFor(pagination.Encode(c1), oldest)
+	require.NoError(t, err)
+	require.Equal(t, c1.CreatedAt, got.CreatedAt)
+}
+
+func TestCursor_DecodeFor_SortChanged(t *testing.T) {
+	byName := pagination.Sort{By: pagination.SortName, Asc: true}
+	enc := pagination.Encode(pagination.Cursor{Name: "Acme", ID: "t1", Sort: byName})
+
+	_, err := pagination.DecodeFor(enc, pagination.Sort{By: pagination.SortName})
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+	_, err = pagination.DecodeFor(enc, pagination.Sort{})
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+
+	legacy := pagination.Encode(pagination.Cursor{CreatedAt: time.Now(), ID: "t1"})
+	_, err = pagination.DecodeFor(legacy, byName)
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
+
+func TestParseSort(t *testing.T) {
+	s, err := pagination.ParseSort("", "")
+	require.NoError(t, err)
+	require.True(t, s.IsDefault())
+
+	s, err = pagination.ParseSort("name", "")
+	require.NoError(t, err)
+	require.Equal(t, "name:asc", s.String())
+
+	_, err = pagination.ParseSort("slug", "")
+	require.Error(t, err)
+	_, err = pagination.ParseSort("name", "sideways")
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package pagination
+
+import (
+	"errors"
+	"time"
+)
+
+type SortKey string
+
+const (
+	SortCreatedAt SortKey = "created_at"
+	SortName      SortKey = "name"
+)
+
+// Sort orders a list; ties are broken by id in the same direction. The zero
+// value is created_at descending, the order lists have always used.
+type Sort struct {
+	By  SortKey
+	Asc bool
+}
+
+// ParseSort reads the sort and order query parameters. An empty order is
+// desc for created_at and asc for name.
+func ParseSort(by, order string) (Sort, error) {
+	s := Sort{By: SortKey(by)}
+	switch s.By {
+	case "":
+		s.By = SortCreatedAt
+	case SortCreatedAt, SortName:
+	default:
+		return Sort{}, errors.New("invalid sort")
+	}
+	switch order {
+	case "":
+		s.Asc = s.By == SortName
+	case "asc":
+		s.Asc = true
+	case "desc":
+	default:
+		return Sort{}, errors.New("invalid order")
+	}
+	return s, nil
+}
+
+func (s Sort) key() SortKey {
+	if s.By == "" {
+		return SortCreatedAt
+	}
+	return s.By
+}
+
+func (s Sort) IsDefault() bool { return s.key() == SortCreatedAt && !s.Asc }
+
+// String is the canonical form, e.g. "name:asc".
+func (s Sort) String() string {
+	if s.Asc {
+		return string(s.key()) + ":asc"
+	}
+	return string(s.key()) + ":desc"
+}
+
+// Filter narrows a list of named rows. Zero fields do not filter.
+type Filter struct {
+	// Prefix matches the start of name or slug (name or email for leads),
+	// case-insensitively.
+	Prefix        string
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+	// Status matches exactly, for lists of rows that have one.
+	Status string
+	Sort   Sort
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in this process. Each replica limits on its own, so
+// with N replicas behind a balancer clients get up to N times the limit;
+// use the Postgres store when that matters.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*memBucket
+	now     func() time.Time
+	swept   time.Time
+}
+
+type memBucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time // when the bucket is full again and can be forgotten
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*memBucket{}, now: time.Now}
+}
+
+// WithClock replaces time.Now, for tests.
+func (m *Memory) WithClock(now func() time.Time) *Memory {
+	m.now = now
+	return m
+}
+
+func (m *Memory) Take(_ context.Context, key string, l Limit) (bool, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memBucket{tokens: float64(l.Burst), at: now}
+		m.buckets[key] = b
+	}
+	b.tokens = min(float64(l.Burst), b.tokens+now.Sub(b.at).Seconds()*l.Rate())
+	b.at = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((float64(l.Burst) - b.tokens) / l.Rate() * float64(time.Second)))
+	return allowed, b.tokens, nil
+}
+
+// sweep drops full buckets once a minute; a new bucket starts full, so
+// forgetting one changes nothing.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < time.Minute {
+		return
+	}
+	m.swept = now
+	for k, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, k)
+		}
+	}
+}
+
+// Len reports how many buckets are held.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
@@ -0,0 +1,310 @@
+// Package ratelimit throttles requests with token buckets. Each route group
+// has limits per dimension (API key, tenant, client IP); a request takes one
+// token from every bucket that applies to it and is refused with 429 when
+// any of them is empty.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Dimensions a limit can be keyed by.
+const (
+	DimKey    = "key"
+	DimTenant = "tenant"
+	DimIP     = "ip"
+)
+
+// AnyGroup in a rule applies to every group without a rule of its own for
+// that dimension.
+const AnyGroup = "*"
+
+// Limit is a bucket holding Burst tokens that refills at Count per Period.
+type Limit struct {
+	Count  int
+	Period time.Duration
+	Burst  int
+}
+
+// Rate is the refill rate in tokens per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Count) / l.Period.Seconds()
+}
+
+// Rule limits one dimension of one route group.
+type Rule struct {
+	Group     string
+	Dimension string
+	Limit     Limit
+}
+
+// ParseRule reads "group.dimension=count/period[/burst]", e.g.
+// "tenants.ip=300/1m/50". Burst defaults to count.
+func ParseRule(s string) (Rule, error) {
+	lhs, rhs, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q: want group.dimension=count/period[/burst]", s)
+	}
+	group, dim, ok := strings.Cut(lhs, ".")
+	if !ok || group == "" {
+		return Rule{}, fmt.Errorf("rate limit %q: want group.dimension on the left", s)
+	}
+	switch dim {
+	case DimKey, DimTenant, DimIP:
+	default:
+		return Rule{}, fmt.Errorf("rate limit %q: dimension must be key, tenant or ip", s)
+	}
+
+	parts := strings.Split(rhs, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Rule{}, fmt.Errorf("rate limit %q: want count/period[/burst]", s)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 1 {
+		return Rule{}, fmt.Errorf("rate limit %q: count must be a positive integer", s)
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: period must be a positive duration", s)
+	}
+	burst := count
+	if len(parts) == 3 {
+		if burst, err = strconv.Atoi(parts[2]); err != nil || burst < 1 {
+			return Rule{}, fmt.Errorf("rate limit %q: burst must be a positive integer", s)
+		}
+	}
+	return Rule{Group: group, Dimension: dim, Limit: Limit{Count: count, Period: period, Burst: burst}}, nil
+}
+
+// ParseRules parses every rule and rejects duplicates.
+func ParseRules(specs []string) ([]Rule, error) {
+	seen := map[string]bool{}
+	out := make([]Rule, 0, len(specs))
+	for _, s := range specs {
+		r, err := ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		id := r.Group + "." + r.Dimension
+		if seen[id] {
+			return nil, fmt.Errorf("rate limit %q: %s is limited twice", s, id)
+		}
+		seen[id] = true
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+type Store interface {
+	// Take refills the bucket at key for the time since it was last used,
+	// then removes one token if there is one. It reports whether a token
+	// was taken and how many are left.
+	Take(ctx context.Context, key string, l Limit) (allowed bool, tokens float64, err error)
+}
+
+// Observer is told about refused requests; *metrics.Metrics satisfies it.
+type Observer interface {
+	RateLimited(group, dimension string)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) RateLimited(string, string) {}
+
+type Limiter struct {
+	store          Store
+	rules          map[string]Limit // "group.dimension"
+	obs            Observer
+	trustForwarded bool
+	apiKeys        map[string]bool // keyID of each configured key
+}
+
+func New(store Store, rules []Rule) *Limiter {
+	l := &Limiter{store: store, rules: map[string]Limit{}, obs: nopObserver{}}
+	for _, r := range rules {
+		l.rules[r.Group+"."+r.Dimension] = r.Limit
+	}
+	return l
+}
+
+func (l *Limiter) WithObserver(o Observer) *Limiter {
+	if o != nil {
+		l.obs = o
+	}
+	return l
+}
+
+// WithTrustForwarded takes the client IP from the last X-Forwarded-For hop,
+// the one our own load balancer appended. Only enable it behind a proxy:
+// otherwise clients pick their own IP.
+func (l *Limiter) WithTrustForwarded(trust bool) *Limiter {
+	l.trustForwarded = trust
+	return l
+}
+
+// WithAPIKeys sets the keys the key dimension counts. Any other presented
+// key gets no key bucket, so a caller cannot mint fresh buckets by making
+// keys up; without keys the key dimension never applies.
+func (l *Limiter) WithAPIKeys(keys []string) *Limiter {
+	l.apiKeys = make(map[string]bool, len(keys))
+	for _, k := range keys {
+		l.apiKeys[keyID(k)] = true
+	}
+	return l
+}
+
+func (l *Limiter) limit(group, dim string) (Limit, bool) {
+	if lim, ok := l.rules[group+"."+dim]; ok {
+		return lim, true
+	}
+	lim, ok := l.rules[AnyGroup+"."+dim]
+	return lim, ok
+}
+
+// bucket is one limit that applies to the current request.
+type bucket struct {
+	dim   string
+	key   string
+	limit Limit
+}
+
+func (l *Limiter) buckets(group string, r *http.Request) []bucket {
+	ids := map[string]string{
+		DimKey:    l.apiKey(r),
+		DimTenant: chi.URLParam(r, "tenantSlug"),
+		DimIP:     l.clientIP(r),
+	}
+	var out []bucket
+	for _, dim := range []string{DimKey, DimTenant, DimIP} {
+		id := ids[dim]
+		if id == "" {
+			continue
+		}
+		if lim, ok := l.limit(group, dim); ok {
+			out = append(out, bucket{dim: dim, key: group + "|" + dim + "|" + id, limit: lim})
+		}
+	}
+	return out
+}
+
+// apiKey identifies the caller's API key, when it is a configured one,
+// without keeping the key itself in the store.
+func (l *Limiter) apiKey(r *http.Request) string {
+	k := r.Header.Get("X-API-Key")
+	if k == "" {
+		if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			k = strings.TrimSpace(v)
+		}
+	}
+	if k == "" {
+		return ""
+	}
+	if id := keyID(k); l.apiKeys[id] {
+		return id
+	}
+	return ""
+}
+
+func keyID(k string) string {
+	sum := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(sum[:12])
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.trustForwarded {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			hops := strings.Split(xff, ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Middleware limits the routes of group. The tenant dimension reads the
+// tenantSlug URL parameter, so mount it where chi has already matched it.
+//
+// Every response carries RateLimit-Limit, -Remaining and -Reset for the
+// bucket closest to empty; a refused request also gets Retry-After. When
+// the store fails the request is let through: an outage of the limiter
+// should not become an outage of the API.
+func (l *Limiter) Middleware(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bs := l.buckets(group, r)
+			if len(bs) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// tokens already taken from earlier buckets stay spent when a
+			// later one refuses; the caller is over a limit either way
+			var tightest *bucket
+			var left float64
+			refused := false
+			for i := range bs {
+				b := &bs[i]
+				allowed, tokens, err := l.store.Take(r.Context(), b.key, b.limit)
+				if err != nil {
+					log.Printf("ratelimit: take %s: %v", b.key, err)
+					continue
+				}
+				if !allowed {
+					tightest, left, refused = b, tokens, true
+					break
+				}
+				if tightest == nil || tokens/float64(b.limit.Burst) < left/float64(tightest.limit.Burst) {
+					tightest, left = b, tokens
+				}
+			}
+			if tightest == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setHeaders(w, tightest.limit, left)
+			if refused {
+				l.obs.RateLimited(group, tightest.dim)
+				wait := math.Ceil((1 - left) / tightest.limit.Rate())
+				w.Header().Set("Retry-After", strconv.Itoa(max(int(wait), 1)))
+				writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setHeaders follows the IETF RateLimit header fields draft: Reset is the
+// number of seconds until the bucket is full again.
+func setHeaders(w http.ResponseWriter, l Limit, tokens float64) {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(l.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(max(int(tokens), 0)))
+	h.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(l.Burst)-tokens)/l.Rate()))))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", l.Count, int(math.Ceil(l.Period.Seconds())), l.Burst))
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": msg})
+}
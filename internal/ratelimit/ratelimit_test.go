@@ -0,0 +1,178 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/ratelimit"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newClock() *clock                   { return &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)} }
+
+func rules(t *testing.T, specs ...string) []ratelimit.Rule {
+	t.Helper()
+	rs, err := ratelimit.ParseRules(specs)
+	require.NoError(t, err)
+	return rs
+}
+
+type groups struct{ limited map[string]int }
+
+func (g *groups) RateLimited(group, dim string) { g.limited[group+"."+dim]++ }
+
+// router mounts the limiter the way httpapi does: under {tenantSlug}.
+func router(l *ratelimit.Limiter) http.Handler {
+	r := chi.NewRouter()
+	r.Route("/v1/tenants/{tenantSlug}", func(r chi.Router) {
+		r.Use(l.Middleware("tenants"))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	})
+	return r
+}
+
+func get(h http.Handler, path, ip string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = ip + ":4321"
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestParseRule(t *testing.T) {
+	r, err := ratelimit.ParseRule("tenants.ip=300/1m/50")
+	require.NoError(t, err)
+	require.Equal(t, ratelimit.Rule{Group: "tenants", Dimension: "ip",
+		Limit: ratelimit.Limit{Count: 300, Period: time.Minute, Burst: 50}}, r)
+	require.InDelta(t, 5.0, r.Limit.Rate(), 1e-9)
+
+	r, err = ratelimit.ParseRule("*.key=10/1s")
+	require.NoError(t, err)
+	require.Equal(t, 10, r.Limit.Burst, "burst defaults to count")
+
+	for _, bad := range []string{"tenants.ip", "ip=1/1s", "tenants.user=1/1s", "tenants.ip=0/1s", "tenants.ip=1/soon", "tenants.ip=1/1s/0", "tenants.ip=1/1s/2/3"} {
+		_, err := ratelimit.ParseRule(bad)
+		require.Error(t, err, bad)
+	}
+	_, err = ratelimit.ParseRules([]string{"*.ip=1/1s", "*.ip=2/1s"})
+	require.ErrorContains(t, err, "limited twice")
+}
+
+func TestMemory_TokenBucket(t *testing.T) {
+	c := newClock()
+	m := ratelimit.NewMemory().WithClock(c.now)
+	l := ratelimit.Limit{Count: 1, Period: time.Second, Burst: 3}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		ok, left, err := m.Take(ctx, "k", l)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.InDelta(t, float64(i), left, 1e-9)
+	}
+	ok, _, _ := m.Take(ctx, "k", l)
+	require.False(t, ok, "burst spent")
+
+	c.advance(1500 * time.Millisecond)
+	ok, left, _ := m.Take(ctx, "k", l)
+	require.True(t, ok)
+	require.InDelta(t, 0.5, left, 1e-9)
+
+	c.advance(time.Hour)
+	ok, left, _ = m.Take(ctx, "k", l)
+	require.True(t, ok)
+	require.InDelta(t, 2.0, left, 1e-9, "refill stops at burst")
+
+	// full buckets are forgotten on the next sweep
+	c.advance(time.Hour)
+	_, _, _ = m.Take(ctx, "other", l)
+	require.Equal(t, 1, m.Len())
+}
+
+func TestMiddleware_RefusesWithHeaders(t *testing.T) {
+	c := newClock()
+	obs := &groups{limited: map[string]int{}}
+	l := ratelimit.New(ratelimit.NewMemory().WithClock(c.now), rules(t, "*.ip=60/1m/2")).WithObserver(obs)
+	h := router(l)
+
+	rr := get(h, "/v1/tenants/acme/", "10.0.0.1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "1", rr.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "60;w=60;burst=2", rr.Header().Get("RateLimit-Policy"))
+
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.1").Code)
+	rr = get(h, "/v1/tenants/acme/", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	require.JSONEq(t, `{"error":"rate limit exceeded"}`, rr.Body.String())
+	require.Equal(t, 1, obs.limited["tenants.ip"])
+
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.2").Code, "other IPs have their own bucket")
+	c.advance(time.Second)
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.1").Code)
+}
+
+func TestMiddleware_TenantAndKeyDimensions(t *testing.T) {
+	l := ratelimit.New(ratelimit.NewMemory(), rules(t, "tenants.tenant=1/1h", "tenants.key=2/1h", "other.ip=1/1h"))
+	h := router(l)
+
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.1").Code)
+	require.Equal(t, http.StatusTooManyRequests, get(h, "/v1/tenants/acme/", "10.0.0.2").Code, "tenant bucket is shared across IPs")
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/globex/", "10.0.0.1").Code)
+
+	l = ratelimit.New(ratelimit.NewMemory(), rules(t, "tenants.key=1/1h")).WithAPIKeys([]string{"k-one", "k-two"})
+	h = router(l)
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.1", "X-API-Key", "k-one").Code)
+	require.Equal(t, http.StatusTooManyRequests, get(h, "/v1/tenants/acme/", "10.0.0.2", "Authorization", "Bearer k-one").Code)
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.1", "X-API-Key", "k-two").Code)
+	rr := get(h, "/v1/tenants/acme/", "10.0.0.1")
+	require.Equal(t, http.StatusOK, rr.Code, "no key, no key limit")
+	require.Empty(t, rr.Header().Get("RateLimit-Limit"))
+	for range 3 {
+		rr = get(h, "/v1/tenants/acme/", "10.0.0.1", "X-API-Key", "made-up")
+		require.Equal(t, http.StatusOK, rr.Code, "unknown keys get no bucket")
+		require.Empty(t, rr.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestMiddleware_TrustForwarded(t *testing.T) {
+	l := ratelimit.New(ratelimit.NewMemory(), rules(t, "*.ip=1/1h"))
+	h := router(l)
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.9", "X-Forwarded-For", "1.1.1.1").Code)
+	require.Equal(t, http.StatusTooManyRequests, get(h, "/v1/tenants/acme/", "10.0.0.9", "X-Forwarded-For", "2.2.2.2").Code,
+		"untrusted X-Forwarded-For is ignored")
+
+	h = router(l.WithTrustForwarded(true))
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.9", "X-Forwarded-For", "6.6.6.6, 3.3.3.3").Code)
+	require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.9", "X-Forwarded-For", "6.6.6.6, 4.4.4.4").Code,
+		"only the hop our proxy appended counts")
+}
+
+type failing struct{}
+
+func (failing) Take(context.Context, string, ratelimit.Limit) (bool, float64, error) {
+	return false, 0, errors.New("db down")
+}
+
+func TestMiddleware_StoreErrorLetsRequestsThrough(t *testing.T) {
+	h := router(ratelimit.New(failing{}, rules(t, "*.ip=1/1h")))
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, get(h, "/v1/tenants/acme/", "10.0.0.1").Code)
+	}
+}
@@ -0,0 +1,132 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gochatbot/internal/repo"
+)
+
+// Compared fields per entity.
+const (
+	FieldName             = "name"
+	FieldPublishedVersion = "published_version" // "" when nothing is published
+)
+
+// PostgresReader reads the tenants/templates/template_versions schema. It is
+// used for Go's own database and for a legacy database with the same table
+// shape (views are fine).
+type PostgresReader struct {
+	db repo.Querier
+}
+
+func NewPostgresReader(db repo.Querier) *PostgresReader {
+	return &PostgresReader{db: db}
+}
+
+var pgQueries = map[string]string{
+	EntityTenant: `
+		select slug, name
+		from tenants
+	`,
+	EntityTemplate: `
+		select tn.slug || '/' || t.slug, t.name, coalesce(v.version::text, '')
+		from templates t
+		join tenants tn on tn.id = t.tenant_id
+		left join template_versions v on v.template_id = t.id and v.status = 'published'
+	`,
+}
+
+func (r *PostgresReader) Read(ctx context.Context, entity string) ([]Record, error) {
+	q, ok := pgQueries[entity]
+	if !ok {
+		return nil, fmt.Errorf("reconcile: unknown entity %q", entity)
+	}
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		var key, name, published string
+		dest := []any{&key, &name}
+		if entity == EntityTemplate {
+			dest = append(dest, &published)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		rec := Record{Key: key, Fields: map[string]string{FieldName: name}}
+		if entity == EntityTemplate {
+			rec.Fields[FieldPublishedVersion] = published
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// JSONReader reads a legacy export file, re-read on every call so a fresh
+// export is picked up by the next run:
+//
+//	{
+//	  "tenants":   [{"slug": "acme", "name": "Acme"}],
+//	  "templates": [{"tenant_slug": "acme", "slug": "faq", "name": "FAQ", "published_version": 2}]
+//	}
+type JSONReader struct {
+	path string
+}
+
+func NewJSONReader(path string) *JSONReader {
+	return &JSONReader{path: path}
+}
+
+type jsonExport struct {
+	Tenants []struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	} `json:"tenants"`
+	Templates []struct {
+		TenantSlug       string `json:"tenant_slug"`
+		Slug             string `json:"slug"`
+		Name             string `json:"name"`
+		PublishedVersion *int   `json:"published_version"`
+	} `json:"templates"`
+}
+
+func (r *JSONReader) Read(_ context.Context, entity string) ([]Record, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	var exp jsonExport
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return nil, fmt.Errorf("reconcile: %s: %w", r.path, err)
+	}
+
+	var out []Record
+	switch entity {
+	case EntityTenant:
+		for _, t := range exp.Tenants {
+			out = append(out, Record{Key: t.Slug, Fields: map[string]string{FieldName: t.Name}})
+		}
+	case EntityTemplate:
+		for _, t := range exp.Templates {
+			published := ""
+			if t.PublishedVersion != nil {
+				published = strconv.Itoa(*t.PublishedVersion)
+			}
+			out = append(out, Record{
+				Key:    t.TenantSlug + "/" + t.Slug,
+				Fields: map[string]string{FieldName: t.Name, FieldPublishedVersion: published},
+			})
+		}
+	default:
+		return nil, fmt.Errorf("reconcile: unknown entity %q", entity)
+	}
+	return out, nil
+}
@@ -0,0 +1,120 @@
+// Package reconcile compares tenants and templates in Go's database with a
+// legacy data source, to catch dual-write drift during the Node → Go
+// migration (Migration.md, safety nets). Rows are matched by natural key,
+// never by id, since the two systems may mint ids independently.
+package reconcile
+
+import (
+	"context"
+	"sort"
+)
+
+// Entities that are reconciled.
+const (
+	EntityTenant   = "tenant"
+	EntityTemplate = "template"
+)
+
+// Entities lists every entity in the order a run checks them.
+var Entities = []string{EntityTenant, EntityTemplate}
+
+// Diff kinds.
+const (
+	KindMissingInGo     = "missing_in_go"
+	KindMissingInLegacy = "missing_in_legacy"
+	KindMismatch        = "mismatch"
+)
+
+// Record is one row in a source-neutral shape.
+type Record struct {
+	// Key is the natural key: the tenant slug, or "tenant-slug/template-slug".
+	Key    string
+	Fields map[string]string
+}
+
+// Reader loads every record of one entity from a data source.
+type Reader interface {
+	Read(ctx context.Context, entity string) ([]Record, error)
+}
+
+// Diff is one difference between the two sides. Field and the values are
+// only set for KindMismatch.
+type Diff struct {
+	Entity string
+	Key    string
+	Kind   string
+	Field  string
+	Go     string
+	Legacy string
+}
+
+// Result is the outcome of comparing one entity.
+type Result struct {
+	Entity  string
+	Checked int // distinct keys seen on either side
+	Diffs   []Diff
+}
+
+// Compare matches records by key and reports rows missing on either side and
+// fields whose values differ. A field absent from one side counts as "".
+// Diffs are ordered by key, then field.
+func Compare(entity string, goRecs, legacyRecs []Record) Result {
+	goByKey := index(goRecs)
+	legacyByKey := index(legacyRecs)
+
+	keys := make([]string, 0, len(goByKey)+len(legacyByKey))
+	for k := range goByKey {
+		keys = append(keys, k)
+	}
+	for k := range legacyByKey {
+		if _, ok := goByKey[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	res := Result{Entity: entity, Checked: len(keys)}
+	for _, k := range keys {
+		g, inGo := goByKey[k]
+		l, inLegacy := legacyByKey[k]
+		switch {
+		case !inGo:
+			res.Diffs = append(res.Diffs, Diff{Entity: entity, Key: k, Kind: KindMissingInGo})
+		case !inLegacy:
+			res.Diffs = append(res.Diffs, Diff{Entity: entity, Key: k, Kind: KindMissingInLegacy})
+		default:
+			for _, f := range fieldNames(g, l) {
+				if g[f] != l[f] {
+					res.Diffs = append(res.Diffs, Diff{
+						Entity: entity, Key: k, Kind: KindMismatch,
+						Field: f, Go: g[f], Legacy: l[f],
+					})
+				}
+			}
+		}
+	}
+	return res
+}
+
+// index keys records by Key; with duplicates the last one wins.
+func index(recs []Record) map[string]map[string]string {
+	out := make(map[string]map[string]string, len(recs))
+	for _, r := range recs {
+		out[r.Key] = r.Fields
+	}
+	return out
+}
+
+func fieldNames(a, b map[string]string) []string {
+	out := make([]string, 0, len(a))
+	for f := range a {
+		out = append(out, f)
+	}
+	for f := range b {
+		if _, ok := a[f]; !ok {
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
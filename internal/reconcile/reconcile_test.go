@@ -0,0 +1,71 @@
+package reconcile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/reconcile"
+)
+
+func rec(key, name string) reconcile.Record {
+	return reconcile.Record{Key: key, Fields: map[string]string{reconcile.FieldName: name}}
+}
+
+func TestCompare_MissingAndMismatched(t *testing.T) {
+	res := reconcile.Compare(reconcile.EntityTenant,
+		[]reconcile.Record{rec("acme", "Acme"), rec("beta", "Beta"), rec("gone-in-node", "X")},
+		[]reconcile.Record{rec("acme", "Acme"), rec("beta", "Beta Corp"), rec("node-only", "Y")},
+	)
+
+	require.Equal(t, 4, res.Checked)
+	require.Equal(t, []reconcile.Diff{
+		{Entity: "tenant", Key: "beta", Kind: reconcile.KindMismatch, Field: "name", Go: "Beta", Legacy: "Beta Corp"},
+		{Entity: "tenant", Key: "gone-in-node", Kind: reconcile.KindMissingInLegacy},
+		{Entity: "tenant", Key: "node-only", Kind: reconcile.KindMissingInGo},
+	}, res.Diffs)
+}
+
+func TestCompare_FieldOnlyOnOneSide(t *testing.T) {
+	g := reconcile.Record{Key: "acme/faq", Fields: map[string]string{"name": "FAQ", "published_version": "2"}}
+	l := reconcile.Record{Key: "acme/faq", Fields: map[string]string{"name": "FAQ"}}
+
+	res := reconcile.Compare(reconcile.EntityTemplate, []reconcile.Record{g}, []reconcile.Record{l})
+	require.Equal(t, []reconcile.Diff{
+		{Entity: "template", Key: "acme/faq", Kind: reconcile.KindMismatch, Field: "published_version", Go: "2"},
+	}, res.Diffs)
+}
+
+func TestJSONReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"tenants": [{"slug": "acme", "name": "Acme"}],
+		"templates": [
+			{"tenant_slug": "acme", "slug": "faq", "name": "FAQ", "published_version": 3},
+			{"tenant_slug": "acme", "slug": "draft-only", "name": "Draft"}
+		]
+	}`), 0o600))
+	r := reconcile.NewJSONReader(path)
+	ctx := context.Background()
+
+	tenants, err := r.Read(ctx, reconcile.EntityTenant)
+	require.NoError(t, err)
+	require.Equal(t, []reconcile.Record{rec("acme", "Acme")}, tenants)
+
+	templates, err := r.Read(ctx, reconcile.EntityTemplate)
+	require.NoError(t, err)
+	require.Equal(t, []reconcile.Record{
+		{Key: "acme/faq", Fields: map[string]string{"name": "FAQ", "published_version": "3"}},
+		{Key: "acme/draft-only", Fields: map[string]string{"name": "Draft", "published_version": ""}},
+	}, templates)
+
+	_, err = r.Read(ctx, "lead")
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"tenants": [`), 0o600))
+	_, err = r.Read(ctx, reconcile.EntityTenant)
+	require.ErrorContains(t, err, "export.json")
+}
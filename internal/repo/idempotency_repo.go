@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"gochatbot/internal/idempotency"
+)
+
+// IdempotencyRepo satisfies idempotency.Store.
+type IdempotencyRepo struct {
+	db Querier
+	// lease is how long a key may stay in flight before a retry of the same
+	// request assumes the first one died with its replica and takes over.
+	lease time.Duration
+}
+
+func NewIdempotencyRepo(db Querier) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db, lease: time.Minute}
+}
+
+// Begin inserts key, or takes over a row for it that has expired or whose
+// in-flight lease ran out on the same request. When a live row already
+// exists it is returned with acquired=false.
+func (r *IdempotencyRepo) Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (idempotency.Record, bool, error) {
+	var claimed bool
+	err := r.db.QueryRow(ctx, `
+		insert into idempotency_keys (key, request_hash, expires_at, locked_until)
+		values ($1, $2, now() + make_interval(secs => $3), now() + make_interval(secs => $4))
+		on conflict (key) do update
+		set request_hash = excluded.request_hash, status = 0, content_type = '',
+		    response_body = null, created_at = now(), expires_at = excluded.expires_at,
+		    locked_until = excluded.locked_until
+		where idempotency_keys.expires_at <= now()
+		   or (idempotency_keys.status = 0 and idempotency_keys.locked_until <= now()
+		       and idempotency_keys.request_hash = excluded.request_hash)
+		returning true
+	`, key, requestHash, ttl.Seconds(), r.lease.Seconds()).Scan(&claimed)
+	if err == nil {
+		return idempotency.Record{Key: key, RequestHash: requestHash}, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return idempotency.Record{}, false, err
+	}
+
+	rec := idempotency.Record{Key: key}
+	err = r.db.QueryRow(ctx, `
+		select request_hash, status, content_type, coalesce(response_body, ''::bytea)
+		from idempotency_keys
+		where key = $1
+	`, key).Scan(&rec.RequestHash, &rec.Status, &rec.ContentType, &rec.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// released between the two statements; let the caller run it
+		return r.Begin(ctx, key, requestHash, ttl)
+	}
+	if err != nil {
+		return idempotency.Record{}, false, err
+	}
+	rec.Done = rec.Status != 0
+	return rec, false, nil
+}
+
+func (r *IdempotencyRepo) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	if body == nil {
+		body = []byte{}
+	}
+	_, err := r.db.Exec(ctx, `
+		update idempotency_keys
+		set status = $2, content_type = $3, response_body = $4
+		where key = $1
+	`, key, status, contentType, body)
+	return err
+}
+
+func (r *IdempotencyRepo) Release(ctx context.Context, key string) error {
+	_, err := r.db.Exec(ctx, `delete from idempotency_keys where key = $1 and status = 0`, key)
+	return err
+}
+
+// PurgeExpired deletes keys past their TTL and reports how many went.
+func (r *IdempotencyRepo) PurgeExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `delete from idempotency_keys where expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
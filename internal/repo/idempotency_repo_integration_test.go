@@ -0,0 +1,99 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestIdempotencyRepo_BeginCompleteReplay(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewIdempotencyRepo(db.Conn)
+	ctx := context.Background()
+
+	_, acquired, err := r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	rec, acquired, err := r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, acquired)
+	require.False(t, rec.Done, "first request still running")
+
+	require.NoError(t, r.Complete(ctx, "k1", 201, "application/json", []byte(`{"id":"t1"}`)))
+	rec, acquired, err = r.Begin(ctx, "k1", "h2", time.Hour)
+	require.NoError(t, err)
+	require.False(t, acquired)
+	require.True(t, rec.Done)
+	require.Equal(t, "h1", rec.RequestHash)
+	require.Equal(t, 201, rec.Status)
+	require.Equal(t, `{"id":"t1"}`, string(rec.Body))
+
+	// completed keys survive Release; only in-flight claims are dropped
+	require.NoError(t, r.Release(ctx, "k1"))
+	_, acquired, err = r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, acquired)
+}
+
+func TestIdempotencyRepo_ExpiredKeyIsReclaimed(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewIdempotencyRepo(db.Conn)
+	ctx := context.Background()
+
+	_, _, err := r.Begin(ctx, "k1", "h1", time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, r.Complete(ctx, "k1", 201, "application/json", []byte(`{}`)))
+	time.Sleep(10 * time.Millisecond)
+
+	_, acquired, err := r.Begin(ctx, "k1", "h2", time.Hour)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	n, err := r.PurgeExpired(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestIdempotencyRepo_LapsedLeaseIsTakenOver(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewIdempotencyRepo(db.Conn)
+	ctx := context.Background()
+
+	_, acquired, err := r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	_, acquired, err = r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, acquired, "the lease is live")
+
+	// the first request's replica died without releasing the key
+	_, err = db.Conn.Exec(ctx, `update idempotency_keys set locked_until = now() - interval '1 second'`)
+	require.NoError(t, err)
+	rec, acquired, err := r.Begin(ctx, "k1", "h2", time.Hour)
+	require.NoError(t, err)
+	require.False(t, acquired, "only a retry of the same request takes over")
+	require.Equal(t, "h1", rec.RequestHash)
+	_, acquired, err = r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	require.NoError(t, r.Complete(ctx, "k1", 201, "application/json", []byte(`{}`)))
+	_, err = db.Conn.Exec(ctx, `update idempotency_keys set locked_until = now() - interval '1 second'`)
+	require.NoError(t, err)
+	rec, acquired, err = r.Begin(ctx, "k1", "h1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, acquired, "a completed key is never taken over")
+	require.True(t, rec.Done)
+}
@@ -0,0 +1,276 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"gochatbot/internal/domain"
+)
+
+type Job struct {
+	ID          string
+	Kind        string
+	Payload     map[string]any
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+const jobColumns = `id::text, kind, payload::text, status, attempts, max_attempts, run_at, coalesce(last_error, ''), created_at, updated_at`
+
+func scanJob(row pgx.Row) (Job, error) {
+	var (
+		j       Job
+		payload []byte
+	)
+	if err := row.Scan(&j.ID, &j.Kind, &payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError, &j.CreatedAt, &j.UpdatedAt); err != nil {
+		return Job{}, err
+	}
+	if err := json.Unmarshal(payload, &j.Payload); err != nil {
+		return Job{}, err
+	}
+	return j, nil
+}
+
+const defaultMaxAttempts = 5
+
+// DefaultJobLease is the lease of a JobRepo built by NewJobRepo.
+const DefaultJobLease = 10 * time.Minute
+
+type JobRepo struct {
+	db Querier
+	// lease is how long a running job may go without finishing before another
+	// worker assumes its owner died and reclaims it.
+	lease time.Duration
+}
+
+func NewJobRepo(db Querier) *JobRepo {
+	return &JobRepo{db: db, lease: DefaultJobLease}
+}
+
+// WithLease sets the lease. It must outlast the worker's job timeout, or a
+// second worker claims a job that is still running.
+func (r *JobRepo) WithLease(d time.Duration) *JobRepo {
+	if d > 0 {
+		r.lease = d
+	}
+	return r
+}
+
+// Enqueue satisfies service.Queue.
+func (r *JobRepo) Enqueue(ctx context.Context, kind string, payload map[string]any) error {
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(ctx, `
+		insert into jobs (kind, payload, max_attempts)
+		values ($1, $2::jsonb, $3)
+	`, kind, string(body), defaultMaxAttempts)
+	return err
+}
+
+// EnqueueOnce enqueues kind unless a job of that kind is already queued or
+// running, and reports whether it did. Two concurrent callers can still both
+// insert; it is meant for periodic jobs where an extra run is harmless.
+func (r *JobRepo) EnqueueOnce(ctx context.Context, kind string, payload map[string]any) (bool, error) {
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+	tag, err := r.db.Exec(ctx, `
+		insert into jobs (kind, payload, max_attempts)
+		select $1, $2::jsonb, $3
+		where not exists (
+			select 1 from jobs where kind = $1 and status in ('queued', 'running')
+		)
+	`, kind, string(body), defaultMaxAttempts)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Claim locks the oldest due job of one of the given kinds and marks it running.
+// SKIP LOCKED lets any number of workers poll the same table.
+func (r *JobRepo) Claim(ctx context.Context, kinds []string) (Job, bool, error) {
+	if len(kinds) == 0 {
+		return Job{}, false, nil
+	}
+
+	j, err := scanJob(r.db.QueryRow(ctx, `
+		update jobs
+		set status = 'running', attempts = attempts + 1, locked_at = now(), updated_at = now()
+		where id = (
+			select id from jobs
+			where kind = any($1)
+			  and ((status = 'queued' and run_at <= now())
+			    or (status = 'running' and locked_at < now() - make_interval(secs => $2)))
+			order by run_at, created_at
+			for update skip locked
+			limit 1
+		)
+		returning `+jobColumns, kinds, r.lease.Seconds()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Job{}, false, nil
+		}
+		return Job{}, false, err
+	}
+	return j, true, nil
+}
+
+func (r *JobRepo) Complete(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `
+		update jobs
+		set status = 'done', locked_at = null, last_error = null, updated_at = now()
+		where id = $1::uuid
+	`, id)
+	return err
+}
+
+// Retry puts a job back in the queue to run again at runAt.
+func (r *JobRepo) Retry(ctx context.Context, id string, runAt time.Time, lastErr string) error {
+	_, err := r.db.Exec(ctx, `
+		update jobs
+		set status = 'queued', run_at = $2, locked_at = null, last_error = $3, updated_at = now()
+		where id = $1::uuid
+	`, id, runAt, lastErr)
+	return err
+}
+
+// Fail parks a job permanently; it stays in the table for inspection.
+func (r *JobRepo) Fail(ctx context.Context, id string, lastErr string) error {
+	_, err := r.db.Exec(ctx, `
+		update jobs
+		set status = 'failed', locked_at = null, last_error = $2, updated_at = now()
+		where id = $1::uuid
+	`, id, lastErr)
+	return err
+}
+
+// Depth counts queued jobs per kind (due or not).
+func (r *JobRepo) Depth(ctx context.Context) (map[string]int64, error) {
+	rows, err := r.db.Query(ctx, `
+		select kind, count(*)
+		from jobs
+		where status = 'queued'
+		group by kind
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]int64{}
+	for rows.Next() {
+		var kind string
+		var n int64
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, err
+		}
+		out[kind] = n
+	}
+	return out, rows.Err()
+}
+
+func (r *JobRepo) Get(ctx context.Context, id string) (Job, error) {
+	j, err := scanJob(r.db.QueryRow(ctx, `select `+jobColumns+` from jobs where id = $1::uuid`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Job{}, domain.ErrJobNotFound
+		}
+		return Job{}, err
+	}
+	return j, nil
+}
+
+// JobFilter narrows List; empty fields match everything.
+type JobFilter struct {
+	Status string
+	Kind   string
+	Limit  int
+}
+
+// List returns the most recently updated jobs first.
+func (r *JobRepo) List(ctx context.Context, f JobFilter) ([]Job, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Limit > 500 {
+		f.Limit = 500
+	}
+
+	rows, err := r.db.Query(ctx, `
+		select `+jobColumns+`
+		from jobs
+		where ($1 = '' or status = $1)
+		  and ($2 = '' or kind = $2)
+		order by updated_at desc, id desc
+		limit $3
+	`, f.Status, f.Kind, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Job, 0, f.Limit)
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
+
+// Requeue gives a failed job a fresh set of attempts, due immediately.
+func (r *JobRepo) Requeue(ctx context.Context, id string) (Job, error) {
+	j, err := scanJob(r.db.QueryRow(ctx, `
+		update jobs
+		set status = 'queued', attempts = 0, run_at = now(), locked_at = null, updated_at = now()
+		where id = $1::uuid and status = 'failed'
+		returning `+jobColumns, id))
+	if err == nil {
+		return j, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Job{}, err
+	}
+	// tell "no such job" apart from "job exists but isn't failed"
+	if _, err := r.Get(ctx, id); err != nil {
+		return Job{}, err
+	}
+	return Job{}, domain.ErrJobNotRetryable
+}
+
+// Purge deletes finished jobs (done or failed) last touched before olderThan.
+func (r *JobRepo) Purge(ctx context.Context, status string, olderThan time.Time) (int64, error) {
+	if status != "done" && status != "failed" {
+		return 0, fmt.Errorf("purge: status must be done or failed, got %q", status)
+	}
+	tag, err := r.db.Exec(ctx, `
+		delete from jobs
+		where status = $1 and updated_at < $2
+	`, status, olderThan)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,145 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestJobRepo_EnqueueClaimComplete(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewJobRepo(db.Conn)
+	ctx := context.Background()
+
+	require.NoError(t, r.Enqueue(ctx, "export_lead", map[string]any{"lead_id": "l1"}))
+	require.NoError(t, r.Enqueue(ctx, "other", nil))
+
+	depth, err := r.Depth(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), depth["export_lead"])
+
+	j, ok, err := r.Claim(ctx, []string{"export_lead"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "export_lead", j.Kind)
+	require.Equal(t, "l1", j.Payload["lead_id"])
+	require.Equal(t, 1, j.Attempts)
+
+	// nothing else of that kind is due
+	_, ok, err = r.Claim(ctx, []string{"export_lead"})
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, r.Complete(ctx, j.ID))
+}
+
+func TestJobRepo_RetryIsNotClaimedBeforeRunAt(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewJobRepo(db.Conn)
+	ctx := context.Background()
+
+	require.NoError(t, r.Enqueue(ctx, "export_lead", nil))
+	j, ok, err := r.Claim(ctx, []string{"export_lead"})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, r.Retry(ctx, j.ID, time.Now().Add(time.Hour), "crm down"))
+
+	_, ok, err = r.Claim(ctx, []string{"export_lead"})
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestJobRepo_ListRequeuePurge(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewJobRepo(db.Conn)
+	ctx := context.Background()
+
+	require.NoError(t, r.Enqueue(ctx, "export_lead", map[string]any{"lead_id": "l1"}))
+	require.NoError(t, r.Enqueue(ctx, "send_email", nil))
+
+	j, ok, err := r.Claim(ctx, []string{"export_lead"})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// a queued job isn't retryable
+	_, err = r.Requeue(ctx, j.ID)
+	require.ErrorIs(t, err, domain.ErrJobNotRetryable)
+
+	require.NoError(t, r.Fail(ctx, j.ID, "crm down"))
+
+	failed, err := r.List(ctx, repo.JobFilter{Status: "failed"})
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	require.Equal(t, "crm down", failed[0].LastError)
+
+	requeued, err := r.Requeue(ctx, j.ID)
+	require.NoError(t, err)
+	require.Equal(t, "queued", requeued.Status)
+	require.Equal(t, 0, requeued.Attempts)
+	require.Equal(t, "l1", requeued.Payload["lead_id"])
+
+	_, err = r.Requeue(ctx, "00000000-0000-0000-0000-000000000000")
+	require.ErrorIs(t, err, domain.ErrJobNotFound)
+
+	j, ok, err = r.Claim(ctx, []string{"export_lead"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, r.Complete(ctx, j.ID))
+
+	// only jobs older than the cutoff go
+	n, err := r.Purge(ctx, "done", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = r.Purge(ctx, "done", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	_, err = r.Get(ctx, j.ID)
+	require.ErrorIs(t, err, domain.ErrJobNotFound)
+
+	all, err := r.List(ctx, repo.JobFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	require.Equal(t, "send_email", all[0].Kind)
+}
+
+func TestJobRepo_EnqueueOnce(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewJobRepo(db.Conn)
+	ctx := context.Background()
+
+	ok, err := r.EnqueueOnce(ctx, "reconcile_legacy", nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = r.EnqueueOnce(ctx, "reconcile_legacy", nil)
+	require.NoError(t, err)
+	require.False(t, ok, "already queued")
+
+	j, _, err := r.Claim(ctx, []string{"reconcile_legacy"})
+	require.NoError(t, err)
+	ok, err = r.EnqueueOnce(ctx, "reconcile_legacy", nil)
+	require.NoError(t, err)
+	require.False(t, ok, "still running")
+
+	require.NoError(t, r.Complete(ctx, j.ID))
+	ok, err = r.EnqueueOnce(ctx, "reconcile_legacy", nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
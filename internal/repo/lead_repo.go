@@ -0,0 +1,279 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/pagination"
+)
+
+// Lead statuses.
+const (
+	LeadNew      = "new"
+	LeadExported = "exported"
+	LeadFailed   = "failed"
+)
+
+// captureTool is tools.CaptureLeadField; its stored results are what a new
+// lead is filled from.
+const captureTool = "capture_lead_field"
+
+type Lead struct {
+	ID        string
+	TenantID  string
+	SessionID string
+	Name      string
+	Email     string
+	Phone     string
+	// Fields are captured details other than name, email and phone.
+	Fields          map[string]any
+	TemplateID      string // empty when the session had no template
+	TemplateVersion int    // published version when the lead was created; 0 if none
+	Status          string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Revision        int64
+}
+
+// LeadUpdate changes the fields that are set; Fields replaces all custom
+// fields when non-nil.
+type LeadUpdate struct {
+	Name   *string
+	Email  *string
+	Phone  *string
+	Fields map[string]any
+	Status *string
+}
+
+type LeadRepo struct {
+	db Querier
+}
+
+func NewLeadRepo(db Querier) *LeadRepo {
+	return &LeadRepo{db: db}
+}
+
+const leadColumns = `id::text, tenant_id::text, session_id::text, name, email, phone, fields,
+	coalesce(template_id::text, ''), coalesce(template_version, 0), status, created_at, updated_at, revision`
+
+func scanLead(row pgx.Row) (Lead, error) {
+	var l Lead
+	err := row.Scan(&l.ID, &l.TenantID, &l.SessionID, &l.Name, &l.Email, &l.Phone, &l.Fields,
+		&l.TemplateID, &l.TemplateVersion, &l.Status, &l.CreatedAt, &l.UpdatedAt, &l.Revision)
+	return l, err
+}
+
+// CreateLeadForSession creates the session's lead from the latest value
+// captured for each field, with the session's template and its published
+// version. It is idempotent: a session that already has a lead gets it
+// back unchanged.
+func (r *LeadRepo) CreateLeadForSession(ctx context.Context, sessionID string) (Lead, error) {
+	l, err := scanLead(r.db.QueryRow(ctx, `
+		with captured as (
+			select distinct on (field) field, value
+			from (
+				select tool_data->'result'->>'field' as field, tool_data->'result'->>'value' as value, seq
+				from messages
+				where session_id = $1::uuid and role = 'tool' and tool_name = $2
+			) c
+			where field is not null and value is not null
+			order by field, seq desc
+		), f as (
+			select coalesce(jsonb_object_agg(field, value), '{}') as v from captured
+		)
+		insert into leads (tenant_id, session_id, name, email, phone, fields, template_id, template_version)
+		select s.tenant_id, s.id,
+			coalesce(f.v->>'name', ''), coalesce(f.v->>'email', ''), coalesce(f.v->>'phone', ''),
+			f.v - 'name' - 'email' - 'phone',
+			s.template_id,
+			(select version from template_versions v where v.template_id = s.template_id and v.status = 'published')
+		from chat_sessions s, f
+		where s.id = $1::uuid
+		on conflict (session_id) do nothing
+		returning `+leadColumns,
+		sessionID, captureTool))
+	if err == nil {
+		return l, nil
+	}
+	if isInvalidText(err) {
+		return Lead{}, domain.ErrSessionNotFound
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Lead{}, err
+	}
+	// no row: the lead already exists, or the session does not
+	l, ok, err := r.GetLeadBySession(ctx, sessionID)
+	if err != nil {
+		return Lead{}, err
+	}
+	if !ok {
+		return Lead{}, domain.ErrSessionNotFound
+	}
+	return l, nil
+}
+
+func (r *LeadRepo) GetLeadBySession(ctx context.Context, sessionID string) (Lead, bool, error) {
+	l, err := scanLead(r.db.QueryRow(ctx, `select `+leadColumns+` from leads where session_id = $1::uuid`, sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return Lead{}, false, nil
+		}
+		return Lead{}, false, err
+	}
+	return l, true, nil
+}
+
+// GetLead reads a lead of the tenant; other tenants' leads are not found.
+func (r *LeadRepo) GetLead(ctx context.Context, tenantID, leadID string) (Lead, error) {
+	l, err := scanLead(r.db.QueryRow(ctx, `
+		select `+leadColumns+` from leads where id = $1::uuid and tenant_id = $2::uuid
+	`, leadID, tenantID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return Lead{}, domain.ErrLeadNotFound
+		}
+		return Lead{}, err
+	}
+	return l, nil
+}
+
+// Stable list: f.Sort, then id in the same direction (cursor paging). A
+// prefix matches name or email.
+func (r *LeadRepo) ListLeads(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) ([]Lead, pagination.Page, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	q := listQuery{search: []string{"name", "email"}}
+	q.where = append(q.where, "tenant_id = "+q.arg(tenantID)+"::uuid")
+	sql, args := q.build(leadColumns, "leads", f, cursor, limit)
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+	defer rows.Close()
+
+	out := make([]Lead, 0, limit+1)
+	for rows.Next() {
+		l, err := scanLead(rows)
+		if err != nil {
+			return nil, pagination.Page{}, err
+		}
+		out = append(out, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	out, p := page(out, limit, f, cursor, func(l Lead) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: l.CreatedAt, Name: l.Name, ID: l.ID}
+	})
+	return out, p, nil
+}
+
+// UpdateLead applies u to a lead of the tenant; ifRevision works as in
+// TenantRepo.Rename.
+func (r *LeadRepo) UpdateLead(ctx context.Context, tenantID, leadID string, u LeadUpdate, ifRevision int64) (Lead, error) {
+	var fields []byte
+	if u.Fields != nil {
+		var err error
+		if fields, err = json.Marshal(u.Fields); err != nil {
+			return Lead{}, err
+		}
+	}
+	l, err := scanLead(r.db.QueryRow(ctx, `
+		update leads
+		set name = coalesce($3, name),
+			email = coalesce($4, email),
+			phone = coalesce($5, phone),
+			fields = coalesce($6::jsonb, fields),
+			status = coalesce($7, status),
+			updated_at = now(),
+			revision = nextval('row_revision_seq')
+		where id = $1::uuid and tenant_id = $2::uuid and ($8::bigint = 0 or revision = $8)
+		returning `+leadColumns,
+		leadID, tenantID, u.Name, u.Email, u.Phone, fields, u.Status, ifRevision))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, gerr := r.GetLead(ctx, tenantID, leadID); gerr != nil {
+				return Lead{}, gerr
+			}
+			return Lead{}, domain.ErrRevisionMismatch
+		}
+		if isInvalidText(err) {
+			return Lead{}, domain.ErrLeadNotFound
+		}
+		return Lead{}, err
+	}
+	return l, nil
+}
+
+// StreamLeads calls fn for each of the tenant's leads that match f, oldest
+// first, as rows arrive from the result cursor: nothing is collected, so an
+// export of any size runs in constant memory. f's sort is ignored; an error
+// from fn stops the query and is returned.
+func (r *LeadRepo) StreamLeads(ctx context.Context, tenantID string, f pagination.Filter, fn func(Lead) error) error {
+	q := listQuery{search: []string{"name", "email"}}
+	q.where = append(q.where, "tenant_id = "+q.arg(tenantID)+"::uuid")
+	q.filter(f)
+	rows, err := r.db.Query(ctx, `select `+leadColumns+` from leads where `+strings.Join(q.where, " and ")+
+		` order by created_at, id`, q.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scanLead(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LeadFieldNames returns the custom lead fields the tenant's templates
+// declare as "lead_fields" in their published content, in template
+// creation order without repeats.
+func (r *LeadRepo) LeadFieldNames(ctx context.Context, tenantID string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		select f.name
+		from templates t
+		join template_versions v on v.template_id = t.id and v.status = 'published'
+		cross join lateral jsonb_array_elements_text(
+			case when jsonb_typeof(v.content->'lead_fields') = 'array' then v.content->'lead_fields' else '[]'::jsonb end
+		) with ordinality as f(name, n)
+		where t.tenant_id = $1::uuid
+		order by t.created_at, t.id, f.n
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	seen := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out, rows.Err()
+}
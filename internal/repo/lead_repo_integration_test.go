@@ -0,0 +1,183 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestLeadRepo_CreateFromCapturedFields(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenant, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	templates := repo.NewTemplateRepo(db.Conn)
+	tpl, err := templates.CreateTemplate(ctx, tenant.ID, "Sales", "sales")
+	require.NoError(t, err)
+	v, err := templates.CreateDraftVersion(ctx, tpl.ID, []byte(`{}`))
+	require.NoError(t, err)
+	_, err = templates.PublishVersion(ctx, tpl.ID, v.Version, 0)
+	require.NoError(t, err)
+
+	sessions := repo.NewSessionRepo(db.Conn)
+	sess, err := sessions.CreateSession(ctx, tenant.ID, tpl.ID)
+	require.NoError(t, err)
+	capture := func(field, value string) {
+		_, err := sessions.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "tool", ToolName: "capture_lead_field",
+			ToolData: map[string]any{"call_id": "c", "result": map[string]any{"field": field, "value": value, "captured": true}}, CreatedAt: time.Now()})
+		require.NoError(t, err)
+	}
+	capture("name", "Ann")
+	capture("email", "old@example.com")
+	capture("email", "ann@example.com")
+	capture("company", "Initech")
+	_, err = sessions.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "tool", ToolName: "capture_lead_field",
+		ToolData: map[string]any{"call_id": "c", "error": "not a valid phone number"}, CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	leads := repo.NewLeadRepo(db.Conn)
+	l, err := leads.CreateLeadForSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, tenant.ID, l.TenantID)
+	require.Equal(t, "Ann", l.Name)
+	require.Equal(t, "ann@example.com", l.Email, "the latest capture wins")
+	require.Empty(t, l.Phone)
+	require.Equal(t, map[string]any{"company": "Initech"}, l.Fields)
+	require.Equal(t, tpl.ID, l.TemplateID)
+	require.Equal(t, v.Version, l.TemplateVersion)
+	require.Equal(t, repo.LeadNew, l.Status)
+
+	again, err := leads.CreateLeadForSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, l.ID, again.ID)
+
+	_, err = leads.CreateLeadForSession(ctx, "00000000-0000-0000-0000-000000000000")
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+}
+
+func TestLeadRepo_ListGetUpdate(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenants := repo.NewTenantRepo(db.Conn)
+	acme, err := tenants.Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	other, err := tenants.Create(ctx, "Other", "other")
+	require.NoError(t, err)
+	sessions := repo.NewSessionRepo(db.Conn)
+	leads := repo.NewLeadRepo(db.Conn)
+	var ids []string
+	for _, tenantID := range []string{acme.ID, acme.ID, acme.ID, other.ID} {
+		sess, err := sessions.CreateSession(ctx, tenantID, "")
+		require.NoError(t, err)
+		l, err := leads.CreateLeadForSession(ctx, sess.ID)
+		require.NoError(t, err)
+		ids = append(ids, l.ID)
+	}
+
+	_, err = leads.GetLead(ctx, acme.ID, ids[3])
+	require.ErrorIs(t, err, domain.ErrLeadNotFound, "another tenant's lead")
+	_, err = leads.GetLead(ctx, acme.ID, "nope")
+	require.ErrorIs(t, err, domain.ErrLeadNotFound)
+
+	l, err := leads.GetLead(ctx, acme.ID, ids[0])
+	require.NoError(t, err)
+	name, exported := "Ann", repo.LeadExported
+	updated, err := leads.UpdateLead(ctx, acme.ID, ids[0], repo.LeadUpdate{Name: &name, Status: &exported, Fields: map[string]any{"budget": "10k"}}, l.Revision)
+	require.NoError(t, err)
+	require.Equal(t, "Ann", updated.Name)
+	require.Equal(t, repo.LeadExported, updated.Status)
+	require.Equal(t, map[string]any{"budget": "10k"}, updated.Fields)
+	require.Greater(t, updated.Revision, l.Revision)
+	require.False(t, updated.UpdatedAt.Before(l.UpdatedAt))
+
+	_, err = leads.UpdateLead(ctx, acme.ID, ids[0], repo.LeadUpdate{Name: &name}, l.Revision)
+	require.ErrorIs(t, err, domain.ErrRevisionMismatch)
+	_, err = leads.UpdateLead(ctx, acme.ID, ids[3], repo.LeadUpdate{Name: &name}, 0)
+	require.ErrorIs(t, err, domain.ErrLeadNotFound)
+
+	all, _, err := leads.ListLeads(ctx, acme.ID, 10, pagination.Filter{}, nil)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	fresh, p, err := leads.ListLeads(ctx, acme.ID, 1, pagination.Filter{Status: repo.LeadNew}, nil)
+	require.NoError(t, err)
+	require.Len(t, fresh, 1)
+	require.NotNil(t, p.Next)
+	rest, _, err := leads.ListLeads(ctx, acme.ID, 10, pagination.Filter{Status: repo.LeadNew}, p.Next)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	require.NotEqual(t, fresh[0].ID, rest[0].ID)
+
+	byName, _, err := leads.ListLeads(ctx, acme.ID, 10, pagination.Filter{Prefix: "an"}, nil)
+	require.NoError(t, err)
+	require.Len(t, byName, 1)
+	require.Equal(t, ids[0], byName[0].ID)
+}
+
+func TestLeadRepo_StreamAndFieldNames(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenant, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	templates := repo.NewTemplateRepo(db.Conn)
+	for slug, content := range map[string]string{
+		"sales":   `{"lead_fields": ["budget", "timeline"]}`,
+		"support": `{"lead_fields": "not a list"}`,
+	} {
+		tpl, err := templates.CreateTemplate(ctx, tenant.ID, slug, slug)
+		require.NoError(t, err)
+		v, err := templates.CreateDraftVersion(ctx, tpl.ID, []byte(content))
+		require.NoError(t, err)
+		_, err = templates.PublishVersion(ctx, tpl.ID, v.Version, 0)
+		require.NoError(t, err)
+	}
+	leads := repo.NewLeadRepo(db.Conn)
+	names, err := leads.LeadFieldNames(ctx, tenant.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"budget", "timeline"}, names)
+
+	sessions := repo.NewSessionRepo(db.Conn)
+	var created []string
+	for range 3 {
+		sess, err := sessions.CreateSession(ctx, tenant.ID, "")
+		require.NoError(t, err)
+		l, err := leads.CreateLeadForSession(ctx, sess.ID)
+		require.NoError(t, err)
+		created = append(created, l.ID)
+	}
+	failed := repo.LeadFailed
+	_, err = leads.UpdateLead(ctx, tenant.ID, created[1], repo.LeadUpdate{Status: &failed}, 0)
+	require.NoError(t, err)
+
+	var streamed []string
+	require.NoError(t, leads.StreamLeads(ctx, tenant.ID, pagination.Filter{}, func(l repo.Lead) error {
+		streamed = append(streamed, l.ID)
+		return nil
+	}))
+	require.Equal(t, created, streamed, "oldest first")
+
+	streamed = nil
+	require.NoError(t, leads.StreamLeads(ctx, tenant.ID, pagination.Filter{Status: repo.LeadNew}, func(l repo.Lead) error {
+		streamed = append(streamed, l.ID)
+		return nil
+	}))
+	require.Equal(t, []string{created[0], created[2]}, streamed)
+
+	stop := errors.New("stop")
+	err = leads.StreamLeads(ctx, tenant.ID, pagination.Filter{}, func(repo.Lead) error { return stop })
+	require.ErrorIs(t, err, stop)
+}
@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LeadSinkRepo stores each tenant's sink.Config as raw JSON; decoding and
+// checking it is the sink package's job.
+type LeadSinkRepo struct {
+	db Querier
+}
+
+func NewLeadSinkRepo(db Querier) *LeadSinkRepo {
+	return &LeadSinkRepo{db: db}
+}
+
+// GetLeadSink returns the tenant's sink config, if it has one.
+func (r *LeadSinkRepo) GetLeadSink(ctx context.Context, tenantID string) ([]byte, bool, error) {
+	var config []byte
+	err := r.db.QueryRow(ctx, `select config::text from lead_sinks where tenant_id = $1::uuid`, tenantID).Scan(&config)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return config, true, nil
+}
+
+// SetLeadSink replaces the tenant's sink config.
+func (r *LeadSinkRepo) SetLeadSink(ctx context.Context, tenantID string, config []byte) error {
+	_, err := r.db.Exec(ctx, `
+		insert into lead_sinks (tenant_id, config) values ($1::uuid, $2::jsonb)
+		on conflict (tenant_id) do update set config = excluded.config, updated_at = now()
+	`, tenantID, string(config))
+	return err
+}
+
+// DeleteLeadSink stops pushing the tenant's leads; it is a no-op for a
+// tenant without a sink.
+func (r *LeadSinkRepo) DeleteLeadSink(ctx context.Context, tenantID string) error {
+	_, err := r.db.Exec(ctx, `delete from lead_sinks where tenant_id = $1::uuid`, tenantID)
+	return err
+}
@@ -0,0 +1,41 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestLeadSinkRepo_SetGetDelete(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	acme, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+
+	sinks := repo.NewLeadSinkRepo(db.Conn)
+	_, ok, err := sinks.GetLeadSink(ctx, acme.ID)
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, ok, err = sinks.GetLeadSink(ctx, "not-a-uuid")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, sinks.SetLeadSink(ctx, acme.ID, []byte(`{"kind":"rest","rest":{"url":"https://a.example"}}`)))
+	require.NoError(t, sinks.SetLeadSink(ctx, acme.ID, []byte(`{"kind":"rest","rest":{"url":"https://b.example"}}`)))
+	got, ok, err := sinks.GetLeadSink(ctx, acme.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.JSONEq(t, `{"kind":"rest","rest":{"url":"https://b.example"}}`, string(got), "set replaces")
+
+	require.NoError(t, sinks.DeleteLeadSink(ctx, acme.ID))
+	require.NoError(t, sinks.DeleteLeadSink(ctx, acme.ID))
+	_, ok, err = sinks.GetLeadSink(ctx, acme.ID)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gochatbot/internal/pagination"
+)
+
+// listQuery builds the keyset-paged select behind the tenant, template and
+// lead lists. The tables have id, name and created_at; conditions added
+// before build (e.g. the owning tenant) are kept.
+type listQuery struct {
+	where []string
+	args  []any
+	// search are the columns a Prefix matches; nil means name and slug.
+	search []string
+}
+
+func (q *listQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *listQuery) build(columns, table string, f pagination.Filter, cursor *pagination.Cursor, limit int) (string, []any) {
+	q.filter(f)
+
+	col, dir, cmp := "created_at", "desc", "<"
+	if f.Sort.By == pagination.SortName {
+		col = "name"
+	}
+	// a backward cursor walks the reverse order; page flips the rows back
+	if f.Sort.Asc != (cursor != nil && cursor.Backward) {
+		dir, cmp = "asc", ">"
+	}
+	if cursor != nil {
+		key := q.arg(cursor.CreatedAt) + "::timestamptz"
+		if f.Sort.By == pagination.SortName {
+			key = q.arg(cursor.Name) + "::text"
+		}
+		q.where = append(q.where, fmt.Sprintf("(%s, id) %s (%s, %s::uuid)", col, cmp, key, q.arg(cursor.ID)))
+	}
+
+	var b strings.Builder
+	b.WriteString("select " + columns + " from " + table)
+	if len(q.where) > 0 {
+		b.WriteString(" where " + strings.Join(q.where, " and "))
+	}
+	// one extra row tells page whether more follow
+	fmt.Fprintf(&b, " order by %s %s, id %s limit %s", col, dir, dir, q.arg(limit+1))
+	return b.String(), q.args
+}
+
+// filter adds the conditions of f other than sort and position; unpaged
+// queries such as the lead export use it without build.
+func (q *listQuery) filter(f pagination.Filter) {
+	if f.Prefix != "" {
+		p := q.arg(likePrefix(f.Prefix))
+		cols := q.search
+		if cols == nil {
+			cols = []string{"name", "slug"}
+		}
+		var ors []string
+		for _, c := range cols {
+			ors = append(ors, c+" ilike "+p)
+		}
+		q.where = append(q.where, "("+strings.Join(ors, " or ")+")")
+	}
+	if f.Status != "" {
+		q.where = append(q.where, "status = "+q.arg(f.Status))
+	}
+	if !f.CreatedAfter.IsZero() {
+		q.where = append(q.where, "created_at >= "+q.arg(f.CreatedAfter)+"::timestamptz")
+	}
+	if !f.CreatedBefore.IsZero() {
+		q.where = append(q.where, "created_at < "+q.arg(f.CreatedBefore)+"::timestamptz")
+	}
+}
+
+// page trims rows fetched by build to limit, restores list order for a
+// backward cursor and derives the cursors on either side. at returns a
+// row's sort position.
+func page[T any](rows []T, limit int, f pagination.Filter, cursor *pagination.Cursor, at func(T) pagination.Cursor) ([]T, pagination.Page) {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	back := cursor != nil && cursor.Backward
+	if back {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, pagination.Page{}
+	}
+
+	edge := func(row T, backward bool) *pagination.Cursor {
+		c := at(row)
+		c.Sort, c.Backward = f.Sort, backward
+		return &c
+	}
+	// a cursor means there are rows on the side it came from; the extra
+	// row tells about the side being walked towards
+	hasNext, hasPrev := more, cursor != nil
+	if back {
+		hasNext, hasPrev = true, more
+	}
+	var p pagination.Page
+	if hasNext {
+		p.Next = edge(rows[len(rows)-1], false)
+	}
+	if hasPrev {
+		p.Prev = edge(rows[0], true)
+	}
+	return rows, p
+}
+
+// likePrefix escapes LIKE metacharacters so a search for "50%" is literal.
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
+}
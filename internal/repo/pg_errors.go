@@ -15,3 +15,12 @@ func isUniqueViolation(err error) bool {
 	}
 	return false
 }
+
+// 22P02 = invalid_text_representation, e.g. a malformed uuid
+func isInvalidText(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.TrimSpace(pgErr.Code) == "22P02"
+	}
+	return false
+}
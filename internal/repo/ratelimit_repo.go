@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+
+	"gochatbot/internal/ratelimit"
+)
+
+// RateLimitRepo satisfies ratelimit.Store. Buckets are refilled against the
+// database clock, so replicas with skewed clocks still agree.
+type RateLimitRepo struct {
+	db Querier
+}
+
+func NewRateLimitRepo(db Querier) *RateLimitRepo {
+	return &RateLimitRepo{db: db}
+}
+
+// Take refills and draws from the bucket in one statement; the row lock taken
+// by the upsert serialises concurrent requests for the same key.
+func (r *RateLimitRepo) Take(ctx context.Context, key string, l ratelimit.Limit) (bool, float64, error) {
+	var (
+		allowed bool
+		tokens  float64
+	)
+	err := r.db.QueryRow(ctx, `
+		insert into rate_limit_buckets as b (key, tokens, allowed, updated_at, expires_at)
+		values ($1, $2::float8 - 1, true, now(), now() + make_interval(secs => 1 / $3::float8))
+		on conflict (key) do update
+		set (tokens, allowed) = (
+		      select case when t >= 1 then t - 1 else t end, t >= 1
+		      from (select least($2::float8, b.tokens + extract(epoch from now() - b.updated_at) * $3::float8) as t) refill
+		    ),
+		    updated_at = now(),
+		    expires_at = now() + make_interval(secs => ($2::float8 - b.tokens) / $3::float8 + 1 / $3::float8)
+		returning allowed, tokens
+	`, key, float64(l.Burst), l.Rate()).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, tokens, nil
+}
+
+// PurgeExpired deletes buckets that have refilled completely and reports
+// how many went.
+func (r *RateLimitRepo) PurgeExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `delete from rate_limit_buckets where expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
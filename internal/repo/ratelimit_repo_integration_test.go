@@ -0,0 +1,49 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/ratelimit"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestRateLimitRepo_TakeRefillsAndRefuses(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewRateLimitRepo(db.Conn)
+	ctx := context.Background()
+	l := ratelimit.Limit{Count: 10, Period: time.Second, Burst: 2}
+
+	ok, left, err := r.Take(ctx, "tenants|ip|10.0.0.1", l)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.InDelta(t, 1.0, left, 0.1)
+
+	ok, _, err = r.Take(ctx, "tenants|ip|10.0.0.1", l)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, left, err = r.Take(ctx, "tenants|ip|10.0.0.1", l)
+	require.NoError(t, err)
+	require.False(t, ok, "burst spent")
+	require.Less(t, left, 1.0)
+
+	ok, _, err = r.Take(ctx, "tenants|ip|10.0.0.2", l)
+	require.NoError(t, err)
+	require.True(t, ok, "keys are independent")
+
+	time.Sleep(150 * time.Millisecond)
+	ok, _, err = r.Take(ctx, "tenants|ip|10.0.0.1", l)
+	require.NoError(t, err)
+	require.True(t, ok, "refilled at 10/s")
+
+	time.Sleep(400 * time.Millisecond)
+	n, err := r.PurgeExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+}
@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"gochatbot/internal/domain"
+)
+
+type ReconcileRun struct {
+	ID               string
+	Source           string
+	Status           string // running, done or failed
+	TenantsChecked   int
+	TemplatesChecked int
+	DiffCount        int // every diff found; at most the stored ones are in reconciliation_diffs
+	Error            string
+	StartedAt        time.Time
+	FinishedAt       *time.Time
+}
+
+type ReconcileDiff struct {
+	Entity string
+	Key    string
+	Kind   string
+	Field  string
+	Go     string
+	Legacy string
+}
+
+const reconcileRunColumns = `id::text, source, status, tenants_checked, templates_checked, diff_count, coalesce(error, ''), started_at, finished_at`
+
+func scanReconcileRun(row pgx.Row) (ReconcileRun, error) {
+	var run ReconcileRun
+	err := row.Scan(&run.ID, &run.Source, &run.Status, &run.TenantsChecked, &run.TemplatesChecked,
+		&run.DiffCount, &run.Error, &run.StartedAt, &run.FinishedAt)
+	return run, err
+}
+
+type ReconcileRepo struct {
+	db Querier
+}
+
+func NewReconcileRepo(db Querier) *ReconcileRepo {
+	return &ReconcileRepo{db: db}
+}
+
+func (r *ReconcileRepo) StartRun(ctx context.Context, source string) (ReconcileRun, error) {
+	return scanReconcileRun(r.db.QueryRow(ctx, `
+		insert into reconciliation_runs (source)
+		values ($1)
+		returning `+reconcileRunColumns, source))
+}
+
+// FinishRun stores diffs and marks the run done in one statement, so a run
+// is never visible as done with only part of its diffs.
+func (r *ReconcileRepo) FinishRun(ctx context.Context, id string, tenantsChecked, templatesChecked, diffCount int, diffs []ReconcileDiff) (ReconcileRun, error) {
+	cols := make([][]string, 6)
+	for _, d := range diffs {
+		for i, v := range []string{d.Entity, d.Key, d.Kind, d.Field, d.Go, d.Legacy} {
+			cols[i] = append(cols[i], v)
+		}
+	}
+
+	run, err := scanReconcileRun(r.db.QueryRow(ctx, `
+		with d as (
+			insert into reconciliation_diffs (run_id, entity, key, kind, field, go_value, legacy_value)
+			select $1::uuid, * from unnest($5::text[], $6::text[], $7::text[], $8::text[], $9::text[], $10::text[])
+		)
+		update reconciliation_runs
+		set status = 'done', tenants_checked = $2, templates_checked = $3, diff_count = $4, finished_at = now()
+		where id = $1::uuid
+		returning `+reconcileRunColumns,
+		id, tenantsChecked, templatesChecked, diffCount,
+		cols[0], cols[1], cols[2], cols[3], cols[4], cols[5]))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ReconcileRun{}, domain.ErrReconcileRunNotFound
+	}
+	return run, err
+}
+
+func (r *ReconcileRepo) FailRun(ctx context.Context, id, lastErr string) error {
+	_, err := r.db.Exec(ctx, `
+		update reconciliation_runs
+		set status = 'failed', error = $2, finished_at = now()
+		where id = $1::uuid
+	`, id, lastErr)
+	return err
+}
+
+func (r *ReconcileRepo) GetRun(ctx context.Context, id string) (ReconcileRun, error) {
+	run, err := scanReconcileRun(r.db.QueryRow(ctx, `
+		select `+reconcileRunColumns+`
+		from reconciliation_runs
+		where id = $1::uuid
+	`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return ReconcileRun{}, domain.ErrReconcileRunNotFound
+		}
+		return ReconcileRun{}, err
+	}
+	return run, nil
+}
+
+// ListRuns returns the most recent runs first.
+func (r *ReconcileRepo) ListRuns(ctx context.Context, limit int) ([]ReconcileRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	rows, err := r.db.Query(ctx, `
+		select `+reconcileRunColumns+`
+		from reconciliation_runs
+		order by started_at desc, id desc
+		limit $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReconcileRun, 0, limit)
+	for rows.Next() {
+		run, err := scanReconcileRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+// ListDiffs returns a run's stored diffs in the order they were found.
+func (r *ReconcileRepo) ListDiffs(ctx context.Context, runID string, limit int) ([]ReconcileDiff, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	rows, err := r.db.Query(ctx, `
+		select entity, key, kind, field, go_value, legacy_value
+		from reconciliation_diffs
+		where run_id = $1::uuid
+		order by id
+		limit $2
+	`, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ReconcileDiff
+	for rows.Next() {
+		var d ReconcileDiff
+		if err := rows.Scan(&d.Entity, &d.Key, &d.Kind, &d.Field, &d.Go, &d.Legacy); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
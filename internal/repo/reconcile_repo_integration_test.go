@@ -0,0 +1,79 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/reconcile"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestReconcileRepo_RunLifecycle(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewReconcileRepo(db.Conn)
+	ctx := context.Background()
+
+	run, err := r.StartRun(ctx, "json:export.json")
+	require.NoError(t, err)
+	require.Equal(t, "running", run.Status)
+	require.Nil(t, run.FinishedAt)
+
+	diffs := []repo.ReconcileDiff{
+		{Entity: "tenant", Key: "acme", Kind: "mismatch", Field: "name", Go: "Acme", Legacy: "Acme Inc"},
+		{Entity: "template", Key: "acme/faq", Kind: "missing_in_go"},
+	}
+	done, err := r.FinishRun(ctx, run.ID, 3, 1, 2, diffs)
+	require.NoError(t, err)
+	require.Equal(t, "done", done.Status)
+	require.Equal(t, 3, done.TenantsChecked)
+	require.NotNil(t, done.FinishedAt)
+
+	got, err := r.ListDiffs(ctx, run.ID, 0)
+	require.NoError(t, err)
+	require.Equal(t, diffs, got)
+
+	failed, err := r.StartRun(ctx, "postgres:legacy-db")
+	require.NoError(t, err)
+	require.NoError(t, r.FailRun(ctx, failed.ID, "connection refused"))
+
+	runs, err := r.ListRuns(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.Equal(t, failed.ID, runs[0].ID)
+	require.Equal(t, "connection refused", runs[0].Error)
+
+	_, err = r.GetRun(ctx, "not-a-uuid")
+	require.ErrorIs(t, err, domain.ErrReconcileRunNotFound)
+}
+
+func TestPostgresReader_ReadsTenantsAndTemplates(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	tenantID := seedTenant(t, db.Conn, "Acme", "acme")
+	tr := repo.NewTemplateRepo(db.Conn)
+	ctx := context.Background()
+	tpl, err := tr.CreateTemplate(ctx, tenantID, "FAQ", "faq")
+	require.NoError(t, err)
+	_, err = tr.CreateDraftVersion(ctx, tpl.ID, []byte(`{}`))
+	require.NoError(t, err)
+	_, err = tr.PublishVersion(ctx, tpl.ID, 1, 0)
+	require.NoError(t, err)
+
+	rd := reconcile.NewPostgresReader(db.Conn)
+	tenants, err := rd.Read(ctx, reconcile.EntityTenant)
+	require.NoError(t, err)
+	require.Equal(t, []reconcile.Record{{Key: "acme", Fields: map[string]string{"name": "Acme"}}}, tenants)
+
+	templates, err := rd.Read(ctx, reconcile.EntityTemplate)
+	require.NoError(t, err)
+	require.Equal(t, []reconcile.Record{
+		{Key: "acme/faq", Fields: map[string]string{"name": "FAQ", "published_version": "1"}},
+	}, templates)
+}
@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gochatbot/internal/domain"
+)
+
+// SessionEventsChannel carries the id of a session whenever a message is
+// added to it or it is closed, and "<id>:typing" while a reply is being
+// written.
+const SessionEventsChannel = "session_events"
+
+const typingSuffix = ":typing"
+
+type Session struct {
+	ID         string
+	TenantID   string
+	TemplateID string // empty when the session has no template
+	CreatedAt  time.Time
+	ClosedAt   *time.Time
+}
+
+type Message struct {
+	Seq       int64 // position across all sessions; the SSE event id
+	ID        string
+	SessionID string
+	Role      string
+	Content   string
+	ToolName  string
+	ToolData  map[string]any
+	CreatedAt time.Time
+}
+
+type SessionRepo struct {
+	db Querier
+}
+
+func NewSessionRepo(db Querier) *SessionRepo {
+	return &SessionRepo{db: db}
+}
+
+const sessionColumns = `id::text, tenant_id::text, coalesce(template_id::text, ''), created_at, closed_at`
+
+// CreateSession starts a session; templateID may be empty.
+func (r *SessionRepo) CreateSession(ctx context.Context, tenantID, templateID string) (Session, error) {
+	var s Session
+	err := r.db.QueryRow(ctx, `
+		insert into chat_sessions (tenant_id, template_id)
+		values ($1, nullif($2, '')::uuid)
+		returning `+sessionColumns,
+		tenantID, templateID).Scan(&s.ID, &s.TenantID, &s.TemplateID, &s.CreatedAt, &s.ClosedAt)
+	if err != nil {
+		if isInvalidText(err) {
+			return Session{}, domain.ErrTenantNotFound
+		}
+		return Session{}, err
+	}
+	return s, nil
+}
+
+func (r *SessionRepo) GetSession(ctx context.Context, sessionID string) (Session, error) {
+	var s Session
+	err := r.db.QueryRow(ctx, `
+		select `+sessionColumns+`
+		from chat_sessions
+		where id = $1
+	`, sessionID).Scan(&s.ID, &s.TenantID, &s.TemplateID, &s.CreatedAt, &s.ClosedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return Session{}, domain.ErrSessionNotFound
+		}
+		return Session{}, err
+	}
+	return s, nil
+}
+
+// MarkSessionClosed sets closed_at once; closing a closed session is a no-op.
+func (r *SessionRepo) MarkSessionClosed(ctx context.Context, sessionID string, closedAt time.Time) error {
+	tag, err := r.db.Exec(ctx, `
+		update chat_sessions set closed_at = coalesce(closed_at, $2)
+		where id = $1
+	`, sessionID, closedAt)
+	if err != nil {
+		if isInvalidText(err) {
+			return domain.ErrSessionNotFound
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
+
+const messageColumns = `seq, id::text, session_id::text, role, content, tool_name, tool_data, created_at`
+
+func scanMessage(row pgx.Row) (Message, error) {
+	var m Message
+	var data []byte
+	if err := row.Scan(&m.Seq, &m.ID, &m.SessionID, &m.Role, &m.Content, &m.ToolName, &data, &m.CreatedAt); err != nil {
+		return Message{}, err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &m.ToolData); err != nil {
+			return Message{}, err
+		}
+	}
+	return m, nil
+}
+
+// InsertMessage adds a message to an open session; a closed one gets
+// domain.ErrSessionClosed.
+//
+// The insert locks the session's row until it commits, so a session's
+// messages commit in seq order: a reader that has seen seq n will never
+// see a smaller seq of that session appear later. Closing takes the same
+// lock, so no message lands after closed_at is set.
+func (r *SessionRepo) InsertMessage(ctx context.Context, m Message) (Message, error) {
+	var data []byte
+	if m.ToolData != nil {
+		var err error
+		if data, err = json.Marshal(m.ToolData); err != nil {
+			return Message{}, err
+		}
+	}
+	out, err := scanMessage(r.db.QueryRow(ctx, `
+		with s as (
+			select id from chat_sessions
+			where id = $1 and closed_at is null
+			for update
+		)
+		insert into messages (session_id, role, content, tool_name, tool_data, created_at)
+		select s.id, $2, $3, $4, $5, $6 from s
+		returning `+messageColumns,
+		m.SessionID, m.Role, m.Content, m.ToolName, data, m.CreatedAt))
+	if err != nil {
+		if isInvalidText(err) {
+			return Message{}, domain.ErrSessionNotFound
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			// missing or closed; GetSession tells which
+			if _, err := r.GetSession(ctx, m.SessionID); err != nil {
+				return Message{}, err
+			}
+			return Message{}, domain.ErrSessionClosed
+		}
+		return Message{}, err
+	}
+	return out, nil
+}
+
+// ListMessagesAfter returns up to limit messages of a session with
+// seq > afterSeq, oldest first.
+func (r *SessionRepo) ListMessagesAfter(ctx context.Context, sessionID string, afterSeq int64, limit int) ([]Message, error) {
+	rows, err := r.db.Query(ctx, `
+		select `+messageColumns+`
+		from messages
+		where session_id = $1 and seq > $2
+		order by seq
+		limit $3
+	`, sessionID, afterSeq, limit)
+	if err != nil {
+		if isInvalidText(err) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// NotifyTyping tells every replica's listeners that a reply for the
+// session is being written. Nothing is stored.
+func (r *SessionRepo) NotifyTyping(ctx context.Context, sessionID string) error {
+	_, err := r.db.Exec(ctx, `select pg_notify($1, $2)`, SessionEventsChannel, sessionID+typingSuffix)
+	return err
+}
+
+// ListenSessionEvents holds one pool connection LISTENing on
+// SessionEventsChannel and calls notify for each event until ctx ends. A
+// lost connection is re-established; once it listens again notify("", false)
+// tells the caller that events may have been missed.
+func ListenSessionEvents(ctx context.Context, pool *pgxpool.Pool, notify func(sessionID string, typing bool)) {
+	for reconnect := false; ctx.Err() == nil; reconnect = true {
+		err := listenOnce(ctx, pool, notify, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("session events: %v; reconnecting", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func listenOnce(ctx context.Context, pool *pgxpool.Pool, notify func(string, bool), reconnect bool) error {
+	pc, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// a LISTENing connection must not go back to the pool
+	conn := pc.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "listen "+SessionEventsChannel); err != nil {
+		return err
+	}
+	if reconnect {
+		notify("", false)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, typing := strings.CutSuffix(n.Payload, typingSuffix)
+		notify(id, typing)
+	}
+}
@@ -0,0 +1,184 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestSessionRepo_MessagesAfterSeq(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenant, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	r := repo.NewSessionRepo(db.Conn)
+	sess, err := r.CreateSession(ctx, tenant.ID, "")
+	require.NoError(t, err)
+
+	var seqs []int64
+	for _, content := range []string{"hi", "hello", "bye"} {
+		m, err := r.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "user", Content: content, CreatedAt: time.Now()})
+		require.NoError(t, err)
+		seqs = append(seqs, m.Seq)
+	}
+	require.Less(t, seqs[0], seqs[1])
+
+	got, err := r.ListMessagesAfter(ctx, sess.ID, seqs[0], 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "hello", got[0].Content)
+	require.Equal(t, seqs[2], got[1].Seq)
+
+	tool, err := r.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "tool", ToolName: "lookup", ToolData: map[string]any{"open": true}, CreatedAt: time.Now()})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"open": true}, tool.ToolData)
+
+	require.NoError(t, r.MarkSessionClosed(ctx, sess.ID, time.Now()))
+	got2, err := r.GetSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got2.ClosedAt)
+
+	_, err = r.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "user", Content: "late", CreatedAt: time.Now()})
+	require.ErrorIs(t, err, domain.ErrSessionClosed)
+	_, err = r.InsertMessage(ctx, repo.Message{SessionID: "00000000-0000-0000-0000-000000000000", Role: "user", Content: "hi", CreatedAt: time.Now()})
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+
+	_, err = r.GetSession(ctx, "not-a-uuid")
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+}
+
+func TestSessionRepo_ConcurrentInsertsCommitInSeqOrder(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenant, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	r := repo.NewSessionRepo(db.Conn)
+	sess, err := r.CreateSession(ctx, tenant.ID, "")
+	require.NoError(t, err)
+
+	pool, err := pgxpool.New(ctx, db.Conn.Config().ConnString())
+	require.NoError(t, err)
+	defer pool.Close()
+	other := repo.NewSessionRepo(pool)
+
+	// an open transaction holds the first insert's lock
+	tx, err := db.Conn.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback(ctx) }()
+	first, err := repo.NewSessionRepo(tx).InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "user", Content: "first", CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	type result struct {
+		m   repo.Message
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		m, err := other.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "user", Content: "second", CreatedAt: time.Now()})
+		done <- result{m, err}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("second insert committed while the first was still open")
+	case <-time.After(200 * time.Millisecond):
+	}
+	got, err := other.ListMessagesAfter(ctx, sess.ID, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, got, "nothing is visible before the first insert commits")
+
+	require.NoError(t, tx.Commit(ctx))
+	second := <-done
+	require.NoError(t, second.err)
+	require.Greater(t, second.m.Seq, first.Seq)
+}
+
+func TestSessionRepo_TemplateLink(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenant, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	tpl, err := repo.NewTemplateRepo(db.Conn).CreateTemplate(ctx, tenant.ID, "Sales", "sales")
+	require.NoError(t, err)
+	r := repo.NewSessionRepo(db.Conn)
+
+	sess, err := r.CreateSession(ctx, tenant.ID, tpl.ID)
+	require.NoError(t, err)
+	require.Equal(t, tpl.ID, sess.TemplateID)
+	got, err := r.GetSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Equal(t, tpl.ID, got.TemplateID)
+
+	plain, err := r.CreateSession(ctx, tenant.ID, "")
+	require.NoError(t, err)
+	require.Empty(t, plain.TemplateID)
+}
+
+func TestListenSessionEvents_NotifiesOnInsertAndClose(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, db.Conn.Config().ConnString())
+	require.NoError(t, err)
+	defer pool.Close()
+
+	tenant, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	r := repo.NewSessionRepo(db.Conn)
+	sess, err := r.CreateSession(ctx, tenant.ID, "")
+	require.NoError(t, err)
+
+	got := make(chan string, 8)
+	go repo.ListenSessionEvents(ctx, pool, func(id string, typing bool) {
+		if typing {
+			id += " typing"
+		}
+		got <- id
+	})
+
+	// LISTEN starts asynchronously; keep inserting until one is heard
+	require.Eventually(t, func() bool {
+		_, err := r.InsertMessage(ctx, repo.Message{SessionID: sess.ID, Role: "user", Content: "hi", CreatedAt: time.Now()})
+		require.NoError(t, err)
+		select {
+		case id := <-got:
+			return id == sess.ID
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for len(got) > 0 {
+		<-got
+	}
+	require.NoError(t, r.MarkSessionClosed(ctx, sess.ID, time.Now()))
+	select {
+	case id := <-got:
+		require.Equal(t, sess.ID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for close")
+	}
+
+	require.NoError(t, r.NotifyTyping(ctx, sess.ID))
+	select {
+	case id := <-got:
+		require.Equal(t, sess.ID+" typing", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no typing notification")
+	}
+}
@@ -17,6 +17,7 @@ type Template struct {
 	Name      string
 	Slug      string
 	CreatedAt time.Time
+	Revision  int64
 }
 
 type TemplateVersion struct {
@@ -26,23 +27,24 @@ type TemplateVersion struct {
 	Status     string
 	Content    []byte // raw JSON
 	CreatedAt  time.Time
+	Revision   int64
 }
 
 type TemplateRepo struct {
-	conn *pgx.Conn
+	db Querier
 }
 
-func NewTemplateRepo(conn *pgx.Conn) *TemplateRepo {
-	return &TemplateRepo{conn: conn}
+func NewTemplateRepo(db Querier) *TemplateRepo {
+	return &TemplateRepo{db: db}
 }
 
 func (r *TemplateRepo) CreateTemplate(ctx context.Context, tenantID, name, slug string) (Template, error) {
 	var t Template
-	err := r.conn.QueryRow(ctx, `
+	err := r.db.QueryRow(ctx, `
         insert into templates (tenant_id, name, slug)
         values ($1::uuid, $2, $3)
-        returning id::text, tenant_id::text, name, slug, created_at
-    `, tenantID, name, slug).Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt)
+        returning id::text, tenant_id::text, name, slug, created_at, revision
+    `, tenantID, name, slug).Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
 
 	if err != nil {
 		if isUniqueViolation(err) {
@@ -55,11 +57,11 @@ func (r *TemplateRepo) CreateTemplate(ctx context.Context, tenantID, name, slug
 
 func (r *TemplateRepo) GetTemplateBySlug(ctx context.Context, tenantID, slug string) (Template, error) {
 	var t Template
-	err := r.conn.QueryRow(ctx, `
-        select id::text, tenant_id::text, name, slug, created_at
+	err := r.db.QueryRow(ctx, `
+        select id::text, tenant_id::text, name, slug, created_at, revision
         from templates
         where tenant_id = $1::uuid and slug = $2
-    `, tenantID, slug).Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt)
+    `, tenantID, slug).Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -70,8 +72,52 @@ func (r *TemplateRepo) GetTemplateBySlug(ctx context.Context, tenantID, slug str
 	return t, nil
 }
 
-// Stable list: created_at DESC, id DESC (cursor paging)
-func (r *TemplateRepo) ListTemplates(ctx context.Context, tenantID string, limit int, cursor *pagination.Cursor) ([]Template, *pagination.Cursor, error) {
+// GetTemplate reads a template by id.
+func (r *TemplateRepo) GetTemplate(ctx context.Context, templateID string) (Template, error) {
+	var t Template
+	err := r.db.QueryRow(ctx, `
+        select id::text, tenant_id::text, name, slug, created_at, revision
+        from templates
+        where id = $1::uuid
+    `, templateID).Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return Template{}, domain.ErrTemplateNotFound
+		}
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// RenameTemplate sets a template's name; ifRevision works as in TenantRepo.Rename.
+func (r *TemplateRepo) RenameTemplate(ctx context.Context, templateID, name string, ifRevision int64) (Template, error) {
+	var t Template
+	err := r.db.QueryRow(ctx, `
+        update templates
+        set name = $2, revision = nextval('row_revision_seq')
+        where id = $1::uuid and ($3::bigint = 0 or revision = $3)
+        returning id::text, tenant_id::text, name, slug, created_at, revision
+    `, templateID, name, ifRevision).Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			var exists bool
+			if e2 := r.db.QueryRow(ctx, `select exists (select 1 from templates where id = $1::uuid)`, templateID).Scan(&exists); e2 != nil {
+				return Template{}, e2
+			}
+			if !exists {
+				return Template{}, domain.ErrTemplateNotFound
+			}
+			return Template{}, domain.ErrRevisionMismatch
+		}
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// Stable list: f.Sort, then id in the same direction (cursor paging)
+func (r *TemplateRepo) ListTemplates(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) ([]Template, pagination.Page, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -79,50 +125,31 @@ func (r *TemplateRepo) ListTemplates(ctx context.Context, tenantID string, limit
 		limit = 200
 	}
 
-	var (
-		rows pgx.Rows
-		err  error
-	)
-	if cursor == nil {
-		rows, err = r.conn.Query(ctx, `
-            select id::text, tenant_id::text, name, slug, created_at
-            from templates
-            where tenant_id = $1::uuid
-            order by created_at desc, id desc
-            limit $2
-        `, tenantID, limit)
-	} else {
-		rows, err = r.conn.Query(ctx, `
-            select id::text, tenant_id::text, name, slug, created_at
-            from templates
-            where tenant_id = $1::uuid
-              and (created_at, id) < ($2::timestamptz, $3::uuid)
-            order by created_at desc, id desc
-            limit $4
-        `, tenantID, cursor.CreatedAt, cursor.ID, limit)
-	}
+	var q listQuery
+	q.where = append(q.where, "tenant_id = "+q.arg(tenantID)+"::uuid")
+	sql, args := q.build("id::text, tenant_id::text, name, slug, created_at, revision", "templates", f, cursor, limit)
+	rows, err := r.db.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, pagination.Page{}, err
 	}
 	defer rows.Close()
 
-	out := make([]Template, 0, limit)
+	out := make([]Template, 0, limit+1)
 	for rows.Next() {
 		var t Template
-		if err := rows.Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt); err != nil {
-			return nil, nil, err
+		if err := rows.Scan(&t.ID, &t.TenantID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision); err != nil {
+			return nil, pagination.Page{}, err
 		}
 		out = append(out, t)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, nil, err
+		return nil, pagination.Page{}, err
 	}
 
-	if len(out) == limit {
-		last := out[len(out)-1]
-		return out, &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
-	}
-	return out, nil, nil
+	out, p := page(out, limit, f, cursor, func(t Template) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: t.CreatedAt, Name: t.Name, ID: t.ID}
+	})
+	return out, p, nil
 }
 
 // Creates the next draft version (append-only version numbers)
@@ -131,7 +158,7 @@ func (r *TemplateRepo) CreateDraftVersion(ctx context.Context, templateID string
 	if len(contentJSON) == 0 {
 		contentJSON = []byte(`{}`)
 	}
-	err := r.conn.QueryRow(ctx, `
+	err := r.db.QueryRow(ctx, `
         with next_version as (
             select coalesce(max(version), 0) + 1 as v
             from template_versions
@@ -140,22 +167,55 @@ func (r *TemplateRepo) CreateDraftVersion(ctx context.Context, templateID string
         insert into template_versions (template_id, version, status, content)
         select $1::uuid, next_version.v, 'draft', $2::jsonb
         from next_version
-        returning id::text, template_id::text, version, status, content::text, created_at
-    `, templateID, string(contentJSON)).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt)
+        returning id::text, template_id::text, version, status, content::text, created_at, revision
+    `, templateID, string(contentJSON)).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt, &v.Revision)
+	return v, err
+}
+
+// UpdateDraftVersion replaces a draft's content in place; published
+// versions are immutable. ifRevision works as in TenantRepo.Rename.
+func (r *TemplateRepo) UpdateDraftVersion(ctx context.Context, templateID string, version int, contentJSON []byte, ifRevision int64) (TemplateVersion, error) {
+	var v TemplateVersion
+	if len(contentJSON) == 0 {
+		contentJSON = []byte(`{}`)
+	}
+	err := r.db.QueryRow(ctx, `
+        update template_versions
+        set content = $3::jsonb, revision = nextval('row_revision_seq')
+        where template_id = $1::uuid
+          and version = $2
+          and status = 'draft'
+          and ($4::bigint = 0 or revision = $4)
+        returning id::text, template_id::text, version, status, content::text, created_at, revision
+    `, templateID, version, string(contentJSON), ifRevision).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt, &v.Revision)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		cur, e2 := r.GetVersion(ctx, templateID, version)
+		switch {
+		case e2 != nil:
+			return TemplateVersion{}, e2
+		case cur.Status == "published":
+			return TemplateVersion{}, domain.ErrPublishedVersionImmutable
+		default:
+			return TemplateVersion{}, domain.ErrRevisionMismatch
+		}
+	}
 	return v, err
 }
 
 // Publish a draft version. Enforces "only one published" via partial unique index.
-func (r *TemplateRepo) PublishVersion(ctx context.Context, templateID string, version int) (TemplateVersion, error) {
+// ifRevision works as in TenantRepo.Rename.
+func (r *TemplateRepo) PublishVersion(ctx context.Context, templateID string, version int, ifRevision int64) (TemplateVersion, error) {
 	var v TemplateVersion
 
-	cmdTag, err := r.conn.Exec(ctx, `
+	cmdTag, err := r.db.Exec(ctx, `
         update template_versions
-        set status = 'published'
+        set status = 'published', revision = nextval('row_revision_seq')
         where template_id = $1::uuid
           and version = $2
           and status = 'draft'
-    `, templateID, version)
+          and ($3::bigint = 0 or revision = $3)
+    `, templateID, version, ifRevision)
 	if err != nil {
 		if isUniqueViolation(err) {
 			return TemplateVersion{}, domain.ErrVersionAlreadyPublished
@@ -163,39 +223,43 @@ func (r *TemplateRepo) PublishVersion(ctx context.Context, templateID string, ve
 		return TemplateVersion{}, err
 	}
 	if cmdTag.RowsAffected() == 0 {
-		var status string
-		e2 := r.conn.QueryRow(ctx, `
-            select status from template_versions
+		var (
+			status   string
+			revision int64
+		)
+		e2 := r.db.QueryRow(ctx, `
+            select status, revision from template_versions
             where template_id = $1::uuid and version = $2
-        `, templateID, version).Scan(&status)
+        `, templateID, version).Scan(&status, &revision)
 
 		if errors.Is(e2, pgx.ErrNoRows) {
 			return TemplateVersion{}, domain.ErrVersionNotFound
 		}
-		if e2 == nil && status == "published" {
-			return TemplateVersion{}, domain.ErrVersionAlreadyPublished
-		}
 		if e2 != nil {
 			return TemplateVersion{}, e2
 		}
+		if status == "published" {
+			return TemplateVersion{}, domain.ErrVersionAlreadyPublished
+		}
+		return TemplateVersion{}, domain.ErrRevisionMismatch
 	}
 
-	err = r.conn.QueryRow(ctx, `
-        select id::text, template_id::text, version, status, content::text, created_at
+	err = r.db.QueryRow(ctx, `
+        select id::text, template_id::text, version, status, content::text, created_at, revision
         from template_versions
         where template_id = $1::uuid and version = $2
-    `, templateID, version).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt)
+    `, templateID, version).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt, &v.Revision)
 
 	return v, err
 }
 
 func (r *TemplateRepo) GetPublishedVersion(ctx context.Context, templateID string) (TemplateVersion, error) {
 	var v TemplateVersion
-	err := r.conn.QueryRow(ctx, `
-        select id::text, template_id::text, version, status, content::text, created_at
+	err := r.db.QueryRow(ctx, `
+        select id::text, template_id::text, version, status, content::text, created_at, revision
         from template_versions
         where template_id = $1::uuid and status = 'published'
-    `, templateID).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt)
+    `, templateID).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt, &v.Revision)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -206,4 +270,19 @@ func (r *TemplateRepo) GetPublishedVersion(ctx context.Context, templateID strin
 	return v, nil
 }
 
+func (r *TemplateRepo) GetVersion(ctx context.Context, templateID string, version int) (TemplateVersion, error) {
+	var v TemplateVersion
+	err := r.db.QueryRow(ctx, `
+        select id::text, template_id::text, version, status, content::text, created_at, revision
+        from template_versions
+        where template_id = $1::uuid and version = $2
+    `, templateID, version).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Status, &v.Content, &v.CreatedAt, &v.Revision)
 
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return TemplateVersion{}, domain.ErrVersionNotFound
+		}
+		return TemplateVersion{}, err
+	}
+	return v, nil
+}
@@ -75,7 +75,7 @@ func TestTemplateRepo_Versions_DraftThenPublish_OnlyOnePublished(t *testing.T) {
 	require.Equal(t, "draft", v1.Status)
 	require.Equal(t, 1, v1.Version)
 
-	pub1, err := r.PublishVersion(ctx, tpl.ID, 1)
+	pub1, err := r.PublishVersion(ctx, tpl.ID, 1, 0)
 	require.NoError(t, err)
 	require.Equal(t, "published", pub1.Status)
 
@@ -83,6 +83,67 @@ func TestTemplateRepo_Versions_DraftThenPublish_OnlyOnePublished(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 2, v2.Version)
 
-	_, err = r.PublishVersion(ctx, tpl.ID, 2)
+	_, err = r.PublishVersion(ctx, tpl.ID, 2, 0)
 	require.ErrorIs(t, err, domain.ErrVersionAlreadyPublished)
 }
+
+func TestTemplateRepo_UpdateDraftVersion_Revisions(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	tenantID := seedTenant(t, db.Conn, "Acme", "acme")
+	r := repo.NewTemplateRepo(db.Conn)
+	ctx := context.Background()
+
+	tpl, err := r.CreateTemplate(ctx, tenantID, "Intake", "intake")
+	require.NoError(t, err)
+	v1, err := r.CreateDraftVersion(ctx, tpl.ID, []byte(`{"x":1}`))
+	require.NoError(t, err)
+
+	upd, err := r.UpdateDraftVersion(ctx, tpl.ID, 1, []byte(`{"x":2}`), v1.Revision)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"x":2}`, string(upd.Content))
+	require.Greater(t, upd.Revision, v1.Revision)
+
+	_, err = r.UpdateDraftVersion(ctx, tpl.ID, 1, []byte(`{"x":3}`), v1.Revision)
+	require.ErrorIs(t, err, domain.ErrRevisionMismatch)
+	_, err = r.PublishVersion(ctx, tpl.ID, 1, v1.Revision)
+	require.ErrorIs(t, err, domain.ErrRevisionMismatch)
+
+	pub, err := r.PublishVersion(ctx, tpl.ID, 1, upd.Revision)
+	require.NoError(t, err)
+	require.Greater(t, pub.Revision, upd.Revision)
+
+	_, err = r.UpdateDraftVersion(ctx, tpl.ID, 1, []byte(`{"x":4}`), 0)
+	require.ErrorIs(t, err, domain.ErrPublishedVersionImmutable)
+	_, err = r.UpdateDraftVersion(ctx, tpl.ID, 9, []byte(`{"x":4}`), 0)
+	require.ErrorIs(t, err, domain.ErrVersionNotFound)
+
+	renamed, err := r.RenameTemplate(ctx, tpl.ID, "Intake v2", tpl.Revision)
+	require.NoError(t, err)
+	require.Equal(t, "Intake v2", renamed.Name)
+	_, err = r.RenameTemplate(ctx, tpl.ID, "Stale", tpl.Revision)
+	require.ErrorIs(t, err, domain.ErrRevisionMismatch)
+}
+
+func TestTemplateRepo_GetVersion(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	tenantID := seedTenant(t, db.Conn, "Acme", "acme")
+	r := repo.NewTemplateRepo(db.Conn)
+	ctx := context.Background()
+
+	tpl, err := r.CreateTemplate(ctx, tenantID, "Intake", "intake")
+	require.NoError(t, err)
+	_, err = r.CreateDraftVersion(ctx, tpl.ID, []byte(`{"greeting":"hi"}`))
+	require.NoError(t, err)
+
+	v, err := r.GetVersion(ctx, tpl.ID, 1)
+	require.NoError(t, err)
+	require.Equal(t, "draft", v.Status)
+	require.JSONEq(t, `{"greeting":"hi"}`, string(v.Content))
+
+	_, err = r.GetVersion(ctx, tpl.ID, 2)
+	require.ErrorIs(t, err, domain.ErrVersionNotFound)
+}
@@ -16,23 +16,24 @@ type Tenant struct {
 	Name      string
 	Slug      string
 	CreatedAt time.Time
+	Revision  int64 // changes on every update; see migrations/0007
 }
 
 type TenantRepo struct {
-	conn *pgx.Conn
+	db Querier
 }
 
-func NewTenantRepo(conn *pgx.Conn) *TenantRepo {
-	return &TenantRepo{conn: conn}
+func NewTenantRepo(db Querier) *TenantRepo {
+	return &TenantRepo{db: db}
 }
 
 func (r *TenantRepo) Create(ctx context.Context, name, slug string) (Tenant, error) {
 	var t Tenant
-	err := r.conn.QueryRow(ctx, `
+	err := r.db.QueryRow(ctx, `
 		insert into tenants (name, slug)
 		values ($1, $2)
-		returning id::text, name, slug, created_at
-	`, name, slug).Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt)
+		returning id::text, name, slug, created_at, revision
+	`, name, slug).Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
 
 	if err != nil {
 		if isUniqueViolation(err) {
@@ -45,11 +46,11 @@ func (r *TenantRepo) Create(ctx context.Context, name, slug string) (Tenant, err
 
 func (r *TenantRepo) GetBySlug(ctx context.Context, slug string) (Tenant, error) {
 	var t Tenant
-	err := r.conn.QueryRow(ctx, `
-		select id::text, name, slug, created_at
+	err := r.db.QueryRow(ctx, `
+		select id::text, name, slug, created_at, revision
 		from tenants
 		where slug = $1
-	`, slug).Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt)
+	`, slug).Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -60,7 +61,31 @@ func (r *TenantRepo) GetBySlug(ctx context.Context, slug string) (Tenant, error)
 	return t, nil
 }
 
-func (r *TenantRepo) List(ctx context.Context, limit int, cursor *pagination.Cursor) ([]Tenant, *pagination.Cursor, error) {
+// Rename sets the tenant's name. A non-zero ifRevision makes the update
+// conditional: it fails with ErrRevisionMismatch unless the row is still at
+// that revision.
+func (r *TenantRepo) Rename(ctx context.Context, slug, name string, ifRevision int64) (Tenant, error) {
+	var t Tenant
+	err := r.db.QueryRow(ctx, `
+		update tenants
+		set name = $2, revision = nextval('row_revision_seq')
+		where slug = $1 and ($3::bigint = 0 or revision = $3)
+		returning id::text, name, slug, created_at, revision
+	`, slug, name, ifRevision).Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, gerr := r.GetBySlug(ctx, slug); gerr != nil {
+				return Tenant{}, gerr
+			}
+			return Tenant{}, domain.ErrRevisionMismatch
+		}
+		return Tenant{}, err
+	}
+	return t, nil
+}
+
+func (r *TenantRepo) List(ctx context.Context, limit int, f pagination.Filter, cursor *pagination.Cursor) ([]Tenant, pagination.Page, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -68,54 +93,57 @@ func (r *TenantRepo) List(ctx context.Context, limit int, cursor *pagination.Cur
 		limit = 200
 	}
 
-	// Stable ordering: created_at DESC, id DESC
-	var rows pgx.Rows
-	var err error
-
-	if cursor == nil {
-		rows, err = r.conn.Query(ctx, `
-			select id::text, name, slug, created_at
-			from tenants
-			order by created_at desc, id desc
-			limit $1
-		`, limit)
-	} else {
-		rows, err = r.conn.Query(ctx, `
-			select id::text, name, slug, created_at
-			from tenants
-			where (created_at, id) < ($1::timestamptz, $2::uuid)
-			order by created_at desc, id desc
-			limit $3
-		`, cursor.CreatedAt, cursor.ID, limit)
-	}
+	// Stable ordering: f.Sort, then id in the same direction
+	var q listQuery
+	sql, args := q.build("id::text, name, slug, created_at, revision", "tenants", f, cursor, limit)
+	rows, err := r.db.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, pagination.Page{}, err
 	}
 	defer rows.Close()
 
-	items := make([]Tenant, 0, limit)
+	items := make([]Tenant, 0, limit+1)
 	for rows.Next() {
 		var t Tenant
-		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt); err != nil {
-			return nil, nil, err
+		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt, &t.Revision); err != nil {
+			return nil, pagination.Page{}, err
 		}
 		items = append(items, t)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, nil, err
+		return nil, pagination.Page{}, err
 	}
 
-	// next cursor = last row (if we returned a full page)
-	if len(items) == limit {
-		last := items[len(items)-1]
-		next := &pagination.Cursor{
-			CreatedAt: last.CreatedAt,
-			ID:        last.ID,
+	items, p := page(items, limit, f, cursor, func(t Tenant) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: t.CreatedAt, Name: t.Name, ID: t.ID}
+	})
+	return items, p, nil
+}
+
+// AllowedTools lists the tools the assistant may call for the tenant.
+func (r *TenantRepo) AllowedTools(ctx context.Context, tenantID string) ([]string, error) {
+	var names []string
+	err := r.db.QueryRow(ctx, `select allowed_tools from tenants where id = $1`, tenantID).Scan(&names)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return nil, domain.ErrTenantNotFound
 		}
-		return items, next, nil
+		return nil, err
 	}
-
-	return items, nil, nil
+	return names, nil
 }
 
-
+// SetAllowedTools replaces the tenant's tool allowlist.
+func (r *TenantRepo) SetAllowedTools(ctx context.Context, slug string, names []string) error {
+	if names == nil {
+		names = []string{}
+	}
+	tag, err := r.db.Exec(ctx, `update tenants set allowed_tools = $2 where slug = $1`, slug, names)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrTenantNotFound
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"gochatbot/internal/domain"
 	"gochatbot/internal/pagination"
 	"gochatbot/internal/repo"
 	"gochatbot/internal/testdb"
@@ -29,6 +30,36 @@ func TestTenantRepo_CreateAndGet(t *testing.T) {
 	require.Equal(t, "Acme", got.Name)
 }
 
+func TestTenantRepo_Rename(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewTenantRepo(db.Conn)
+	ctx := context.Background()
+
+	created, err := r.Create(ctx, "Acme", "acme-law")
+	require.NoError(t, err)
+
+	renamed, err := r.Rename(ctx, "acme-law", "Acme Legal", 0)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, renamed.ID)
+	require.Equal(t, "Acme Legal", renamed.Name)
+	require.Equal(t, "acme-law", renamed.Slug)
+	require.Greater(t, renamed.Revision, created.Revision)
+
+	_, err = r.Rename(ctx, "acme-law", "Stale", created.Revision)
+	require.ErrorIs(t, err, domain.ErrRevisionMismatch)
+
+	again, err := r.Rename(ctx, "acme-law", "Acme Legal LLP", renamed.Revision)
+	require.NoError(t, err)
+	require.Equal(t, "Acme Legal LLP", again.Name)
+
+	_, err = r.Rename(ctx, "missing", "X", 0)
+	require.ErrorIs(t, err, domain.ErrTenantNotFound)
+	_, err = r.Rename(ctx, "missing", "X", again.Revision)
+	require.ErrorIs(t, err, domain.ErrTenantNotFound)
+}
+
 func TestTenantRepo_UniqueSlug(t *testing.T) {
 	db := testdb.NewPostgres(t)
 	testdb.ApplyMigrations(t, db.Conn)
@@ -43,6 +74,34 @@ func TestTenantRepo_UniqueSlug(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestTenantRepo_AllowedTools(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewTenantRepo(db.Conn)
+	ctx := context.Background()
+
+	tn, err := r.Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	names, err := r.AllowedTools(ctx, tn.ID)
+	require.NoError(t, err)
+	require.Empty(t, names, "new tenants get no tools")
+
+	require.NoError(t, r.SetAllowedTools(ctx, "acme", []string{"capture_lead_field", "lookup_business_hours"}))
+	names, err = r.AllowedTools(ctx, tn.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"capture_lead_field", "lookup_business_hours"}, names)
+
+	require.NoError(t, r.SetAllowedTools(ctx, "acme", nil))
+	names, err = r.AllowedTools(ctx, tn.ID)
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	require.ErrorIs(t, r.SetAllowedTools(ctx, "nope", nil), domain.ErrTenantNotFound)
+	_, err = r.AllowedTools(ctx, "not-a-uuid")
+	require.ErrorIs(t, err, domain.ErrTenantNotFound)
+}
+
 func TestTenantRepo_ListWithCursor(t *testing.T) {
 	db := testdb.NewPostgres(t)
 	testdb.ApplyMigrations(t, db.Conn)
@@ -57,18 +116,66 @@ func TestTenantRepo_ListWithCursor(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 	c, _ := r.Create(ctx, "C", "c")
 
-	page1, cur, err := r.List(ctx, 2, nil)
+	page1, p1, err := r.List(ctx, 2, pagination.Filter{}, nil)
 	require.NoError(t, err)
 	require.Len(t, page1, 2)
-	require.NotNil(t, cur)
+	require.NotNil(t, p1.Next)
+	require.Nil(t, p1.Prev)
 
 	// should be newest first (c then b)
 	require.Equal(t, c.ID, page1[0].ID)
 	require.Equal(t, b.ID, page1[1].ID)
 
-	page2, cur2, err := r.List(ctx, 2, &pagination.Cursor{CreatedAt: cur.CreatedAt, ID: cur.ID})
+	page2, p2, err := r.List(ctx, 2, pagination.Filter{}, p1.Next)
 	require.NoError(t, err)
 	require.Len(t, page2, 1)
-	require.Nil(t, cur2)
+	require.Nil(t, p2.Next)
 	require.Equal(t, a.ID, page2[0].ID)
+
+	// and back again, in list order
+	back, pb, err := r.List(ctx, 2, pagination.Filter{}, p2.Prev)
+	require.NoError(t, err)
+	require.Equal(t, []string{c.ID, b.ID}, []string{back[0].ID, back[1].ID})
+	require.Nil(t, pb.Prev)
+	require.NotNil(t, pb.Next)
+}
+
+func TestTenantRepo_List_FilterAndSortByName(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+
+	r := repo.NewTenantRepo(db.Conn)
+	ctx := context.Background()
+
+	for _, n := range []string{"Beta", "Acme West", "Acme East", "100% Legal"} {
+		_, err := r.Create(ctx, n, n)
+		require.NoError(t, err)
+	}
+
+	byName := pagination.Filter{Prefix: "acme", Sort: pagination.Sort{By: pagination.SortName, Asc: true}}
+	page1, p1, err := r.List(ctx, 1, byName, nil)
+	require.NoError(t, err)
+	require.Len(t, page1, 1)
+	require.Equal(t, "Acme East", page1[0].Name)
+	require.Equal(t, byName.Sort, p1.Next.Sort)
+
+	// the cursor survives encoding and resumes by name
+	codec := pagination.NewCodec(nil)
+	decoded, err := codec.Decode(codec.Encode(*p1.Next, byName), byName)
+	require.NoError(t, err)
+	page2, _, err := r.List(ctx, 1, byName, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, "Acme West", page2[0].Name)
+
+	// LIKE wildcards in the prefix are literal
+	pct, _, err := r.List(ctx, 10, pagination.Filter{Prefix: "100%"}, nil)
+	require.NoError(t, err)
+	require.Len(t, pct, 1)
+	none, _, err := r.List(ctx, 10, pagination.Filter{Prefix: "_"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, none)
+
+	later, _, err := r.List(ctx, 10, pagination.Filter{CreatedAfter: time.Now().Add(time.Hour)}, nil)
+	require.NoError(t, err)
+	require.Empty(t, later)
 }
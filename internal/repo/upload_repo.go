@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"gochatbot/internal/domain"
+)
+
+type Upload struct {
+	ID          string
+	TenantID    string
+	SessionID   string
+	FileName    string
+	ContentType string
+	Size        int64
+	StorageKey  string
+	CreatedAt   time.Time
+}
+
+type UploadRepo struct {
+	db Querier
+}
+
+func NewUploadRepo(db Querier) *UploadRepo {
+	return &UploadRepo{db: db}
+}
+
+const uploadColumns = `id::text, tenant_id::text, session_id::text, file_name, content_type, size_bytes, storage_key, created_at`
+
+func scanUpload(row pgx.Row) (Upload, error) {
+	var u Upload
+	err := row.Scan(&u.ID, &u.TenantID, &u.SessionID, &u.FileName, &u.ContentType, &u.Size, &u.StorageKey, &u.CreatedAt)
+	return u, err
+}
+
+// CreateUpload records a file already in the blob store; ID and CreatedAt
+// are assigned.
+func (r *UploadRepo) CreateUpload(ctx context.Context, u Upload) (Upload, error) {
+	u, err := scanUpload(r.db.QueryRow(ctx, `
+		insert into uploads (tenant_id, session_id, file_name, content_type, size_bytes, storage_key)
+		values ($1, $2, $3, $4, $5, $6)
+		returning `+uploadColumns,
+		u.TenantID, u.SessionID, u.FileName, u.ContentType, u.Size, u.StorageKey))
+	if err != nil {
+		if isInvalidText(err) {
+			return Upload{}, domain.ErrSessionNotFound
+		}
+		return Upload{}, err
+	}
+	return u, nil
+}
+
+func (r *UploadRepo) GetUpload(ctx context.Context, uploadID string) (Upload, error) {
+	u, err := scanUpload(r.db.QueryRow(ctx, `select `+uploadColumns+` from uploads where id = $1`, uploadID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return Upload{}, domain.ErrUploadNotFound
+		}
+		return Upload{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,41 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestUploadRepo_CreateGet(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	acme, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	sess, err := repo.NewSessionRepo(db.Conn).CreateSession(ctx, acme.ID, "")
+	require.NoError(t, err)
+
+	uploads := repo.NewUploadRepo(db.Conn)
+	u, err := uploads.CreateUpload(ctx, repo.Upload{TenantID: acme.ID, SessionID: sess.ID, FileName: "report.pdf",
+		ContentType: "application/pdf", Size: 1234, StorageKey: acme.ID + "/" + sess.ID + "/k1"})
+	require.NoError(t, err)
+	require.NotEmpty(t, u.ID)
+	require.False(t, u.CreatedAt.IsZero())
+
+	got, err := uploads.GetUpload(ctx, u.ID)
+	require.NoError(t, err)
+	require.Equal(t, u, got)
+
+	_, err = uploads.GetUpload(ctx, "00000000-0000-0000-0000-000000000000")
+	require.ErrorIs(t, err, domain.ErrUploadNotFound)
+	_, err = uploads.GetUpload(ctx, "nope")
+	require.ErrorIs(t, err, domain.ErrUploadNotFound)
+	_, err = uploads.CreateUpload(ctx, repo.Upload{TenantID: acme.ID, SessionID: "nope", StorageKey: "k2"})
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+}
@@ -0,0 +1,344 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"gochatbot/internal/domain"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookEndpoint struct {
+	ID        string
+	TenantID  string
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID         string
+	EndpointID string
+	EventID    string
+	EventType  string
+	Payload    []byte
+	Status     string
+	Attempts   int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// Log is filled by ListDeliveries, oldest attempt first.
+	Log []WebhookAttempt
+}
+
+type WebhookAttempt struct {
+	Attempt      int
+	StatusCode   int // 0 when no response came back
+	Error        string
+	ResponseBody string
+	Duration     time.Duration
+	CreatedAt    time.Time
+}
+
+type WebhookRepo struct {
+	db Querier
+}
+
+func NewWebhookRepo(db Querier) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+const endpointColumns = `id::text, tenant_id::text, url, secret, events, created_at`
+
+func scanEndpoint(row pgx.Row) (WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	err := row.Scan(&e.ID, &e.TenantID, &e.URL, &e.Secret, &e.Events, &e.CreatedAt)
+	return e, err
+}
+
+const deliveryColumns = `d.id::text, d.endpoint_id::text, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.created_at, d.updated_at`
+
+func scanDelivery(row pgx.Row) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload string
+	err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.CreatedAt, &d.UpdatedAt)
+	d.Payload = []byte(payload)
+	return d, err
+}
+
+func (r *WebhookRepo) CreateEndpoint(ctx context.Context, tenantID, url, secret string, events []string) (WebhookEndpoint, error) {
+	return scanEndpoint(r.db.QueryRow(ctx, `
+		insert into webhook_endpoints (tenant_id, url, secret, events)
+		values ($1::uuid, $2, $3, $4)
+		returning `+endpointColumns,
+		tenantID, url, secret, events))
+}
+
+// ListEndpoints returns the tenant's endpoints, oldest first. Tenants have
+// a handful, so the list is not paged.
+func (r *WebhookRepo) ListEndpoints(ctx context.Context, tenantID string) ([]WebhookEndpoint, error) {
+	rows, err := r.db.Query(ctx, `
+		select `+endpointColumns+` from webhook_endpoints
+		where tenant_id = $1::uuid
+		order by created_at, id
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebhookEndpoint
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// GetEndpoint reads an endpoint of the tenant; other tenants' endpoints are
+// not found.
+func (r *WebhookRepo) GetEndpoint(ctx context.Context, tenantID, endpointID string) (WebhookEndpoint, error) {
+	e, err := scanEndpoint(r.db.QueryRow(ctx, `
+		select `+endpointColumns+` from webhook_endpoints
+		where id = $1::uuid and tenant_id = $2::uuid
+	`, endpointID, tenantID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return WebhookEndpoint{}, domain.ErrWebhookNotFound
+		}
+		return WebhookEndpoint{}, err
+	}
+	return e, nil
+}
+
+// DeleteEndpoint removes the endpoint with its delivery log. Jobs already
+// queued for it find nothing to send and finish.
+func (r *WebhookRepo) DeleteEndpoint(ctx context.Context, tenantID, endpointID string) error {
+	tag, err := r.db.Exec(ctx, `
+		delete from webhook_endpoints where id = $1::uuid and tenant_id = $2::uuid
+	`, endpointID, tenantID)
+	if err != nil {
+		if isInvalidText(err) {
+			return domain.ErrWebhookNotFound
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// CreateDeliveries records a pending delivery of the event for each of the
+// tenant's endpoints subscribed to its type and returns their ids.
+func (r *WebhookRepo) CreateDeliveries(ctx context.Context, tenantID, eventID, eventType string, payload []byte) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		insert into webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		select id, $2, $3, $4
+		from webhook_endpoints
+		where tenant_id = $1::uuid and $3 = any(events)
+		returning id::text
+	`, tenantID, eventID, eventType, string(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetDelivery reads a delivery with the endpoint it goes to, for sending.
+func (r *WebhookRepo) GetDelivery(ctx context.Context, deliveryID string) (WebhookDelivery, WebhookEndpoint, error) {
+	var (
+		e       WebhookEndpoint
+		payload string
+	)
+	var d WebhookDelivery
+	err := r.db.QueryRow(ctx, `
+		select `+deliveryColumns+`, e.tenant_id::text, e.url, e.secret, e.events, e.created_at
+		from webhook_deliveries d
+		join webhook_endpoints e on e.id = d.endpoint_id
+		where d.id = $1::uuid
+	`, deliveryID).Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.CreatedAt, &d.UpdatedAt,
+		&e.TenantID, &e.URL, &e.Secret, &e.Events, &e.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return WebhookDelivery{}, WebhookEndpoint{}, domain.ErrDeliveryNotFound
+		}
+		return WebhookDelivery{}, WebhookEndpoint{}, err
+	}
+	d.Payload, e.ID = []byte(payload), d.EndpointID
+	return d, e, nil
+}
+
+// RecordAttempt logs an attempt under the delivery's next attempt number
+// and moves the delivery to status.
+func (r *WebhookRepo) RecordAttempt(ctx context.Context, deliveryID string, a WebhookAttempt, status string) (WebhookAttempt, error) {
+	var code *int
+	if a.StatusCode != 0 {
+		code = &a.StatusCode
+	}
+	err := r.db.QueryRow(ctx, `
+		with d as (
+			update webhook_deliveries
+			set attempts = attempts + 1, status = $2, updated_at = now()
+			where id = $1::uuid
+			returning id, attempts
+		)
+		insert into webhook_delivery_attempts (delivery_id, attempt, status_code, error, response_body, duration_ms)
+		select d.id, d.attempts, $3, $4, $5, $6 from d
+		returning attempt, created_at
+	`, deliveryID, status, code, a.Error, a.ResponseBody, a.Duration.Milliseconds()).Scan(&a.Attempt, &a.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidText(err) {
+			return WebhookAttempt{}, domain.ErrDeliveryNotFound
+		}
+		return WebhookAttempt{}, err
+	}
+	return a, nil
+}
+
+// ListDeliveries returns the endpoint's latest deliveries, newest first,
+// each with its attempt log.
+func (r *WebhookRepo) ListDeliveries(ctx context.Context, tenantID, endpointID string, limit int) ([]WebhookDelivery, error) {
+	if _, err := r.GetEndpoint(ctx, tenantID, endpointID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	rows, err := r.db.Query(ctx, `
+		select `+deliveryColumns+` from webhook_deliveries d
+		where d.endpoint_id = $1::uuid
+		order by d.created_at desc, d.id desc
+		limit $2
+	`, endpointID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]WebhookDelivery, 0, limit)
+	ids := make([]string, 0, limit)
+	at := map[string]int{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		at[d.ID] = len(out)
+		ids = append(ids, d.ID)
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	attempts, err := r.db.Query(ctx, `
+		select delivery_id::text, attempt, coalesce(status_code, 0), error, response_body, duration_ms, created_at
+		from webhook_delivery_attempts
+		where delivery_id = any($1::uuid[])
+		order by delivery_id, attempt
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer attempts.Close()
+	for attempts.Next() {
+		var (
+			id string
+			a  WebhookAttempt
+			ms int64
+		)
+		if err := attempts.Scan(&id, &a.Attempt, &a.StatusCode, &a.Error, &a.ResponseBody, &ms, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		i := at[id]
+		out[i].Log = append(out[i].Log, a)
+	}
+	return out, attempts.Err()
+}
+
+// deliveryJobKind is service.WebhookJobKind, whose payload names the
+// delivery it sends.
+const deliveryJobKind = "deliver_webhook"
+
+// strandedAfter is how long a pending delivery may go without a queued or
+// running job before ResetDelivery takes it for lost rather than still
+// being queued by Publish.
+const strandedAfter = time.Minute
+
+// ResetDelivery makes a finished delivery of the tenant's endpoint pending
+// again so it can be sent once more; its attempt log is kept and continues.
+// A pending delivery left without a live job, because queueing it failed
+// after the row was stored, is reset too.
+func (r *WebhookRepo) ResetDelivery(ctx context.Context, tenantID, endpointID, deliveryID string) (WebhookDelivery, error) {
+	d, err := scanDelivery(r.db.QueryRow(ctx, `
+		update webhook_deliveries d
+		set status = 'pending', updated_at = now()
+		from webhook_endpoints e
+		where d.id = $1::uuid and d.endpoint_id = $2::uuid
+		  and e.id = d.endpoint_id and e.tenant_id = $3::uuid
+		  and (d.status <> 'pending' or (
+			d.updated_at < now() - make_interval(secs => $5)
+			and not exists (
+				select 1 from jobs j
+				where j.kind = $4 and j.status in ('queued', 'running')
+				  and j.payload->>'delivery_id' = d.id::text
+			)
+		  ))
+		returning `+deliveryColumns,
+		deliveryID, endpointID, tenantID, deliveryJobKind, strandedAfter.Seconds()))
+	if err == nil {
+		return d, nil
+	}
+	if isInvalidText(err) {
+		return WebhookDelivery{}, domain.ErrDeliveryNotFound
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return WebhookDelivery{}, err
+	}
+
+	// tell "no such delivery" apart from "still being delivered"
+	var status string
+	err = r.db.QueryRow(ctx, `
+		select d.status from webhook_deliveries d
+		join webhook_endpoints e on e.id = d.endpoint_id
+		where d.id = $1::uuid and d.endpoint_id = $2::uuid and e.tenant_id = $3::uuid
+	`, deliveryID, endpointID, tenantID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return WebhookDelivery{}, domain.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	return WebhookDelivery{}, domain.ErrDeliveryPending
+}
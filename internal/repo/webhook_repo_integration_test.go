@@ -0,0 +1,155 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/testdb"
+)
+
+func TestWebhookRepo_Endpoints(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenants := repo.NewTenantRepo(db.Conn)
+	acme, err := tenants.Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	other, err := tenants.Create(ctx, "Other", "other")
+	require.NoError(t, err)
+
+	hooks := repo.NewWebhookRepo(db.Conn)
+	e, err := hooks.CreateEndpoint(ctx, acme.ID, "https://example.com/a", "whsec_a", []string{"lead.created"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"lead.created"}, e.Events)
+
+	got, err := hooks.GetEndpoint(ctx, acme.ID, e.ID)
+	require.NoError(t, err)
+	require.Equal(t, "whsec_a", got.Secret)
+	_, err = hooks.GetEndpoint(ctx, other.ID, e.ID)
+	require.ErrorIs(t, err, domain.ErrWebhookNotFound, "other tenants' endpoints are not found")
+	_, err = hooks.GetEndpoint(ctx, acme.ID, "nope")
+	require.ErrorIs(t, err, domain.ErrWebhookNotFound)
+
+	list, err := hooks.ListEndpoints(ctx, acme.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	require.ErrorIs(t, hooks.DeleteEndpoint(ctx, other.ID, e.ID), domain.ErrWebhookNotFound)
+	require.NoError(t, hooks.DeleteEndpoint(ctx, acme.ID, e.ID))
+	require.ErrorIs(t, hooks.DeleteEndpoint(ctx, acme.ID, e.ID), domain.ErrWebhookNotFound)
+}
+
+func TestWebhookRepo_DeliveriesAndAttempts(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	tenants := repo.NewTenantRepo(db.Conn)
+	acme, err := tenants.Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	other, err := tenants.Create(ctx, "Other", "other")
+	require.NoError(t, err)
+
+	hooks := repo.NewWebhookRepo(db.Conn)
+	leads, err := hooks.CreateEndpoint(ctx, acme.ID, "https://example.com/a", "whsec_a", []string{"lead.created", "session.closed"})
+	require.NoError(t, err)
+	_, err = hooks.CreateEndpoint(ctx, acme.ID, "https://example.com/b", "whsec_b", []string{"template.published"})
+	require.NoError(t, err)
+	_, err = hooks.CreateEndpoint(ctx, other.ID, "https://example.com/c", "whsec_c", []string{"lead.created"})
+	require.NoError(t, err)
+
+	ids, err := hooks.CreateDeliveries(ctx, acme.ID, "evt_1", "lead.created", []byte(`{"id":"evt_1"}`))
+	require.NoError(t, err)
+	require.Len(t, ids, 1, "only the tenant's endpoints subscribed to the type")
+
+	d, e, err := hooks.GetDelivery(ctx, ids[0])
+	require.NoError(t, err)
+	require.Equal(t, leads.ID, e.ID)
+	require.Equal(t, "whsec_a", e.Secret)
+	require.Equal(t, `{"id":"evt_1"}`, string(d.Payload))
+	require.Equal(t, repo.DeliveryPending, d.Status)
+
+	_, err = hooks.ResetDelivery(ctx, acme.ID, leads.ID, d.ID)
+	require.ErrorIs(t, err, domain.ErrDeliveryPending)
+
+	a, err := hooks.RecordAttempt(ctx, d.ID, repo.WebhookAttempt{Error: "timeout", Duration: 10 * time.Second}, repo.DeliveryPending)
+	require.NoError(t, err)
+	require.Equal(t, 1, a.Attempt)
+	_, err = hooks.RecordAttempt(ctx, d.ID, repo.WebhookAttempt{StatusCode: 200, ResponseBody: "ok", Duration: 5 * time.Millisecond},
+		repo.DeliverySucceeded)
+	require.NoError(t, err)
+
+	_, err = hooks.CreateDeliveries(ctx, acme.ID, "evt_2", "session.closed", []byte(`{"id":"evt_2"}`))
+	require.NoError(t, err)
+
+	list, err := hooks.ListDeliveries(ctx, acme.ID, leads.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "evt_2", list[0].EventID, "newest first")
+	require.Empty(t, list[0].Log)
+	got := list[1]
+	require.Equal(t, repo.DeliverySucceeded, got.Status)
+	require.Equal(t, 2, got.Attempts)
+	require.Len(t, got.Log, 2)
+	require.Zero(t, got.Log[0].StatusCode)
+	require.Equal(t, "timeout", got.Log[0].Error)
+	require.Equal(t, 10*time.Second, got.Log[0].Duration)
+	require.Equal(t, 200, got.Log[1].StatusCode)
+	require.Equal(t, "ok", got.Log[1].ResponseBody)
+
+	_, err = hooks.ListDeliveries(ctx, other.ID, leads.ID, 10)
+	require.ErrorIs(t, err, domain.ErrWebhookNotFound)
+
+	_, err = hooks.ResetDelivery(ctx, other.ID, leads.ID, d.ID)
+	require.ErrorIs(t, err, domain.ErrDeliveryNotFound)
+	reset, err := hooks.ResetDelivery(ctx, acme.ID, leads.ID, d.ID)
+	require.NoError(t, err)
+	require.Equal(t, repo.DeliveryPending, reset.Status)
+	require.Equal(t, 2, reset.Attempts, "the log carries on")
+
+	require.NoError(t, hooks.DeleteEndpoint(ctx, acme.ID, leads.ID))
+	_, _, err = hooks.GetDelivery(ctx, d.ID)
+	require.ErrorIs(t, err, domain.ErrDeliveryNotFound)
+}
+
+func TestWebhookRepo_ResetStrandedDelivery(t *testing.T) {
+	db := testdb.NewPostgres(t)
+	testdb.ApplyMigrations(t, db.Conn)
+	ctx := context.Background()
+
+	acme, err := repo.NewTenantRepo(db.Conn).Create(ctx, "Acme", "acme")
+	require.NoError(t, err)
+	hooks := repo.NewWebhookRepo(db.Conn)
+	e, err := hooks.CreateEndpoint(ctx, acme.ID, "https://example.com/a", "whsec_a", []string{"lead.created"})
+	require.NoError(t, err)
+	ids, err := hooks.CreateDeliveries(ctx, acme.ID, "evt_1", "lead.created", []byte(`{"id":"evt_1"}`))
+	require.NoError(t, err)
+	age := func() {
+		_, err := db.Conn.Exec(ctx, `update webhook_deliveries set updated_at = now() - interval '2 minutes' where id = $1`, ids[0])
+		require.NoError(t, err)
+	}
+
+	_, err = hooks.ResetDelivery(ctx, acme.ID, e.ID, ids[0])
+	require.ErrorIs(t, err, domain.ErrDeliveryPending, "Publish may still be queueing it")
+
+	jobs := repo.NewJobRepo(db.Conn)
+	require.NoError(t, jobs.Enqueue(ctx, "deliver_webhook", map[string]any{"delivery_id": ids[0]}))
+	age()
+	_, err = hooks.ResetDelivery(ctx, acme.ID, e.ID, ids[0])
+	require.ErrorIs(t, err, domain.ErrDeliveryPending, "its job is still queued")
+
+	_, err = db.Conn.Exec(ctx, `update jobs set status = 'failed'`)
+	require.NoError(t, err)
+	d, err := hooks.ResetDelivery(ctx, acme.ID, e.ID, ids[0])
+	require.NoError(t, err, "no live job: stranded")
+	require.Equal(t, repo.DeliveryPending, d.Status)
+
+	_, err = hooks.ResetDelivery(ctx, acme.ID, e.ID, ids[0])
+	require.ErrorIs(t, err, domain.ErrDeliveryPending, "reset restarts the clock")
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gochatbot/internal/llm"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+)
+
+// Context window defaults, used unless the builder is configured otherwise.
+const (
+	defaultContextTokens = 12000
+	defaultRecentTurns   = 4
+)
+
+// summaryPrompt instructs the model that condenses older turns.
+const summaryPrompt = `You condense a chat between a business's website assistant and a visitor so the assistant can continue it. ` +
+	`Keep names, contact details, questions still open and anything promised. Reply with the summary only, in plain prose.`
+
+// ContextBuilder picks the messages a reply prompt is built from so that
+// they fit a token budget. System messages and the most recent turns are
+// always kept; older turns are condensed by the model into a summary,
+// which is stored as a system message so later replies start from it
+// instead of summarizing again.
+//
+// A turn is a user message and everything after it up to the next one, so
+// a cut never separates tool results from the question that led to them.
+type ContextBuilder struct {
+	provider    llm.Provider
+	messages    *MessageService
+	counter     llm.TokenCounter
+	budget      int
+	recentTurns int
+}
+
+func NewContextBuilder(provider llm.Provider, messages *MessageService) *ContextBuilder {
+	return &ContextBuilder{
+		provider:    provider,
+		messages:    messages,
+		counter:     llm.ApproxCounter{},
+		budget:      defaultContextTokens,
+		recentTurns: defaultRecentTurns,
+	}
+}
+
+// WithBudget sets how many tokens the prompt's messages may take; tool
+// schemas and the reply come on top.
+func (b *ContextBuilder) WithBudget(tokens int) *ContextBuilder {
+	if tokens > 0 {
+		b.budget = tokens
+	}
+	return b
+}
+
+// WithRecentTurns sets how many of the latest turns are kept whole, even
+// when they alone exceed the budget.
+func (b *ContextBuilder) WithRecentTurns(n int) *ContextBuilder {
+	if n > 0 {
+		b.recentTurns = n
+	}
+	return b
+}
+
+func (b *ContextBuilder) WithCounter(c llm.TokenCounter) *ContextBuilder {
+	if c != nil {
+		b.counter = c
+	}
+	return b
+}
+
+// Build returns the prompt for a reply to the session: the template's
+// system prompt, the latest summary if there is one, and as much of the
+// history after it as the budget allows. When turns have to go, they are
+// summarized (together with the previous summary) and the new summary is
+// stored before the prompt is returned.
+func (b *ContextBuilder) Build(ctx context.Context, sessionID, system string, history []repo.Message) (_ []llm.Message, err error) {
+	ctx, span := tracing.Start(ctx, "ContextBuilder.Build")
+	defer func() { tracing.End(span, err) }()
+
+	summary, through := latestSummary(history)
+	var pinned, turns []repo.Message
+	for _, m := range history {
+		switch {
+		case isSummary(m):
+		case m.Seq <= through:
+			if Role(m.Role) == RoleSystem {
+				pinned = append(pinned, m)
+			}
+		default:
+			turns = append(turns, m)
+		}
+	}
+
+	msgs := b.assemble(system, summary, pinned, turns)
+	if b.counter.CountTokens(msgs) <= b.budget {
+		return msgs, nil
+	}
+
+	// summarize what does not fit, keeping a quarter of the budget for the
+	// summary itself
+	summaryTokens := b.budget / 4
+	cut := b.cut(system, pinned, turns, b.budget-summaryTokens)
+	if cut == 0 {
+		return msgs, nil // the recent turns alone are over budget; nothing left to condense
+	}
+	for _, m := range turns[:cut] {
+		if Role(m.Role) == RoleSystem {
+			pinned = append(pinned, m)
+		}
+	}
+	summary, err = b.summarize(ctx, summary, turns[:cut], summaryTokens)
+	if err != nil {
+		return nil, err
+	}
+	through = turns[cut-1].Seq
+	if _, err := b.messages.Append(ctx, sessionID, RoleSystem, summary, "", map[string]any{"summary": true, "through_seq": through}); err != nil {
+		return nil, err
+	}
+	return b.assemble(system, summary, pinned, turns[cut:]), nil
+}
+
+// cut returns the index of the first turn to keep: the earliest turn
+// start from which the rest fits within budget, but never later than the
+// start of the last recentTurns turns.
+func (b *ContextBuilder) cut(system string, pinned, turns []repo.Message, budget int) int {
+	var starts []int
+	for i, m := range turns {
+		if i == 0 || Role(m.Role) == RoleUser {
+			starts = append(starts, i)
+		}
+	}
+	if len(starts) <= b.recentTurns {
+		return 0
+	}
+	latest := starts[len(starts)-b.recentTurns]
+	for _, i := range starts[1:] {
+		if i >= latest {
+			break
+		}
+		kept := systemOnly(append(append([]repo.Message(nil), pinned...), turns[:i]...))
+		if b.counter.CountTokens(b.assemble(system, "", kept, turns[i:])) <= budget {
+			return i
+		}
+	}
+	return latest
+}
+
+func (b *ContextBuilder) summarize(ctx context.Context, previous string, turns []repo.Message, maxTokens int) (string, error) {
+	var sb strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&sb, "Summary so far:\n%s\n\n", previous)
+	}
+	sb.WriteString("Conversation:\n")
+	for _, m := range turns {
+		switch Role(m.Role) {
+		case RoleUser:
+			fmt.Fprintf(&sb, "Visitor: %s\n", m.Content)
+		case RoleAssistant:
+			fmt.Fprintf(&sb, "Assistant: %s\n", m.Content)
+		case RoleTool:
+			fmt.Fprintf(&sb, "Tool %s: %s\n", m.ToolName, m.Content)
+		}
+	}
+	resp, err := b.provider.Complete(ctx, llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: summaryPrompt},
+			{Role: llm.RoleUser, Content: sb.String()},
+		},
+		MaxTokens: maxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("summarize: %w", err)
+	}
+	s := strings.TrimSpace(resp.Message.Content)
+	if s == "" {
+		return "", fmt.Errorf("summarize: model returned no content (finish reason %q)", resp.FinishReason)
+	}
+	return s, nil
+}
+
+// assemble is the prompt for the kept messages: system prompt, summary,
+// system messages from before the cut, then the kept turns.
+func (b *ContextBuilder) assemble(system, summary string, pinned, turns []repo.Message) []llm.Message {
+	out := prompt(system, append(append(make([]repo.Message, 0, len(pinned)+len(turns)), pinned...), turns...))
+	if summary == "" {
+		return out
+	}
+	at := 0
+	if system != "" {
+		at = 1
+	}
+	s := llm.Message{Role: llm.RoleSystem, Content: "Summary of the conversation so far:\n" + summary}
+	return append(out[:at], append([]llm.Message{s}, out[at:]...)...)
+}
+
+func systemOnly(msgs []repo.Message) []repo.Message {
+	out := msgs[:0]
+	for _, m := range msgs {
+		if Role(m.Role) == RoleSystem {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// isSummary reports whether m is a summary stored by a ContextBuilder.
+func isSummary(m repo.Message) bool {
+	s, _ := m.ToolData["summary"].(bool)
+	return Role(m.Role) == RoleSystem && s
+}
+
+// latestSummary returns the newest summary and the seq of the last message
+// it covers.
+func latestSummary(history []repo.Message) (string, int64) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if m := history[i]; isSummary(m) {
+			// jsonb hands numbers back as float64
+			switch n := m.ToolData["through_seq"].(type) {
+			case float64:
+				return m.Content, int64(n)
+			case int64:
+				return m.Content, n
+			}
+			return m.Content, 0
+		}
+	}
+	return "", 0
+}
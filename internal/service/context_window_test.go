@@ -0,0 +1,147 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/llm"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+)
+
+// wordCounter makes budgets easy to reason about: one token per word plus
+// one per message.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(msgs []llm.Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += 1 + len(strings.Fields(m.Content))
+	}
+	return n
+}
+
+// chat returns turns user/assistant pairs of ten words per message.
+func chat(turns int) *replySessions {
+	s := &replySessions{}
+	for i := 1; i <= turns; i++ {
+		s.say("user", fmt.Sprintf("question %d one two three four five six seven eight", i))
+		s.say("assistant", fmt.Sprintf("answer %d one two three four five six seven eight", i))
+	}
+	return s
+}
+
+func TestContextBuilder_KeepsEverythingWithinBudget(t *testing.T) {
+	sessions := chat(3)
+	provider := llm.NewScripted()
+	b := service.NewContextBuilder(provider, service.NewMessageService(newFakeMsgRepo(), nil)).
+		WithCounter(wordCounter{}).WithBudget(100)
+
+	msgs, err := b.Build(context.Background(), "s1", "Be brief.", sessions.msgs)
+	require.NoError(t, err)
+	require.Len(t, msgs, 7)
+	require.Equal(t, llm.Message{Role: llm.RoleSystem, Content: "Be brief."}, msgs[0])
+	require.Empty(t, provider.Requests())
+}
+
+func TestContextBuilder_SummarizesOlderTurns(t *testing.T) {
+	sessions := chat(5)
+	msgRepo := newFakeMsgRepo()
+	provider := llm.NewScripted(llm.Response{Message: llm.Message{Content: "Visitor asked five things."}})
+	// 5 turns of 22 tokens plus the system prompt (3) is 113; 80 leaves 60
+	// for turns after the summary's share, so two turns stay
+	b := service.NewContextBuilder(provider, service.NewMessageService(msgRepo, nil)).
+		WithCounter(wordCounter{}).WithBudget(80).WithRecentTurns(1)
+
+	msgs, err := b.Build(context.Background(), "s1", "Be brief.", sessions.msgs)
+	require.NoError(t, err)
+	require.Len(t, msgs, 6)
+	require.Equal(t, "Be brief.", msgs[0].Content)
+	require.Equal(t, llm.Message{Role: llm.RoleSystem, Content: "Summary of the conversation so far:\nVisitor asked five things."}, msgs[1])
+	require.True(t, strings.HasPrefix(msgs[2].Content, "question 4 "))
+	require.LessOrEqual(t, wordCounter{}.CountTokens(msgs), 80)
+
+	require.Len(t, msgRepo.inserted, 1)
+	stored := msgRepo.inserted[0]
+	require.Equal(t, service.RoleSystem, stored.Role)
+	require.Equal(t, "Visitor asked five things.", stored.Content)
+	require.Equal(t, map[string]any{"summary": true, "through_seq": int64(6)}, stored.ToolData)
+
+	reqs := provider.Requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, 20, reqs[0].MaxTokens)
+	transcript := reqs[0].Messages[1].Content
+	require.Contains(t, transcript, "Visitor: question 1 ")
+	require.Contains(t, transcript, "Assistant: answer 3 ")
+	require.NotContains(t, transcript, "question 4")
+}
+
+func TestContextBuilder_StartsFromStoredSummary(t *testing.T) {
+	sessions := chat(3)
+	sessions.msgs = append(sessions.msgs, repo.Message{Seq: 7, SessionID: "s1", Role: "system", Content: "Earlier: pricing.",
+		ToolData: map[string]any{"summary": true, "through_seq": float64(4)}})
+	sessions.say("user", "and delivery?")
+	provider := llm.NewScripted()
+	b := service.NewContextBuilder(provider, service.NewMessageService(newFakeMsgRepo(), nil)).WithCounter(wordCounter{})
+
+	msgs, err := b.Build(context.Background(), "s1", "", sessions.msgs)
+	require.NoError(t, err)
+	require.Equal(t, []llm.Message{
+		{Role: llm.RoleSystem, Content: "Summary of the conversation so far:\nEarlier: pricing."},
+		{Role: llm.RoleUser, Content: "question 3 one two three four five six seven eight"},
+		{Role: llm.RoleAssistant, Content: "answer 3 one two three four five six seven eight"},
+		{Role: llm.RoleUser, Content: "and delivery?"},
+	}, msgs)
+	require.Empty(t, provider.Requests())
+}
+
+func TestContextBuilder_NeverCutsRecentTurns(t *testing.T) {
+	sessions := chat(2)
+	provider := llm.NewScripted()
+	b := service.NewContextBuilder(provider, service.NewMessageService(newFakeMsgRepo(), nil)).
+		WithCounter(wordCounter{}).WithBudget(10).WithRecentTurns(2)
+
+	msgs, err := b.Build(context.Background(), "s1", "", sessions.msgs)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4, "over budget, but both turns are recent")
+	require.Empty(t, provider.Requests())
+}
+
+func TestReply_SummarizesLongSessions(t *testing.T) {
+	sessions := chat(5)
+	sessions.say("user", "one more question")
+	msgRepo := newFakeMsgRepo()
+	messages := service.NewMessageService(msgRepo, nil)
+	provider := llm.NewScripted(
+		llm.Response{Message: llm.Message{Content: "Visitor asked five things."}},
+		llm.Response{Message: llm.Message{Content: "Sure."}},
+	)
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, messages).
+		WithContextWindow(service.NewContextBuilder(provider, messages).WithCounter(wordCounter{}).WithBudget(60).WithRecentTurns(1))
+
+	m, replied, err := svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Equal(t, "Sure.", m.Content)
+	require.Len(t, msgRepo.inserted, 2)
+	require.Equal(t, service.RoleSystem, msgRepo.inserted[0].Role)
+
+	reply := provider.Requests()[1].Messages
+	require.Equal(t, "Summary of the conversation so far:\nVisitor asked five things.", reply[0].Content)
+	require.Equal(t, llm.Message{Role: llm.RoleUser, Content: "one more question"}, reply[len(reply)-1])
+
+	// a retry after the summary was stored still answers the user
+	sessions.msgs = append(sessions.msgs, repo.Message{Seq: 12, SessionID: "s1", Role: "system",
+		Content: "Visitor asked five things.", ToolData: map[string]any{"summary": true, "through_seq": float64(8)}})
+	provider = llm.NewScripted(llm.Response{Message: llm.Message{Content: "Sure."}})
+	svc = service.NewReplyService(sessions, fakeVersions{}, provider, messages).
+		WithContextWindow(service.NewContextBuilder(provider, messages).WithCounter(wordCounter{}).WithBudget(60).WithRecentTurns(1))
+	_, replied, err = svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Len(t, provider.Requests(), 1, "the stored summary is reused")
+}
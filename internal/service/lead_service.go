@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+	"gochatbot/internal/validate"
+)
+
+type LeadRepo interface {
+	ListLeads(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) ([]repo.Lead, pagination.Page, error)
+	GetLead(ctx context.Context, tenantID, leadID string) (repo.Lead, error)
+	UpdateLead(ctx context.Context, tenantID, leadID string, u repo.LeadUpdate, ifRevision int64) (repo.Lead, error)
+	StreamLeads(ctx context.Context, tenantID string, f pagination.Filter, fn func(repo.Lead) error) error
+	LeadFieldNames(ctx context.Context, tenantID string) ([]string, error)
+}
+
+// capturedFields are the custom fields capture_lead_field records; every
+// export has columns for them.
+var capturedFields = []string{"company", "note"}
+
+type LeadService struct {
+	repo LeadRepo
+}
+
+func NewLeadService(r LeadRepo) *LeadService {
+	return &LeadService{repo: r}
+}
+
+func (s *LeadService) ListLeads(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) (_ httpapi.ListLeadsResult, err error) {
+	ctx, span := tracing.Start(ctx, "LeadService.ListLeads")
+	defer func() { tracing.End(span, err) }()
+
+	items, page, err := s.repo.ListLeads(ctx, tenantID, limit, f, cursor)
+	if err != nil {
+		return httpapi.ListLeadsResult{}, err
+	}
+
+	out := make([]httpapi.Lead, 0, len(items))
+	for _, l := range items {
+		out = append(out, leadDTO(l))
+	}
+	return httpapi.ListLeadsResult{Items: out, Page: page}, nil
+}
+
+func (s *LeadService) GetLead(ctx context.Context, tenantID, leadID string) (_ httpapi.Lead, err error) {
+	ctx, span := tracing.Start(ctx, "LeadService.GetLead")
+	defer func() { tracing.End(span, err) }()
+
+	l, err := s.repo.GetLead(ctx, tenantID, leadID)
+	if err != nil {
+		return httpapi.Lead{}, err
+	}
+	return leadDTO(l), nil
+}
+
+// UpdateLead normalizes contact fields the same way capture_lead_field does,
+// so edited and captured leads look alike. An empty email or phone clears it.
+func (s *LeadService) UpdateLead(ctx context.Context, tenantID, leadID string, u httpapi.LeadUpdate, ifRevision int64) (_ httpapi.Lead, err error) {
+	ctx, span := tracing.Start(ctx, "LeadService.UpdateLead")
+	defer func() { tracing.End(span, err) }()
+
+	upd := repo.LeadUpdate{Fields: u.Fields}
+	if u.Name != nil {
+		name := trim(*u.Name)
+		upd.Name = &name
+	}
+	if upd.Email, err = normalizeOptional(u.Email, validate.NormalizeEmail); err != nil {
+		return httpapi.Lead{}, err
+	}
+	if upd.Phone, err = normalizeOptional(u.Phone, validate.NormalizePhone); err != nil {
+		return httpapi.Lead{}, err
+	}
+	if u.Status != nil {
+		switch LeadStatus(*u.Status) {
+		case LeadNew, LeadExported, LeadFailed:
+			upd.Status = u.Status
+		default:
+			return httpapi.Lead{}, domain.ErrInvalidLeadStatus
+		}
+	}
+
+	l, err := s.repo.UpdateLead(ctx, tenantID, leadID, upd, ifRevision)
+	if err != nil {
+		return httpapi.Lead{}, err
+	}
+	return leadDTO(l), nil
+}
+
+// LeadFields is capturedFields followed by what the tenant's templates
+// declare, minus the contact fields that have columns of their own.
+func (s *LeadService) LeadFields(ctx context.Context, tenantID string) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "LeadService.LeadFields")
+	defer func() { tracing.End(span, err) }()
+
+	declared, err := s.repo.LeadFieldNames(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]string(nil), capturedFields...)
+	for _, name := range declared {
+		switch {
+		case name == "name", name == "email", name == "phone":
+		case slices.Contains(out, name):
+		default:
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+func (s *LeadService) ExportLeads(ctx context.Context, tenantID string, f pagination.Filter, fn func(httpapi.Lead) error) (err error) {
+	ctx, span := tracing.Start(ctx, "LeadService.ExportLeads")
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.StreamLeads(ctx, tenantID, f, func(l repo.Lead) error {
+		return fn(leadDTO(l))
+	})
+}
+
+// normalizeOptional applies norm to a set, non-blank value; blank stays
+// blank (clearing the field) and nil stays nil.
+func normalizeOptional(v *string, norm func(string) (string, error)) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	s := trim(*v)
+	if s == "" {
+		return &s, nil
+	}
+	n, err := norm(s)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func leadDTO(l repo.Lead) httpapi.Lead {
+	fields := l.Fields
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	return httpapi.Lead{
+		ID: l.ID, TenantID: l.TenantID, SessionID: l.SessionID,
+		Name: l.Name, Email: l.Email, Phone: l.Phone, Fields: fields,
+		TemplateID: l.TemplateID, TemplateVersion: l.TemplateVersion,
+		Status: l.Status, CreatedAt: l.CreatedAt, UpdatedAt: l.UpdatedAt, Revision: l.Revision,
+	}
+}
@@ -0,0 +1,86 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/pagination"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+)
+
+type fakeLeadRepo struct {
+	updated  *repo.LeadUpdate
+	declared []string
+}
+
+func (f *fakeLeadRepo) ListLeads(ctx context.Context, tenantID string, limit int, _ pagination.Filter, cursor *pagination.Cursor) ([]repo.Lead, pagination.Page, error) {
+	return []repo.Lead{{ID: "l1", TenantID: tenantID, Status: repo.LeadNew}}, pagination.Page{}, nil
+}
+
+func (f *fakeLeadRepo) GetLead(ctx context.Context, tenantID, leadID string) (repo.Lead, error) {
+	return repo.Lead{}, domain.ErrLeadNotFound
+}
+
+func (f *fakeLeadRepo) UpdateLead(ctx context.Context, tenantID, leadID string, u repo.LeadUpdate, ifRevision int64) (repo.Lead, error) {
+	f.updated = &u
+	return repo.Lead{ID: leadID, TenantID: tenantID, Status: repo.LeadNew, Revision: ifRevision + 1}, nil
+}
+
+func (f *fakeLeadRepo) StreamLeads(ctx context.Context, tenantID string, _ pagination.Filter, fn func(repo.Lead) error) error {
+	return fn(repo.Lead{ID: "l1", TenantID: tenantID, Status: repo.LeadNew})
+}
+
+func (f *fakeLeadRepo) LeadFieldNames(ctx context.Context, tenantID string) ([]string, error) {
+	return f.declared, nil
+}
+
+func ptr(s string) *string { return &s }
+
+func TestLeadService_UpdateNormalizesContact(t *testing.T) {
+	r := &fakeLeadRepo{}
+	svc := service.NewLeadService(r)
+
+	l, err := svc.UpdateLead(context.Background(), "t1", "l1", httpapi.LeadUpdate{
+		Name:  ptr("  Ann "),
+		Email: ptr(" Ann@Example.COM"),
+		Phone: ptr(""),
+	}, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), l.Revision)
+	require.Equal(t, "Ann", *r.updated.Name)
+	require.Equal(t, "ann@example.com", *r.updated.Email)
+	require.Equal(t, "", *r.updated.Phone, "blank clears the phone")
+	require.Nil(t, r.updated.Status)
+	require.NotNil(t, l.Fields, "fields always serialize as an object")
+}
+
+func TestLeadService_UpdateRejectsInvalidValues(t *testing.T) {
+	r := &fakeLeadRepo{}
+	svc := service.NewLeadService(r)
+	ctx := context.Background()
+
+	_, err := svc.UpdateLead(ctx, "t1", "l1", httpapi.LeadUpdate{Email: ptr("nope")}, 0)
+	require.ErrorIs(t, err, domain.ErrInvalidEmail)
+	_, err = svc.UpdateLead(ctx, "t1", "l1", httpapi.LeadUpdate{Phone: ptr("12")}, 0)
+	require.ErrorIs(t, err, domain.ErrInvalidPhone)
+	_, err = svc.UpdateLead(ctx, "t1", "l1", httpapi.LeadUpdate{Status: ptr("won")}, 0)
+	require.ErrorIs(t, err, domain.ErrInvalidLeadStatus)
+	require.Nil(t, r.updated, "nothing reached the repo")
+
+	_, err = svc.UpdateLead(ctx, "t1", "l1", httpapi.LeadUpdate{Phone: ptr("(415) 555-2671")}, 0)
+	require.NoError(t, err)
+	require.Equal(t, "+14155552671", *r.updated.Phone)
+}
+
+func TestLeadService_LeadFields(t *testing.T) {
+	svc := service.NewLeadService(&fakeLeadRepo{declared: []string{"budget", "email", "company", "timeline"}})
+
+	fields, err := svc.LeadFields(context.Background(), "t1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"company", "note", "budget", "timeline"}, fields)
+}
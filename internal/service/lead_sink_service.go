@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"gochatbot/internal/repo"
+	"gochatbot/internal/sink"
+	"gochatbot/internal/tracing"
+)
+
+// LeadExportJobKind is the job that pushes a closed session's lead to the
+// tenant's CRM; its payload carries the session_id and lead_id.
+const LeadExportJobKind = "export_lead"
+
+// ExportLeads is the part of *repo.LeadRepo the export job needs.
+type ExportLeads interface {
+	GetLeadBySession(ctx context.Context, sessionID string) (repo.Lead, bool, error)
+	UpdateLead(ctx context.Context, tenantID, leadID string, u repo.LeadUpdate, ifRevision int64) (repo.Lead, error)
+}
+
+// LeadSinkRepo is satisfied by *repo.LeadSinkRepo.
+type LeadSinkRepo interface {
+	GetLeadSink(ctx context.Context, tenantID string) ([]byte, bool, error)
+	SetLeadSink(ctx context.Context, tenantID string, config []byte) error
+	DeleteLeadSink(ctx context.Context, tenantID string) error
+}
+
+// LeadSinkService keeps each tenant's sink config and runs the export job.
+type LeadSinkService struct {
+	leads  ExportLeads
+	sinks  LeadSinkRepo
+	client *http.Client
+}
+
+// NewLeadSinkService pushes with client; nil uses one with
+// sink.DefaultTimeout.
+func NewLeadSinkService(leads ExportLeads, sinks LeadSinkRepo, client *http.Client) *LeadSinkService {
+	return &LeadSinkService{leads: leads, sinks: sinks, client: client}
+}
+
+// GetLeadSink returns the tenant's sink, if it has one.
+func (s *LeadSinkService) GetLeadSink(ctx context.Context, tenantID string) (_ sink.Config, _ bool, err error) {
+	ctx, span := tracing.Start(ctx, "LeadSinkService.GetLeadSink")
+	defer func() { tracing.End(span, err) }()
+
+	raw, ok, err := s.sinks.GetLeadSink(ctx, tenantID)
+	if err != nil || !ok {
+		return sink.Config{}, false, err
+	}
+	c, err := sink.ParseConfig(raw)
+	if err != nil {
+		return sink.Config{}, false, err
+	}
+	return c, true, nil
+}
+
+// SetLeadSink checks config, mapping included, and makes it the tenant's
+// sink. Leads already exported are not pushed again.
+func (s *LeadSinkService) SetLeadSink(ctx context.Context, tenantID string, config []byte) (_ sink.Config, err error) {
+	ctx, span := tracing.Start(ctx, "LeadSinkService.SetLeadSink")
+	defer func() { tracing.End(span, err) }()
+
+	c, err := sink.ParseConfig(config)
+	if err != nil {
+		return sink.Config{}, err
+	}
+	if err := s.sinks.SetLeadSink(ctx, tenantID, config); err != nil {
+		return sink.Config{}, err
+	}
+	return c, nil
+}
+
+func (s *LeadSinkService) DeleteLeadSink(ctx context.Context, tenantID string) (err error) {
+	ctx, span := tracing.Start(ctx, "LeadSinkService.DeleteLeadSink")
+	defer func() { tracing.End(span, err) }()
+
+	return s.sinks.DeleteLeadSink(ctx, tenantID)
+}
+
+// HandleJob runs a LeadExportJobKind job. A lead that is no longer new, or
+// whose tenant has no sink, is left alone. The lead becomes exported once
+// the sink takes it, and failed when the sink rejects it, the stored sink
+// config is unusable (no retry can fix either) or the job runs out of
+// attempts; other errors are returned so the queue retries.
+func (s *LeadSinkService) HandleJob(ctx context.Context, job repo.Job) error {
+	sessionID, _ := job.Payload["session_id"].(string)
+	if sessionID == "" {
+		return fmt.Errorf("export job %s: missing session_id", job.ID)
+	}
+	l, ok, err := s.leads.GetLeadBySession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok || l.Status != repo.LeadNew {
+		return nil
+	}
+
+	raw, ok, err := s.sinks.GetLeadSink(ctx, l.TenantID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil // no CRM configured; the lead stays new for a manual export
+	}
+	c, err := sink.ParseConfig(raw)
+	if err != nil {
+		return s.failLead(ctx, l, err)
+	}
+	dst, err := sink.New(c, s.client)
+	if err != nil {
+		return s.failLead(ctx, l, err)
+	}
+
+	pushErr := dst.Push(ctx, sink.Lead{ID: l.ID, TenantID: l.TenantID, SessionID: l.SessionID, Name: l.Name,
+		Email: l.Email, Phone: l.Phone, Fields: l.Fields, TemplateID: l.TemplateID,
+		TemplateVersion: l.TemplateVersion, CreatedAt: l.CreatedAt})
+
+	status := repo.LeadExported
+	switch {
+	case pushErr == nil:
+	case errors.Is(pushErr, sink.ErrRejected):
+		log.Printf("lead %s: %s sink rejected it: %v", l.ID, c.Kind, pushErr)
+		status, pushErr = repo.LeadFailed, nil
+	case job.Attempts >= job.MaxAttempts:
+		status = repo.LeadFailed
+	default:
+		return fmt.Errorf("lead %s: push: %w", l.ID, pushErr)
+	}
+	if _, err := s.leads.UpdateLead(ctx, l.TenantID, l.ID, repo.LeadUpdate{Status: &status}, 0); err != nil {
+		return err
+	}
+	if pushErr != nil {
+		return fmt.Errorf("lead %s: push: %w", l.ID, pushErr)
+	}
+	return nil
+}
+
+// failLead marks l failed for a reason retrying cannot fix.
+func (s *LeadSinkService) failLead(ctx context.Context, l repo.Lead, reason error) error {
+	log.Printf("lead %s: not exported: %v", l.ID, reason)
+	status := repo.LeadFailed
+	_, err := s.leads.UpdateLead(ctx, l.TenantID, l.ID, repo.LeadUpdate{Status: &status}, 0)
+	return err
+}
@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+)
+
+type fakeExportLeads struct {
+	lead     repo.Lead
+	statuses []string
+	captured repo.Lead // what CreateLeadForSession makes the lead
+}
+
+func (f *fakeExportLeads) CreateLeadForSession(_ context.Context, sessionID string) (repo.Lead, error) {
+	f.lead = f.captured
+	f.lead.SessionID = sessionID
+	return f.lead, nil
+}
+
+func (f *fakeExportLeads) GetLeadBySession(_ context.Context, sessionID string) (repo.Lead, bool, error) {
+	return f.lead, f.lead.SessionID == sessionID, nil
+}
+
+func (f *fakeExportLeads) UpdateLead(_ context.Context, tenantID, leadID string, u repo.LeadUpdate, _ int64) (repo.Lead, error) {
+	f.statuses = append(f.statuses, *u.Status)
+	f.lead.Status = *u.Status
+	return f.lead, nil
+}
+
+type fakeSessionRecords map[string]repo.Session
+
+func (f fakeSessionRecords) CreateSession(_ context.Context, tenantID, templateID string) (repo.Session, error) {
+	s := repo.Session{ID: "s1", TenantID: tenantID, TemplateID: templateID}
+	f[s.ID] = s
+	return s, nil
+}
+
+func (f fakeSessionRecords) GetSession(_ context.Context, sessionID string) (repo.Session, error) {
+	return f[sessionID], nil
+}
+
+func (f fakeSessionRecords) MarkSessionClosed(_ context.Context, sessionID string, closedAt time.Time) error {
+	s := f[sessionID]
+	s.ClosedAt = &closedAt
+	f[sessionID] = s
+	return nil
+}
+
+type fakeSinkRepo map[string][]byte
+
+func (f fakeSinkRepo) GetLeadSink(_ context.Context, tenantID string) ([]byte, bool, error) {
+	c, ok := f[tenantID]
+	return c, ok, nil
+}
+
+func (f fakeSinkRepo) SetLeadSink(_ context.Context, tenantID string, config []byte) error {
+	f[tenantID] = config
+	return nil
+}
+
+func (f fakeSinkRepo) DeleteLeadSink(_ context.Context, tenantID string) error {
+	delete(f, tenantID)
+	return nil
+}
+
+// crm stands in for a remote CRM, answering each push with status.
+type crm struct {
+	*httptest.Server
+	status int
+	bodies []string
+}
+
+func newCRM(t *testing.T) *crm {
+	c := &crm{status: http.StatusCreated}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		c.bodies = append(c.bodies, string(b))
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *crm) config() []byte {
+	return []byte(`{"kind":"rest","rest":{"url":"` + c.URL + `","mapping":["email -> contact.email","fields.company -> contact.company"]}}`)
+}
+
+func exportJob(attempts int) repo.Job {
+	return repo.Job{ID: "j1", Kind: service.LeadExportJobKind, Attempts: attempts, MaxAttempts: 3,
+		Payload: map[string]any{"session_id": "s1", "lead_id": "l1"}}
+}
+
+func newLead() repo.Lead {
+	return repo.Lead{ID: "l1", TenantID: "t1", SessionID: "s1", Email: "ann@example.com",
+		Fields: map[string]any{"company": "Initech"}, Status: repo.LeadNew}
+}
+
+func TestLeadSinkService_HandleJob_Exports(t *testing.T) {
+	remote := newCRM(t)
+	leads := &fakeExportLeads{lead: newLead()}
+	svc := service.NewLeadSinkService(leads, fakeSinkRepo{"t1": remote.config()}, remote.Client())
+	ctx := context.Background()
+
+	require.NoError(t, svc.HandleJob(ctx, exportJob(1)))
+	require.Equal(t, []string{repo.LeadExported}, leads.statuses)
+	require.Len(t, remote.bodies, 1)
+	require.JSONEq(t, `{"contact":{"email":"ann@example.com","company":"Initech"}}`, remote.bodies[0])
+
+	require.NoError(t, svc.HandleJob(ctx, exportJob(1)), "a rerun after success")
+	require.Len(t, remote.bodies, 1, "an exported lead is not pushed again")
+}
+
+func TestLeadSinkService_ClosingASessionPushesItsLead(t *testing.T) {
+	remote := newCRM(t)
+	leads := &fakeExportLeads{captured: repo.Lead{ID: "l1", TenantID: "t1", Email: "ann@example.com",
+		Fields: map[string]any{"company": "Initech"}, Status: repo.LeadNew}}
+	q := &fakeQueue{}
+	sessions := service.NewSessionService(service.StoredSessions(fakeSessionRecords{}, leads), q, nil)
+	export := service.NewLeadSinkService(leads, fakeSinkRepo{"t1": remote.config()}, remote.Client())
+	ctx := context.Background()
+
+	sess, err := sessions.StartSession(ctx, "t1", "")
+	require.NoError(t, err)
+	require.NoError(t, sessions.CloseSession(ctx, "t1", sess.ID))
+
+	require.Len(t, q.jobs, 1)
+	require.Equal(t, service.LeadExportJobKind, q.jobs[0].kind)
+	require.NoError(t, export.HandleJob(ctx, repo.Job{ID: "j1", Kind: q.jobs[0].kind, Payload: q.jobs[0].payload,
+		Attempts: 1, MaxAttempts: 5}))
+	require.Len(t, remote.bodies, 1)
+	require.JSONEq(t, `{"contact":{"email":"ann@example.com","company":"Initech"}}`, remote.bodies[0])
+	require.Equal(t, []string{repo.LeadExported}, leads.statuses)
+}
+
+func TestLeadSinkService_HandleJob_NoSink(t *testing.T) {
+	leads := &fakeExportLeads{lead: newLead()}
+	svc := service.NewLeadSinkService(leads, fakeSinkRepo{}, nil)
+
+	require.NoError(t, svc.HandleJob(context.Background(), exportJob(1)))
+	require.Empty(t, leads.statuses, "the lead stays new")
+}
+
+func TestLeadSinkService_HandleJob_Failures(t *testing.T) {
+	remote := newCRM(t)
+	ctx := context.Background()
+
+	remote.status = http.StatusUnprocessableEntity
+	leads := &fakeExportLeads{lead: newLead()}
+	svc := service.NewLeadSinkService(leads, fakeSinkRepo{"t1": remote.config()}, remote.Client())
+	require.NoError(t, svc.HandleJob(ctx, exportJob(1)), "a rejected lead is not retried")
+	require.Equal(t, []string{repo.LeadFailed}, leads.statuses)
+
+	remote.status = http.StatusServiceUnavailable
+	leads = &fakeExportLeads{lead: newLead()}
+	svc = service.NewLeadSinkService(leads, fakeSinkRepo{"t1": remote.config()}, remote.Client())
+	require.Error(t, svc.HandleJob(ctx, exportJob(1)))
+	require.Empty(t, leads.statuses, "retried while attempts remain")
+	require.Error(t, svc.HandleJob(ctx, exportJob(3)))
+	require.Equal(t, []string{repo.LeadFailed}, leads.statuses, "failed on the last attempt")
+
+	for name, config := range map[string]string{
+		"malformed":    `{"kind":`,
+		"unknown kind": `{"kind":"carrier-pigeon"}`,
+	} {
+		leads = &fakeExportLeads{lead: newLead()}
+		svc = service.NewLeadSinkService(leads, fakeSinkRepo{"t1": []byte(config)}, nil)
+		require.NoError(t, svc.HandleJob(ctx, exportJob(1)), "%s config is not retried", name)
+		require.Equal(t, []string{repo.LeadFailed}, leads.statuses, name)
+	}
+}
+
+func TestLeadSinkService_SetLeadSink(t *testing.T) {
+	sinks := fakeSinkRepo{}
+	svc := service.NewLeadSinkService(&fakeExportLeads{}, sinks, nil)
+	ctx := context.Background()
+
+	_, err := svc.SetLeadSink(ctx, "t1", []byte(`{"kind":"rest","rest":{"url":"https://crm.example.com","mapping":["mail -> email"]}}`))
+	require.ErrorContains(t, err, `unknown lead field "mail"`)
+	require.Empty(t, sinks)
+
+	c, err := svc.SetLeadSink(ctx, "t1", []byte(`{"kind":"rest","rest":{"url":"https://crm.example.com","mapping":["email -> email"]}}`))
+	require.NoError(t, err)
+	require.Equal(t, "https://crm.example.com", c.REST.URL)
+	got, ok, err := svc.GetLeadSink(ctx, "t1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, c, got)
+}
@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"log"
 	"strings"
 	"time"
 
 	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
 )
 
 type Role string
@@ -33,8 +37,9 @@ type MessageRepo interface {
 }
 
 type MessageService struct {
-	repo MessageRepo
-	now  func() time.Time
+	repo  MessageRepo
+	now   func() time.Time
+	queue Queue // nil: user messages get no assistant reply
 }
 
 func NewMessageService(repo MessageRepo, now func() time.Time) *MessageService {
@@ -44,7 +49,16 @@ func NewMessageService(repo MessageRepo, now func() time.Time) *MessageService {
 	return &MessageService{repo: repo, now: now}
 }
 
-func (s *MessageService) Append(ctx context.Context, sessionID string, role Role, content string, toolName string, toolData map[string]any) (Message, error) {
+// WithReplies queues a ReplyJobKind job after every widget message.
+func (s *MessageService) WithReplies(q Queue) *MessageService {
+	s.queue = q
+	return s
+}
+
+func (s *MessageService) Append(ctx context.Context, sessionID string, role Role, content string, toolName string, toolData map[string]any) (_ Message, err error) {
+	ctx, span := tracing.Start(ctx, "MessageService.Append")
+	defer func() { tracing.End(span, err) }()
+
 	if !isValidRole(role) {
 		return Message{}, domain.ErrInvalidRole
 	}
@@ -89,6 +103,56 @@ func (s *MessageService) Append(ctx context.Context, sessionID string, role Role
 	return s.repo.InsertMessage(ctx, msg)
 }
 
+// PostUserMessage stores a message typed into the chat widget. It goes
+// through Append, so the closed-session and empty-message rules apply.
+// The message is kept even if its reply cannot be queued.
+func (s *MessageService) PostUserMessage(ctx context.Context, sessionID, content string) (httpapi.Message, error) {
+	m, err := s.Append(ctx, sessionID, RoleUser, content, "", nil)
+	if err != nil {
+		return httpapi.Message{}, err
+	}
+	if s.queue != nil {
+		if err := s.queue.Enqueue(ctx, ReplyJobKind, map[string]any{"session_id": sessionID}); err != nil {
+			log.Printf("session %s: queue reply: %v", sessionID, err)
+		}
+	}
+	return httpapi.Message{ID: m.ID, SessionID: m.SessionID, Role: string(m.Role), Content: m.Content,
+		ToolName: m.ToolName, ToolData: m.ToolData, CreatedAt: m.CreatedAt}, nil
+}
+
+// SessionStore is the part of *repo.SessionRepo that StoredMessages needs.
+type SessionStore interface {
+	GetSession(ctx context.Context, sessionID string) (repo.Session, error)
+	InsertMessage(ctx context.Context, m repo.Message) (repo.Message, error)
+}
+
+// StoredMessages adapts a SessionStore to MessageRepo.
+func StoredMessages(s SessionStore) MessageRepo {
+	return storedMessages{s}
+}
+
+type storedMessages struct {
+	store SessionStore
+}
+
+func (r storedMessages) IsSessionClosed(ctx context.Context, sessionID string) (bool, error) {
+	sess, err := r.store.GetSession(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	return sess.ClosedAt != nil, nil
+}
+
+func (r storedMessages) InsertMessage(ctx context.Context, msg Message) (Message, error) {
+	m, err := r.store.InsertMessage(ctx, repo.Message{SessionID: msg.SessionID, Role: string(msg.Role), Content: msg.Content,
+		ToolName: msg.ToolName, ToolData: msg.ToolData, CreatedAt: msg.CreatedAt})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{ID: m.ID, SessionID: m.SessionID, Role: Role(m.Role), Content: m.Content,
+		ToolName: m.ToolName, ToolData: m.ToolData, CreatedAt: m.CreatedAt}, nil
+}
+
 func isValidRole(r Role) bool {
 	switch r {
 	case RoleUser, RoleAssistant, RoleSystem, RoleTool:
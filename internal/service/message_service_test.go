@@ -109,3 +109,27 @@ func TestAppend_FailsIfSessionClosed(t *testing.T) {
 	_, err := svc.Append(ctx, "s1", service.RoleUser, "hi", "", nil)
 	require.ErrorIs(t, err, domain.ErrSessionClosed)
 }
+
+func TestPostUserMessage_StoresAsUser(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeMsgRepo()
+	t0 := time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
+	q := &fakeQueue{}
+	svc := service.NewMessageService(repo, func() time.Time { return t0 }).WithReplies(q)
+
+	m, err := svc.PostUserMessage(ctx, "s1", `  "hello"  `)
+	require.NoError(t, err)
+	require.Equal(t, "m1", m.ID)
+	require.Equal(t, "user", m.Role)
+	require.Equal(t, "hello", m.Content)
+	require.Equal(t, t0, m.CreatedAt)
+	require.Equal(t, []job{{kind: service.ReplyJobKind, payload: map[string]any{"session_id": "s1"}}}, q.jobs)
+
+	_, err = svc.PostUserMessage(ctx, "s1", "   ")
+	require.ErrorIs(t, err, domain.ErrEmptyMessage)
+
+	repo.closed["s1"] = true
+	_, err = svc.PostUserMessage(ctx, "s1", "hi")
+	require.ErrorIs(t, err, domain.ErrSessionClosed)
+	require.Len(t, q.jobs, 1, "rejected messages get no reply")
+}
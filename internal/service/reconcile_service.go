@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/reconcile"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+)
+
+// ReconcileJobKind is the job that runs one reconciliation.
+const ReconcileJobKind = "reconcile_legacy"
+
+// maxStoredDiffs bounds one run's rows in reconciliation_diffs; the full
+// count is still recorded on the run.
+const maxStoredDiffs = 1000
+
+// maxLoggedDiffs is how many diffs a run logs individually.
+const maxLoggedDiffs = 10
+
+type ReconcileRepo interface {
+	StartRun(ctx context.Context, source string) (repo.ReconcileRun, error)
+	FinishRun(ctx context.Context, id string, tenantsChecked, templatesChecked, diffCount int, diffs []repo.ReconcileDiff) (repo.ReconcileRun, error)
+	FailRun(ctx context.Context, id, lastErr string) error
+	GetRun(ctx context.Context, id string) (repo.ReconcileRun, error)
+	ListRuns(ctx context.Context, limit int) ([]repo.ReconcileRun, error)
+	ListDiffs(ctx context.Context, runID string, limit int) ([]repo.ReconcileDiff, error)
+}
+
+// OnceQueue is satisfied by *repo.JobRepo.
+type OnceQueue interface {
+	EnqueueOnce(ctx context.Context, kind string, payload map[string]any) (bool, error)
+}
+
+// ReconcileService compares Go's tenants and templates with the legacy
+// source and keeps a report of every run.
+type ReconcileService struct {
+	repo   ReconcileRepo
+	ours   reconcile.Reader
+	legacy reconcile.Reader
+	source string // describes legacy in reports, e.g. "postgres" or "json:/path"
+	queue  OnceQueue
+}
+
+func NewReconcileService(r ReconcileRepo, ours, legacy reconcile.Reader, source string, queue OnceQueue) *ReconcileService {
+	return &ReconcileService{repo: r, ours: ours, legacy: legacy, source: source, queue: queue}
+}
+
+// Run performs one reconciliation. A failed read, or failing to store the
+// report, marks the run failed and returns the error, so the job is retried.
+func (s *ReconcileService) Run(ctx context.Context) (_ httpapi.ReconcileRun, err error) {
+	ctx, span := tracing.Start(ctx, "ReconcileService.Run")
+	defer func() { tracing.End(span, err) }()
+
+	run, err := s.repo.StartRun(ctx, s.source)
+	if err != nil {
+		return httpapi.ReconcileRun{}, err
+	}
+
+	results, err := s.compare(ctx)
+	if err != nil {
+		s.failRun(ctx, run.ID, err)
+		return httpapi.ReconcileRun{}, err
+	}
+
+	checked := map[string]int{}
+	var diffs []repo.ReconcileDiff
+	total := 0
+	for _, res := range results {
+		checked[res.Entity] = res.Checked
+		total += len(res.Diffs)
+		for _, d := range res.Diffs {
+			if len(diffs) == maxStoredDiffs {
+				break
+			}
+			diffs = append(diffs, repo.ReconcileDiff(d))
+		}
+	}
+
+	done, err := s.repo.FinishRun(ctx, run.ID, checked[reconcile.EntityTenant], checked[reconcile.EntityTemplate], total, diffs)
+	if err != nil {
+		s.failRun(ctx, run.ID, err)
+		return httpapi.ReconcileRun{}, err
+	}
+	logRun(done, diffs)
+	return toReconcileRun(done), nil
+}
+
+// failRun marks the run failed, even when ctx is what ended it, so no run
+// is left running.
+func (s *ReconcileService) failRun(ctx context.Context, runID string, cause error) {
+	if err := s.repo.FailRun(context.WithoutCancel(ctx), runID, cause.Error()); err != nil {
+		log.Printf("reconcile: run %s: %v", runID, err)
+	}
+}
+
+func (s *ReconcileService) compare(ctx context.Context) ([]reconcile.Result, error) {
+	out := make([]reconcile.Result, 0, len(reconcile.Entities))
+	for _, entity := range reconcile.Entities {
+		goRecs, err := s.ours.Read(ctx, entity)
+		if err != nil {
+			return nil, fmt.Errorf("read go %ss: %w", entity, err)
+		}
+		legacyRecs, err := s.legacy.Read(ctx, entity)
+		if err != nil {
+			return nil, fmt.Errorf("read legacy %ss: %w", entity, err)
+		}
+		out = append(out, reconcile.Compare(entity, goRecs, legacyRecs))
+	}
+	return out, nil
+}
+
+func logRun(run repo.ReconcileRun, diffs []repo.ReconcileDiff) {
+	log.Printf("reconcile: run %s vs %s: %d tenants, %d templates checked, %d diffs",
+		run.ID, run.Source, run.TenantsChecked, run.TemplatesChecked, run.DiffCount)
+	for i, d := range diffs {
+		if i == maxLoggedDiffs {
+			log.Printf("reconcile: run %s: +%d more", run.ID, run.DiffCount-maxLoggedDiffs)
+			break
+		}
+		if d.Kind == reconcile.KindMismatch {
+			log.Printf("reconcile: %s %s: %s go=%q legacy=%q", d.Entity, d.Key, d.Field, d.Go, d.Legacy)
+		} else {
+			log.Printf("reconcile: %s %s: %s", d.Entity, d.Key, d.Kind)
+		}
+	}
+}
+
+func (s *ReconcileService) TriggerRun(ctx context.Context) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "ReconcileService.TriggerRun")
+	defer func() { tracing.End(span, err) }()
+
+	return s.queue.EnqueueOnce(ctx, ReconcileJobKind, nil)
+}
+
+// Schedule queues a run every interval until ctx is done.
+func (s *ReconcileService) Schedule(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := s.TriggerRun(ctx); err != nil {
+			log.Printf("reconcile: schedule: %v", err)
+		}
+	}
+}
+
+func (s *ReconcileService) ListRuns(ctx context.Context, limit int) (_ []httpapi.ReconcileRun, err error) {
+	ctx, span := tracing.Start(ctx, "ReconcileService.ListRuns")
+	defer func() { tracing.End(span, err) }()
+
+	runs, err := s.repo.ListRuns(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]httpapi.ReconcileRun, 0, len(runs))
+	for _, r := range runs {
+		out = append(out, toReconcileRun(r))
+	}
+	return out, nil
+}
+
+func (s *ReconcileService) GetReport(ctx context.Context, runID string) (_ httpapi.ReconcileReport, err error) {
+	ctx, span := tracing.Start(ctx, "ReconcileService.GetReport")
+	defer func() { tracing.End(span, err) }()
+
+	var run repo.ReconcileRun
+	if runID == "latest" {
+		runs, err := s.repo.ListRuns(ctx, 1)
+		if err != nil {
+			return httpapi.ReconcileReport{}, err
+		}
+		if len(runs) == 0 {
+			return httpapi.ReconcileReport{}, domain.ErrReconcileRunNotFound
+		}
+		run = runs[0]
+	} else {
+		run, err = s.repo.GetRun(ctx, runID)
+		if err != nil {
+			return httpapi.ReconcileReport{}, err
+		}
+	}
+
+	diffs, err := s.repo.ListDiffs(ctx, run.ID, maxStoredDiffs)
+	if err != nil {
+		return httpapi.ReconcileReport{}, err
+	}
+	rep := httpapi.ReconcileReport{Run: toReconcileRun(run), Diffs: make([]httpapi.ReconcileDiff, 0, len(diffs))}
+	for _, d := range diffs {
+		rep.Diffs = append(rep.Diffs, httpapi.ReconcileDiff(d))
+	}
+	return rep, nil
+}
+
+func toReconcileRun(r repo.ReconcileRun) httpapi.ReconcileRun {
+	return httpapi.ReconcileRun{
+		ID:               r.ID,
+		Source:           r.Source,
+		Status:           r.Status,
+		TenantsChecked:   r.TenantsChecked,
+		TemplatesChecked: r.TemplatesChecked,
+		DiffCount:        r.DiffCount,
+		Error:            r.Error,
+		StartedAt:        r.StartedAt,
+		FinishedAt:       r.FinishedAt,
+	}
+}
@@ -0,0 +1,137 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/reconcile"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+)
+
+type fakeReader map[string][]reconcile.Record
+
+func (f fakeReader) Read(_ context.Context, entity string) ([]reconcile.Record, error) {
+	recs, ok := f[entity]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return recs, nil
+}
+
+type fakeReconcileRepo struct {
+	runs      []repo.ReconcileRun
+	diffs     map[string][]repo.ReconcileDiff
+	finishErr error
+}
+
+func (f *fakeReconcileRepo) StartRun(_ context.Context, source string) (repo.ReconcileRun, error) {
+	run := repo.ReconcileRun{ID: "run1", Source: source, Status: "running"}
+	f.runs = append([]repo.ReconcileRun{run}, f.runs...)
+	return run, nil
+}
+
+func (f *fakeReconcileRepo) FinishRun(_ context.Context, id string, tenants, templates, total int, diffs []repo.ReconcileDiff) (repo.ReconcileRun, error) {
+	if f.finishErr != nil {
+		return repo.ReconcileRun{}, f.finishErr
+	}
+	run := &f.runs[0]
+	run.Status, run.TenantsChecked, run.TemplatesChecked, run.DiffCount = "done", tenants, templates, total
+	f.diffs = map[string][]repo.ReconcileDiff{id: diffs}
+	return *run, nil
+}
+
+func (f *fakeReconcileRepo) FailRun(ctx context.Context, _ string, lastErr string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.runs[0].Status, f.runs[0].Error = "failed", lastErr
+	return nil
+}
+
+func (f *fakeReconcileRepo) GetRun(_ context.Context, id string) (repo.ReconcileRun, error) {
+	for _, r := range f.runs {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return repo.ReconcileRun{}, domain.ErrReconcileRunNotFound
+}
+
+func (f *fakeReconcileRepo) ListRuns(_ context.Context, limit int) ([]repo.ReconcileRun, error) {
+	if len(f.runs) < limit {
+		limit = len(f.runs)
+	}
+	return f.runs[:limit], nil
+}
+
+func (f *fakeReconcileRepo) ListDiffs(_ context.Context, runID string, _ int) ([]repo.ReconcileDiff, error) {
+	return f.diffs[runID], nil
+}
+
+func tenantRec(slug, name string) reconcile.Record {
+	return reconcile.Record{Key: slug, Fields: map[string]string{"name": name}}
+}
+
+func TestReconcileService_RunStoresReport(t *testing.T) {
+	r := &fakeReconcileRepo{}
+	ours := fakeReader{
+		reconcile.EntityTenant:   {tenantRec("acme", "Acme"), tenantRec("beta", "Beta")},
+		reconcile.EntityTemplate: {},
+	}
+	legacy := fakeReader{
+		reconcile.EntityTenant:   {tenantRec("acme", "Acme Inc")},
+		reconcile.EntityTemplate: {},
+	}
+	svc := service.NewReconcileService(r, ours, legacy, "json:export.json", nil)
+	ctx := context.Background()
+
+	run, err := svc.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "done", run.Status)
+	require.Equal(t, 2, run.TenantsChecked)
+	require.Equal(t, 2, run.DiffCount)
+
+	rep, err := svc.GetReport(ctx, "latest")
+	require.NoError(t, err)
+	require.Equal(t, "json:export.json", rep.Run.Source)
+	require.Len(t, rep.Diffs, 2)
+	require.Equal(t, reconcile.KindMismatch, rep.Diffs[0].Kind)
+	require.Equal(t, "Acme Inc", rep.Diffs[0].Legacy)
+	require.Equal(t, reconcile.KindMissingInLegacy, rep.Diffs[1].Kind)
+	require.Equal(t, "beta", rep.Diffs[1].Key)
+}
+
+func TestReconcileService_FailedReadMarksRunFailed(t *testing.T) {
+	r := &fakeReconcileRepo{}
+	ours := fakeReader{reconcile.EntityTenant: {}, reconcile.EntityTemplate: {}}
+	svc := service.NewReconcileService(r, ours, fakeReader{}, "postgres", nil)
+
+	_, err := svc.Run(context.Background())
+	require.ErrorContains(t, err, "read legacy tenants")
+	require.Equal(t, "failed", r.runs[0].Status)
+	require.Contains(t, r.runs[0].Error, "connection refused")
+}
+
+func TestReconcileService_FailedFinishMarksRunFailed(t *testing.T) {
+	r := &fakeReconcileRepo{finishErr: errors.New("conn closed")}
+	both := fakeReader{reconcile.EntityTenant: {}, reconcile.EntityTemplate: {}}
+	svc := service.NewReconcileService(r, both, both, "postgres", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := svc.Run(ctx)
+	require.ErrorContains(t, err, "conn closed")
+	require.Equal(t, "failed", r.runs[0].Status, "not left running")
+	require.Equal(t, "conn closed", r.runs[0].Error)
+}
+
+func TestReconcileService_LatestWithoutRuns(t *testing.T) {
+	svc := service.NewReconcileService(&fakeReconcileRepo{}, fakeReader{}, fakeReader{}, "postgres", nil)
+	_, err := svc.GetReport(context.Background(), "latest")
+	require.ErrorIs(t, err, domain.ErrReconcileRunNotFound)
+}
@@ -0,0 +1,17 @@
+package service
+
+// Recorder receives domain events worth counting. *metrics.Metrics satisfies it;
+// services default to a no-op so tests and tools don't need to care.
+type Recorder interface {
+	SessionStarted()
+	SessionClosed()
+	LeadCreated()
+	TemplatePublished()
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) SessionStarted()    {}
+func (nopRecorder) SessionClosed()     {}
+func (nopRecorder) LeadCreated()       {}
+func (nopRecorder) TemplatePublished() {}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/llm"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tools"
+	"gochatbot/internal/tracing"
+)
+
+// ReplyJobKind is the job that answers a session's latest user message.
+const ReplyJobKind = "assistant_reply"
+
+// historyBatch is how many messages one read fetches while loading a
+// session's history.
+const historyBatch = 500
+
+// maxToolRounds bounds how often one reply may call tools; the model then
+// has to answer without them.
+const maxToolRounds = 4
+
+type ReplyRepo interface {
+	GetSession(ctx context.Context, sessionID string) (repo.Session, error)
+	ListMessagesAfter(ctx context.Context, sessionID string, afterSeq int64, limit int) ([]repo.Message, error)
+}
+
+// PublishedVersions is satisfied by *repo.TemplateRepo.
+type PublishedVersions interface {
+	GetPublishedVersion(ctx context.Context, templateID string) (repo.TemplateVersion, error)
+}
+
+// Typer announces a reply in progress; *SessionEvents satisfies it.
+type Typer interface {
+	Typing(ctx context.Context, sessionID string) error
+}
+
+type nopTyper struct{}
+
+func (nopTyper) Typing(context.Context, string) error { return nil }
+
+// ToolRunner is satisfied by *tools.Registry.
+type ToolRunner interface {
+	Available(ctx context.Context, tenantID string) ([]llm.Tool, error)
+	Execute(ctx context.Context, call llm.ToolCall, c tools.Call) (tools.Result, error)
+}
+
+// ReplyService writes the assistant's answer to a session: the template's
+// system prompt and the session's history go to the model, and the reply
+// is stored through MessageService like any other message.
+type ReplyService struct {
+	sessions  ReplyRepo
+	templates PublishedVersions
+	provider  llm.Provider
+	messages  *MessageService
+	typer     Typer
+	tools     ToolRunner      // nil: the model is offered no tools
+	window    *ContextBuilder // nil: the whole history is sent
+}
+
+func NewReplyService(sessions ReplyRepo, templates PublishedVersions, provider llm.Provider, messages *MessageService) *ReplyService {
+	return &ReplyService{sessions: sessions, templates: templates, provider: provider, messages: messages, typer: nopTyper{}}
+}
+
+func (s *ReplyService) WithTyper(t Typer) *ReplyService {
+	if t != nil {
+		s.typer = t
+	}
+	return s
+}
+
+// WithTools lets the model call the tools its tenant is allowed; each
+// call and its result are stored as a tool message.
+func (s *ReplyService) WithTools(r ToolRunner) *ReplyService {
+	s.tools = r
+	return s
+}
+
+// WithContextWindow fits long sessions into the model's context by
+// summarizing older turns.
+func (s *ReplyService) WithContextWindow(b *ContextBuilder) *ReplyService {
+	s.window = b
+	return s
+}
+
+// Reply answers the session when its last message is from the user, or
+// is a tool result still waiting for the model, and reports whether it did.
+// A closed session, or one already answered (as when two reply jobs race),
+// is left alone. Tool results are stored as they come in, so a retried
+// job resumes after the last one.
+func (s *ReplyService) Reply(ctx context.Context, sessionID string) (_ Message, replied bool, err error) {
+	ctx, span := tracing.Start(ctx, "ReplyService.Reply")
+	defer func() { tracing.End(span, err) }()
+
+	sess, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return Message{}, false, err
+	}
+	if sess.ClosedAt != nil {
+		return Message{}, false, nil
+	}
+	history, err := s.history(ctx, sessionID)
+	if err != nil {
+		return Message{}, false, err
+	}
+	if last := lastTurnRole(history); last != RoleUser && last != RoleTool {
+		return Message{}, false, nil
+	}
+	template, err := s.templateContent(ctx, sess.TemplateID)
+	if err != nil {
+		return Message{}, false, err
+	}
+	system, err := systemPrompt(template)
+	if err != nil {
+		return Message{}, false, fmt.Errorf("template %s: %w", sess.TemplateID, err)
+	}
+	var offered []llm.Tool
+	if s.tools != nil {
+		if offered, err = s.tools.Available(ctx, sess.TenantID); err != nil {
+			return Message{}, false, err
+		}
+	}
+
+	if err := s.typer.Typing(ctx, sessionID); err != nil {
+		log.Printf("reply %s: typing: %v", sessionID, err)
+	}
+	msgs := prompt(system, history)
+	if s.window != nil {
+		if msgs, err = s.window.Build(ctx, sessionID, system, history); err != nil {
+			return Message{}, false, err
+		}
+	}
+	for round := 0; ; round++ {
+		req := llm.Request{Messages: msgs}
+		if round < maxToolRounds {
+			req.Tools = offered
+		}
+		resp, err := s.provider.Complete(ctx, req)
+		if err != nil {
+			return Message{}, false, err
+		}
+		calls := resp.Message.ToolCalls
+		if len(calls) == 0 || len(req.Tools) == 0 {
+			if resp.Message.Content == "" {
+				return Message{}, false, fmt.Errorf("reply %s: model returned no content (finish reason %q)", sessionID, resp.FinishReason)
+			}
+			m, err := s.messages.Append(ctx, sessionID, RoleAssistant, resp.Message.Content, "", nil)
+			if err != nil {
+				return Message{}, false, err
+			}
+			return m, true, nil
+		}
+
+		// text sent alongside tool calls is dropped: the answer comes after
+		msgs = append(msgs, llm.Message{Role: llm.RoleAssistant, ToolCalls: calls})
+		for _, call := range calls {
+			res, err := s.tools.Execute(ctx, call, tools.Call{TenantID: sess.TenantID, SessionID: sessionID, Template: template})
+			if err != nil {
+				return Message{}, false, err
+			}
+			if _, err := s.messages.Append(ctx, sessionID, RoleTool, res.Content(), call.Name, toolData(call, res)); err != nil {
+				return Message{}, false, err
+			}
+			msgs = append(msgs, llm.Message{Role: llm.RoleTool, ToolCallID: call.ID, Content: res.Content()})
+		}
+	}
+}
+
+// HandleJob runs a ReplyJobKind job; its payload carries the session_id.
+func (s *ReplyService) HandleJob(ctx context.Context, job repo.Job) error {
+	sessionID, _ := job.Payload["session_id"].(string)
+	if sessionID == "" {
+		return fmt.Errorf("reply job %s: missing session_id", job.ID)
+	}
+	_, _, err := s.Reply(ctx, sessionID)
+	if errors.Is(err, domain.ErrSessionNotFound) || errors.Is(err, domain.ErrSessionClosed) {
+		return nil // nothing left to answer; retrying will not change that
+	}
+	return err
+}
+
+func (s *ReplyService) history(ctx context.Context, sessionID string) ([]repo.Message, error) {
+	var all []repo.Message
+	var after int64
+	for {
+		msgs, err := s.sessions.ListMessagesAfter(ctx, sessionID, after, historyBatch)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, msgs...)
+		if len(msgs) < historyBatch {
+			return all, nil
+		}
+		after = msgs[len(msgs)-1].Seq
+	}
+}
+
+// lastTurnRole is the role of the newest message other than a stored
+// summary; "" for an empty history.
+func lastTurnRole(history []repo.Message) Role {
+	for i := len(history) - 1; i >= 0; i-- {
+		if !isSummary(history[i]) {
+			return Role(history[i].Role)
+		}
+	}
+	return ""
+}
+
+// templateContent reads the template's published version. A session
+// without a template, or whose template has nothing published yet, gets
+// none.
+func (s *ReplyService) templateContent(ctx context.Context, templateID string) (json.RawMessage, error) {
+	if templateID == "" {
+		return nil, nil
+	}
+	v, err := s.templates.GetPublishedVersion(ctx, templateID)
+	if errors.Is(err, domain.ErrVersionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v.Content, nil
+}
+
+// systemPrompt is the template content's "system_prompt".
+func systemPrompt(template json.RawMessage) (string, error) {
+	if len(template) == 0 {
+		return "", nil
+	}
+	var content struct {
+		SystemPrompt string `json:"system_prompt"`
+	}
+	if err := json.Unmarshal(template, &content); err != nil {
+		return "", err
+	}
+	return content.SystemPrompt, nil
+}
+
+// toolData is what a tool message keeps of its call: the id that ties it
+// to the model's request, the arguments, and the output or error.
+func toolData(call llm.ToolCall, res tools.Result) map[string]any {
+	data := map[string]any{"call_id": call.ID}
+	var args any
+	if json.Unmarshal(call.Arguments, &args) == nil {
+		data["arguments"] = args
+	} else {
+		data["arguments"] = string(call.Arguments)
+	}
+	if res.Error != "" {
+		data["error"] = res.Error
+	} else {
+		data["result"] = res.Output
+	}
+	return data
+}
+
+// prompt turns stored messages into model turns. A run of tool messages
+// becomes the assistant turn that asked for the calls followed by their
+// results, which is the order the model accepts them in.
+func prompt(system string, history []repo.Message) []llm.Message {
+	out := make([]llm.Message, 0, len(history)+1)
+	if system != "" {
+		out = append(out, llm.Message{Role: llm.RoleSystem, Content: system})
+	}
+	for i := 0; i < len(history); i++ {
+		m := history[i]
+		switch Role(m.Role) {
+		case RoleUser:
+			out = append(out, llm.Message{Role: llm.RoleUser, Content: m.Content})
+		case RoleAssistant:
+			out = append(out, llm.Message{Role: llm.RoleAssistant, Content: m.Content})
+		case RoleSystem:
+			if !isSummary(m) { // summaries are placed by ContextBuilder
+				out = append(out, llm.Message{Role: llm.RoleSystem, Content: m.Content})
+			}
+		case RoleTool:
+			var calls []llm.ToolCall
+			var results []llm.Message
+			for ; i < len(history) && Role(history[i].Role) == RoleTool; i++ {
+				call, ok := storedCall(history[i])
+				if !ok {
+					continue // recorded without a call id; nothing to pair it with
+				}
+				calls = append(calls, call)
+				results = append(results, llm.Message{Role: llm.RoleTool, ToolCallID: call.ID, Content: history[i].Content})
+			}
+			i--
+			if len(calls) > 0 {
+				out = append(out, llm.Message{Role: llm.RoleAssistant, ToolCalls: calls})
+				out = append(out, results...)
+			}
+		}
+	}
+	return out
+}
+
+func storedCall(m repo.Message) (llm.ToolCall, bool) {
+	id, _ := m.ToolData["call_id"].(string)
+	if id == "" {
+		return llm.ToolCall{}, false
+	}
+	args := json.RawMessage("{}")
+	switch a := m.ToolData["arguments"].(type) {
+	case string:
+		args = json.RawMessage(a)
+	case nil:
+	default:
+		if b, err := json.Marshal(a); err == nil {
+			args = b
+		}
+	}
+	return llm.ToolCall{ID: id, Name: m.ToolName, Arguments: args}, true
+}
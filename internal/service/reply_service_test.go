@@ -0,0 +1,236 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/llm"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+	"gochatbot/internal/tools"
+)
+
+type fakeVersions map[string]string
+
+func (f fakeVersions) GetPublishedVersion(_ context.Context, templateID string) (repo.TemplateVersion, error) {
+	content, ok := f[templateID]
+	if !ok {
+		return repo.TemplateVersion{}, domain.ErrVersionNotFound
+	}
+	return repo.TemplateVersion{TemplateID: templateID, Version: 1, Content: []byte(content)}, nil
+}
+
+type fakeTyper struct{ sessions []string }
+
+func (f *fakeTyper) Typing(_ context.Context, sessionID string) error {
+	f.sessions = append(f.sessions, sessionID)
+	return nil
+}
+
+// replySessions serves one session, s1, from a fixed history.
+type replySessions struct {
+	templateID string
+	closed     bool
+	msgs       []repo.Message
+}
+
+func (r *replySessions) GetSession(_ context.Context, id string) (repo.Session, error) {
+	if id != "s1" {
+		return repo.Session{}, domain.ErrSessionNotFound
+	}
+	s := repo.Session{ID: id, TenantID: "t1", TemplateID: r.templateID}
+	if r.closed {
+		now := time.Now()
+		s.ClosedAt = &now
+	}
+	return s, nil
+}
+
+func (r *replySessions) ListMessagesAfter(_ context.Context, _ string, after int64, limit int) ([]repo.Message, error) {
+	var out []repo.Message
+	for _, m := range r.msgs {
+		if m.Seq > after && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (r *replySessions) say(role, content string) {
+	r.msgs = append(r.msgs, repo.Message{Seq: int64(len(r.msgs) + 1), SessionID: "s1", Role: role, Content: content})
+}
+
+func TestReply_PromptsWithTemplateAndHistory(t *testing.T) {
+	sessions := &replySessions{templateID: "tpl"}
+	sessions.say("user", "hi")
+	sessions.say("assistant", "Hello! How can I help?")
+	sessions.say("tool", "")
+	sessions.say("user", "Are you open Monday?")
+
+	msgRepo := newFakeMsgRepo()
+	provider := llm.NewScripted(llm.Response{Message: llm.Message{Content: "Yes, 9 to 5."}})
+	typer := &fakeTyper{}
+	svc := service.NewReplyService(sessions, fakeVersions{"tpl": `{"system_prompt":"You answer for Acme."}`},
+		provider, service.NewMessageService(msgRepo, nil)).WithTyper(typer)
+
+	m, replied, err := svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Equal(t, service.RoleAssistant, m.Role)
+	require.Equal(t, "Yes, 9 to 5.", m.Content)
+	require.Len(t, msgRepo.inserted, 1)
+	require.Equal(t, []string{"s1"}, typer.sessions)
+
+	reqs := provider.Requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, []llm.Message{
+		{Role: llm.RoleSystem, Content: "You answer for Acme."},
+		{Role: llm.RoleUser, Content: "hi"},
+		{Role: llm.RoleAssistant, Content: "Hello! How can I help?"},
+		{Role: llm.RoleUser, Content: "Are you open Monday?"},
+	}, reqs[0].Messages)
+}
+
+func TestReply_SkipsAnsweredAndClosedSessions(t *testing.T) {
+	sessions := &replySessions{}
+	provider := llm.NewScripted()
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, service.NewMessageService(newFakeMsgRepo(), nil))
+	ctx := context.Background()
+
+	_, replied, err := svc.Reply(ctx, "s1")
+	require.NoError(t, err)
+	require.False(t, replied, "empty session")
+
+	sessions.say("user", "hi")
+	sessions.say("assistant", "hello")
+	_, replied, err = svc.Reply(ctx, "s1")
+	require.NoError(t, err)
+	require.False(t, replied, "already answered")
+
+	sessions.say("user", "bye")
+	sessions.closed = true
+	_, replied, err = svc.Reply(ctx, "s1")
+	require.NoError(t, err)
+	require.False(t, replied, "closed")
+	require.Empty(t, provider.Requests())
+}
+
+func TestReply_UnpublishedTemplateHasNoSystemPrompt(t *testing.T) {
+	sessions := &replySessions{templateID: "draft-only"}
+	sessions.say("user", "hi")
+	provider := llm.NewScripted().WithFallback(llm.Echo)
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, service.NewMessageService(newFakeMsgRepo(), nil))
+
+	m, _, err := svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.Equal(t, "You said: hi", m.Content)
+	require.Equal(t, []llm.Message{{Role: llm.RoleUser, Content: "hi"}}, provider.Requests()[0].Messages)
+}
+
+type toolAllowlist []string
+
+func (a toolAllowlist) AllowedTools(context.Context, string) ([]string, error) { return a, nil }
+
+func leadTools(t *testing.T) *tools.Registry {
+	t.Helper()
+	r := tools.NewRegistry(toolAllowlist{tools.CaptureLeadField})
+	for _, tool := range tools.Builtins(nil) {
+		require.NoError(t, r.Register(tool))
+	}
+	return r
+}
+
+func TestReply_RunsToolCallsAndStoresResults(t *testing.T) {
+	sessions := &replySessions{}
+	sessions.say("user", "I'm Ann, ann@example.com")
+	msgRepo := newFakeMsgRepo()
+	provider := llm.NewScripted(
+		llm.Response{Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+			{ID: "c1", Name: tools.CaptureLeadField, Arguments: json.RawMessage(`{"field":"name","value":"Ann"}`)},
+			{ID: "c2", Name: tools.LookupBusinessHours, Arguments: json.RawMessage(`{}`)},
+		}}},
+		llm.Response{Message: llm.Message{Content: "Thanks, Ann!"}},
+	)
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, service.NewMessageService(msgRepo, nil)).
+		WithTools(leadTools(t))
+
+	m, replied, err := svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.True(t, replied)
+	require.Equal(t, "Thanks, Ann!", m.Content)
+
+	require.Len(t, msgRepo.inserted, 3)
+	captured, denied := msgRepo.inserted[0], msgRepo.inserted[1]
+	require.Equal(t, service.RoleTool, captured.Role)
+	require.Equal(t, tools.CaptureLeadField, captured.ToolName)
+	require.JSONEq(t, `{"captured":true,"field":"name","value":"Ann"}`, captured.Content)
+	require.Equal(t, map[string]any{"call_id": "c1", "arguments": map[string]any{"field": "name", "value": "Ann"},
+		"result": map[string]any{"captured": true, "field": "name", "value": "Ann"}}, captured.ToolData)
+	require.Equal(t, `tool "lookup_business_hours" is not available`, denied.ToolData["error"], "not on the allowlist")
+
+	reqs := provider.Requests()
+	require.Len(t, reqs, 2)
+	require.Len(t, reqs[0].Tools, 1, "only allowed tools are offered")
+	require.Equal(t, tools.CaptureLeadField, reqs[0].Tools[0].Name)
+	second := reqs[1].Messages
+	require.Len(t, second, 4)
+	require.Len(t, second[1].ToolCalls, 2)
+	require.Equal(t, llm.Message{Role: llm.RoleTool, ToolCallID: "c1", Content: captured.Content}, second[2])
+	require.Equal(t, "c2", second[3].ToolCallID)
+}
+
+func TestReply_ResumesAfterStoredToolResults(t *testing.T) {
+	sessions := &replySessions{}
+	sessions.say("user", "My name is Ann")
+	sessions.msgs = append(sessions.msgs, repo.Message{Seq: 2, SessionID: "s1", Role: "tool", ToolName: tools.CaptureLeadField,
+		Content: `{"captured":true}`, ToolData: map[string]any{"call_id": "c1", "arguments": map[string]any{"field": "name", "value": "Ann"}}})
+	provider := llm.NewScripted(llm.Response{Message: llm.Message{Content: "Nice to meet you."}})
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, service.NewMessageService(newFakeMsgRepo(), nil)).
+		WithTools(leadTools(t))
+
+	_, replied, err := svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.True(t, replied, "a tool result still waits for the model")
+	got := provider.Requests()[0].Messages
+	require.Len(t, got, 3)
+	require.Equal(t, llm.Message{Role: llm.RoleUser, Content: "My name is Ann"}, got[0])
+	require.Len(t, got[1].ToolCalls, 1)
+	require.Equal(t, "c1", got[1].ToolCalls[0].ID)
+	require.JSONEq(t, `{"field":"name","value":"Ann"}`, string(got[1].ToolCalls[0].Arguments))
+	require.Equal(t, llm.Message{Role: llm.RoleTool, ToolCallID: "c1", Content: `{"captured":true}`}, got[2])
+}
+
+func TestReply_StopsOfferingToolsAfterMaxRounds(t *testing.T) {
+	sessions := &replySessions{}
+	sessions.say("user", "hi")
+	loop := llm.Response{Message: llm.Message{ToolCalls: []llm.ToolCall{
+		{ID: "c", Name: tools.CaptureLeadField, Arguments: json.RawMessage(`{"field":"note","value":"again"}`)}}}}
+	provider := llm.NewScripted(loop, loop, loop, loop, llm.Response{Message: llm.Message{Content: "done"}})
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, service.NewMessageService(newFakeMsgRepo(), nil)).
+		WithTools(leadTools(t))
+
+	m, _, err := svc.Reply(context.Background(), "s1")
+	require.NoError(t, err)
+	require.Equal(t, "done", m.Content)
+	reqs := provider.Requests()
+	require.Len(t, reqs, 5)
+	require.NotEmpty(t, reqs[3].Tools)
+	require.Empty(t, reqs[4].Tools, "the last round has to answer")
+}
+
+func TestReply_HandleJob(t *testing.T) {
+	sessions := &replySessions{}
+	sessions.say("user", "hi")
+	provider := llm.NewScripted()
+	svc := service.NewReplyService(sessions, fakeVersions{}, provider, service.NewMessageService(newFakeMsgRepo(), nil))
+	ctx := context.Background()
+
+	require.Error(t, svc.HandleJob(ctx, repo.Job{ID: "j1", Payload: map[string]any{}}))
+	require.NoError(t, svc.HandleJob(ctx, repo.Job{Payload: map[string]any{"session_id": "gone"}}), "nothing to retry")
+	require.ErrorIs(t, svc.HandleJob(ctx, repo.Job{Payload: map[string]any{"session_id": "s1"}}), llm.ErrScriptExhausted)
+}
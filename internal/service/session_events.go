@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+)
+
+// eventBatch is how many messages one read fetches while catching up.
+const eventBatch = 100
+
+type SessionEventRepo interface {
+	GetSession(ctx context.Context, sessionID string) (repo.Session, error)
+	ListMessagesAfter(ctx context.Context, sessionID string, afterSeq int64, limit int) ([]repo.Message, error)
+	NotifyTyping(ctx context.Context, sessionID string) error
+}
+
+// SessionEvents fans session changes out to subscribers. Notifications only
+// say which session changed; each subscriber then reads the messages past
+// the last one it sent, so a burst of inserts costs one query and a missed
+// notification only delays delivery until the next one.
+type SessionEvents struct {
+	repo SessionEventRepo
+
+	mu   sync.Mutex
+	subs map[string]map[*watcher]struct{}
+}
+
+// watcher holds one subscriber's pending signals; each is buffered so a
+// burst collapses into one.
+type watcher struct {
+	wake   chan struct{}
+	typing chan struct{}
+}
+
+func NewSessionEvents(r SessionEventRepo) *SessionEvents {
+	return &SessionEvents{repo: r, subs: map[string]map[*watcher]struct{}{}}
+}
+
+// Notify wakes the subscribers of sessionID, or every subscriber when it is
+// empty; with typing set they send a typing event instead of re-reading.
+// It never blocks; repo.ListenSessionEvents calls it.
+func (s *SessionEvents) Notify(sessionID string, typing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sessionID != "" {
+		notifyWatchers(s.subs[sessionID], typing)
+		return
+	}
+	for _, set := range s.subs {
+		notifyWatchers(set, typing)
+	}
+}
+
+func notifyWatchers(set map[*watcher]struct{}, typing bool) {
+	for w := range set {
+		ch := w.wake
+		if typing {
+			ch = w.typing
+		}
+		select {
+		case ch <- struct{}{}:
+		default: // already pending
+		}
+	}
+}
+
+// Typing announces that a reply for the session is being written, on
+// every replica.
+func (s *SessionEvents) Typing(ctx context.Context, sessionID string) (err error) {
+	ctx, span := tracing.Start(ctx, "SessionEvents.Typing")
+	defer func() { tracing.End(span, err) }()
+	return s.repo.NotifyTyping(ctx, sessionID)
+}
+
+func (s *SessionEvents) watch(sessionID string) *watcher {
+	w := &watcher{wake: make(chan struct{}, 1), typing: make(chan struct{}, 1)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[sessionID] == nil {
+		s.subs[sessionID] = map[*watcher]struct{}{}
+	}
+	s.subs[sessionID][w] = struct{}{}
+	return w
+}
+
+func (s *SessionEvents) unwatch(sessionID string, w *watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs[sessionID], w)
+	if len(s.subs[sessionID]) == 0 {
+		delete(s.subs, sessionID)
+	}
+}
+
+// Subscribers reports how many streams are open.
+func (s *SessionEvents) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, set := range s.subs {
+		n += len(set)
+	}
+	return n
+}
+
+func (s *SessionEvents) Subscribe(ctx context.Context, sessionID string, afterSeq int64) (_ <-chan httpapi.SessionEvent, err error) {
+	sctx, span := tracing.Start(ctx, "SessionEvents.Subscribe")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.repo.GetSession(sctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	// watch before the first read, so nothing stored after it goes unseen
+	w := s.watch(sessionID)
+	out := make(chan httpapi.SessionEvent)
+	go func() {
+		defer close(out)
+		defer s.unwatch(sessionID, w)
+		if err := s.stream(ctx, sessionID, afterSeq, w, out); err != nil && ctx.Err() == nil {
+			log.Printf("session events %s: %v", sessionID, err)
+		}
+	}()
+	return out, nil
+}
+
+func (s *SessionEvents) stream(ctx context.Context, sessionID string, last int64, w *watcher, out chan<- httpapi.SessionEvent) error {
+	send := func(ev httpapi.SessionEvent) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		// read the session before its messages: InsertMessage refuses a
+		// closed session under the row lock closing also takes, so once
+		// closed is seen every message is already committed
+		sess, err := s.repo.GetSession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		for {
+			msgs, err := s.repo.ListMessagesAfter(ctx, sessionID, last, eventBatch)
+			if err != nil {
+				return err
+			}
+			for _, m := range msgs {
+				last = m.Seq
+				if !visible(m) {
+					continue
+				}
+				if !send(httpapi.SessionEvent{Type: httpapi.EventMessage, Seq: m.Seq, Message: toHTTPMessage(m)}) {
+					return nil
+				}
+			}
+			if len(msgs) < eventBatch {
+				break
+			}
+		}
+		if sess.ClosedAt != nil {
+			send(httpapi.SessionEvent{Type: httpapi.EventClosed})
+			return nil
+		}
+
+		// typing events need no read; keep waiting for a change
+		for woken := false; !woken; {
+			select {
+			case <-w.wake:
+				woken = true
+			case <-w.typing:
+				if !send(httpapi.SessionEvent{Type: httpapi.EventTyping}) {
+					return nil
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// visible reports whether the widget may see m. System messages (context
+// summaries among them) and tool results are for the model only.
+func visible(m repo.Message) bool {
+	return Role(m.Role) == RoleUser || Role(m.Role) == RoleAssistant
+}
+
+func toHTTPMessage(m repo.Message) *httpapi.Message {
+	return &httpapi.Message{ID: m.ID, SessionID: m.SessionID, Role: m.Role, Content: m.Content,
+		ToolName: m.ToolName, ToolData: m.ToolData, CreatedAt: m.CreatedAt}
+}
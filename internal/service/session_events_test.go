@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+)
+
+type fakeEventRepo struct {
+	mu     sync.Mutex
+	closed bool
+	msgs   []repo.Message
+}
+
+func (r *fakeEventRepo) GetSession(_ context.Context, id string) (repo.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id != "s1" {
+		return repo.Session{}, domain.ErrSessionNotFound
+	}
+	s := repo.Session{ID: id}
+	if r.closed {
+		now := time.Now()
+		s.ClosedAt = &now
+	}
+	return s, nil
+}
+
+func (r *fakeEventRepo) ListMessagesAfter(_ context.Context, id string, after int64, limit int) ([]repo.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []repo.Message
+	for _, m := range r.msgs {
+		if m.Seq > after && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeEventRepo) NotifyTyping(context.Context, string) error { return nil }
+
+func (r *fakeEventRepo) add(seq int64, content string) {
+	r.addAs(seq, "assistant", content)
+}
+
+func (r *fakeEventRepo) addAs(seq int64, role, content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, repo.Message{Seq: seq, ID: "m", SessionID: "s1", Role: role, Content: content})
+}
+
+func next(t *testing.T, ch <-chan httpapi.SessionEvent) httpapi.SessionEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok, "stream ended early")
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+		return httpapi.SessionEvent{}
+	}
+}
+
+func TestSessionEvents_ResumeThenLiveThenClose(t *testing.T) {
+	r := &fakeEventRepo{}
+	r.add(3, "one")
+	r.add(7, "two")
+	ev := service.NewSessionEvents(r)
+
+	ch, err := ev.Subscribe(context.Background(), "s1", 3)
+	require.NoError(t, err)
+	got := next(t, ch)
+	require.Equal(t, httpapi.EventMessage, got.Type)
+	require.Equal(t, int64(7), got.Seq, "resumes after Last-Event-ID")
+	require.Equal(t, "two", got.Message.Content)
+
+	r.add(9, "three")
+	ev.Notify("other-session", false)
+	ev.Notify("s1", false)
+	require.Equal(t, int64(9), next(t, ch).Seq)
+
+	ev.Notify("s1", true)
+	require.Equal(t, httpapi.EventTyping, next(t, ch).Type)
+
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.add(10, "last words")
+	ev.Notify("", false) // as after a listener reconnect
+	require.Equal(t, int64(10), next(t, ch).Seq, "messages stored before the close still go out")
+	require.Equal(t, httpapi.EventClosed, next(t, ch).Type)
+
+	_, ok := <-ch
+	require.False(t, ok)
+	require.Eventually(t, func() bool { return ev.Subscribers() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestSessionEvents_HidesSystemAndToolMessages(t *testing.T) {
+	r := &fakeEventRepo{}
+	r.addAs(1, "user", "hi")
+	r.addAs(2, "system", "Summary of the conversation so far")
+	r.addAs(3, "tool", "")
+	r.addAs(4, "assistant", "hello")
+	ev := service.NewSessionEvents(r)
+
+	ch, err := ev.Subscribe(context.Background(), "s1", 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), next(t, ch).Seq)
+	require.Equal(t, int64(4), next(t, ch).Seq)
+
+	r.addAs(5, "system", "a newer summary")
+	r.addAs(6, "user", "thanks")
+	ev.Notify("s1", false)
+	got := next(t, ch)
+	require.Equal(t, int64(6), got.Seq)
+	require.Equal(t, "thanks", got.Message.Content)
+}
+
+func TestSessionEvents_CancelUnsubscribes(t *testing.T) {
+	ev := service.NewSessionEvents(&fakeEventRepo{})
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := ev.Subscribe(ctx, "s1", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, ev.Subscribers())
+
+	cancel()
+	for range ch {
+	}
+	require.Eventually(t, func() bool { return ev.Subscribers() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestSessionEvents_UnknownSession(t *testing.T) {
+	_, err := service.NewSessionEvents(&fakeEventRepo{}).Subscribe(context.Background(), "nope", 0)
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+}
@@ -2,22 +2,53 @@ package service
 
 import (
 	"context"
+	"log"
 	"time"
 
 	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+	"gochatbot/internal/webhook"
 )
 
 type Session struct {
-	ID       string
-	ClosedAt *time.Time
+	ID         string
+	TenantID   string
+	TemplateID string
+	CreatedAt  time.Time
+	ClosedAt   *time.Time
 }
 
+type LeadStatus string
+
+const (
+	LeadNew      LeadStatus = "new"
+	LeadExported LeadStatus = "exported" // delivered to the tenant's CRM
+	LeadFailed   LeadStatus = "failed"   // export gave up
+)
+
+// Lead is the contact a session produced: what the assistant captured,
+// normalized, plus the template version that was talking to the visitor.
 type Lead struct {
 	ID        string
+	TenantID  string
 	SessionID string
+	Name      string
+	Email     string // normalized by validate.NormalizeEmail
+	Phone     string // E.164, by validate.NormalizePhone
+	// Fields holds captured details other than the three above.
+	Fields          map[string]any
+	TemplateID      string
+	TemplateVersion int
+	Status          LeadStatus
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Revision        int64
 }
 
 type Repo interface {
+	CreateSession(ctx context.Context, tenantID, templateID string) (Session, error)
 	GetSession(ctx context.Context, sessionID string) (Session, error)
 	MarkSessionClosed(ctx context.Context, sessionID string, closedAt time.Time) error
 
@@ -25,31 +56,138 @@ type Repo interface {
 	CreateLeadForSession(ctx context.Context, sessionID string) (Lead, error)
 }
 
+// SessionRecords is the part of *repo.SessionRepo that StoredSessions needs.
+type SessionRecords interface {
+	CreateSession(ctx context.Context, tenantID, templateID string) (repo.Session, error)
+	GetSession(ctx context.Context, sessionID string) (repo.Session, error)
+	MarkSessionClosed(ctx context.Context, sessionID string, closedAt time.Time) error
+}
+
+// LeadRecords is the part of *repo.LeadRepo that StoredSessions needs.
+type LeadRecords interface {
+	GetLeadBySession(ctx context.Context, sessionID string) (repo.Lead, bool, error)
+	CreateLeadForSession(ctx context.Context, sessionID string) (repo.Lead, error)
+}
+
+// StoredSessions adapts the session and lead repos to Repo.
+func StoredSessions(sessions SessionRecords, leads LeadRecords) Repo {
+	return storedSessions{sessions: sessions, leads: leads}
+}
+
+type storedSessions struct {
+	sessions SessionRecords
+	leads    LeadRecords
+}
+
+func (r storedSessions) CreateSession(ctx context.Context, tenantID, templateID string) (Session, error) {
+	s, err := r.sessions.CreateSession(ctx, tenantID, templateID)
+	if err != nil {
+		return Session{}, err
+	}
+	return storedSession(s), nil
+}
+
+func (r storedSessions) GetSession(ctx context.Context, sessionID string) (Session, error) {
+	s, err := r.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	return storedSession(s), nil
+}
+
+func storedSession(s repo.Session) Session {
+	return Session{ID: s.ID, TenantID: s.TenantID, TemplateID: s.TemplateID, CreatedAt: s.CreatedAt, ClosedAt: s.ClosedAt}
+}
+
+func (r storedSessions) MarkSessionClosed(ctx context.Context, sessionID string, closedAt time.Time) error {
+	return r.sessions.MarkSessionClosed(ctx, sessionID, closedAt)
+}
+
+func (r storedSessions) GetLeadBySession(ctx context.Context, sessionID string) (Lead, bool, error) {
+	l, ok, err := r.leads.GetLeadBySession(ctx, sessionID)
+	if err != nil || !ok {
+		return Lead{}, ok, err
+	}
+	return storedLead(l), true, nil
+}
+
+func (r storedSessions) CreateLeadForSession(ctx context.Context, sessionID string) (Lead, error) {
+	l, err := r.leads.CreateLeadForSession(ctx, sessionID)
+	if err != nil {
+		return Lead{}, err
+	}
+	return storedLead(l), nil
+}
+
+func storedLead(l repo.Lead) Lead {
+	return Lead{ID: l.ID, TenantID: l.TenantID, SessionID: l.SessionID, Name: l.Name, Email: l.Email, Phone: l.Phone,
+		Fields: l.Fields, TemplateID: l.TemplateID, TemplateVersion: l.TemplateVersion, Status: LeadStatus(l.Status),
+		CreatedAt: l.CreatedAt, UpdatedAt: l.UpdatedAt, Revision: l.Revision}
+}
+
 type Queue interface {
 	Enqueue(ctx context.Context, kind string, payload map[string]any) error
 }
 
 type SessionService struct {
-	repo  Repo
-	queue Queue
-	now   func() time.Time
+	repo   Repo
+	queue  Queue
+	now    func() time.Time
+	rec    Recorder
+	events EventPublisher // nil: no webhooks
 }
 
 func NewSessionService(repo Repo, queue Queue, now func() time.Time) *SessionService {
 	if now == nil {
 		now = time.Now
 	}
-	return &SessionService{repo: repo, queue: queue, now: now}
+	return &SessionService{repo: repo, queue: queue, now: now, rec: nopRecorder{}}
+}
+
+func (s *SessionService) WithRecorder(rec Recorder) *SessionService {
+	if rec != nil {
+		s.rec = rec
+	}
+	return s
+}
+
+// WithEvents publishes session.closed and lead.created as sessions close.
+func (s *SessionService) WithEvents(p EventPublisher) *SessionService {
+	s.events = p
+	return s
+}
+
+// StartSession opens a session for the tenant, following templateID's
+// published version when it is set.
+func (s *SessionService) StartSession(ctx context.Context, tenantID, templateID string) (_ httpapi.Session, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.StartSession")
+	defer func() { tracing.End(span, err) }()
+
+	sess, err := s.repo.CreateSession(ctx, tenantID, templateID)
+	if err != nil {
+		return httpapi.Session{}, err
+	}
+	s.rec.SessionStarted()
+	return httpapi.Session{ID: sess.ID, TenantID: sess.TenantID, TemplateID: sess.TemplateID,
+		CreatedAt: sess.CreatedAt, ClosedAt: sess.ClosedAt}, nil
 }
 
 // CloseSession is idempotent:
 // - if already closed: OK
 // - else: close session, create lead if missing, enqueue export job once
-func (s *SessionService) CloseSession(ctx context.Context, sessionID string) error {
+//
+// Another tenant's session is not found.
+func (s *SessionService) CloseSession(ctx context.Context, tenantID, sessionID string) (err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.CloseSession")
+	defer func() { tracing.End(span, err) }()
+
 	sess, err := s.repo.GetSession(ctx, sessionID)
 	if err != nil {
 		return err
 	}
+	if sess.TenantID != tenantID {
+		return domain.ErrSessionNotFound
+	}
 	if sess.ClosedAt != nil {
 		return nil
 	}
@@ -60,6 +198,11 @@ func (s *SessionService) CloseSession(ctx context.Context, sessionID string) err
 	if err := s.repo.MarkSessionClosed(ctx, sessionID, t); err != nil {
 		return err
 	}
+	s.rec.SessionClosed()
+	s.publish(ctx, sess.TenantID, webhook.SessionClosed, map[string]any{
+		"session_id": sessionID,
+		"closed_at":  t.UTC(),
+	})
 
 	lead, exists, err := s.repo.GetLeadBySession(ctx, sessionID)
 	if err != nil {
@@ -70,13 +213,31 @@ func (s *SessionService) CloseSession(ctx context.Context, sessionID string) err
 		if err != nil {
 			return err
 		}
+		s.rec.LeadCreated()
+		s.publish(ctx, sess.TenantID, webhook.LeadCreated, map[string]any{
+			"lead_id":    lead.ID,
+			"session_id": sessionID,
+			"name":       lead.Name,
+			"email":      lead.Email,
+			"phone":      lead.Phone,
+			"fields":     lead.Fields,
+		})
 	}
 
 	// enqueue export job (idempotency enforced by lead existence + close state)
-	return s.queue.Enqueue(ctx, "export_lead", map[string]any{
+	return s.queue.Enqueue(ctx, LeadExportJobKind, map[string]any{
 		"session_id": sessionID,
 		"lead_id":    lead.ID,
 	})
 }
 
-var _ = domain.ErrSessionNotFound
+// publish records a webhook event. Closing goes ahead if it cannot be
+// recorded; the failure is only logged.
+func (s *SessionService) publish(ctx context.Context, tenantID, eventType string, data map[string]any) {
+	if s.events == nil {
+		return
+	}
+	if err := s.events.Publish(ctx, tenantID, eventType, data); err != nil {
+		log.Printf("session %s: publish %s event: %v", data["session_id"], eventType, err)
+	}
+}
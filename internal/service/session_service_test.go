@@ -2,6 +2,7 @@ package service_test
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -29,6 +30,15 @@ func newFakeRepo() *fakeRepo {
 	}
 }
 
+func (r *fakeRepo) CreateSession(ctx context.Context, tenantID, templateID string) (service.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := service.Session{ID: fmt.Sprintf("s%d", len(r.sessions)+1), TenantID: tenantID, TemplateID: templateID}
+	r.sessions[s.ID] = s
+	return s, nil
+}
+
 func (r *fakeRepo) GetSession(ctx context.Context, sessionID string) (service.Session, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -96,11 +106,11 @@ func TestCloseSession_CreatesLeadAndEnqueuesOnce(t *testing.T) {
 	q := &fakeQueue{}
 	t0 := time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
 
-	repo.sessions["s1"] = service.Session{ID: "s1", ClosedAt: nil}
+	repo.sessions["s1"] = service.Session{ID: "s1", TenantID: "t1", ClosedAt: nil}
 
 	svc := service.NewSessionService(repo, q, func() time.Time { return t0 })
 
-	err := svc.CloseSession(ctx, "s1")
+	err := svc.CloseSession(ctx, "t1", "s1")
 	require.NoError(t, err)
 
 	require.Equal(t, 1, repo.closeCount)
@@ -119,12 +129,12 @@ func TestCloseSession_IdempotentSecondCallDoesNothing(t *testing.T) {
 	q := &fakeQueue{}
 	t0 := time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
 
-	repo.sessions["s1"] = service.Session{ID: "s1", ClosedAt: nil}
+	repo.sessions["s1"] = service.Session{ID: "s1", TenantID: "t1", ClosedAt: nil}
 
 	svc := service.NewSessionService(repo, q, func() time.Time { return t0 })
 
-	require.NoError(t, svc.CloseSession(ctx, "s1"))
-	require.NoError(t, svc.CloseSession(ctx, "s1"))
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
 
 	// Still only closed once, lead created once, job enqueued once
 	require.Equal(t, 1, repo.closeCount)
@@ -140,6 +150,76 @@ func TestCloseSession_NotFound(t *testing.T) {
 
 	svc := service.NewSessionService(repo, q, func() time.Time { return time.Now() })
 
-	err := svc.CloseSession(ctx, "missing")
+	err := svc.CloseSession(ctx, "t1", "missing")
 	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+
+	repo.sessions["s1"] = service.Session{ID: "s1", TenantID: "t2"}
+	err = svc.CloseSession(ctx, "t1", "s1")
+	require.ErrorIs(t, err, domain.ErrSessionNotFound, "other tenants' sessions are not found")
+	require.Zero(t, repo.closeCount)
+}
+
+type countingRecorder struct {
+	started, closed, leads int
+}
+
+func (r *countingRecorder) SessionStarted()    { r.started++ }
+func (r *countingRecorder) SessionClosed()     { r.closed++ }
+func (r *countingRecorder) LeadCreated()       { r.leads++ }
+func (r *countingRecorder) TemplatePublished() {}
+
+func TestStartSession(t *testing.T) {
+	ctx := context.Background()
+
+	repo := newFakeRepo()
+	rec := &countingRecorder{}
+	svc := service.NewSessionService(repo, &fakeQueue{}, nil).WithRecorder(rec)
+
+	sess, err := svc.StartSession(ctx, "t1", "tpl1")
+	require.NoError(t, err)
+	require.Equal(t, "t1", sess.TenantID)
+	require.Equal(t, "tpl1", sess.TemplateID)
+	require.Nil(t, sess.ClosedAt)
+	require.Contains(t, repo.sessions, sess.ID)
+	require.Equal(t, 1, rec.started)
+
+	require.NoError(t, svc.CloseSession(ctx, "t1", sess.ID))
+	require.Equal(t, 1, rec.closed)
+}
+
+func TestCloseSession_RecordsCloseAndLeadOnce(t *testing.T) {
+	ctx := context.Background()
+
+	repo := newFakeRepo()
+	repo.sessions["s1"] = service.Session{ID: "s1", TenantID: "t1"}
+	rec := &countingRecorder{}
+
+	svc := service.NewSessionService(repo, &fakeQueue{}, nil).WithRecorder(rec)
+
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
+
+	require.Equal(t, 1, rec.closed)
+	require.Equal(t, 1, rec.leads)
+}
+
+func TestCloseSession_PublishesEvents(t *testing.T) {
+	ctx := context.Background()
+
+	repo := newFakeRepo()
+	repo.sessions["s1"] = service.Session{ID: "s1", TenantID: "t1"}
+	events := &fakePublisher{}
+	t0 := time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
+
+	svc := service.NewSessionService(repo, &fakeQueue{}, func() time.Time { return t0 }).WithEvents(events)
+
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
+
+	require.Len(t, events.events, 2, "one of each, however often the session is closed")
+	require.Equal(t, "t1", events.events[0].tenantID)
+	require.Equal(t, "session.closed", events.events[0].eventType)
+	require.Equal(t, t0, events.events[0].data["closed_at"])
+	require.Equal(t, "lead.created", events.events[1].eventType)
+	require.Equal(t, "lead-s1", events.events[1].data["lead_id"])
 }
@@ -4,36 +4,62 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log"
 
 	"gochatbot/internal/domain"
 	"gochatbot/internal/httpapi"
+	"gochatbot/internal/jsondiff"
 	"gochatbot/internal/pagination"
 	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
 	"gochatbot/internal/validate"
+	"gochatbot/internal/webhook"
 )
 
 type TemplateRepo interface {
 	CreateTemplate(ctx context.Context, tenantID, name, slug string) (repo.Template, error)
+	GetTemplate(ctx context.Context, templateID string) (repo.Template, error)
 	GetTemplateBySlug(ctx context.Context, tenantID, slug string) (repo.Template, error)
-	ListTemplates(ctx context.Context, tenantID string, limit int, cursor *pagination.Cursor) ([]repo.Template, *pagination.Cursor, error)
+	ListTemplates(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) ([]repo.Template, pagination.Page, error)
+	RenameTemplate(ctx context.Context, templateID, name string, ifRevision int64) (repo.Template, error)
 
 	CreateDraftVersion(ctx context.Context, templateID string, contentJSON []byte) (repo.TemplateVersion, error)
-	PublishVersion(ctx context.Context, templateID string, version int) (repo.TemplateVersion, error)
+	UpdateDraftVersion(ctx context.Context, templateID string, version int, contentJSON []byte, ifRevision int64) (repo.TemplateVersion, error)
+	PublishVersion(ctx context.Context, templateID string, version int, ifRevision int64) (repo.TemplateVersion, error)
 	GetPublishedVersion(ctx context.Context, templateID string) (repo.TemplateVersion, error)
+	GetVersion(ctx context.Context, templateID string, version int) (repo.TemplateVersion, error)
 }
 
 type TemplateService struct {
-	repo TemplateRepo
+	repo   TemplateRepo
+	rec    Recorder
+	events EventPublisher // nil: no webhooks
 }
 
 func NewTemplateService(r TemplateRepo) *TemplateService {
-	return &TemplateService{repo: r}
+	return &TemplateService{repo: r, rec: nopRecorder{}}
 }
 
-func (s *TemplateService) CreateTemplate(ctx context.Context, tenantID, name, slug string) (httpapi.Template, error) {
+func (s *TemplateService) WithRecorder(rec Recorder) *TemplateService {
+	if rec != nil {
+		s.rec = rec
+	}
+	return s
+}
+
+// WithEvents publishes template.published after every publish.
+func (s *TemplateService) WithEvents(p EventPublisher) *TemplateService {
+	s.events = p
+	return s
+}
+
+func (s *TemplateService) CreateTemplate(ctx context.Context, tenantID, name, slug string) (_ httpapi.Template, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.CreateTemplate")
+	defer func() { tracing.End(span, err) }()
+
 	name = trim(name)
 	if name == "" {
-		return httpapi.Template{}, errors.New("invalid name")
+		return httpapi.Template{}, domain.ErrInvalidName
 	}
 
 	norm, err := validate.NormalizeSlug(slug)
@@ -45,10 +71,13 @@ func (s *TemplateService) CreateTemplate(ctx context.Context, tenantID, name, sl
 	if err != nil {
 		return httpapi.Template{}, err
 	}
-	return httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt}, nil
+	return httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt, Revision: t.Revision}, nil
 }
 
-func (s *TemplateService) GetTemplate(ctx context.Context, tenantID, slug string) (httpapi.Template, error) {
+func (s *TemplateService) GetTemplate(ctx context.Context, tenantID, slug string) (_ httpapi.Template, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.GetTemplate")
+	defer func() { tracing.End(span, err) }()
+
 	norm, err := validate.NormalizeSlug(slug)
 	if err != nil {
 		return httpapi.Template{}, domain.ErrInvalidSlug
@@ -57,49 +86,145 @@ func (s *TemplateService) GetTemplate(ctx context.Context, tenantID, slug string
 	if err != nil {
 		return httpapi.Template{}, err
 	}
-	return httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt}, nil
+	return httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt, Revision: t.Revision}, nil
+}
+
+// RenameTemplate sets a template's name. A non-zero ifRevision makes it
+// conditional on the template being unchanged (domain.ErrRevisionMismatch).
+func (s *TemplateService) RenameTemplate(ctx context.Context, templateID, name string, ifRevision int64) (_ httpapi.Template, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.RenameTemplate")
+	defer func() { tracing.End(span, err) }()
+
+	name = trim(name)
+	if name == "" {
+		return httpapi.Template{}, domain.ErrInvalidName
+	}
+	t, err := s.repo.RenameTemplate(ctx, templateID, name, ifRevision)
+	if err != nil {
+		return httpapi.Template{}, err
+	}
+	return httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt, Revision: t.Revision}, nil
 }
 
-func (s *TemplateService) ListTemplates(ctx context.Context, tenantID string, limit int, cursor *pagination.Cursor) (httpapi.ListTemplatesResult, error) {
-	items, next, err := s.repo.ListTemplates(ctx, tenantID, limit, cursor)
+func (s *TemplateService) ListTemplates(ctx context.Context, tenantID string, limit int, f pagination.Filter, cursor *pagination.Cursor) (_ httpapi.ListTemplatesResult, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.ListTemplates")
+	defer func() { tracing.End(span, err) }()
+
+	items, page, err := s.repo.ListTemplates(ctx, tenantID, limit, f, cursor)
 	if err != nil {
 		return httpapi.ListTemplatesResult{}, err
 	}
 
 	out := make([]httpapi.Template, 0, len(items))
 	for _, t := range items {
-		out = append(out, httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt})
+		out = append(out, httpapi.Template{ID: t.ID, TenantID: t.TenantID, Name: t.Name, Slug: t.Slug, CreatedAt: t.CreatedAt, Revision: t.Revision})
 	}
-	var nextEnc string
-	if next != nil {
-		nextEnc = pagination.Encode(*next)
-	}
-	return httpapi.ListTemplatesResult{Items: out, NextCursor: nextEnc}, nil
+	return httpapi.ListTemplatesResult{Items: out, Page: page}, nil
 }
 
-func (s *TemplateService) CreateDraft(ctx context.Context, templateID string, content json.RawMessage) (httpapi.TemplateVersion, error) {
+func (s *TemplateService) CreateDraft(ctx context.Context, templateID string, content json.RawMessage) (_ httpapi.TemplateVersion, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.CreateDraft")
+	defer func() { tracing.End(span, err) }()
+
 	v, err := s.repo.CreateDraftVersion(ctx, templateID, []byte(content))
 	if err != nil {
 		return httpapi.TemplateVersion{}, err
 	}
-	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt}, nil
+	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt, Revision: v.Revision}, nil
 }
 
-func (s *TemplateService) Publish(ctx context.Context, templateID string, version int) (httpapi.TemplateVersion, error) {
+// UpdateDraft replaces a draft's content; ifRevision works as in RenameTemplate.
+func (s *TemplateService) UpdateDraft(ctx context.Context, templateID string, version int, content json.RawMessage, ifRevision int64) (_ httpapi.TemplateVersion, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.UpdateDraft")
+	defer func() { tracing.End(span, err) }()
+
 	if version <= 0 {
-		return httpapi.TemplateVersion{}, errors.New("invalid version")
+		return httpapi.TemplateVersion{}, domain.ErrInvalidVersion
 	}
-	v, err := s.repo.PublishVersion(ctx, templateID, version)
+	v, err := s.repo.UpdateDraftVersion(ctx, templateID, version, []byte(content), ifRevision)
 	if err != nil {
 		return httpapi.TemplateVersion{}, err
 	}
-	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt}, nil
+	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt, Revision: v.Revision}, nil
 }
 
-func (s *TemplateService) GetPublished(ctx context.Context, templateID string) (httpapi.TemplateVersion, error) {
+// Publish publishes a draft; ifRevision works as in RenameTemplate.
+func (s *TemplateService) Publish(ctx context.Context, templateID string, version int, ifRevision int64) (_ httpapi.TemplateVersion, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.Publish")
+	defer func() { tracing.End(span, err) }()
+
+	if version <= 0 {
+		return httpapi.TemplateVersion{}, domain.ErrInvalidVersion
+	}
+	v, err := s.repo.PublishVersion(ctx, templateID, version, ifRevision)
+	if err != nil {
+		return httpapi.TemplateVersion{}, err
+	}
+	s.rec.TemplatePublished()
+	s.publishEvent(ctx, v)
+	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt, Revision: v.Revision}, nil
+}
+
+// publishEvent announces a published version. The version stays published
+// if the event cannot be recorded.
+func (s *TemplateService) publishEvent(ctx context.Context, v repo.TemplateVersion) {
+	if s.events == nil {
+		return
+	}
+	t, err := s.repo.GetTemplate(ctx, v.TemplateID)
+	if err == nil {
+		err = s.events.Publish(ctx, t.TenantID, webhook.TemplatePublished, map[string]any{
+			"template_id": t.ID,
+			"slug":        t.Slug,
+			"name":        t.Name,
+			"version":     v.Version,
+		})
+	}
+	if err != nil {
+		log.Printf("template %s: publish %s event: %v", v.TemplateID, webhook.TemplatePublished, err)
+	}
+}
+
+func (s *TemplateService) GetPublished(ctx context.Context, templateID string) (_ httpapi.TemplateVersion, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.GetPublished")
+	defer func() { tracing.End(span, err) }()
+
 	v, err := s.repo.GetPublishedVersion(ctx, templateID)
 	if err != nil {
 		return httpapi.TemplateVersion{}, err
 	}
-	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt}, nil
+	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt, Revision: v.Revision}, nil
+}
+
+func (s *TemplateService) GetVersion(ctx context.Context, templateID string, version int) (_ httpapi.TemplateVersion, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.GetVersion")
+	defer func() { tracing.End(span, err) }()
+
+	if version <= 0 {
+		return httpapi.TemplateVersion{}, domain.ErrInvalidVersion
+	}
+	v, err := s.repo.GetVersion(ctx, templateID, version)
+	if err != nil {
+		return httpapi.TemplateVersion{}, err
+	}
+	return httpapi.TemplateVersion{ID: v.ID, TemplateID: v.TemplateID, Version: v.Version, Status: v.Status, Content: json.RawMessage(v.Content), CreatedAt: v.CreatedAt, Revision: v.Revision}, nil
+}
+
+// DiffVersions compares the content of two versions of the same template.
+func (s *TemplateService) DiffVersions(ctx context.Context, templateID string, from, to int) (_ []jsondiff.Change, err error) {
+	ctx, span := tracing.Start(ctx, "TemplateService.DiffVersions")
+	defer func() { tracing.End(span, err) }()
+
+	if from <= 0 || to <= 0 {
+		return nil, errors.New("invalid version")
+	}
+	a, err := s.repo.GetVersion(ctx, templateID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.repo.GetVersion(ctx, templateID, to)
+	if err != nil {
+		return nil, err
+	}
+	return jsondiff.Diff(a.Content, b.Content)
 }
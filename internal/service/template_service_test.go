@@ -14,7 +14,9 @@ import (
 )
 
 type fakeTemplateRepo struct {
-	createErr error
+	createErr     error
+	versions      map[int]string
+	draftRevision int64
 }
 
 func (f *fakeTemplateRepo) CreateTemplate(ctx context.Context, tenantID, name, slug string) (repo.Template, error) {
@@ -24,19 +26,35 @@ func (f *fakeTemplateRepo) CreateTemplate(ctx context.Context, tenantID, name, s
 	return repo.Template{ID: "tpl1", TenantID: tenantID, Name: name, Slug: slug}, nil
 }
 
+func (f *fakeTemplateRepo) GetTemplate(ctx context.Context, templateID string) (repo.Template, error) {
+	return repo.Template{ID: templateID, TenantID: "tenant1", Name: "Sales", Slug: "sales"}, nil
+}
+
 func (f *fakeTemplateRepo) GetTemplateBySlug(ctx context.Context, tenantID, slug string) (repo.Template, error) {
 	return repo.Template{}, domain.ErrTemplateNotFound
 }
 
-func (f *fakeTemplateRepo) ListTemplates(ctx context.Context, tenantID string, limit int, cursor *pagination.Cursor) ([]repo.Template, *pagination.Cursor, error) {
-	return nil, nil, nil
+func (f *fakeTemplateRepo) ListTemplates(ctx context.Context, tenantID string, limit int, _ pagination.Filter, cursor *pagination.Cursor) ([]repo.Template, pagination.Page, error) {
+	return nil, pagination.Page{}, nil
+}
+
+func (f *fakeTemplateRepo) RenameTemplate(ctx context.Context, templateID, name string, ifRevision int64) (repo.Template, error) {
+	return repo.Template{ID: templateID, Name: name, Revision: ifRevision + 1}, nil
 }
 
 func (f *fakeTemplateRepo) CreateDraftVersion(ctx context.Context, templateID string, contentJSON []byte) (repo.TemplateVersion, error) {
 	return repo.TemplateVersion{ID: "v1", TemplateID: templateID, Version: 1, Status: "draft", Content: contentJSON}, nil
 }
 
-func (f *fakeTemplateRepo) PublishVersion(ctx context.Context, templateID string, version int) (repo.TemplateVersion, error) {
+func (f *fakeTemplateRepo) UpdateDraftVersion(ctx context.Context, templateID string, version int, contentJSON []byte, ifRevision int64) (repo.TemplateVersion, error) {
+	if ifRevision != 0 && ifRevision != f.draftRevision {
+		return repo.TemplateVersion{}, domain.ErrRevisionMismatch
+	}
+	f.draftRevision++
+	return repo.TemplateVersion{ID: "v1", TemplateID: templateID, Version: version, Status: "draft", Content: contentJSON, Revision: f.draftRevision}, nil
+}
+
+func (f *fakeTemplateRepo) PublishVersion(ctx context.Context, templateID string, version int, ifRevision int64) (repo.TemplateVersion, error) {
 	return repo.TemplateVersion{ID: "v1", TemplateID: templateID, Version: version, Status: "published"}, nil
 }
 
@@ -44,6 +62,14 @@ func (f *fakeTemplateRepo) GetPublishedVersion(ctx context.Context, templateID s
 	return repo.TemplateVersion{}, domain.ErrVersionNotFound
 }
 
+func (f *fakeTemplateRepo) GetVersion(ctx context.Context, templateID string, version int) (repo.TemplateVersion, error) {
+	content, ok := f.versions[version]
+	if !ok {
+		return repo.TemplateVersion{}, domain.ErrVersionNotFound
+	}
+	return repo.TemplateVersion{ID: "v", TemplateID: templateID, Version: version, Status: "draft", Content: []byte(content)}, nil
+}
+
 func TestTemplateService_CreateTemplate_NormalizesSlug(t *testing.T) {
 	svc := service.NewTemplateService(&fakeTemplateRepo{})
 	tpl, err := svc.CreateTemplate(context.Background(), "tenant1", "My Template", "My Template!!")
@@ -70,3 +96,42 @@ func TestTemplateService_CreateDraft_PassesJSONThrough(t *testing.T) {
 	require.NoError(t, err)
 	require.JSONEq(t, string(raw), string(v.Content))
 }
+
+func TestTemplateService_DiffVersions(t *testing.T) {
+	svc := service.NewTemplateService(&fakeTemplateRepo{versions: map[int]string{
+		1: `{"greeting":"hi"}`,
+		2: `{"greeting":"hello"}`,
+	}})
+	changes, err := svc.DiffVersions(context.Background(), "tpl1", 1, 2)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "$.greeting", changes[0].Path)
+
+	_, err = svc.DiffVersions(context.Background(), "tpl1", 1, 3)
+	require.ErrorIs(t, err, domain.ErrVersionNotFound)
+}
+
+func TestTemplateService_UpdateDraft_CarriesRevision(t *testing.T) {
+	fake := &fakeTemplateRepo{draftRevision: 7}
+	svc := service.NewTemplateService(fake)
+
+	v, err := svc.UpdateDraft(context.Background(), "tpl1", 1, json.RawMessage(`{"k":2}`), 7)
+	require.NoError(t, err)
+	require.Equal(t, int64(8), v.Revision)
+
+	_, err = svc.UpdateDraft(context.Background(), "tpl1", 1, json.RawMessage(`{"k":3}`), 7)
+	require.ErrorIs(t, err, domain.ErrRevisionMismatch)
+}
+
+func TestTemplateService_Publish_PublishesEvent(t *testing.T) {
+	events := &fakePublisher{}
+	svc := service.NewTemplateService(&fakeTemplateRepo{}).WithEvents(events)
+
+	_, err := svc.Publish(context.Background(), "tpl1", 3, 0)
+	require.NoError(t, err)
+
+	require.Len(t, events.events, 1)
+	require.Equal(t, "tenant1", events.events[0].tenantID)
+	require.Equal(t, "template.published", events.events[0].eventType)
+	require.Equal(t, map[string]any{"template_id": "tpl1", "slug": "sales", "name": "Sales", "version": 3}, events.events[0].data)
+}
@@ -7,13 +7,15 @@ import (
 	"gochatbot/internal/httpapi"
 	"gochatbot/internal/pagination"
 	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
 	"gochatbot/internal/validate"
 )
 
 type TenantRepo interface {
 	Create(ctx context.Context, name, slug string) (repo.Tenant, error)
 	GetBySlug(ctx context.Context, slug string) (repo.Tenant, error)
-	List(ctx context.Context, limit int, cursor *pagination.Cursor) ([]repo.Tenant, *pagination.Cursor, error)
+	Rename(ctx context.Context, slug, name string, ifRevision int64) (repo.Tenant, error)
+	List(ctx context.Context, limit int, f pagination.Filter, cursor *pagination.Cursor) ([]repo.Tenant, pagination.Page, error)
 }
 
 type TenantService struct {
@@ -24,7 +26,10 @@ func NewTenantService(r TenantRepo) *TenantService {
 	return &TenantService{repo: r}
 }
 
-func (s *TenantService) CreateTenant(_ httpapi.RequestContext, name string, slug string) (httpapi.Tenant, error) {
+func (s *TenantService) CreateTenant(rctx httpapi.RequestContext, name string, slug string) (_ httpapi.Tenant, err error) {
+	ctx, span := tracing.Start(rctx.Context(), "TenantService.CreateTenant")
+	defer func() { tracing.End(span, err) }()
+
 	name = trim(name)
 	if name == "" {
 		return httpapi.Tenant{}, domain.ErrEmptyMessage
@@ -36,7 +41,7 @@ func (s *TenantService) CreateTenant(_ httpapi.RequestContext, name string, slug
 		return httpapi.Tenant{}, domain.ErrInvalidSlug
 	}
 
-	t, err := s.repo.Create(context.Background(), name, norm)
+	t, err := s.repo.Create(ctx, name, norm)
 	if err != nil {
 		// pass through known domain errors
 		if err == domain.ErrTenantSlugTaken {
@@ -45,39 +50,63 @@ func (s *TenantService) CreateTenant(_ httpapi.RequestContext, name string, slug
 		return httpapi.Tenant{}, err
 	}
 
-	return httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug}, nil
+	return httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug, Revision: t.Revision}, nil
 }
 
-func (s *TenantService) GetTenantBySlug(_ httpapi.RequestContext, slug string) (httpapi.Tenant, error) {
+func (s *TenantService) GetTenantBySlug(rctx httpapi.RequestContext, slug string) (_ httpapi.Tenant, err error) {
+	ctx, span := tracing.Start(rctx.Context(), "TenantService.GetTenantBySlug")
+	defer func() { tracing.End(span, err) }()
+
 	norm, err := validate.NormalizeSlug(slug)
 	if err != nil {
 		return httpapi.Tenant{}, domain.ErrInvalidSlug
 	}
 
-	t, err := s.repo.GetBySlug(context.Background(), norm)
+	t, err := s.repo.GetBySlug(ctx, norm)
 	if err != nil {
 		return httpapi.Tenant{}, err
 	}
-	return httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug}, nil
+	return httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug, Revision: t.Revision}, nil
 }
 
-func (s *TenantService) ListTenants(_ httpapi.RequestContext, limit int, cursor *pagination.Cursor) (httpapi.ListTenantsResult, error) {
-	items, next, err := s.repo.List(context.Background(), limit, cursor)
+// RenameTenant sets a tenant's name. A non-zero ifRevision makes it
+// conditional on the tenant being unchanged (domain.ErrRevisionMismatch).
+func (s *TenantService) RenameTenant(rctx httpapi.RequestContext, slug string, name string, ifRevision int64) (_ httpapi.Tenant, err error) {
+	ctx, span := tracing.Start(rctx.Context(), "TenantService.RenameTenant")
+	defer func() { tracing.End(span, err) }()
+
+	name = trim(name)
+	if name == "" {
+		return httpapi.Tenant{}, domain.ErrEmptyMessage
+	}
+
+	norm, err := validate.NormalizeSlug(slug)
+	if err != nil {
+		return httpapi.Tenant{}, domain.ErrInvalidSlug
+	}
+
+	t, err := s.repo.Rename(ctx, norm, name, ifRevision)
+	if err != nil {
+		return httpapi.Tenant{}, err
+	}
+	return httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug, Revision: t.Revision}, nil
+}
+
+func (s *TenantService) ListTenants(rctx httpapi.RequestContext, limit int, f pagination.Filter, cursor *pagination.Cursor) (_ httpapi.ListTenantsResult, err error) {
+	ctx, span := tracing.Start(rctx.Context(), "TenantService.ListTenants")
+	defer func() { tracing.End(span, err) }()
+
+	items, page, err := s.repo.List(ctx, limit, f, cursor)
 	if err != nil {
 		return httpapi.ListTenantsResult{}, err
 	}
 
 	out := make([]httpapi.Tenant, 0, len(items))
 	for _, t := range items {
-		out = append(out, httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug})
-	}
-
-	var nextEnc string
-	if next != nil {
-		nextEnc = pagination.Encode(*next)
+		out = append(out, httpapi.Tenant{ID: t.ID, Name: t.Name, Slug: t.Slug, Revision: t.Revision})
 	}
 
-	return httpapi.ListTenantsResult{Items: out, NextCursor: nextEnc}, nil
+	return httpapi.ListTenantsResult{Items: out, Page: page}, nil
 }
 
 func trim(s string) string {
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gochatbot/internal/blob"
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+)
+
+// UploadRepo is satisfied by *repo.UploadRepo.
+type UploadRepo interface {
+	CreateUpload(ctx context.Context, u repo.Upload) (repo.Upload, error)
+	GetUpload(ctx context.Context, uploadID string) (repo.Upload, error)
+}
+
+// UploadSessions is the part of *repo.SessionRepo uploads need.
+type UploadSessions interface {
+	GetSession(ctx context.Context, sessionID string) (repo.Session, error)
+}
+
+// defaultUploadTypes are the MIME types accepted when WithLimits is not
+// called.
+var defaultUploadTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}
+
+// UploadService keeps files sent in chat sessions: the bytes in a
+// blob.Store, the rest in uploads. Files are read back only through
+// Linker-signed links, which is also what transcripts link to.
+type UploadService struct {
+	uploads  UploadRepo
+	sessions UploadSessions
+	store    blob.Store
+	links    *blob.Linker
+	maxBytes int64
+	types    []string
+}
+
+func NewUploadService(uploads UploadRepo, sessions UploadSessions, store blob.Store, links *blob.Linker) *UploadService {
+	return &UploadService{uploads: uploads, sessions: sessions, store: store, links: links,
+		maxBytes: 10 << 20, types: defaultUploadTypes}
+}
+
+// WithLimits sets the largest file accepted and the MIME types it may be.
+// The type is sniffed from the content; what the client claims is ignored.
+func (s *UploadService) WithLimits(maxBytes int64, types []string) *UploadService {
+	s.maxBytes, s.types = maxBytes, types
+	return s
+}
+
+func (s *UploadService) MaxUploadBytes() int64 {
+	return s.maxBytes
+}
+
+func (s *UploadService) Upload(ctx context.Context, tenantID, sessionID, fileName string, size int64, body io.Reader) (_ httpapi.Upload, err error) {
+	ctx, span := tracing.Start(ctx, "UploadService.Upload")
+	defer func() { tracing.End(span, err) }()
+
+	if size <= 0 {
+		return httpapi.Upload{}, domain.ErrEmptyUpload
+	}
+	if size > s.maxBytes {
+		return httpapi.Upload{}, domain.ErrUploadTooLarge
+	}
+	sess, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return httpapi.Upload{}, err
+	}
+	if sess.TenantID != tenantID {
+		return httpapi.Upload{}, domain.ErrSessionNotFound
+	}
+	if sess.ClosedAt != nil {
+		return httpapi.Upload{}, domain.ErrSessionClosed
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return httpapi.Upload{}, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if mt, _, _ := mime.ParseMediaType(contentType); !slices.Contains(s.types, mt) {
+		return httpapi.Upload{}, domain.ErrUploadTypeNotAllowed
+	}
+
+	key := tenantID + "/" + sessionID + "/" + randomKey()
+	if err := s.store.Put(ctx, key, io.MultiReader(bytes.NewReader(head), body), size, contentType); err != nil {
+		return httpapi.Upload{}, err
+	}
+	u, err := s.uploads.CreateUpload(ctx, repo.Upload{TenantID: tenantID, SessionID: sessionID,
+		FileName: cleanFileName(fileName), ContentType: contentType, Size: size, StorageKey: key})
+	if err != nil {
+		if derr := s.store.Delete(ctx, key); derr != nil {
+			log.Printf("upload %s: removing orphaned blob: %v", key, derr)
+		}
+		return httpapi.Upload{}, err
+	}
+	return s.toDTO(u), nil
+}
+
+// OpenUpload checks the link before looking the upload up, so a bad link
+// says nothing about whether the file exists.
+func (s *UploadService) OpenUpload(ctx context.Context, uploadID string, link url.Values) (_ httpapi.UploadFile, err error) {
+	ctx, span := tracing.Start(ctx, "UploadService.OpenUpload")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.links.Verify(downloadPath(uploadID), link); err != nil {
+		return httpapi.UploadFile{}, domain.ErrInvalidDownloadLink
+	}
+	u, err := s.uploads.GetUpload(ctx, uploadID)
+	if err != nil {
+		return httpapi.UploadFile{}, err
+	}
+	body, err := s.store.Open(ctx, u.StorageKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		return httpapi.UploadFile{}, domain.ErrUploadNotFound
+	}
+	if err != nil {
+		return httpapi.UploadFile{}, err
+	}
+	return httpapi.UploadFile{Upload: s.toDTO(u), Body: body}, nil
+}
+
+// Link is a fresh signed download link for the upload, e.g. for
+// transcript.LinkUploads.
+func (s *UploadService) Link(uploadID string) string {
+	link, _ := s.links.Link(downloadPath(uploadID))
+	return link
+}
+
+func (s *UploadService) toDTO(u repo.Upload) httpapi.Upload {
+	link, expires := s.links.Link(downloadPath(u.ID))
+	return httpapi.Upload{ID: u.ID, SessionID: u.SessionID, FileName: u.FileName, ContentType: u.ContentType,
+		Size: u.Size, URL: link, URLExpiresAt: expires, CreatedAt: u.CreatedAt}
+}
+
+func downloadPath(uploadID string) string {
+	return "/v1/files/" + url.PathEscape(uploadID)
+}
+
+func randomKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cleanFileName keeps the base name the client sent, without control
+// characters, capped at 255 runes; it is only ever shown, never a path.
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if r := []rune(name); len(r) > 255 {
+		name = string(r[:255])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "upload"
+	}
+	return name
+}
@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/blob"
+	"gochatbot/internal/domain"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+)
+
+type fakeUploadRepo map[string]repo.Upload
+
+func (f fakeUploadRepo) CreateUpload(_ context.Context, u repo.Upload) (repo.Upload, error) {
+	u.ID = "u" + string(rune('1'+len(f)))
+	f[u.ID] = u
+	return u, nil
+}
+
+func (f fakeUploadRepo) GetUpload(_ context.Context, uploadID string) (repo.Upload, error) {
+	u, ok := f[uploadID]
+	if !ok {
+		return repo.Upload{}, domain.ErrUploadNotFound
+	}
+	return u, nil
+}
+
+type fakeUploadSessions map[string]repo.Session
+
+func (f fakeUploadSessions) GetSession(_ context.Context, sessionID string) (repo.Session, error) {
+	s, ok := f[sessionID]
+	if !ok {
+		return repo.Session{}, domain.ErrSessionNotFound
+	}
+	return s, nil
+}
+
+func newUploadService(t *testing.T) (*service.UploadService, fakeUploadRepo, blob.Store) {
+	store, err := blob.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	closed := time.Now()
+	uploads := fakeUploadRepo{}
+	sessions := fakeUploadSessions{
+		"s1": {ID: "s1", TenantID: "t1"},
+		"s2": {ID: "s2", TenantID: "t1", ClosedAt: &closed},
+	}
+	links := blob.NewLinker([]byte("k"), "https://api.example.com", time.Hour, nil)
+	return service.NewUploadService(uploads, sessions, store, links).WithLimits(1<<10, []string{"application/pdf", "text/plain"}), uploads, store
+}
+
+func TestUploadService_UploadAndDownload(t *testing.T) {
+	svc, uploads, _ := newUploadService(t)
+	ctx := context.Background()
+	pdf := "%PDF-1.7\n" + strings.Repeat("x", 600)
+
+	up, err := svc.Upload(ctx, "t1", "s1", `C:\Users\chris\police report.pdf`, int64(len(pdf)), strings.NewReader(pdf))
+	require.NoError(t, err)
+	require.Equal(t, "police report.pdf", up.FileName)
+	require.Equal(t, "application/pdf", up.ContentType)
+	require.Equal(t, int64(len(pdf)), up.Size)
+	require.True(t, strings.HasPrefix(uploads[up.ID].StorageKey, "t1/s1/"))
+	require.True(t, strings.HasPrefix(up.URL, "https://api.example.com/v1/files/"+up.ID+"?"))
+
+	link, err := url.Parse(up.URL)
+	require.NoError(t, err)
+	f, err := svc.OpenUpload(ctx, up.ID, link.Query())
+	require.NoError(t, err)
+	got, _ := io.ReadAll(f.Body)
+	require.NoError(t, f.Body.Close())
+	require.Equal(t, pdf, string(got), "the sniffed head is stored too")
+
+	_, err = svc.OpenUpload(ctx, "u9", link.Query())
+	require.ErrorIs(t, err, domain.ErrInvalidDownloadLink, "a link is for one file")
+	q := link.Query()
+	q.Set("expires", "9999999999")
+	_, err = svc.OpenUpload(ctx, up.ID, q)
+	require.ErrorIs(t, err, domain.ErrInvalidDownloadLink)
+
+	link, err = url.Parse(svc.Link(up.ID))
+	require.NoError(t, err)
+	f, err = svc.OpenUpload(ctx, up.ID, link.Query())
+	require.NoError(t, err, "Link gives a fresh link")
+	require.NoError(t, f.Body.Close())
+}
+
+func TestUploadService_Rejects(t *testing.T) {
+	svc, uploads, _ := newUploadService(t)
+	ctx := context.Background()
+	upload := func(sessionID, content string) error {
+		_, err := svc.Upload(ctx, "t1", sessionID, "f", int64(len(content)), strings.NewReader(content))
+		return err
+	}
+
+	require.ErrorIs(t, upload("s1", ""), domain.ErrEmptyUpload)
+	require.ErrorIs(t, upload("s1", strings.Repeat("a", 1<<10+1)), domain.ErrUploadTooLarge)
+	require.ErrorIs(t, upload("s1", "<html><script>alert(1)</script>"), domain.ErrUploadTypeNotAllowed, "sniffed, not declared")
+	require.ErrorIs(t, upload("s1", "PK\x03\x04zip"), domain.ErrUploadTypeNotAllowed)
+	require.ErrorIs(t, upload("s2", "notes"), domain.ErrSessionClosed)
+	require.ErrorIs(t, upload("nope", "notes"), domain.ErrSessionNotFound)
+	_, err := svc.Upload(ctx, "t2", "s1", "f", 5, bytes.NewReader([]byte("notes")))
+	require.ErrorIs(t, err, domain.ErrSessionNotFound, "other tenants' sessions are not found")
+	require.Empty(t, uploads)
+
+	require.NoError(t, upload("s1", "plain notes"))
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/tracing"
+	"gochatbot/internal/webhook"
+)
+
+// WebhookJobKind is the job that sends one webhook delivery. The queue's
+// retries are the delivery's retries: up to the job's max attempts, backing
+// off as jobs.Backoff does.
+const WebhookJobKind = "deliver_webhook"
+
+// EventPublisher is satisfied by *WebhookService.
+type EventPublisher interface {
+	Publish(ctx context.Context, tenantID, eventType string, data map[string]any) error
+}
+
+// WebhookRepo is satisfied by *repo.WebhookRepo.
+type WebhookRepo interface {
+	CreateEndpoint(ctx context.Context, tenantID, url, secret string, events []string) (repo.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, tenantID string) ([]repo.WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, tenantID, endpointID string) (repo.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, tenantID, endpointID string) error
+
+	CreateDeliveries(ctx context.Context, tenantID, eventID, eventType string, payload []byte) ([]string, error)
+	GetDelivery(ctx context.Context, deliveryID string) (repo.WebhookDelivery, repo.WebhookEndpoint, error)
+	RecordAttempt(ctx context.Context, deliveryID string, a repo.WebhookAttempt, status string) (repo.WebhookAttempt, error)
+	ListDeliveries(ctx context.Context, tenantID, endpointID string, limit int) ([]repo.WebhookDelivery, error)
+	ResetDelivery(ctx context.Context, tenantID, endpointID, deliveryID string) (repo.WebhookDelivery, error)
+}
+
+// WebhookSender is satisfied by *webhook.Sender.
+type WebhookSender interface {
+	Send(ctx context.Context, r webhook.Request) webhook.Result
+}
+
+// WebhookService manages tenants' endpoints and delivers events to them.
+// Publish only records a delivery per subscribed endpoint and queues it;
+// HandleJob does the sending, so a slow receiver never holds up the code
+// that raised the event.
+type WebhookService struct {
+	repo     WebhookRepo
+	queue    Queue
+	sender   WebhookSender
+	now      func() time.Time
+	insecure bool
+}
+
+func NewWebhookService(r WebhookRepo, queue Queue, sender WebhookSender, now func() time.Time) *WebhookService {
+	if now == nil {
+		now = time.Now
+	}
+	return &WebhookService{repo: r, queue: queue, sender: sender, now: now}
+}
+
+// WithInsecureURLs accepts plain http endpoints, for development against a
+// local receiver.
+func (s *WebhookService) WithInsecureURLs(allow bool) *WebhookService {
+	s.insecure = allow
+	return s
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, tenantID string, req httpapi.CreateWebhookRequest) (_ httpapi.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.CreateWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	u, err := s.checkURL(req.URL)
+	if err != nil {
+		return httpapi.Webhook{}, err
+	}
+	events, err := checkEvents(req.Events)
+	if err != nil {
+		return httpapi.Webhook{}, err
+	}
+
+	e, err := s.repo.CreateEndpoint(ctx, tenantID, u, webhook.NewSecret(), events)
+	if err != nil {
+		return httpapi.Webhook{}, err
+	}
+	out := webhookDTO(e)
+	out.Secret = e.Secret
+	return out, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, tenantID string) (_ httpapi.ListWebhooksResult, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListWebhooks")
+	defer func() { tracing.End(span, err) }()
+
+	items, err := s.repo.ListEndpoints(ctx, tenantID)
+	if err != nil {
+		return httpapi.ListWebhooksResult{}, err
+	}
+	out := make([]httpapi.Webhook, 0, len(items))
+	for _, e := range items {
+		out = append(out, webhookDTO(e))
+	}
+	return httpapi.ListWebhooksResult{Items: out}, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, tenantID, webhookID string) (_ httpapi.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.GetWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	e, err := s.repo.GetEndpoint(ctx, tenantID, webhookID)
+	if err != nil {
+		return httpapi.Webhook{}, err
+	}
+	return webhookDTO(e), nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, tenantID, webhookID string) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.DeleteWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.DeleteEndpoint(ctx, tenantID, webhookID)
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, tenantID, webhookID string, limit int) (_ httpapi.ListWebhookDeliveriesResult, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	items, err := s.repo.ListDeliveries(ctx, tenantID, webhookID, limit)
+	if err != nil {
+		return httpapi.ListWebhookDeliveriesResult{}, err
+	}
+	out := make([]httpapi.WebhookDelivery, 0, len(items))
+	for _, d := range items {
+		out = append(out, deliveryDTO(d))
+	}
+	return httpapi.ListWebhookDeliveriesResult{Items: out}, nil
+}
+
+// Redeliver sends a succeeded or failed delivery again, with the same event
+// ID, so receivers that already have it can drop it. A delivery still being
+// retried is domain.ErrDeliveryPending; one that is pending only because
+// Publish could not queue it is queued now.
+func (s *WebhookService) Redeliver(ctx context.Context, tenantID, webhookID, deliveryID string) (_ httpapi.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Redeliver")
+	defer func() { tracing.End(span, err) }()
+
+	d, err := s.repo.ResetDelivery(ctx, tenantID, webhookID, deliveryID)
+	if err != nil {
+		return httpapi.WebhookDelivery{}, err
+	}
+	if err := s.queue.Enqueue(ctx, WebhookJobKind, map[string]any{"delivery_id": d.ID}); err != nil {
+		return httpapi.WebhookDelivery{}, err
+	}
+	return deliveryDTO(d), nil
+}
+
+// Publish records the event for every endpoint of the tenant subscribed to
+// eventType and queues the deliveries. A delivery that cannot be queued is
+// only logged, like a reply in MessageService.PostUserMessage; Redeliver
+// picks it up later.
+func (s *WebhookService) Publish(ctx context.Context, tenantID, eventType string, data map[string]any) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Publish")
+	defer func() { tracing.End(span, err) }()
+
+	if !webhook.Known(eventType) {
+		return fmt.Errorf("%w: %q", domain.ErrInvalidWebhookEvent, eventType)
+	}
+	ev := webhook.NewEvent(eventType, tenantID, data, s.now())
+	body, err := ev.Body()
+	if err != nil {
+		return err
+	}
+	ids, err := s.repo.CreateDeliveries(ctx, tenantID, ev.ID, ev.Type, body)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.queue.Enqueue(ctx, WebhookJobKind, map[string]any{"delivery_id": id}); err != nil {
+			log.Printf("webhook delivery %s: queue: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// HandleJob runs a WebhookJobKind job; its payload carries the delivery_id.
+// Every attempt is logged on the delivery. A failed attempt returns an
+// error so the queue retries it; the last one marks the delivery failed.
+func (s *WebhookService) HandleJob(ctx context.Context, job repo.Job) error {
+	deliveryID, _ := job.Payload["delivery_id"].(string)
+	if deliveryID == "" {
+		return fmt.Errorf("webhook job %s: missing delivery_id", job.ID)
+	}
+
+	d, e, err := s.repo.GetDelivery(ctx, deliveryID)
+	if errors.Is(err, domain.ErrDeliveryNotFound) {
+		return nil // the endpoint was deleted
+	}
+	if err != nil {
+		return err
+	}
+	if d.Status != repo.DeliveryPending {
+		return nil // already settled, e.g. a job left over from before a redelivery
+	}
+
+	res := s.sender.Send(ctx, webhook.Request{
+		URL: e.URL, Secret: e.Secret, DeliveryID: d.ID, EventID: d.EventID, EventType: d.EventType, Body: d.Payload,
+	})
+
+	a := repo.WebhookAttempt{StatusCode: res.StatusCode, ResponseBody: res.Body, Duration: res.Duration}
+	status := repo.DeliverySucceeded
+	var sendErr error
+	switch {
+	case res.Err != nil:
+		a.Error = res.Err.Error()
+		sendErr = res.Err
+	case !res.OK():
+		sendErr = fmt.Errorf("endpoint answered %d", res.StatusCode)
+	}
+	if sendErr != nil {
+		status = repo.DeliveryPending
+		if job.Attempts >= job.MaxAttempts {
+			status = repo.DeliveryFailed
+		}
+	}
+
+	if _, err := s.repo.RecordAttempt(ctx, d.ID, a, status); err != nil {
+		if errors.Is(err, domain.ErrDeliveryNotFound) {
+			return nil
+		}
+		return err
+	}
+	if sendErr != nil {
+		return fmt.Errorf("webhook delivery %s: %w", d.ID, sendErr)
+	}
+	return nil
+}
+
+// checkURL accepts absolute https URLs (http too when insecure) without
+// credentials; credentials belong in the signature, not the URL.
+func (s *WebhookService) checkURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return "", domain.ErrInvalidWebhookURL
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !s.insecure {
+			return "", domain.ErrInvalidWebhookURL
+		}
+	default:
+		return "", domain.ErrInvalidWebhookURL
+	}
+	return u.String(), nil
+}
+
+// checkEvents requires at least one known event type and drops duplicates.
+func checkEvents(in []string) ([]string, error) {
+	if len(in) == 0 {
+		return nil, domain.ErrInvalidWebhookEvent
+	}
+	out := make([]string, 0, len(in))
+	for _, t := range in {
+		t = strings.TrimSpace(t)
+		if !webhook.Known(t) {
+			return nil, fmt.Errorf("%w: %q", domain.ErrInvalidWebhookEvent, t)
+		}
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func webhookDTO(e repo.WebhookEndpoint) httpapi.Webhook {
+	return httpapi.Webhook{ID: e.ID, TenantID: e.TenantID, URL: e.URL, Events: e.Events, CreatedAt: e.CreatedAt}
+}
+
+func deliveryDTO(d repo.WebhookDelivery) httpapi.WebhookDelivery {
+	attempts := make([]httpapi.WebhookAttempt, 0, len(d.Log))
+	for _, a := range d.Log {
+		attempts = append(attempts, httpapi.WebhookAttempt{Attempt: a.Attempt, StatusCode: a.StatusCode, Error: a.Error,
+			ResponseBody: a.ResponseBody, DurationMS: a.Duration.Milliseconds(), CreatedAt: a.CreatedAt})
+	}
+	return httpapi.WebhookDelivery{ID: d.ID, EventID: d.EventID, EventType: d.EventType, Status: d.Status,
+		Attempts: attempts, CreatedAt: d.CreatedAt, UpdatedAt: d.UpdatedAt}
+}
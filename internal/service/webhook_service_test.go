@@ -0,0 +1,280 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/domain"
+	"gochatbot/internal/httpapi"
+	"gochatbot/internal/repo"
+	"gochatbot/internal/service"
+	"gochatbot/internal/webhook"
+)
+
+type fakeWebhookRepo struct {
+	endpoints  []repo.WebhookEndpoint
+	deliveries map[string]*repo.WebhookDelivery
+}
+
+func newFakeWebhookRepo(endpoints ...repo.WebhookEndpoint) *fakeWebhookRepo {
+	return &fakeWebhookRepo{endpoints: endpoints, deliveries: map[string]*repo.WebhookDelivery{}}
+}
+
+func (f *fakeWebhookRepo) CreateEndpoint(_ context.Context, tenantID, url, secret string, events []string) (repo.WebhookEndpoint, error) {
+	e := repo.WebhookEndpoint{ID: "wh1", TenantID: tenantID, URL: url, Secret: secret, Events: events}
+	f.endpoints = append(f.endpoints, e)
+	return e, nil
+}
+
+func (f *fakeWebhookRepo) ListEndpoints(_ context.Context, tenantID string) ([]repo.WebhookEndpoint, error) {
+	return f.endpoints, nil
+}
+
+func (f *fakeWebhookRepo) GetEndpoint(_ context.Context, tenantID, endpointID string) (repo.WebhookEndpoint, error) {
+	for _, e := range f.endpoints {
+		if e.ID == endpointID && e.TenantID == tenantID {
+			return e, nil
+		}
+	}
+	return repo.WebhookEndpoint{}, domain.ErrWebhookNotFound
+}
+
+func (f *fakeWebhookRepo) DeleteEndpoint(context.Context, string, string) error {
+	return nil
+}
+
+func (f *fakeWebhookRepo) CreateDeliveries(_ context.Context, tenantID, eventID, eventType string, payload []byte) ([]string, error) {
+	var ids []string
+	for _, e := range f.endpoints {
+		if e.TenantID != tenantID || !slices.Contains(e.Events, eventType) {
+			continue
+		}
+		id := "d-" + e.ID
+		if _, taken := f.deliveries[id]; taken {
+			id = fmt.Sprintf("d-%s-%d", e.ID, len(f.deliveries))
+		}
+		f.deliveries[id] = &repo.WebhookDelivery{ID: id, EndpointID: e.ID, EventID: eventID, EventType: eventType,
+			Payload: payload, Status: repo.DeliveryPending}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (f *fakeWebhookRepo) GetDelivery(_ context.Context, deliveryID string) (repo.WebhookDelivery, repo.WebhookEndpoint, error) {
+	d, ok := f.deliveries[deliveryID]
+	if !ok {
+		return repo.WebhookDelivery{}, repo.WebhookEndpoint{}, domain.ErrDeliveryNotFound
+	}
+	for _, e := range f.endpoints {
+		if e.ID == d.EndpointID {
+			return *d, e, nil
+		}
+	}
+	return repo.WebhookDelivery{}, repo.WebhookEndpoint{}, domain.ErrDeliveryNotFound
+}
+
+func (f *fakeWebhookRepo) RecordAttempt(_ context.Context, deliveryID string, a repo.WebhookAttempt, status string) (repo.WebhookAttempt, error) {
+	d := f.deliveries[deliveryID]
+	d.Attempts++
+	d.Status = status
+	a.Attempt = d.Attempts
+	d.Log = append(d.Log, a)
+	return a, nil
+}
+
+func (f *fakeWebhookRepo) ListDeliveries(context.Context, string, string, int) ([]repo.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (f *fakeWebhookRepo) ResetDelivery(_ context.Context, _, _, deliveryID string) (repo.WebhookDelivery, error) {
+	d, ok := f.deliveries[deliveryID]
+	if !ok {
+		return repo.WebhookDelivery{}, domain.ErrDeliveryNotFound
+	}
+	if d.Status == repo.DeliveryPending {
+		return repo.WebhookDelivery{}, domain.ErrDeliveryPending
+	}
+	d.Status = repo.DeliveryPending
+	return *d, nil
+}
+
+// fakeSender answers with the next of its results and keeps what it was sent.
+type fakeSender struct {
+	results []webhook.Result
+	sent    []webhook.Request
+}
+
+func (f *fakeSender) Send(_ context.Context, r webhook.Request) webhook.Result {
+	f.sent = append(f.sent, r)
+	res := f.results[0]
+	if len(f.results) > 1 {
+		f.results = f.results[1:]
+	}
+	return res
+}
+
+// fakePublisher keeps the events published through it.
+type fakePublisher struct {
+	events []publishedEvent
+}
+
+type publishedEvent struct {
+	tenantID, eventType string
+	data                map[string]any
+}
+
+func (f *fakePublisher) Publish(_ context.Context, tenantID, eventType string, data map[string]any) error {
+	f.events = append(f.events, publishedEvent{tenantID, eventType, data})
+	return nil
+}
+
+func TestWebhookService_CreateWebhook_Validates(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewWebhookService(newFakeWebhookRepo(), &fakeQueue{}, &fakeSender{}, nil)
+
+	hook, err := svc.CreateWebhook(ctx, "t1", httpapi.CreateWebhookRequest{
+		URL: " https://example.com/hook ", Events: []string{"template.published", "lead.created", "lead.created"}})
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/hook", hook.URL)
+	require.Equal(t, []string{"lead.created", "template.published"}, hook.Events)
+	require.Contains(t, hook.Secret, "whsec_", "the secret is returned once, at creation")
+
+	got, err := svc.GetWebhook(ctx, "t1", hook.ID)
+	require.NoError(t, err)
+	require.Empty(t, got.Secret)
+
+	for _, url := range []string{"http://example.com", "https://user:pw@example.com", "ftp://example.com", "https://", "example.com"} {
+		_, err := svc.CreateWebhook(ctx, "t1", httpapi.CreateWebhookRequest{URL: url, Events: []string{"lead.created"}})
+		require.ErrorIs(t, err, domain.ErrInvalidWebhookURL, url)
+	}
+	for _, events := range [][]string{nil, {"lead.deleted"}} {
+		_, err := svc.CreateWebhook(ctx, "t1", httpapi.CreateWebhookRequest{URL: "https://example.com", Events: events})
+		require.ErrorIs(t, err, domain.ErrInvalidWebhookEvent)
+	}
+
+	insecure := service.NewWebhookService(newFakeWebhookRepo(), &fakeQueue{}, &fakeSender{}, nil).WithInsecureURLs(true)
+	_, err = insecure.CreateWebhook(ctx, "t1", httpapi.CreateWebhookRequest{URL: "http://localhost:9000", Events: []string{"lead.created"}})
+	require.NoError(t, err)
+}
+
+func TestWebhookService_PublishQueuesSubscribedEndpoints(t *testing.T) {
+	r := newFakeWebhookRepo(
+		repo.WebhookEndpoint{ID: "a", TenantID: "t1", Events: []string{webhook.LeadCreated}},
+		repo.WebhookEndpoint{ID: "b", TenantID: "t1", Events: []string{webhook.SessionClosed}},
+		repo.WebhookEndpoint{ID: "c", TenantID: "t2", Events: []string{webhook.LeadCreated}},
+	)
+	q := &fakeQueue{}
+	t0 := time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
+	svc := service.NewWebhookService(r, q, &fakeSender{}, func() time.Time { return t0 })
+
+	require.NoError(t, svc.Publish(context.Background(), "t1", webhook.LeadCreated, map[string]any{"lead_id": "l1"}))
+
+	require.Len(t, q.jobs, 1)
+	require.Equal(t, service.WebhookJobKind, q.jobs[0].kind)
+	require.Equal(t, "d-a", q.jobs[0].payload["delivery_id"])
+
+	var ev webhook.Event
+	require.NoError(t, json.Unmarshal(r.deliveries["d-a"].Payload, &ev))
+	require.Equal(t, webhook.LeadCreated, ev.Type)
+	require.Equal(t, 1, ev.Version)
+	require.Equal(t, "t1", ev.TenantID)
+	require.Equal(t, t0, ev.CreatedAt)
+	require.Equal(t, "l1", ev.Data["lead_id"])
+
+	require.ErrorIs(t, svc.Publish(context.Background(), "t1", "lead.deleted", nil), domain.ErrInvalidWebhookEvent)
+}
+
+func TestWebhookService_HandleJob_RetriesThenFails(t *testing.T) {
+	ctx := context.Background()
+	r := newFakeWebhookRepo(repo.WebhookEndpoint{ID: "a", TenantID: "t1", URL: "https://example.com", Secret: "whsec_a",
+		Events: []string{webhook.LeadCreated}})
+	sender := &fakeSender{results: []webhook.Result{
+		{Err: errors.New("dial tcp: i/o timeout"), Duration: time.Second},
+		{StatusCode: http.StatusInternalServerError, Body: "oops"},
+	}}
+	q := &fakeQueue{}
+	svc := service.NewWebhookService(r, q, sender, nil)
+	require.NoError(t, svc.Publish(ctx, "t1", webhook.LeadCreated, nil))
+
+	job := repo.Job{ID: "j1", Kind: service.WebhookJobKind, Payload: q.jobs[0].payload, Attempts: 1, MaxAttempts: 2}
+	require.Error(t, svc.HandleJob(ctx, job), "a failed attempt is retried by the queue")
+	d := r.deliveries["d-a"]
+	require.Equal(t, repo.DeliveryPending, d.Status)
+	require.Equal(t, "dial tcp: i/o timeout", d.Log[0].Error)
+	require.Zero(t, d.Log[0].StatusCode)
+
+	job.Attempts = 2
+	require.Error(t, svc.HandleJob(ctx, job))
+	require.Equal(t, repo.DeliveryFailed, d.Status, "the last attempt settles the delivery")
+	require.Equal(t, http.StatusInternalServerError, d.Log[1].StatusCode)
+	require.Equal(t, "oops", d.Log[1].ResponseBody)
+
+	sent := sender.sent[0]
+	require.Equal(t, "https://example.com", sent.URL)
+	require.Equal(t, "whsec_a", sent.Secret)
+	require.Equal(t, "d-a", sent.DeliveryID)
+	require.Equal(t, d.Payload, sent.Body)
+	require.Equal(t, d.EventID, sent.EventID)
+
+	require.NoError(t, svc.HandleJob(ctx, job), "a settled delivery is not sent again")
+	require.Len(t, sender.sent, 2)
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	ctx := context.Background()
+	r := newFakeWebhookRepo(repo.WebhookEndpoint{ID: "a", TenantID: "t1", Events: []string{webhook.LeadCreated}})
+	sender := &fakeSender{results: []webhook.Result{{StatusCode: http.StatusNoContent}}}
+	q := &fakeQueue{}
+	svc := service.NewWebhookService(r, q, sender, nil)
+	require.NoError(t, svc.Publish(ctx, "t1", webhook.LeadCreated, nil))
+
+	_, err := svc.Redeliver(ctx, "t1", "a", "d-a")
+	require.ErrorIs(t, err, domain.ErrDeliveryPending, "still being delivered")
+
+	require.NoError(t, svc.HandleJob(ctx, repo.Job{Payload: q.jobs[0].payload, Attempts: 1, MaxAttempts: 5}))
+	require.Equal(t, repo.DeliverySucceeded, r.deliveries["d-a"].Status)
+
+	d, err := svc.Redeliver(ctx, "t1", "a", "d-a")
+	require.NoError(t, err)
+	require.Equal(t, repo.DeliveryPending, d.Status)
+	require.Len(t, q.jobs, 2)
+	require.Equal(t, "d-a", q.jobs[1].payload["delivery_id"])
+
+	require.NoError(t, svc.HandleJob(ctx, repo.Job{Payload: q.jobs[1].payload, Attempts: 1, MaxAttempts: 5}))
+	require.Len(t, r.deliveries["d-a"].Log, 2, "the log continues across redeliveries")
+	require.Equal(t, sender.sent[0].EventID, sender.sent[1].EventID)
+
+	_, err = svc.Redeliver(ctx, "t1", "a", "nope")
+	require.ErrorIs(t, err, domain.ErrDeliveryNotFound)
+}
+
+func TestWebhookService_ClosingASessionDelivers(t *testing.T) {
+	ctx := context.Background()
+	hooks := newFakeWebhookRepo(repo.WebhookEndpoint{ID: "a", TenantID: "t1", URL: "https://example.com",
+		Events: []string{webhook.SessionClosed, webhook.LeadCreated}})
+	q := &fakeQueue{}
+	webhooks := service.NewWebhookService(hooks, q, &fakeSender{}, nil)
+
+	sessions := newFakeRepo()
+	sessions.sessions["s1"] = service.Session{ID: "s1", TenantID: "t1"}
+	svc := service.NewSessionService(sessions, q, nil).WithEvents(webhooks)
+	require.NoError(t, svc.CloseSession(ctx, "t1", "s1"))
+
+	var types, queued []string
+	for _, d := range hooks.deliveries {
+		types = append(types, d.EventType)
+	}
+	for _, j := range q.jobs {
+		queued = append(queued, j.kind)
+	}
+	require.ElementsMatch(t, []string{webhook.SessionClosed, webhook.LeadCreated}, types, "one delivery per event and endpoint")
+	require.Equal(t, []string{service.WebhookJobKind, service.WebhookJobKind, service.LeadExportJobKind}, queued)
+}
@@ -0,0 +1,384 @@
+// Package shadow mirrors GET traffic to the legacy Node backend and compares
+// its responses with ours (Migration.md, Phase 1). Clients only ever see the
+// Go response; the legacy call happens after it has been written.
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gochatbot/internal/jsondiff"
+)
+
+// Outcomes reported to the Recorder.
+const (
+	OutcomeMatch    = "match"
+	OutcomeMismatch = "mismatch"
+	OutcomeError    = "error"   // legacy call failed or a body was too large
+	OutcomeDropped  = "dropped" // MaxInFlight comparisons were already running
+)
+
+// HeaderShadow is set on mirrored requests so the legacy side can tell them
+// apart from real traffic.
+const HeaderShadow = "X-Shadow-Request"
+
+// maxBody caps how much of either response is buffered for comparison.
+const maxBody = 1 << 20
+
+// Recorder receives one outcome per mirrored request; *metrics.Metrics satisfies it.
+type Recorder interface {
+	ShadowCompared(route, outcome string)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) ShadowCompared(string, string) {}
+
+type Config struct {
+	// LegacyURL is the Node base URL; request paths are appended to it.
+	LegacyURL string
+	// IgnorePaths are JSON paths left out of the comparison, e.g. "id" or
+	// "items[*].created_at". A leading "$." is optional and "*" matches any
+	// object key or array index. Everything below an ignored path is ignored.
+	IgnorePaths []string
+	Timeout     time.Duration
+	MaxInFlight int
+	Client      *http.Client
+}
+
+// Mismatch describes one request whose responses differ.
+type Mismatch struct {
+	Method       string
+	Path         string // path and query as requested
+	Route        string
+	GoStatus     int
+	LegacyStatus int
+	// Changes lists body differences from Go to legacy; nil when the
+	// bodies were not both JSON.
+	Changes []jsondiff.Change
+}
+
+type Comparator struct {
+	base    *url.URL
+	ignore  []*regexp.Regexp
+	timeout time.Duration
+	client  *http.Client
+
+	rec    Recorder
+	report func(Mismatch)
+
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+func New(cfg Config) (*Comparator, error) {
+	base, err := url.Parse(strings.TrimRight(cfg.LegacyURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("shadow: legacy url must be an absolute http(s) URL, got %q", cfg.LegacyURL)
+	}
+
+	ignore := make([]*regexp.Regexp, 0, len(cfg.IgnorePaths))
+	for _, p := range cfg.IgnorePaths {
+		re, err := compileIgnore(p)
+		if err != nil {
+			return nil, err
+		}
+		ignore = append(ignore, re)
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 32
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &Comparator{
+		base:    base,
+		ignore:  ignore,
+		timeout: cfg.Timeout,
+		client:  client,
+		rec:     nopRecorder{},
+		report:  logMismatch,
+		sem:     make(chan struct{}, cfg.MaxInFlight),
+	}, nil
+}
+
+func (c *Comparator) WithRecorder(rec Recorder) *Comparator {
+	if rec != nil {
+		c.rec = rec
+	}
+	return c
+}
+
+// WithReporter replaces the default log line for mismatches.
+func (c *Comparator) WithReporter(fn func(Mismatch)) *Comparator {
+	if fn != nil {
+		c.report = fn
+	}
+	return c
+}
+
+// compileIgnore turns "items[*].created_at" into a regexp over jsondiff paths
+// that also matches anything nested below the path.
+func compileIgnore(p string) (*regexp.Regexp, error) {
+	p = strings.TrimSpace(p)
+	if p == "" || p == "$" {
+		return nil, fmt.Errorf("shadow: empty ignore path")
+	}
+	if !strings.HasPrefix(p, "$") {
+		if !strings.HasPrefix(p, "[") {
+			p = "." + p
+		}
+		p = "$" + p
+	}
+	expr := regexp.QuoteMeta(p)
+	expr = strings.ReplaceAll(expr, `\[\*\]`, `\[\d+\]`)
+	expr = strings.ReplaceAll(expr, `\.\*`, `\.[^.\[]+`)
+	re, err := regexp.Compile(`^` + expr + `($|[.\[])`)
+	if err != nil {
+		return nil, fmt.Errorf("shadow: ignore path %q: %w", p, err)
+	}
+	return re, nil
+}
+
+func (c *Comparator) ignored(path string) bool {
+	for _, re := range c.ignore {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait blocks until in-flight comparisons finish; call it on shutdown.
+func (c *Comparator) Wait() {
+	c.wg.Wait()
+}
+
+// Serve runs next for the client and then, for GETs, replays the request
+// against the legacy backend in the background. route is the chi route
+// pattern used to label the outcome. Other methods are never replayed.
+func (c *Comparator) Serve(w http.ResponseWriter, r *http.Request, route string, next http.Handler) {
+	if r.Method != http.MethodGet {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &bodyRecorder{ResponseWriter: w}
+	next.ServeHTTP(rec, r)
+
+	select {
+	case c.sem <- struct{}{}:
+	default:
+		c.rec.ShadowCompared(route, OutcomeDropped)
+		return
+	}
+
+	// r must not be touched once the handler returns, so copy what the
+	// replay needs now
+	req := Request{
+		Path:   r.URL.RequestURI(),
+		Route:  route,
+		Header: r.Header.Clone(),
+	}
+	status, body, overflow := rec.result()
+	ctx := context.WithoutCancel(r.Context())
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() { <-c.sem }()
+
+		if overflow {
+			log.Printf("shadow: %s: response larger than %d bytes, not compared", req.Path, maxBody)
+			c.rec.ShadowCompared(route, OutcomeError)
+			return
+		}
+		if _, err := c.Compare(ctx, req, status, body); err != nil {
+			log.Printf("shadow: %s: %v", req.Path, err)
+		}
+	}()
+}
+
+// Request is the part of an incoming GET needed to replay it.
+type Request struct {
+	Path   string // path and query
+	Route  string
+	Header http.Header
+}
+
+// Compare fetches req from the legacy backend and compares the response with
+// the Go one. It records the outcome and reports mismatches; the returned
+// Mismatch is nil when the responses agree.
+func (c *Comparator) Compare(ctx context.Context, req Request, goStatus int, goBody []byte) (*Mismatch, error) {
+	legacyStatus, legacyBody, err := c.fetch(ctx, req)
+	if err != nil {
+		c.rec.ShadowCompared(req.Route, OutcomeError)
+		return nil, err
+	}
+
+	m := Mismatch{
+		Method:       http.MethodGet,
+		Path:         req.Path,
+		Route:        req.Route,
+		GoStatus:     goStatus,
+		LegacyStatus: legacyStatus,
+	}
+	same := goStatus == legacyStatus
+
+	var a, b any
+	if json.Unmarshal(goBody, &a) == nil && json.Unmarshal(legacyBody, &b) == nil {
+		for _, ch := range jsondiff.Values(a, b) {
+			if !c.ignored(ch.Path) {
+				m.Changes = append(m.Changes, ch)
+			}
+		}
+		same = same && len(m.Changes) == 0
+	} else {
+		same = same && bytes.Equal(bytes.TrimSpace(goBody), bytes.TrimSpace(legacyBody))
+	}
+
+	if same {
+		c.rec.ShadowCompared(req.Route, OutcomeMatch)
+		return nil, nil
+	}
+	c.rec.ShadowCompared(req.Route, OutcomeMismatch)
+	c.report(m)
+	return &m, nil
+}
+
+// hopHeaders are connection-scoped and must not be forwarded.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func (c *Comparator) fetch(ctx context.Context, req Request) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	target := *c.base
+	u, err := url.Parse(req.Path)
+	if err != nil {
+		return 0, nil, err
+	}
+	target.Path = c.base.Path + u.Path
+	target.RawQuery = u.RawQuery
+
+	out, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	out.Header = req.Header.Clone()
+	if out.Header == nil {
+		out.Header = http.Header{}
+	}
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	// let the transport negotiate compression so we compare decoded bodies
+	out.Header.Del("Accept-Encoding")
+	out.Header.Set(HeaderShadow, "1")
+
+	resp, err := c.client.Do(out)
+	if err != nil {
+		return 0, nil, fmt.Errorf("legacy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+	if err != nil {
+		return 0, nil, fmt.Errorf("legacy: read body: %w", err)
+	}
+	if len(body) > maxBody {
+		return 0, nil, fmt.Errorf("legacy: response larger than %d bytes", maxBody)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// logMismatch is the default reporter: one line with the first few changes.
+func logMismatch(m Mismatch) {
+	const show = 5
+	var b strings.Builder
+	for i, ch := range m.Changes {
+		if i == show {
+			fmt.Fprintf(&b, "; +%d more", len(m.Changes)-show)
+			break
+		}
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s %s", ch.Op, ch.Path)
+		if ch.Op == jsondiff.OpChanged {
+			fmt.Fprintf(&b, " %s -> %s", compact(ch.From), compact(ch.To))
+		}
+	}
+	if len(m.Changes) == 0 && m.GoStatus == m.LegacyStatus {
+		b.WriteString("non-JSON bodies differ")
+	}
+	log.Printf("shadow: mismatch %s %s (route %s) go=%d legacy=%d: %s",
+		m.Method, m.Path, m.Route, m.GoStatus, m.LegacyStatus, b.String())
+}
+
+func compact(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// bodyRecorder tees the response to the client and keeps a bounded copy.
+type bodyRecorder struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *bodyRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(b) > maxBody {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *bodyRecorder) result() (int, []byte, bool) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return status, w.buf.Bytes(), w.overflow
+}
@@ -0,0 +1,165 @@
+package shadow_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/jsondiff"
+	"gochatbot/internal/shadow"
+)
+
+type fakeRecorder struct {
+	mu       sync.Mutex
+	outcomes []string
+}
+
+func (f *fakeRecorder) ShadowCompared(route, outcome string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outcomes = append(f.outcomes, route+" "+outcome)
+}
+
+// legacy stands in for the Node backend and remembers the last request.
+type legacy struct {
+	mu     sync.Mutex
+	last   *http.Request
+	status int
+	body   string
+}
+
+func (l *legacy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	l.last = r
+	l.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(l.status)
+	_, _ = w.Write([]byte(l.body))
+}
+
+// serveGo answers with body as the Go side and mirrors through cmp.
+func serveGo(cmp *shadow.Comparator, body string) http.Handler {
+	goHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cmp.Serve(w, r, "/v1/tenants", goHandler)
+	})
+}
+
+func setup(t *testing.T, l *legacy, cfg shadow.Config) (*shadow.Comparator, *fakeRecorder, *[]shadow.Mismatch) {
+	t.Helper()
+	srv := httptest.NewServer(l)
+	t.Cleanup(srv.Close)
+
+	cfg.LegacyURL = srv.URL
+	cmp, err := shadow.New(cfg)
+	require.NoError(t, err)
+
+	rec := &fakeRecorder{}
+	var mu sync.Mutex
+	var mismatches []shadow.Mismatch
+	cmp.WithRecorder(rec).WithReporter(func(m shadow.Mismatch) {
+		mu.Lock()
+		defer mu.Unlock()
+		mismatches = append(mismatches, m)
+	})
+	return cmp, rec, &mismatches
+}
+
+func TestServe_MatchIgnoresConfiguredPaths(t *testing.T) {
+	l := &legacy{status: 200, body: `{"items":[{"slug":"acme","created_at":"2024-01-01T00:00:00.000Z"}],"next_cursor":"node"}`}
+	cmp, rec, mismatches := setup(t, l, shadow.Config{
+		IgnorePaths: []string{"items[*].created_at", "$.next_cursor"},
+	})
+
+	h := serveGo(cmp, `{"next_cursor":"go","items":[{"created_at":"2024-01-01T00:00:00Z","slug":"acme"}]}`)
+	req := httptest.NewRequest("GET", "/v1/tenants?limit=5", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	cmp.Wait()
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, []string{"/v1/tenants match"}, rec.outcomes)
+	require.Empty(t, *mismatches)
+
+	require.Equal(t, "/v1/tenants", l.last.URL.Path)
+	require.Equal(t, "limit=5", l.last.URL.RawQuery)
+	require.Equal(t, "req-1", l.last.Header.Get("X-Request-ID"))
+	require.Equal(t, "1", l.last.Header.Get(shadow.HeaderShadow))
+}
+
+func TestServe_MismatchIsReportedNotServed(t *testing.T) {
+	l := &legacy{status: 200, body: `{"items":[{"slug":"acme","name":"Acme Inc"}]}`}
+	cmp, rec, mismatches := setup(t, l, shadow.Config{})
+
+	h := serveGo(cmp, `{"items":[{"slug":"acme","name":"Acme"}]}`)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/tenants", nil))
+	cmp.Wait()
+
+	require.JSONEq(t, `{"items":[{"slug":"acme","name":"Acme"}]}`, rr.Body.String())
+	require.Equal(t, []string{"/v1/tenants mismatch"}, rec.outcomes)
+	require.Len(t, *mismatches, 1)
+	require.Equal(t, []jsondiff.Change{
+		{Op: jsondiff.OpChanged, Path: "$.items[0].name", From: "Acme", To: "Acme Inc"},
+	}, (*mismatches)[0].Changes)
+}
+
+func TestServe_NeverReplaysWrites(t *testing.T) {
+	l := &legacy{status: 200, body: `{}`}
+	cmp, rec, _ := setup(t, l, shadow.Config{})
+
+	rr := httptest.NewRecorder()
+	serveGo(cmp, `{}`).ServeHTTP(rr, httptest.NewRequest("POST", "/v1/tenants", nil))
+	cmp.Wait()
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Empty(t, rec.outcomes)
+	require.Nil(t, l.last)
+}
+
+func TestCompare_StatusMismatch(t *testing.T) {
+	l := &legacy{status: 404, body: `{"error":"tenant not found"}`}
+	cmp, rec, _ := setup(t, l, shadow.Config{})
+
+	m, err := cmp.Compare(context.Background(), shadow.Request{Path: "/v1/tenants/acme", Route: "/v1/tenants/{tenantSlug}"},
+		200, []byte(`{"error":"tenant not found"}`))
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	require.Equal(t, 200, m.GoStatus)
+	require.Equal(t, 404, m.LegacyStatus)
+	require.Empty(t, m.Changes)
+	require.Equal(t, []string{"/v1/tenants/{tenantSlug} mismatch"}, rec.outcomes)
+}
+
+func TestCompare_LegacyDown(t *testing.T) {
+	l := &legacy{status: 200}
+	srv := httptest.NewServer(l)
+	cmp, err := shadow.New(shadow.Config{LegacyURL: srv.URL})
+	require.NoError(t, err)
+	srv.Close()
+
+	rec := &fakeRecorder{}
+	cmp.WithRecorder(rec)
+	_, err = cmp.Compare(context.Background(), shadow.Request{Path: "/v1/tenants", Route: "/v1/tenants"}, 200, []byte(`{}`))
+	require.Error(t, err)
+	require.Equal(t, []string{"/v1/tenants error"}, rec.outcomes)
+}
+
+func TestNew_RejectsBadConfig(t *testing.T) {
+	_, err := shadow.New(shadow.Config{LegacyURL: "node:3000"})
+	require.Error(t, err)
+
+	_, err = shadow.New(shadow.Config{LegacyURL: "http://node:3000", IgnorePaths: []string{" "}})
+	require.Error(t, err)
+}
@@ -0,0 +1,289 @@
+package sink
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Mapping turns a lead into the JSON body a remote API expects. Its
+// source is one rule per line, blank lines and # comments aside:
+//
+//	<source> [| <filter>]... -> <path>
+//
+// source is a lead field (id, tenant_id, session_id, name, email, phone,
+// template_id, template_version, created_at), fields.<key> for a custom
+// field, or a double-quoted string literal. Filters, applied left to
+// right: lower, upper, trim, and first and last, which split a full name
+// at its first space ("Ann Marie Smith" is "Ann" and "Marie Smith").
+//
+// path is dot-separated keys, each optionally followed by array indexes:
+// properties.email, phones[0].number. No path may repeat, lie inside
+// another or use a key where another uses an index.
+//
+// Rules whose value is empty are skipped, so a lead without a phone does
+// not blank the remote's; an array may then have null gaps.
+type Mapping struct {
+	rules []rule
+}
+
+type rule struct {
+	line    int
+	source  string // lead field, or "" for a literal
+	literal string
+	filters []func(string) string
+	path    []step
+}
+
+// step is a key, or an array index when key is empty.
+type step struct {
+	key   string
+	index int
+}
+
+// maxIndex caps array indexes so a typo cannot allocate a huge array.
+const maxIndex = 100
+
+// MappingError points at the line of a mapping that does not compile.
+type MappingError struct {
+	Line int
+	Msg  string
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("mapping line %d: %s", e.Line, e.Msg)
+}
+
+var leadFields = map[string]bool{
+	"id": true, "tenant_id": true, "session_id": true, "name": true, "email": true, "phone": true,
+	"template_id": true, "template_version": true, "created_at": true,
+}
+
+var filters = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"first": func(s string) string {
+		first, _, _ := strings.Cut(strings.TrimSpace(s), " ")
+		return first
+	},
+	"last": func(s string) string {
+		_, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+		return strings.TrimSpace(rest)
+	},
+}
+
+// CompileMapping parses a mapping's source.
+func CompileMapping(src string) (Mapping, error) {
+	var m Mapping
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRule(line)
+		if err != nil {
+			return Mapping{}, &MappingError{Line: i + 1, Msg: err.Error()}
+		}
+		r.line = i + 1
+		for _, prev := range m.rules {
+			if conflicts(prev.path, r.path) {
+				return Mapping{}, &MappingError{Line: r.line, Msg: fmt.Sprintf("path %s conflicts with line %d", pathString(r.path), prev.line)}
+			}
+		}
+		m.rules = append(m.rules, r)
+	}
+	if len(m.rules) == 0 {
+		return Mapping{}, &MappingError{Line: 1, Msg: "no rules"}
+	}
+	return m, nil
+}
+
+func parseRule(line string) (rule, error) {
+	i := strings.LastIndex(line, "->")
+	if i < 0 {
+		return rule{}, fmt.Errorf(`want "<source> -> <path>"`)
+	}
+	lhs, rhs := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+2:])
+
+	var r rule
+	var err error
+	if r.path, err = parsePath(rhs); err != nil {
+		return rule{}, err
+	}
+
+	// a literal may contain "|", so it is read before splitting off filters
+	var names []string
+	if strings.HasPrefix(lhs, `"`) {
+		q, err := strconv.QuotedPrefix(lhs)
+		if err != nil {
+			return rule{}, fmt.Errorf("bad string literal")
+		}
+		r.literal, _ = strconv.Unquote(q)
+		rest := strings.TrimSpace(lhs[len(q):])
+		if rest != "" && !strings.HasPrefix(rest, "|") {
+			return rule{}, fmt.Errorf("unexpected %q after literal", rest)
+		}
+		if rest != "" {
+			names = strings.Split(rest, "|")[1:]
+		}
+	} else {
+		parts := strings.Split(lhs, "|")
+		r.source, names = strings.TrimSpace(parts[0]), parts[1:]
+		if !leadFields[r.source] && !(strings.HasPrefix(r.source, "fields.") && len(r.source) > len("fields.")) {
+			return rule{}, fmt.Errorf("unknown lead field %q", r.source)
+		}
+	}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		f, ok := filters[name]
+		if !ok {
+			return rule{}, fmt.Errorf("unknown filter %q", name)
+		}
+		r.filters = append(r.filters, f)
+	}
+	return r, nil
+}
+
+var segment = regexp.MustCompile(`^([^.\[\]\s]+)((?:\[\d+\])*)$`)
+
+// parsePath reads a.b[0].c into steps.
+func parsePath(s string) ([]step, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing path")
+	}
+	var out []step
+	for _, seg := range strings.Split(s, ".") {
+		m := segment.FindStringSubmatch(seg)
+		if m == nil {
+			return nil, fmt.Errorf("bad path %q", s)
+		}
+		out = append(out, step{key: m[1]})
+		for _, idx := range strings.Split(m[2], "[")[1:] {
+			n, _ := strconv.Atoi(strings.TrimSuffix(idx, "]"))
+			if n > maxIndex {
+				return nil, fmt.Errorf("array index %d above %d", n, maxIndex)
+			}
+			out = append(out, step{index: n})
+		}
+	}
+	return out, nil
+}
+
+// conflicts reports whether two paths cannot both be set: one equals or
+// lies inside the other, or they need the same node to be an object and an
+// array.
+func conflicts(a, b []step) bool {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return (a[i].key == "") != (b[i].key == "")
+		}
+	}
+	return true
+}
+
+func pathString(p []step) string {
+	var b strings.Builder
+	for i, s := range p {
+		switch {
+		case s.key == "":
+			fmt.Fprintf(&b, "[%d]", s.index)
+		case i > 0:
+			b.WriteString("." + s.key)
+		default:
+			b.WriteString(s.key)
+		}
+	}
+	return b.String()
+}
+
+// Apply builds the body for l.
+func (m Mapping) Apply(l Lead) map[string]any {
+	root := map[string]any{}
+	for _, r := range m.rules {
+		v := r.value(l)
+		if v == nil {
+			continue
+		}
+		root = put(root, r.path, v).(map[string]any)
+	}
+	return root
+}
+
+// value is the rule's value for l, or nil when it is empty.
+func (r rule) value(l Lead) any {
+	var v any
+	switch r.source {
+	case "":
+		v = r.literal
+	case "id":
+		v = l.ID
+	case "tenant_id":
+		v = l.TenantID
+	case "session_id":
+		v = l.SessionID
+	case "name":
+		v = l.Name
+	case "email":
+		v = l.Email
+	case "phone":
+		v = l.Phone
+	case "template_id":
+		v = l.TemplateID
+	case "template_version":
+		if l.TemplateVersion > 0 {
+			v = l.TemplateVersion
+		}
+	case "created_at":
+		if !l.CreatedAt.IsZero() {
+			v = l.CreatedAt.UTC().Format(time.RFC3339)
+		}
+	default:
+		v = l.Fields[strings.TrimPrefix(r.source, "fields.")]
+	}
+
+	if len(r.filters) > 0 && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		for _, f := range r.filters {
+			s = f(s)
+		}
+		v = s
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil
+	}
+	return v
+}
+
+// put sets v at path under node, creating objects and arrays on the way,
+// and returns the updated node. CompileMapping rules out paths that would
+// need node to be both.
+func put(node any, path []step, v any) any {
+	if len(path) == 0 {
+		return v
+	}
+	s := path[0]
+	if s.key != "" {
+		m, _ := node.(map[string]any)
+		if m == nil {
+			m = map[string]any{}
+		}
+		m[s.key] = put(m[s.key], path[1:], v)
+		return m
+	}
+	a, _ := node.([]any)
+	for len(a) <= s.index {
+		a = append(a, nil)
+	}
+	a[s.index] = put(a[s.index], path[1:], v)
+	return a
+}
@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody is how much of a refusal's body goes into the error.
+const maxErrorBody = 512
+
+// RESTConfig sends each lead as one JSON request, e.g. to a HubSpot or
+// Salesforce-style "create contact" endpoint.
+type RESTConfig struct {
+	URL string `json:"url"`
+	// Method is POST (the default), PUT or PATCH.
+	Method string `json:"method,omitempty"`
+	// Headers go out with every request; put the API token here.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Mapping is the body's Mapping, one rule per element.
+	Mapping []string `json:"mapping"`
+}
+
+// REST is the generic REST sink.
+type REST struct {
+	url     string
+	method  string
+	headers http.Header
+	mapping Mapping
+	client  *http.Client
+}
+
+func NewREST(c RESTConfig, client *http.Client) (*REST, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("sink config: rest url must be an absolute http(s) URL")
+	}
+	method := strings.ToUpper(c.Method)
+	switch method {
+	case "":
+		method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("sink config: rest method must be POST, PUT or PATCH, got %q", c.Method)
+	}
+	m, err := CompileMapping(strings.Join(c.Mapping, "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("sink config: %w", err)
+	}
+	h := http.Header{}
+	for k, v := range c.Headers {
+		h.Set(k, v)
+	}
+	return &REST{url: u.String(), method: method, headers: h, mapping: m, client: client}, nil
+}
+
+// Push sends the mapped lead. The lead's id goes out as Idempotency-Key,
+// so a remote that honours it drops the duplicate when a retry follows a
+// push whose response was lost.
+func (s *REST) Push(ctx context.Context, l Lead) error {
+	body, err := json.Marshal(s.mapping.Apply(l))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, s.method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range s.headers {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "gochatbot-sinks/1")
+	req.Header.Set("Idempotency-Key", l.ID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &StatusError{Code: resp.StatusCode, Body: strings.ToValidUTF8(string(msg), "")}
+}
+
+// StatusError is a non-2xx answer. It is ErrRejected unless the status
+// says the remote may accept the lead later: 408, 425, 429 and 5xx.
+type StatusError struct {
+	Code int
+	Body string // the start of the response body
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sink: remote answered %d: %s", e.Code, e.Body)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrRejected && !e.retryable()
+}
+
+func (e *StatusError) retryable() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return e.Code >= 500
+}
@@ -0,0 +1,86 @@
+// Package sink pushes leads to tenants' CRMs. Each tenant configures at
+// most one sink; the export job builds it from that Config and pushes the
+// lead once its session closes. Storage and retries belong to the service
+// and the job queue.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Lead is what a sink sends: the stored lead, minus bookkeeping.
+type Lead struct {
+	ID              string
+	TenantID        string
+	SessionID       string
+	Name            string
+	Email           string
+	Phone           string
+	Fields          map[string]any
+	TemplateID      string
+	TemplateVersion int
+	CreatedAt       time.Time
+}
+
+// LeadSink delivers a lead to one remote system. An error wrapping
+// ErrRejected means the remote refused the lead and retrying is pointless;
+// any other error may go away on a later attempt.
+type LeadSink interface {
+	Push(ctx context.Context, l Lead) error
+}
+
+// ErrRejected marks a lead the remote refused for good, e.g. with 400 or 401.
+var ErrRejected = errors.New("sink: lead rejected")
+
+// Sink kinds.
+const (
+	KindREST = "rest"
+)
+
+// DefaultTimeout bounds one push when New is given no client.
+const DefaultTimeout = 30 * time.Second
+
+// Config is a tenant's sink, stored as JSON. Kind picks the sink; the
+// field named after it holds its settings.
+type Config struct {
+	Kind string      `json:"kind"`
+	REST *RESTConfig `json:"rest,omitempty"`
+}
+
+// ParseConfig decodes and checks a stored or operator-supplied config.
+// Unknown keys are errors, so a typo does not silently drop a setting.
+func ParseConfig(b []byte) (Config, error) {
+	var c Config
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("sink config: %w", err)
+	}
+	if _, err := New(c, nil); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// New builds the sink c describes. A nil client gets one with
+// DefaultTimeout.
+func New(c Config, client *http.Client) (LeadSink, error) {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	switch c.Kind {
+	case KindREST:
+		if c.REST == nil {
+			return nil, errors.New("sink config: rest settings missing")
+		}
+		return NewREST(*c.REST, client)
+	default:
+		return nil, fmt.Errorf("sink config: unknown kind %q", c.Kind)
+	}
+}
@@ -0,0 +1,158 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/sink"
+)
+
+var ann = sink.Lead{
+	ID: "l1", TenantID: "t1", SessionID: "s1", Name: "Ann Marie Smith", Email: "ann@example.com",
+	Fields: map[string]any{"company": "Initech", "seats": float64(12)}, TemplateVersion: 3,
+	CreatedAt: time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC),
+}
+
+func TestMapping_Apply(t *testing.T) {
+	m, err := sink.CompileMapping(`
+		# HubSpot-style contact
+		email                  -> properties.email
+		name | first           -> properties.firstname
+		name | last | upper    -> properties.lastname
+		phone                  -> properties.phone
+		fields.company         -> properties.company
+		fields.seats           -> properties.seats
+		fields.missing         -> properties.missing
+		"chat | web"           -> properties.lead_source
+		" x " | trim           -> tags[1]
+		template_version       -> meta.template.version
+		created_at             -> meta.created_at
+		id                     -> externalIds[0].value
+	`)
+	require.NoError(t, err)
+
+	got, err := json.Marshal(m.Apply(ann))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"properties": {"email": "ann@example.com", "firstname": "Ann", "lastname": "MARIE SMITH",
+			"company": "Initech", "seats": 12, "lead_source": "chat | web"},
+		"tags": [null, "x"],
+		"meta": {"template": {"version": 3}, "created_at": "2025-12-18T12:00:00Z"},
+		"externalIds": [{"value": "l1"}]
+	}`, string(got), "empty values are left out")
+}
+
+func TestCompileMapping_Errors(t *testing.T) {
+	cases := []struct {
+		src, msg string
+		line     int
+	}{
+		{"", "no rules", 1},
+		{"email properties.email", "want", 1},
+		{"mail -> a", `unknown lead field "mail"`, 1},
+		{"fields. -> a", "unknown lead field", 1},
+		{"email | shout -> a", `unknown filter "shout"`, 1},
+		{`"open -> a`, "bad string literal", 1},
+		{`"x" y -> a`, "after literal", 1},
+		{"email -> a..b", "bad path", 1},
+		{"email -> a[1000]", "array index", 1},
+		{"email -> a.b\n\nname -> a", "conflicts with line 1", 3},
+		{"email -> a\nname -> a", "conflicts", 2},
+		{"email -> a[0]\nname -> a.b", "conflicts", 2},
+	}
+	for _, c := range cases {
+		_, err := sink.CompileMapping(c.src)
+		var me *sink.MappingError
+		require.ErrorAs(t, err, &me, c.src)
+		require.Equal(t, c.line, me.Line, c.src)
+		require.Contains(t, me.Msg, c.msg, c.src)
+	}
+
+	_, err := sink.CompileMapping("email -> a[0].x\nname -> a[1].x\nphone -> a[0].y")
+	require.NoError(t, err, "siblings do not conflict")
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := sink.ParseConfig([]byte(`{"kind":"rest","rest":{"url":"https://crm.example.com/contacts","mapping":["email -> email"]}}`))
+	require.NoError(t, err)
+	require.Equal(t, sink.KindREST, c.Kind)
+
+	for _, bad := range []string{
+		`{"kind":"ftp"}`,
+		`{"kind":"rest"}`,
+		`{"kind":"rest","rest":{"url":"crm.example.com","mapping":["email -> email"]}}`,
+		`{"kind":"rest","rest":{"url":"https://crm.example.com","method":"GET","mapping":["email -> email"]}}`,
+		`{"kind":"rest","rest":{"url":"https://crm.example.com","mapping":["email -> "]}}`,
+		`{"kind":"rest","rest":{"url":"https://crm.example.com","mapping":["email -> email"],"mappings":[]}}`,
+	} {
+		_, err := sink.ParseConfig([]byte(bad))
+		require.Error(t, err, bad)
+	}
+}
+
+func TestREST_Push(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	s, err := sink.New(sink.Config{Kind: sink.KindREST, REST: &sink.RESTConfig{
+		URL: srv.URL + "/contacts", Method: "put",
+		Headers: map[string]string{"Authorization": "Bearer tok"},
+		Mapping: []string{"email -> properties.email", "name | first -> properties.firstname"},
+	}}, srv.Client())
+	require.NoError(t, err)
+
+	require.NoError(t, s.Push(context.Background(), ann))
+	require.Equal(t, http.MethodPut, got.Method)
+	require.Equal(t, "/contacts", got.URL.Path)
+	require.Equal(t, "Bearer tok", got.Header.Get("Authorization"))
+	require.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	require.Equal(t, "l1", got.Header.Get("Idempotency-Key"))
+	require.JSONEq(t, `{"properties":{"email":"ann@example.com","firstname":"Ann"}}`, string(body))
+}
+
+func TestREST_Push_Failures(t *testing.T) {
+	status := http.StatusBadRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, `{"message":"Property \"email\" is invalid"}`+strings.Repeat(" ", 1000))
+	}))
+	defer srv.Close()
+
+	s, err := sink.NewREST(sink.RESTConfig{URL: srv.URL, Mapping: []string{"email -> email"}}, srv.Client())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = s.Push(ctx, ann)
+	require.ErrorIs(t, err, sink.ErrRejected)
+	var se *sink.StatusError
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, http.StatusBadRequest, se.Code)
+	require.Contains(t, se.Body, "is invalid")
+	require.Len(t, se.Body, 512)
+
+	for _, status = range []int{http.StatusTooManyRequests, http.StatusBadGateway} {
+		err = s.Push(ctx, ann)
+		require.Error(t, err)
+		require.False(t, errors.Is(err, sink.ErrRejected), "%d may succeed later", status)
+	}
+
+	srv.Close()
+	err = s.Push(ctx, ann)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, sink.ErrRejected), "no answer is retried")
+}
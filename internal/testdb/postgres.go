@@ -2,15 +2,15 @@ package testdb
 
 import (
 	"context"
-	"os"
-	"path/filepath"
-	"strings"
 	"testing"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"gochatbot/internal/migrate"
+	"gochatbot/migrations"
 )
 
 type DB struct {
@@ -60,72 +60,14 @@ func NewPostgres(t *testing.T) *DB {
 	return &DB{Conn: conn}
 }
 
+// ApplyMigrations runs the embedded migrations through the same runner
+// production uses, so tests also exercise the history table and checksums.
 func ApplyMigrations(t *testing.T, conn *pgx.Conn) {
 	t.Helper()
-	ctx := context.Background()
-
-	sql := readAllMigrations(t)
-	_, err := conn.Exec(ctx, sql)
-	require.NoError(t, err)
-}
-
-func readAllMigrations(t *testing.T) string {
-	t.Helper()
 
-	// find repo root by walking up until we see /migrations
-	dir, err := os.Getwd()
+	r, err := migrate.New(migrations.FS)
 	require.NoError(t, err)
 
-	var migDir string
-	for i := 0; i < 10; i++ {
-		candidate := filepath.Join(dir, "migrations")
-		if st, err := os.Stat(candidate); err == nil && st.IsDir() {
-			migDir = candidate
-			break
-		}
-		dir = filepath.Dir(dir)
-	}
-	require.NotEmpty(t, migDir, "could not find migrations directory")
-
-	entries, err := os.ReadDir(migDir)
+	_, err = r.Up(context.Background(), conn)
 	require.NoError(t, err)
-
-	// read in filename order (0001_, 0002_, etc.)
-	var names []string
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		if strings.HasSuffix(e.Name(), ".sql") {
-			names = append(names, e.Name())
-		}
-	}
-	require.NotEmpty(t, names, "no .sql migrations found")
-
-	sortStrings(names)
-
-	var b strings.Builder
-	for _, name := range names {
-		path := filepath.Join(migDir, name)
-		body, err := os.ReadFile(path)
-		require.NoError(t, err)
-		b.WriteString("\n-- ")
-		b.WriteString(name)
-		b.WriteString("\n")
-		b.Write(body)
-		b.WriteString("\n")
-	}
-
-	return b.String()
-}
-
-// tiny lexical sort (0001_ prefix means lexical == numeric order)
-func sortStrings(a []string) {
-	for i := 0; i < len(a); i++ {
-		for j := i + 1; j < len(a); j++ {
-			if a[j] < a[i] {
-				a[i], a[j] = a[j], a[i]
-			}
-		}
-	}
 }
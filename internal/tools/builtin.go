@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // business hours name IANA zones; slim images ship none
+
+	"gochatbot/internal/validate"
+)
+
+// Built-in tool names.
+const (
+	CaptureLeadField    = "capture_lead_field"
+	LookupBusinessHours = "lookup_business_hours"
+)
+
+// Builtins are the tools every deployment registers; tenants still have
+// to be allowed each one.
+func Builtins(now func() time.Time) []Tool {
+	if now == nil {
+		now = time.Now
+	}
+	return []Tool{captureLeadField(), lookupBusinessHours(now)}
+}
+
+func captureLeadField() Tool {
+	return Tool{
+		Name:        CaptureLeadField,
+		Description: "Record one detail the visitor gave about themselves, as soon as they give it.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"field": {"type": "string", "enum": ["name", "email", "phone", "company", "note"]},
+				"value": {"type": "string", "minLength": 1, "maxLength": 500}
+			},
+			"required": ["field", "value"],
+			"additionalProperties": false
+		}`),
+		// the stored tool message is the record, so the handler only
+		// normalizes; the reply service persists the result
+		Handler: func(_ context.Context, c Call) (map[string]any, error) {
+			field, value := c.Args["field"].(string), strings.TrimSpace(c.Args["value"].(string))
+			var err error
+			switch field {
+			case "email":
+				if value, err = validate.NormalizeEmail(value); err != nil {
+					return nil, errors.New("not a valid email address; ask the visitor again")
+				}
+			case "phone":
+				if value, err = validate.NormalizePhone(value); err != nil {
+					return nil, errors.New("not a valid phone number; ask the visitor again")
+				}
+			}
+			if value == "" {
+				return nil, errors.New("value is empty")
+			}
+			return map[string]any{"field": field, "value": value, "captured": true}, nil
+		},
+	}
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// businessHours is the "business_hours" object of a template's content:
+// a timezone and, per weekday, "closed" or comma-separated HH:MM-HH:MM
+// ranges. A day that is left out is closed.
+type businessHours struct {
+	Timezone string            `json:"timezone"`
+	Days     map[string]string `json:"-"`
+}
+
+func (b *businessHours) UnmarshalJSON(data []byte) error {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	b.Timezone, b.Days = raw["timezone"], map[string]string{}
+	for _, d := range weekdays {
+		h := strings.TrimSpace(raw[d])
+		if h == "" {
+			h = "closed"
+		}
+		if h != "closed" {
+			if _, err := parseRanges(h); err != nil {
+				return fmt.Errorf("%s: %w", d, err)
+			}
+		}
+		b.Days[d] = h
+	}
+	return nil
+}
+
+type span struct{ from, to int } // minutes since midnight
+
+func parseRanges(s string) ([]span, error) {
+	var out []span
+	for _, part := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(part), "-")
+		if !ok {
+			return nil, fmt.Errorf("%q is not HH:MM-HH:MM", part)
+		}
+		f, err1 := parseClock(from)
+		t, err2 := parseClock(to)
+		if err1 != nil || err2 != nil || t <= f {
+			return nil, fmt.Errorf("%q is not HH:MM-HH:MM", part)
+		}
+		out = append(out, span{f, t})
+	}
+	return out, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func lookupBusinessHours(now func() time.Time) Tool {
+	return Tool{
+		Name:        LookupBusinessHours,
+		Description: "Look up the business's opening hours, for one day or the whole week, and whether it is open right now.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"day": {"type": "string", "enum": ["today", "mon", "tue", "wed", "thu", "fri", "sat", "sun"]}
+			},
+			"additionalProperties": false
+		}`),
+		Handler: func(_ context.Context, c Call) (map[string]any, error) {
+			var content struct {
+				Hours *businessHours `json:"business_hours"`
+			}
+			if len(c.Template) > 0 {
+				if err := json.Unmarshal(c.Template, &content); err != nil {
+					return nil, fmt.Errorf("business hours are misconfigured: %v", err)
+				}
+			}
+			if content.Hours == nil {
+				return nil, errors.New("business hours are not configured; say you do not know them")
+			}
+			// LoadLocation reads "" as UTC, which would be silently wrong
+			if strings.TrimSpace(content.Hours.Timezone) == "" {
+				return nil, errors.New("business hours timezone is not configured; say you do not know the hours")
+			}
+			loc, err := time.LoadLocation(content.Hours.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("business hours are misconfigured: %v", err)
+			}
+
+			t := now().In(loc)
+			today := weekdays[t.Weekday()]
+			open := false
+			if h := content.Hours.Days[today]; h != "closed" {
+				spans, _ := parseRanges(h)
+				m := t.Hour()*60 + t.Minute()
+				for _, s := range spans {
+					open = open || (m >= s.from && m < s.to)
+				}
+			}
+
+			out := map[string]any{
+				"timezone":   content.Hours.Timezone,
+				"local_time": t.Format("Mon 15:04"),
+				"open_now":   open,
+			}
+			day, _ := c.Args["day"].(string)
+			if day == "today" {
+				day = today
+			}
+			if day == "" {
+				week := map[string]any{}
+				for d, h := range content.Hours.Days {
+					week[d] = h
+				}
+				out["hours"] = week
+			} else {
+				out["day"] = day
+				out["hours"] = content.Hours.Days[day]
+			}
+			return out, nil
+		},
+	}
+}
@@ -0,0 +1,244 @@
+// Package tools runs the functions the assistant may call. Each tool
+// declares a JSON Schema for its arguments; the registry offers a tenant
+// only the tools on its allowlist, validates the model's arguments and
+// bounds every call with a timeout. Failures come back as results the
+// model can read, never as errors that abort a reply.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"gochatbot/internal/llm"
+)
+
+// Outcomes reported to the Observer.
+const (
+	OutcomeOK      = "ok"
+	OutcomeDenied  = "denied"  // unknown or not on the tenant's allowlist
+	OutcomeInvalid = "invalid" // arguments failed the schema
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+// defaultTimeout bounds a call when neither the tool nor the registry set
+// a limit.
+const defaultTimeout = 10 * time.Second
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Call is one invocation with arguments already validated.
+type Call struct {
+	TenantID  string
+	SessionID string
+	// Template is the session template's published content; nil without one.
+	Template json.RawMessage
+	Args     map[string]any
+}
+
+// Handler returns the tool's output, which is shown to the model as JSON.
+// An error is reported to the model too, so it should read as a reason.
+type Handler func(ctx context.Context, call Call) (map[string]any, error)
+
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON Schema of an object
+	Timeout     time.Duration   // 0 uses the registry's
+	Handler     Handler
+}
+
+// Allowlist names the tools a tenant may use; *repo.TenantRepo satisfies it.
+type Allowlist interface {
+	AllowedTools(ctx context.Context, tenantID string) ([]string, error)
+}
+
+// Observer receives one outcome per call; *metrics.Metrics satisfies it.
+type Observer interface {
+	ToolCalled(tool, outcome string)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ToolCalled(string, string) {}
+
+// Result of one call: Output on success, Error otherwise.
+type Result struct {
+	Output map[string]any
+	Error  string
+}
+
+// Content is the result as the model reads it.
+func (r Result) Content() string {
+	v := r.Output
+	if r.Error != "" {
+		v = map[string]any{"error": r.Error}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+type entry struct {
+	Tool
+	schema *schema
+}
+
+type Registry struct {
+	tools   map[string]*entry
+	allow   Allowlist
+	timeout time.Duration
+	obs     Observer
+}
+
+func NewRegistry(allow Allowlist) *Registry {
+	return &Registry{tools: map[string]*entry{}, allow: allow, timeout: defaultTimeout, obs: nopObserver{}}
+}
+
+// WithTimeout sets the limit for tools that declare none.
+func (r *Registry) WithTimeout(d time.Duration) *Registry {
+	if d > 0 {
+		r.timeout = d
+	}
+	return r
+}
+
+func (r *Registry) WithObserver(obs Observer) *Registry {
+	if obs != nil {
+		r.obs = obs
+	}
+	return r
+}
+
+// Register adds a tool; names are unique snake_case.
+func (r *Registry) Register(t Tool) error {
+	if !validName.MatchString(t.Name) {
+		return fmt.Errorf("tools: invalid name %q", t.Name)
+	}
+	if _, dup := r.tools[t.Name]; dup {
+		return fmt.Errorf("tools: %s registered twice", t.Name)
+	}
+	if t.Handler == nil {
+		return fmt.Errorf("tools: %s has no handler", t.Name)
+	}
+	s, err := compileSchema(t.Parameters)
+	if err != nil {
+		return fmt.Errorf("tools: %s: %w", t.Name, err)
+	}
+	r.tools[t.Name] = &entry{Tool: t, schema: s}
+	return nil
+}
+
+// Names lists the registered tools, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.tools))
+	for n := range r.tools {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Available describes, for the model, the registered tools the tenant may use.
+func (r *Registry) Available(ctx context.Context, tenantID string) ([]llm.Tool, error) {
+	allowed, err := r.allow.AllowedTools(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var out []llm.Tool
+	for _, name := range r.Names() {
+		if slices.Contains(allowed, name) {
+			t := r.tools[name]
+			out = append(out, llm.Tool{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+		}
+	}
+	return out, nil
+}
+
+// Execute runs one call the model asked for. The allowlist is checked
+// again here: a model may name a tool it was not offered. The error is
+// only for failing to read the allowlist or ctx ending; everything else is
+// a Result for the model.
+func (r *Registry) Execute(ctx context.Context, call llm.ToolCall, c Call) (Result, error) {
+	t, ok := r.tools[call.Name]
+	if ok {
+		allowed, err := r.allow.AllowedTools(ctx, c.TenantID)
+		if err != nil {
+			return Result{}, err
+		}
+		ok = slices.Contains(allowed, call.Name)
+	}
+	if !ok {
+		r.obs.ToolCalled(call.Name, OutcomeDenied)
+		return Result{Error: fmt.Sprintf("tool %q is not available", call.Name)}, nil
+	}
+
+	var args any
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		r.obs.ToolCalled(t.Name, OutcomeInvalid)
+		return Result{Error: "arguments are not valid JSON"}, nil
+	}
+	if err := t.schema.validate("arguments", args); err != nil {
+		r.obs.ToolCalled(t.Name, OutcomeInvalid)
+		return Result{Error: err.Error()}, nil
+	}
+	c.Args = args.(map[string]any)
+
+	out, err := r.run(ctx, t, c)
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		r.obs.ToolCalled(t.Name, OutcomeTimeout)
+		return Result{Error: "tool timed out"}, nil
+	case err != nil:
+		r.obs.ToolCalled(t.Name, OutcomeError)
+		return Result{Error: err.Error()}, nil
+	}
+	r.obs.ToolCalled(t.Name, OutcomeOK)
+	if out == nil {
+		out = map[string]any{}
+	}
+	return Result{Output: out}, nil
+}
+
+// run enforces the timeout even on a handler that ignores ctx; such a
+// handler keeps running in the background until it returns.
+func (r *Registry) run(ctx context.Context, t *entry, c Call) (map[string]any, error) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = r.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		out map[string]any
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("tool failed: %v", p)}
+			}
+		}()
+		out, err := t.Handler(ctx, c)
+		done <- result{out, err}
+	}()
+	select {
+	case res := <-done:
+		if ctx.Err() != nil && res.err != nil {
+			return nil, ctx.Err()
+		}
+		return res.out, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,182 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/llm"
+	"gochatbot/internal/tools"
+)
+
+type allowlist map[string][]string
+
+func (a allowlist) AllowedTools(_ context.Context, tenantID string) ([]string, error) {
+	return a[tenantID], nil
+}
+
+type outcomes []string
+
+func (o *outcomes) ToolCalled(tool, outcome string) { *o = append(*o, tool+":"+outcome) }
+
+func call(name, args string) llm.ToolCall {
+	return llm.ToolCall{ID: "c1", Name: name, Arguments: json.RawMessage(args)}
+}
+
+func newRegistry(t *testing.T, allow allowlist, extra ...tools.Tool) (*tools.Registry, *outcomes) {
+	t.Helper()
+	obs := &outcomes{}
+	r := tools.NewRegistry(allow).WithTimeout(50 * time.Millisecond).WithObserver(obs)
+	monday10am := func() time.Time { return time.Date(2025, 12, 15, 10, 0, 0, 0, time.UTC) }
+	for _, tool := range append(tools.Builtins(monday10am), extra...) {
+		require.NoError(t, r.Register(tool))
+	}
+	return r, obs
+}
+
+func TestRegistry_RegisterRejectsBadTools(t *testing.T) {
+	r := tools.NewRegistry(allowlist{})
+	ok := func(context.Context, tools.Call) (map[string]any, error) { return nil, nil }
+
+	require.ErrorContains(t, r.Register(tools.Tool{Name: "Bad Name", Parameters: json.RawMessage(`{"type":"object"}`), Handler: ok}), "invalid name")
+	require.ErrorContains(t, r.Register(tools.Tool{Name: "no_handler", Parameters: json.RawMessage(`{"type":"object"}`)}), "no handler")
+	require.ErrorContains(t, r.Register(tools.Tool{Name: "not_object", Parameters: json.RawMessage(`{"type":"string"}`), Handler: ok}), "type must be object")
+	require.ErrorContains(t, r.Register(tools.Tool{Name: "bad_pattern", Handler: ok,
+		Parameters: json.RawMessage(`{"type":"object","properties":{"a":{"type":"string","pattern":"("}}}`)}), "pattern")
+
+	require.NoError(t, r.Register(tools.Tool{Name: "twice", Parameters: json.RawMessage(`{"type":"object"}`), Handler: ok}))
+	require.ErrorContains(t, r.Register(tools.Tool{Name: "twice", Parameters: json.RawMessage(`{"type":"object"}`), Handler: ok}), "registered twice")
+}
+
+func TestRegistry_AllowlistGatesOfferAndExecution(t *testing.T) {
+	r, obs := newRegistry(t, allowlist{"acme": {tools.LookupBusinessHours, "retired_tool"}})
+	ctx := context.Background()
+
+	offered, err := r.Available(ctx, "acme")
+	require.NoError(t, err)
+	require.Len(t, offered, 1)
+	require.Equal(t, tools.LookupBusinessHours, offered[0].Name)
+	require.JSONEq(t, `{"type":"object","properties":{"day":{"type":"string","enum":["today","mon","tue","wed","thu","fri","sat","sun"]}},"additionalProperties":false}`,
+		string(offered[0].Parameters))
+
+	none, err := r.Available(ctx, "other")
+	require.NoError(t, err)
+	require.Empty(t, none)
+
+	res, err := r.Execute(ctx, call(tools.CaptureLeadField, `{"field":"name","value":"Ann"}`), tools.Call{TenantID: "acme"})
+	require.NoError(t, err)
+	require.Equal(t, `tool "capture_lead_field" is not available`, res.Error)
+	res, err = r.Execute(ctx, call("delete_everything", `{}`), tools.Call{TenantID: "acme"})
+	require.NoError(t, err)
+	require.Equal(t, `{"error":"tool \"delete_everything\" is not available"}`, res.Content())
+	require.Equal(t, outcomes{"capture_lead_field:denied", "delete_everything:denied"}, *obs)
+}
+
+func TestRegistry_ValidatesArguments(t *testing.T) {
+	r, _ := newRegistry(t, allowlist{"acme": {tools.CaptureLeadField}})
+	ctx := context.Background()
+	c := tools.Call{TenantID: "acme"}
+
+	for args, want := range map[string]string{
+		`not json`:                              "arguments are not valid JSON",
+		`[]`:                                    "arguments: must be object",
+		`{"field":"name"}`:                      `arguments: missing "value"`,
+		`{"field":"age","value":"40"}`:          `arguments.field: must be one of "name", "email", "phone", "company", "note"`,
+		`{"field":"name","value":""}`:           "arguments.value: must be at least 1 characters",
+		`{"field":"name","value":3}`:            "arguments.value: must be string",
+		`{"field":"name","value":"a","x":true}`: `arguments: unexpected "x"`,
+	} {
+		res, err := r.Execute(ctx, call(tools.CaptureLeadField, args), c)
+		require.NoError(t, err)
+		require.Equal(t, want, res.Error, args)
+	}
+}
+
+func TestRegistry_TimeoutsErrorsAndPanics(t *testing.T) {
+	params := json.RawMessage(`{"type":"object"}`)
+	r, obs := newRegistry(t, allowlist{"acme": {"slow", "broken", "panics"}},
+		tools.Tool{Name: "slow", Parameters: params, Handler: func(ctx context.Context, _ tools.Call) (map[string]any, error) {
+			time.Sleep(time.Second) // ignores ctx on purpose
+			return map[string]any{}, nil
+		}},
+		tools.Tool{Name: "broken", Parameters: params, Handler: func(context.Context, tools.Call) (map[string]any, error) {
+			return nil, errors.New("upstream unavailable")
+		}},
+		tools.Tool{Name: "panics", Parameters: params, Handler: func(context.Context, tools.Call) (map[string]any, error) {
+			panic("boom")
+		}},
+	)
+	ctx := context.Background()
+	c := tools.Call{TenantID: "acme"}
+
+	start := time.Now()
+	res, err := r.Execute(ctx, call("slow", `{}`), c)
+	require.NoError(t, err)
+	require.Equal(t, "tool timed out", res.Error)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	res, err = r.Execute(ctx, call("broken", `{}`), c)
+	require.NoError(t, err)
+	require.Equal(t, "upstream unavailable", res.Error)
+
+	res, err = r.Execute(ctx, call("panics", `{}`), c)
+	require.NoError(t, err)
+	require.Equal(t, "tool failed: boom", res.Error)
+	require.Equal(t, outcomes{"slow:timeout", "broken:error", "panics:error"}, *obs)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = r.Execute(cancelled, call("broken", `{}`), c)
+	require.ErrorIs(t, err, context.Canceled, "an ended reply is not a tool result")
+}
+
+func TestCaptureLeadField_Normalizes(t *testing.T) {
+	r, _ := newRegistry(t, allowlist{"acme": {tools.CaptureLeadField}})
+	ctx := context.Background()
+	c := tools.Call{TenantID: "acme"}
+
+	res, err := r.Execute(ctx, call(tools.CaptureLeadField, `{"field":"email","value":"  Ann@Example.COM "}`), c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"field": "email", "value": "ann@example.com", "captured": true}, res.Output)
+
+	res, err = r.Execute(ctx, call(tools.CaptureLeadField, `{"field":"email","value":"ann at example"}`), c)
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "not a valid email address")
+}
+
+func TestLookupBusinessHours(t *testing.T) {
+	r, _ := newRegistry(t, allowlist{"acme": {tools.LookupBusinessHours}})
+	ctx := context.Background()
+	// Monday 10:00 UTC is 11:00 in Berlin
+	c := tools.Call{TenantID: "acme", Template: json.RawMessage(`{"system_prompt":"hi",
+		"business_hours":{"timezone":"Europe/Berlin","mon":"09:00-12:00, 13:00-17:00","sat":"closed"}}`)}
+
+	res, err := r.Execute(ctx, call(tools.LookupBusinessHours, `{"day":"today"}`), c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"timezone": "Europe/Berlin", "local_time": "Mon 11:00", "open_now": true,
+		"day": "mon", "hours": "09:00-12:00, 13:00-17:00"}, res.Output)
+
+	res, err = r.Execute(ctx, call(tools.LookupBusinessHours, `{}`), c)
+	require.NoError(t, err)
+	week := res.Output["hours"].(map[string]any)
+	require.Len(t, week, 7)
+	require.Equal(t, "closed", week["sun"], "left out means closed")
+
+	res, err = r.Execute(ctx, call(tools.LookupBusinessHours, `{}`), tools.Call{TenantID: "acme"})
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "not configured")
+
+	c.Template = json.RawMessage(`{"business_hours":{"mon":"09:00-17:00"}}`)
+	res, err = r.Execute(ctx, call(tools.LookupBusinessHours, `{}`), c)
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "timezone is not configured")
+
+	c.Template = json.RawMessage(`{"business_hours":{"timezone":"Europe/Berlin","mon":"9-5"}}`)
+	res, err = r.Execute(ctx, call(tools.LookupBusinessHours, `{}`), c)
+	require.NoError(t, err)
+	require.Contains(t, res.Error, "misconfigured")
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PayloadKey is the job payload field that carries W3C trace headers
+// (traceparent, tracestate, baggage) from the producer to the worker.
+const PayloadKey = "_trace"
+
+// Enqueuer matches service.Queue without importing the service package.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, kind string, payload map[string]any) error
+}
+
+type tracingQueue struct {
+	next Enqueuer
+}
+
+// WrapQueue starts a producer span per enqueue and injects its context into
+// the payload, so the worker's consumer span joins the same trace.
+func WrapQueue(next Enqueuer) Enqueuer {
+	return &tracingQueue{next: next}
+}
+
+func (q *tracingQueue) Enqueue(ctx context.Context, kind string, payload map[string]any) (err error) {
+	ctx, span := Start(ctx, "enqueue "+kind,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("job.kind", kind)),
+	)
+	defer func() { End(span, err) }()
+
+	return q.next.Enqueue(ctx, kind, InjectPayload(ctx, payload))
+}
+
+// OnceEnqueuer matches service.OnceQueue.
+type OnceEnqueuer interface {
+	EnqueueOnce(ctx context.Context, kind string, payload map[string]any) (bool, error)
+}
+
+type tracingOnceQueue struct {
+	next OnceEnqueuer
+}
+
+// WrapOnceQueue is WrapQueue for EnqueueOnce.
+func WrapOnceQueue(next OnceEnqueuer) OnceEnqueuer {
+	return &tracingOnceQueue{next: next}
+}
+
+func (q *tracingOnceQueue) EnqueueOnce(ctx context.Context, kind string, payload map[string]any) (_ bool, err error) {
+	ctx, span := Start(ctx, "enqueue "+kind,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("job.kind", kind)),
+	)
+	defer func() { End(span, err) }()
+
+	queued, err := q.next.EnqueueOnce(ctx, kind, InjectPayload(ctx, payload))
+	span.SetAttributes(attribute.Bool("job.queued", queued))
+	return queued, err
+}
+
+// InjectPayload returns a copy of payload with the current trace context under
+// PayloadKey. The caller's map is left untouched.
+func InjectPayload(ctx context.Context, payload map[string]any) map[string]any {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	out := make(map[string]any, len(payload)+1)
+	for k, v := range payload {
+		out[k] = v
+	}
+	if len(carrier) == 0 {
+		return out
+	}
+	fields := make(map[string]any, len(carrier))
+	for k, v := range carrier {
+		fields[k] = v
+	}
+	out[PayloadKey] = fields
+	return out
+}
+
+// ExtractPayload restores the producer's trace context from a job payload.
+// Payloads round-trip through jsonb, so the carrier arrives as map[string]any.
+func ExtractPayload(ctx context.Context, payload map[string]any) context.Context {
+	raw, ok := payload[PayloadKey].(map[string]any)
+	if !ok {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			carrier[k] = s
+		}
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// StartJob opens the consumer span for a job, continuing the producer's trace.
+func StartJob(ctx context.Context, kind string, payload map[string]any) (context.Context, trace.Span) {
+	ctx = ExtractPayload(ctx, payload)
+	return Start(ctx, "job "+kind,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("job.kind", kind)),
+	)
+}
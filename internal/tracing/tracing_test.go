@@ -0,0 +1,103 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"gochatbot/internal/tracing"
+)
+
+func installRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return rec
+}
+
+type captureQueue struct {
+	payload map[string]any
+}
+
+func (q *captureQueue) Enqueue(_ context.Context, _ string, payload map[string]any) error {
+	q.payload = payload
+	return nil
+}
+
+func TestWrapQueue_WorkerContinuesProducerTrace(t *testing.T) {
+	rec := installRecorder(t)
+
+	ctx, parent := tracing.Start(context.Background(), "SessionService.CloseSession")
+	q := &captureQueue{}
+	orig := map[string]any{"lead_id": "l1"}
+	require.NoError(t, tracing.WrapQueue(q).Enqueue(ctx, "export_lead", orig))
+	parent.End()
+
+	_, hasTrace := orig[tracing.PayloadKey]
+	require.False(t, hasTrace, "caller's payload must not be mutated")
+
+	// simulate the jsonb round trip the real queue does
+	raw, err := json.Marshal(q.payload)
+	require.NoError(t, err)
+	var stored map[string]any
+	require.NoError(t, json.Unmarshal(raw, &stored))
+	require.Equal(t, "l1", stored["lead_id"])
+
+	_, span := tracing.StartJob(context.Background(), "export_lead", stored)
+	span.End()
+
+	require.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+
+	names := []string{}
+	for _, s := range rec.Ended() {
+		names = append(names, s.Name())
+	}
+	require.ElementsMatch(t, []string{"SessionService.CloseSession", "enqueue export_lead", "job export_lead"}, names)
+}
+
+type captureOnceQueue struct {
+	captureQueue
+}
+
+func (q *captureOnceQueue) EnqueueOnce(ctx context.Context, kind string, payload map[string]any) (bool, error) {
+	return true, q.Enqueue(ctx, kind, payload)
+}
+
+func TestWrapOnceQueue_InjectsTrace(t *testing.T) {
+	installRecorder(t)
+
+	ctx, parent := tracing.Start(context.Background(), "ReconcileService.TriggerRun")
+	q := &captureOnceQueue{}
+	queued, err := tracing.WrapOnceQueue(q).EnqueueOnce(ctx, "reconcile", nil)
+	require.NoError(t, err)
+	require.True(t, queued)
+	parent.End()
+
+	_, span := tracing.StartJob(context.Background(), "reconcile", q.payload)
+	span.End()
+	require.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+}
+
+func TestExtractPayload_NoCarrierIsNoop(t *testing.T) {
+	ctx := tracing.ExtractPayload(context.Background(), map[string]any{"x": 1})
+	require.False(t, trace.SpanContextFromContext(ctx).IsValid())
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "carrier-pigeon"})
+	require.Error(t, err)
+}
@@ -17,6 +17,22 @@ type Row struct {
 	AnswerType AnswerType
 	Answer     string // text answer or file URL for uploads
 	FileName   string // used when AnswerTypeFileUpload
+	UploadID   string // stored upload; LinkUploads turns it into Answer
+}
+
+// LinkUploads returns rows with each stored upload's Answer set to
+// link(UploadID), a signed download link such as UploadService.Link gives.
+// Links expire, so they are made when the transcript is rendered, not when
+// the answer is recorded.
+func LinkUploads(rows []Row, link func(uploadID string) string) []Row {
+	out := make([]Row, len(rows))
+	for i, r := range rows {
+		if r.AnswerType == AnswerTypeFileUpload && r.UploadID != "" {
+			r.Answer = link(r.UploadID)
+		}
+		out[i] = r
+	}
+	return out
 }
 
 func stripOuterQuotes(s string) string {
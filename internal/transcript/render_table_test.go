@@ -49,3 +49,22 @@ func TestRenderTranscriptTable_Golden(t *testing.T) {
 
 	require.Equal(t, want, got)
 }
+
+func TestLinkUploads(t *testing.T) {
+	rows := []transcript.Row{
+		{Question: "First name", AnswerType: transcript.AnswerTypeText, Answer: `"Chris"`},
+		{Question: "Police report", AnswerType: transcript.AnswerTypeFileUpload, UploadID: "u1", FileName: "report.pdf"},
+		{Question: "Photo", AnswerType: transcript.AnswerTypeFileUpload, Answer: "https://legacy.example.com/p.jpg", FileName: "p.jpg"},
+	}
+
+	got := transcript.LinkUploads(rows, func(id string) string {
+		return "https://api.example.com/v1/files/" + id + "?expires=1&sig=ab"
+	})
+	require.Equal(t, `"Chris"`, got[0].Answer)
+	require.Equal(t, "https://api.example.com/v1/files/u1?expires=1&sig=ab", got[1].Answer)
+	require.Equal(t, "https://legacy.example.com/p.jpg", got[2].Answer, "rows without an upload keep their URL")
+	require.Empty(t, rows[1].Answer, "the input is not modified")
+
+	html := transcript.RenderTranscriptTable(got)
+	require.Contains(t, html, `<a href="https://api.example.com/v1/files/u1?expires=1&amp;sig=ab" target="_blank" style="color: #007BFF;">report.pdf</a>`)
+}
@@ -0,0 +1,76 @@
+// Package webhook builds, signs and sends the events tenants subscribe
+// their own systems to. Storage and retries belong to the service and the
+// job queue; this package knows nothing about either.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Event types.
+const (
+	LeadCreated       = "lead.created"
+	SessionClosed     = "session.closed"
+	TemplatePublished = "template.published"
+)
+
+// versions is the current schema version of each event type's data. A
+// change that could break a receiver (a field removed, renamed or retyped)
+// bumps it; added fields do not.
+var versions = map[string]int{
+	LeadCreated:       1,
+	SessionClosed:     1,
+	TemplatePublished: 1,
+}
+
+// Types lists the event types endpoints can subscribe to.
+func Types() []string {
+	out := make([]string, 0, len(versions))
+	for t := range versions {
+		out = append(out, t)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Known reports whether t is an event type.
+func Known(t string) bool {
+	_, ok := versions[t]
+	return ok
+}
+
+// Event is the JSON body of every delivery. The same event sent to several
+// endpoints, or sent again, keeps its ID, so receivers can drop duplicates.
+type Event struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Version   int            `json:"version"`
+	TenantID  string         `json:"tenant_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      map[string]any `json:"data"`
+}
+
+// NewEvent stamps data as an event of type t with a fresh ID.
+func NewEvent(t, tenantID string, data map[string]any, now time.Time) Event {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	if data == nil {
+		data = map[string]any{}
+	}
+	return Event{
+		ID:        "evt_" + hex.EncodeToString(b[:]),
+		Type:      t,
+		Version:   versions[t],
+		TenantID:  tenantID,
+		CreatedAt: now.UTC(),
+		Data:      data,
+	}
+}
+
+func (e Event) Body() ([]byte, error) {
+	return json.Marshal(e)
+}
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxResponseBody is how much of a receiver's response is kept for the
+// delivery log.
+const maxResponseBody = 1024
+
+// ErrPrivateAddress is returned when an endpoint resolves to an address
+// tenants must not reach through us: loopback, private, link-local and the
+// like.
+var ErrPrivateAddress = errors.New("webhook: endpoint resolves to a private address")
+
+// Request is one attempt to deliver an event to an endpoint.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	EventID    string
+	EventType  string
+	Body       []byte
+}
+
+// Result is what came of a Request. Err is set when no response arrived.
+type Result struct {
+	StatusCode int
+	Body       string // the start of the response body
+	Duration   time.Duration
+	Err        error
+}
+
+// OK reports whether the receiver accepted the event (any 2xx).
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sender posts signed events. Redirects are not followed: a receiver that
+// moved has to be updated, not chased.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender sends with the given per-request timeout. Unless allowPrivate
+// is set, connections to non-public addresses are refused after DNS
+// resolution, so a tenant cannot point a webhook at our own network.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil // a proxy would hide the address being dialed
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+func (s *Sender) Send(ctx context.Context, r Request) Result {
+	start := s.now()
+	res := s.send(ctx, r)
+	res.Duration = s.now().Sub(start)
+	return res
+}
+
+func (s *Sender) send(ctx context.Context, r Request) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	h := req.Header
+	h.Set("Content-Type", "application/json")
+	h.Set("User-Agent", "gochatbot-webhooks/1")
+	h.Set(EventHeader, r.EventType)
+	h.Set(EventIDHeader, r.EventID)
+	h.Set(DeliveryHeader, r.DeliveryID)
+	h.Set(SignatureHeader, Sign(r.Secret, s.now(), r.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // lets the connection be reused
+	return Result{StatusCode: resp.StatusCode, Body: strings.ToValidUTF8(string(body), "")}
+}
+
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook: dial %s: %w", address, err)
+	}
+	ip := ap.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || isShared(ip) {
+		return fmt.Errorf("%w (%s)", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// cgnat is 100.64.0.0/10, which IsPrivate leaves out.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+func isShared(ip netip.Addr) bool {
+	return cgnat.Contains(ip)
+}
@@ -0,0 +1,98 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gochatbot/internal/webhook"
+)
+
+func TestSignVerify(t *testing.T) {
+	at := time.Unix(1_760_000_000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+	sig := webhook.Sign("whsec_a", at, body)
+	require.True(t, strings.HasPrefix(sig, "t=1760000000,v1="))
+
+	require.NoError(t, webhook.Verify("whsec_a", sig, body, 5*time.Minute, at.Add(time.Minute)))
+	require.NoError(t, webhook.Verify("whsec_a", "t=1760000000,v1=00,"+sig[len("t=1760000000,"):], body, time.Minute, at),
+		"any v1 may match")
+	require.ErrorIs(t, webhook.Verify("whsec_b", sig, body, time.Minute, at), webhook.ErrBadSignature)
+	require.ErrorIs(t, webhook.Verify("whsec_a", sig, []byte(`{"id":"evt_2"}`), time.Minute, at), webhook.ErrBadSignature)
+	require.ErrorIs(t, webhook.Verify("whsec_a", strings.Replace(sig, "t=1760000000", "t=1760000999", 1), body, time.Hour, at),
+		webhook.ErrBadSignature, "the timestamp is signed")
+	require.ErrorIs(t, webhook.Verify("whsec_a", sig, body, 5*time.Minute, at.Add(time.Hour)), webhook.ErrStale)
+	require.ErrorIs(t, webhook.Verify("whsec_a", "garbage", body, time.Minute, at), webhook.ErrBadSignature)
+}
+
+func TestNewEvent(t *testing.T) {
+	ev := webhook.NewEvent(webhook.LeadCreated, "t1", map[string]any{"lead_id": "l1"}, time.Unix(0, 0))
+	require.True(t, strings.HasPrefix(ev.ID, "evt_"))
+	require.Equal(t, 1, ev.Version)
+	require.NotEqual(t, ev.ID, webhook.NewEvent(webhook.LeadCreated, "t1", nil, time.Unix(0, 0)).ID)
+
+	body, err := ev.Body()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"`+ev.ID+`","type":"lead.created","version":1,"tenant_id":"t1",
+		"created_at":"1970-01-01T00:00:00Z","data":{"lead_id":"l1"}}`, string(body))
+	require.Equal(t, []string{"lead.created", "session.closed", "template.published"}, webhook.Types())
+}
+
+func TestSender_PostsSignedEvent(t *testing.T) {
+	var got http.Header
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, strings.Repeat("x", 5000))
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"id":"evt_1"}`)
+	res := webhook.NewSender(time.Second, true).Send(context.Background(), webhook.Request{
+		URL: srv.URL, Secret: "whsec_a", DeliveryID: "d1", EventID: "evt_1", EventType: webhook.LeadCreated, Body: body,
+	})
+	require.NoError(t, res.Err)
+	require.True(t, res.OK())
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+	require.Len(t, res.Body, 1024, "the response is truncated for the log")
+
+	require.Equal(t, body, gotBody)
+	require.Equal(t, "application/json", got.Get("Content-Type"))
+	require.Equal(t, "lead.created", got.Get(webhook.EventHeader))
+	require.Equal(t, "evt_1", got.Get(webhook.EventIDHeader))
+	require.Equal(t, "d1", got.Get(webhook.DeliveryHeader))
+	require.NoError(t, webhook.Verify("whsec_a", got.Get(webhook.SignatureHeader), gotBody, time.Minute, time.Now()))
+}
+
+func TestSender_Failures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	res := webhook.NewSender(time.Second, true).Send(ctx, webhook.Request{URL: srv.URL, Body: []byte(`{}`)})
+	require.False(t, res.OK())
+	require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+	res = webhook.NewSender(time.Second, true).Send(ctx, webhook.Request{URL: srv.URL + "/moved", Body: []byte(`{}`)})
+	require.Equal(t, http.StatusFound, res.StatusCode, "redirects are not followed")
+	require.False(t, res.OK())
+
+	// httptest listens on loopback, which a production sender refuses
+	res = webhook.NewSender(time.Second, false).Send(ctx, webhook.Request{URL: srv.URL, Body: []byte(`{}`)})
+	require.ErrorIs(t, res.Err, webhook.ErrPrivateAddress)
+	require.Zero(t, res.StatusCode)
+}
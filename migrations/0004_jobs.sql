@@ -0,0 +1,23 @@
+create table if not exists jobs (
+  id uuid primary key default gen_random_uuid(),
+  kind text not null,
+  payload jsonb not null default '{}'::jsonb,
+  status text not null default 'queued' check (status in ('queued','running','done','failed')),
+  attempts int not null default 0,
+  max_attempts int not null default 5,
+  run_at timestamptz not null default now(),
+  locked_at timestamptz,
+  last_error text,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+-- Claim scans queued jobs that are due, oldest first
+create index if not exists ix_jobs_claim
+  on jobs(run_at, created_at)
+  where status = 'queued';
+
+-- Stale-lease recovery scans running jobs by lock time
+create index if not exists ix_jobs_running
+  on jobs(locked_at)
+  where status = 'running';
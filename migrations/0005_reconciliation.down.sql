@@ -0,0 +1,2 @@
+drop table if exists reconciliation_diffs;
+drop table if exists reconciliation_runs;
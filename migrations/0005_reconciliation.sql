@@ -0,0 +1,29 @@
+-- Dual-write reconciliation: one row per run, one per difference found.
+create table if not exists reconciliation_runs (
+  id uuid primary key default gen_random_uuid(),
+  source text not null,
+  status text not null default 'running' check (status in ('running','done','failed')),
+  tenants_checked int not null default 0,
+  templates_checked int not null default 0,
+  diff_count int not null default 0,
+  error text,
+  started_at timestamptz not null default now(),
+  finished_at timestamptz
+);
+
+create index if not exists ix_reconciliation_runs_started
+  on reconciliation_runs(started_at desc);
+
+create table if not exists reconciliation_diffs (
+  id bigserial primary key,
+  run_id uuid not null references reconciliation_runs(id) on delete cascade,
+  entity text not null,
+  key text not null,
+  kind text not null check (kind in ('missing_in_go','missing_in_legacy','mismatch')),
+  field text not null default '',
+  go_value text not null default '',
+  legacy_value text not null default ''
+);
+
+create index if not exists ix_reconciliation_diffs_run
+  on reconciliation_diffs(run_id, id);
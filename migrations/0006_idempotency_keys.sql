@@ -0,0 +1,13 @@
+-- Idempotency-Key: the first response per key, replayed on retries
+create table if not exists idempotency_keys (
+  key text primary key,
+  request_hash text not null,
+  status int not null default 0, -- 0 while the first request is running
+  content_type text not null default '',
+  response_body bytea,
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null
+);
+
+create index if not exists ix_idempotency_keys_expires
+  on idempotency_keys(expires_at);
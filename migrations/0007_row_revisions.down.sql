@@ -0,0 +1,4 @@
+alter table template_versions drop column if exists revision;
+alter table templates drop column if exists revision;
+alter table tenants drop column if exists revision;
+drop sequence if exists row_revision_seq;
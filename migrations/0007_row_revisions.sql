@@ -0,0 +1,11 @@
+-- Row revisions back ETags and If-Match. One sequence serves every table,
+-- so a revision names one state of one row and ETags never collide across
+-- resources (e.g. two versions of a template).
+create sequence if not exists row_revision_seq;
+
+alter table tenants
+  add column if not exists revision bigint not null default nextval('row_revision_seq');
+alter table templates
+  add column if not exists revision bigint not null default nextval('row_revision_seq');
+alter table template_versions
+  add column if not exists revision bigint not null default nextval('row_revision_seq');
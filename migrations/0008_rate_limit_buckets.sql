@@ -0,0 +1,11 @@
+-- token buckets shared by every API replica (ratelimit.backend=postgres)
+create table if not exists rate_limit_buckets (
+  key text primary key,
+  tokens double precision not null,
+  allowed boolean not null, -- whether the last take got a token
+  updated_at timestamptz not null,
+  expires_at timestamptz not null -- full again from here on; safe to delete
+);
+
+create index if not exists ix_rate_limit_buckets_expires
+  on rate_limit_buckets(expires_at);
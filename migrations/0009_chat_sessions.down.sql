@@ -0,0 +1,4 @@
+drop table if exists messages;
+drop table if exists chat_sessions;
+drop function if exists notify_session_event();
+drop function if exists notify_session_closed();
@@ -0,0 +1,51 @@
+-- chat sessions and their messages; inserts and closes are announced on the
+-- session_events channel so every replica can push them to SSE clients
+create table if not exists chat_sessions (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null references tenants(id) on delete restrict,
+  created_at timestamptz not null default now(),
+  closed_at timestamptz
+);
+
+create index if not exists ix_chat_sessions_tenant
+  on chat_sessions(tenant_id, created_at desc);
+
+create table if not exists messages (
+  seq bigint generated always as identity primary key, -- SSE event id
+  id uuid not null unique default gen_random_uuid(),
+  session_id uuid not null references chat_sessions(id) on delete cascade,
+  role text not null check (role in ('user','assistant','system','tool')),
+  content text not null default '',
+  tool_name text not null default '',
+  tool_data jsonb,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists ix_messages_session_seq
+  on messages(session_id, seq);
+
+-- the payload is only the session id; listeners read what changed
+create or replace function notify_session_event() returns trigger as $$
+begin
+  perform pg_notify('session_events', new.session_id::text);
+  return null;
+end
+$$ language plpgsql;
+
+create or replace function notify_session_closed() returns trigger as $$
+begin
+  perform pg_notify('session_events', new.id::text);
+  return null;
+end
+$$ language plpgsql;
+
+drop trigger if exists tr_messages_notify on messages;
+create trigger tr_messages_notify
+  after insert on messages
+  for each row execute function notify_session_event();
+
+drop trigger if exists tr_chat_sessions_closed on chat_sessions;
+create trigger tr_chat_sessions_closed
+  after update of closed_at on chat_sessions
+  for each row when (old.closed_at is null and new.closed_at is not null)
+  execute function notify_session_closed();
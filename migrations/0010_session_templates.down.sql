@@ -0,0 +1 @@
+alter table chat_sessions drop column if exists template_id;
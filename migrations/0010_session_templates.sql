@@ -0,0 +1,4 @@
+-- A session answers with its template's published system prompt; sessions
+-- without a template get none.
+alter table chat_sessions
+  add column if not exists template_id uuid references templates(id) on delete set null;
@@ -0,0 +1 @@
+alter table tenants drop column if exists allowed_tools;
@@ -0,0 +1,3 @@
+-- Tools the assistant may call for a tenant; empty allows none.
+alter table tenants
+  add column if not exists allowed_tools text[] not null default '{}';
@@ -0,0 +1,23 @@
+-- One lead per chat session, filled from the contact details the assistant
+-- captured. status tracks the export to the tenant's CRM.
+create table if not exists leads (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null references tenants(id) on delete restrict,
+  session_id uuid not null unique references chat_sessions(id) on delete cascade,
+  name text not null default '',
+  email text not null default '',
+  phone text not null default '',
+  fields jsonb not null default '{}',
+  template_id uuid references templates(id) on delete set null,
+  template_version int,
+  status text not null default 'new' check (status in ('new','exported','failed')),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  revision bigint not null default nextval('row_revision_seq')
+);
+
+create index if not exists ix_leads_tenant_created
+  on leads(tenant_id, created_at desc, id desc);
+
+create index if not exists ix_leads_tenant_status
+  on leads(tenant_id, status, created_at desc, id desc);
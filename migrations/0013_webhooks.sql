@@ -0,0 +1,41 @@
+-- Endpoints tenants register to be sent events, one row per event per
+-- subscribed endpoint, and every attempt to deliver it.
+create table if not exists webhook_endpoints (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null references tenants(id) on delete cascade,
+  url text not null,
+  secret text not null,
+  events text[] not null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists ix_webhook_endpoints_tenant
+  on webhook_endpoints(tenant_id, created_at);
+
+-- payload is the exact body that is signed and sent on every attempt
+create table if not exists webhook_deliveries (
+  id uuid primary key default gen_random_uuid(),
+  endpoint_id uuid not null references webhook_endpoints(id) on delete cascade,
+  event_id text not null,
+  event_type text not null,
+  payload text not null,
+  status text not null default 'pending' check (status in ('pending','succeeded','failed')),
+  attempts int not null default 0,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists ix_webhook_deliveries_endpoint
+  on webhook_deliveries(endpoint_id, created_at desc, id desc);
+
+-- status_code is null when no response came back (see error)
+create table if not exists webhook_delivery_attempts (
+  delivery_id uuid not null references webhook_deliveries(id) on delete cascade,
+  attempt int not null,
+  status_code int,
+  error text not null default '',
+  response_body text not null default '',
+  duration_ms int not null,
+  created_at timestamptz not null default now(),
+  primary key (delivery_id, attempt)
+);
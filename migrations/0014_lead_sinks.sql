@@ -0,0 +1,7 @@
+-- The CRM each tenant's leads are pushed to (sink.Config as JSON); at most
+-- one per tenant. config may hold API tokens.
+create table if not exists lead_sinks (
+  tenant_id uuid primary key references tenants(id) on delete cascade,
+  config jsonb not null,
+  updated_at timestamptz not null default now()
+);
@@ -0,0 +1,16 @@
+-- Files sent in a chat session, e.g. answers to File_Upload questions. The
+-- bytes live in the blob store under storage_key; content_type is sniffed
+-- from them, not taken from the client.
+create table if not exists uploads (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null references tenants(id) on delete cascade,
+  session_id uuid not null references chat_sessions(id) on delete cascade,
+  file_name text not null,
+  content_type text not null,
+  size_bytes bigint not null,
+  storage_key text not null unique,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists ix_uploads_session
+  on uploads(session_id, created_at);
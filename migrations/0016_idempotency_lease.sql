@@ -0,0 +1,4 @@
+-- An in-flight key is only held for a short lease; a retry of the same
+-- request takes it over once the lease runs out, e.g. after a crash.
+alter table idempotency_keys
+  add column if not exists locked_until timestamptz not null default now();
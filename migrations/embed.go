@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema so binaries and tests apply the
+// exact files checked in here. See internal/migrate for naming rules.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS